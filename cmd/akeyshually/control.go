@@ -0,0 +1,223 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	evdev "github.com/holoplot/go-evdev"
+
+	"github.com/deprecatedluar/akeyshually/internal/config"
+	"github.com/deprecatedluar/akeyshually/internal/events"
+	"github.com/deprecatedluar/akeyshually/internal/executor"
+	"github.com/deprecatedluar/akeyshually/internal/ipc"
+	"github.com/deprecatedluar/akeyshually/internal/ladder"
+	"github.com/deprecatedluar/akeyshually/internal/listener"
+	"github.com/deprecatedluar/akeyshually/internal/matcher"
+)
+
+// reloadRequested is set when a reload request ends run, so main execs the
+// daemon again instead of exiting.
+var reloadRequested atomic.Bool
+
+// execSelf replaces the process with a fresh daemon started the same way.
+// The PID the service manager tracks stays the same, and the instance lock
+// is released along with the old image.
+func execSelf() error {
+	exe, err := os.Executable()
+	if err != nil {
+		return err
+	}
+	return syscall.Exec(exe, os.Args, os.Environ())
+}
+
+// control answers the IPC requests that reach past the remap engine
+// (status, listings, trigger, reload, overlays). It implements ipc.Daemon.
+type control struct {
+	*matcher.Matcher // .switch cycle state
+	cfg              *config.Config
+	configPath       string // -c path, empty for config.toml with overlays
+	overlays         []string
+	devices          *deviceList
+	loopState        *executor.LoopState
+	outputs          executor.Outputs
+	started          time.Time
+	restart          func() // ends run so main execs the daemon again
+}
+
+// Trigger fires a shortcut as if its key had been held for hold.
+func (c *control) Trigger(combo, behavior string, hold time.Duration) error {
+	return ladder.Trigger(combo, behavior, hold, c.Matcher, c.cfg, c.loopState, c.outputs)
+}
+
+func (c *control) Status() ipc.Status {
+	shortcuts := 0
+	for _, list := range c.cfg.ParsedShortcuts {
+		shortcuts += len(list)
+	}
+	return ipc.Status{
+		PID:       os.Getpid(),
+		Started:   c.started,
+		Config:    c.configPath,
+		Overlays:  c.overlays,
+		Devices:   len(c.devices.list()),
+		Shortcuts: shortcuts,
+	}
+}
+
+func (c *control) Shortcuts() []ipc.ShortcutInfo {
+	var infos []ipc.ShortcutInfo
+	for combo, list := range c.cfg.ParsedShortcuts {
+		for _, s := range list {
+			commands := make([]string, len(s.Commands))
+			for i, cmd := range s.Commands {
+				commands[i] = config.DisplayCommand(cmd)
+			}
+			infos = append(infos, ipc.ShortcutInfo{Combo: combo, Behavior: s.Behavior.String(), Commands: commands})
+		}
+	}
+	sort.Slice(infos, func(i, j int) bool {
+		if infos[i].Combo != infos[j].Combo {
+			return infos[i].Combo < infos[j].Combo
+		}
+		return infos[i].Behavior < infos[j].Behavior
+	})
+	return infos
+}
+
+func (c *control) Devices() []ipc.DeviceInfo {
+	return c.devices.list()
+}
+
+// Reload checks that the config files load and returns the daemon restart.
+func (c *control) Reload() (func(), error) {
+	if _, _, err := loadConfig(c.configPath); err != nil {
+		return nil, err
+	}
+	return c.restart, nil
+}
+
+func (c *control) EnableOverlay(name string) (func(), error) {
+	name, err := c.overlayFile(name)
+	if err != nil {
+		return nil, err
+	}
+	configDir, err := config.GetConfigDir()
+	if err != nil {
+		return nil, err
+	}
+	if _, err := os.Stat(filepath.Join(configDir, name)); err != nil {
+		return nil, fmt.Errorf("overlay not found: %s", name)
+	}
+	return c.changeOverlays(events.OverlayEnabled, name, func() error { return config.AddOverlay(name) })
+}
+
+func (c *control) DisableOverlay(name string) (func(), error) {
+	name, err := c.overlayFile(name)
+	if err != nil {
+		return nil, err
+	}
+	enabled, err := config.ReadEnabledState()
+	if err != nil {
+		return nil, err
+	}
+	if !slices.Contains(enabled, name) {
+		return nil, fmt.Errorf("overlay not enabled: %s", name)
+	}
+	return c.changeOverlays(events.OverlayDisabled, name, func() error { return config.RemoveOverlay(name) })
+}
+
+// overlayFile returns name with its .toml suffix.
+func (c *control) overlayFile(name string) (string, error) {
+	if c.configPath != "" {
+		return "", errors.New("overlays are not used with a custom config (-c)")
+	}
+	if name == "" {
+		return "", errors.New("no overlay given")
+	}
+	if !strings.HasSuffix(name, ".toml") {
+		name += ".toml"
+	}
+	return name, nil
+}
+
+// changeOverlays applies change to the enabled state and reloads, putting
+// the previous state back if the resulting config does not load. On success
+// it publishes event for the overlay before the daemon restarts.
+func (c *control) changeOverlays(event, name string, change func() error) (func(), error) {
+	previous, err := config.ReadEnabledState()
+	if err != nil {
+		return nil, err
+	}
+	if err := change(); err != nil {
+		return nil, err
+	}
+	restart, err := c.Reload()
+	if err != nil {
+		if err := config.WriteEnabledState(previous); err != nil {
+			fmt.Fprintf(os.Stderr, "Failed to restore enabled overlays: %v\n", err)
+		}
+		return nil, err
+	}
+	events.Publish(events.Event{Type: event, Overlay: name})
+	return restart, nil
+}
+
+// deviceList tracks the devices run listens to as they connect and
+// disconnect, and the grabbed ones to release on shutdown.
+type deviceList struct {
+	mu    sync.Mutex
+	infos []ipc.DeviceInfo
+	pairs map[string]listener.KeyboardPair // grabbed devices by physical path
+}
+
+func newDeviceList() *deviceList {
+	return &deviceList{pairs: make(map[string]listener.KeyboardPair)}
+}
+
+// addPair records a grabbed device of kind (listener.UseKeyboard or UseDeclared).
+func (d *deviceList) addPair(pair listener.KeyboardPair, kind string) {
+	d.add(pair.Physical, kind)
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.pairs[pair.Physical.Path()] = pair
+}
+
+func (d *deviceList) add(dev *evdev.InputDevice, kind string) {
+	name, _ := dev.Name()
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.infos = append(d.infos, ipc.DeviceInfo{Name: name, Path: dev.Path(), Kind: kind})
+}
+
+// remove forgets the device at path; its listener has cleaned it up.
+func (d *deviceList) remove(path string) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.infos = slices.DeleteFunc(d.infos, func(info ipc.DeviceInfo) bool { return info.Path == path })
+	delete(d.pairs, path)
+}
+
+func (d *deviceList) list() []ipc.DeviceInfo {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return slices.Clone(d.infos)
+}
+
+// cleanup ungrabs every grabbed device and destroys its virtual twin.
+func (d *deviceList) cleanup() {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	for path, pair := range d.pairs {
+		listener.Cleanup(pair)
+		delete(d.pairs, path)
+	}
+}
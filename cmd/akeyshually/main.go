@@ -7,7 +7,9 @@ import (
 	"os"
 	"strings"
 	"sync"
+	"time"
 
+	godbus "github.com/godbus/dbus/v5"
 	evdev "github.com/holoplot/go-evdev"
 
 	daemon "github.com/deprecatedluar/luar-daemonator"
@@ -15,11 +17,14 @@ import (
 	"github.com/deprecatedluar/akeyshually/internal/commands"
 	"github.com/deprecatedluar/akeyshually/internal/common"
 	"github.com/deprecatedluar/akeyshually/internal/config"
+	"github.com/deprecatedluar/akeyshually/internal/dbus"
+	"github.com/deprecatedluar/akeyshually/internal/events"
 	"github.com/deprecatedluar/akeyshually/internal/executor"
 	"github.com/deprecatedluar/akeyshually/internal/handlers"
 	"github.com/deprecatedluar/akeyshually/internal/ipc"
 	"github.com/deprecatedluar/akeyshually/internal/listener"
 	"github.com/deprecatedluar/akeyshually/internal/matcher"
+	"github.com/deprecatedluar/akeyshually/internal/stats"
 	"github.com/deprecatedluar/akeyshually/internal/timers"
 )
 
@@ -27,6 +32,7 @@ const (
 	githubRepo      = "DeprecatedLuar/akeyshually"
 	keyReleaseValue = 0
 	keyPressValue   = 1
+	keyRepeatValue  = 2
 )
 
 var version = "dev"
@@ -66,6 +72,12 @@ func main() {
 			fmt.Fprintf(os.Stderr, "%v\n", err)
 			os.Exit(1)
 		}
+		if reloadRequested.Load() {
+			if err := execSelf(); err != nil {
+				fmt.Fprintf(os.Stderr, "Reload failed: %v\n", err)
+				os.Exit(1)
+			}
+		}
 		return
 	}
 
@@ -79,6 +91,9 @@ func main() {
 	case "restart":
 		commands.Restart()
 		os.Exit(0)
+	case "reload":
+		commands.Reload()
+		os.Exit(0)
 	case "update":
 		if err := commands.HandleUpdate(version, githubRepo); err != nil {
 			fmt.Fprintf(os.Stderr, "Update failed: %v\n", err)
@@ -154,6 +169,27 @@ func main() {
 			os.Exit(1)
 		}
 		os.Exit(0)
+	case "last":
+		commands.Last(remaining[1:])
+		os.Exit(0)
+	case "switch":
+		commands.Switch(remaining[1:])
+		os.Exit(0)
+	case "trigger":
+		commands.Trigger(remaining[1:])
+		os.Exit(0)
+	case "stats":
+		commands.Stats(remaining[1:])
+		os.Exit(0)
+	case "watch":
+		commands.Watch(remaining[1:])
+		os.Exit(0)
+	case "inspect":
+		commands.Inspect(remaining[1:])
+		os.Exit(0)
+	case "devices":
+		commands.Devices(remaining[1:])
+		os.Exit(0)
 	case "help", "-h", "--help":
 		commands.Help(remaining[1:]...)
 		os.Exit(0)
@@ -181,6 +217,7 @@ func handleConfigError(err error) {
 }
 
 func newDeviceEventHandler(
+	device string,
 	m *matcher.Matcher,
 	cfg *config.Config,
 	loopState *executor.LoopState,
@@ -193,22 +230,39 @@ func newDeviceEventHandler(
 	prevValues handlers.PrevValuesMap,
 	execCtx executor.ExecContext,
 	translator *handlers.Translator,
+	gestures *handlers.GestureState,
 ) listener.EventHandler {
-	return func(event evdev.InputEvent) bool {
+	handle := func(event evdev.InputEvent) bool {
 		handlers.ResetAbsStateOnContactEnd(event, accumulators, prevValues)
 
 		switch event.Type {
 		case evdev.EV_SYN:
 			if event.Code == evdev.SYN_REPORT {
 				handlers.FlushAbs(accumulators, absInfoMap, cfg, execCtx)
+				if gestures != nil {
+					gestures.Sync()
+				}
 			}
 			return false
 
 		case evdev.EV_ABS:
-			return handlers.HandleAbs(uint16(event.Code), event.Value, absInfoMap, accumulators, prevValues, cfg, execCtx)
+			code := uint16(event.Code)
+			if gestures != nil {
+				// Multitouch slots feed the gesture recognizer instead of
+				// being accumulated as plain axes.
+				suppress := gestures.HandleAbs(code, event.Value)
+				if handlers.IsMultitouchAxis(code) {
+					return suppress
+				}
+				return handlers.HandleAbs(code, event.Value, absInfoMap, accumulators, prevValues, cfg, execCtx) || suppress
+			}
+			return handlers.HandleAbs(code, event.Value, absInfoMap, accumulators, prevValues, cfg, execCtx)
 
 		case evdev.EV_KEY:
 			code := uint16(event.Code)
+			if gestures != nil {
+				gestures.HandleKey(code, event.Value)
+			}
 			if cfg.Settings.DisableMediaKeys && listener.IsMediaKey(code) {
 				return false
 			}
@@ -217,14 +271,54 @@ func newDeviceEventHandler(
 				return handlers.HandlePress(code, event.Value, m, cfg, loopState, outputs, virtual, stateMap, emittedTracker, translator)
 			case keyReleaseValue:
 				return handlers.HandleRelease(code, event.Value, m, cfg, loopState, outputs, virtual, stateMap, emittedTracker, translator)
+			case keyRepeatValue:
+				return handlers.HandleRepeat(code, event.Value, m, cfg, loopState, outputs, virtual, stateMap, emittedTracker, translator)
 			}
 		}
 
 		return false
 	}
+
+	// Dual-role keys replay buffered events through the same handler, from
+	// the hold timer as well, so all handling is serialized on the translator.
+	translator.SetDispatch(handle)
+	return func(event evdev.InputEvent) bool {
+		translator.Lock()
+		defer translator.Unlock()
+		// Described before handling: a modifier press changes the combo
+		if events.Inspecting() {
+			if e, ok := handlers.Describe(device, event, m, cfg); ok {
+				events.Publish(e)
+			}
+		}
+		return handle(event)
+	}
+}
+
+// loadConfig loads the custom config at configPath, or config.toml with the
+// enabled overlays (also returned) when configPath is empty.
+func loadConfig(configPath string) (*config.Config, []string, error) {
+	if configPath != "" {
+		// Custom config - no overlays
+		cfg, err := config.LoadFromPath(configPath)
+		return cfg, nil, err
+	}
+
+	enabledOverlays, err := config.ReadEnabledState()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: failed to read enabled state: %v\n", err)
+		enabledOverlays = []string{}
+	}
+	cfg, err := config.LoadWithOverlays(enabledOverlays)
+	return cfg, enabledOverlays, err
 }
 
 func run(ctx context.Context, configPath, sockPath string) error {
+	// A reload request ends run through this context; main then execs the
+	// daemon again.
+	ctx, restart := context.WithCancel(ctx)
+	defer restart()
+	started := time.Now()
 
 	// Only ensure default config exists if not using custom config
 	if configPath == "" {
@@ -234,32 +328,41 @@ func run(ctx context.Context, configPath, sockPath string) error {
 		}
 	}
 
-	// Load config
-	var cfg *config.Config
-	var err error
-	if configPath != "" {
-		// Custom config - no overlays
-		cfg, err = config.LoadFromPath(configPath)
-		if err != nil {
-			handleConfigError(err)
-		}
-	} else {
-		// Default config with overlays
-		enabledOverlays, err := config.ReadEnabledState()
-		if err != nil {
-			fmt.Fprintf(os.Stderr, "Warning: failed to read enabled state: %v\n", err)
-			enabledOverlays = []string{}
-		}
-		cfg, err = config.LoadWithOverlays(enabledOverlays)
-		if err != nil {
-			handleConfigError(err)
-		}
-		if len(enabledOverlays) > 0 {
-			fmt.Printf("Enabled overlays: %v\n", enabledOverlays)
+	cfg, enabledOverlays, err := loadConfig(configPath)
+	if err != nil {
+		handleConfigError(err)
+	}
+	if len(enabledOverlays) > 0 {
+		fmt.Printf("Enabled overlays: %v\n", enabledOverlays)
+	}
+
+	if err := executor.LoadEnvironment(cfg); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: %v (commands start without it)\n", err)
+	}
+
+	// Usage counters carry over reloads and restarts through the state dir
+	if stateDir, err := config.GetStateDir(); err == nil {
+		statsPath := stats.StatePath(stateDir)
+		if err := stats.Load(statsPath); err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: failed to load usage statistics: %v\n", err)
 		}
+		defer func() {
+			if err := stats.Save(statsPath); err != nil {
+				fmt.Fprintf(os.Stderr, "Warning: failed to save usage statistics: %v\n", err)
+			}
+		}()
+	}
+
+	// Devices plugged in later are picked up from /dev/input events, started
+	// before the scan so none slip through; without them lost devices are
+	// polled for instead
+	excludedDevices := cfg.Settings.ExcludeDevices
+	hotplug, err := listener.NewHotplug(cfg.Settings.Devices, excludedDevices)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: hotplug detection unavailable (%v)\n", err)
 	}
 
-	result, err := listener.FindKeyboards()
+	result, err := listener.FindKeyboards(excludedDevices)
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "Keyboard detection error: %v\n", err)
 		common.NotifyError("akeyshually startup failed", fmt.Sprintf("Keyboard detection error: %v", err))
@@ -274,7 +377,7 @@ func run(ctx context.Context, configPath, sockPath string) error {
 
 	var declaredResult listener.DeviceResult
 	if len(cfg.Settings.Devices) > 0 {
-		declaredResult, err = listener.FindDeclaredDevices(cfg.Settings.Devices)
+		declaredResult, err = listener.FindDeclaredDevices(cfg.Settings.Devices, excludedDevices)
 		if err != nil {
 			fmt.Fprintf(os.Stderr, "Warning: declared device error: %v\n", err)
 		}
@@ -292,6 +395,10 @@ func run(ctx context.Context, configPath, sockPath string) error {
 	fmt.Printf("Devices:\n")
 	for _, pair := range allPairs {
 		name, _ := pair.Physical.Name()
+		if window := cfg.ChatterFilterFor(name); window > 0 {
+			fmt.Printf("  %s+ %s%s %s(chatter filter %vms)%s\n", green, name, reset, dim, window, reset)
+			continue
+		}
 		fmt.Printf("  %s+ %s%s\n", green, name, reset)
 	}
 	for _, fail := range allFailures {
@@ -299,14 +406,20 @@ func run(ctx context.Context, configPath, sockPath string) error {
 	}
 
 	m := matcher.New(cfg.ParsedShortcuts)
+	m.SetCustomModifiers(cfg.Modifiers)
+	if err := m.LoadSwitchState(); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: failed to load switch state: %v\n", err)
+	}
 
-	// Create shared tap state and detect mice (if tap shortcuts exist)
+	// Create shared tap state and detect mice (if tap shortcuts exist).
+	// With hotplug detection a mouse may still be plugged in later.
 	var tapState *matcher.TapState
 	mice, err := listener.FindMice()
-	if err == nil && len(mice) > 0 {
+	if (err == nil && len(mice) > 0) || hotplug != nil {
 		tapState = matcher.NewTapState()
 		m.SetTapState(tapState)
-
+	}
+	if tapState != nil && len(mice) > 0 {
 		fmt.Printf("Monitoring %d mouse device(s) for tap cancellation\n", len(mice))
 	}
 
@@ -331,22 +444,77 @@ func run(ctx context.Context, configPath, sockPath string) error {
 	// Create shared loop state
 	loopState := executor.NewLoopState()
 
+	// Processes started by .toggleprocess keep running over reloads and
+	// restarts; the next daemon adopts them so their shortcut still stops them
+	if stateDir, err := config.GetStateDir(); err == nil {
+		toggledPath := executor.ToggledStatePath(stateDir)
+		if err := loopState.RestoreToggled(toggledPath); err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: failed to restore toggled processes: %v\n", err)
+		}
+		defer func() {
+			if err := loopState.SaveToggled(toggledPath); err != nil {
+				fmt.Fprintf(os.Stderr, "Warning: failed to save toggled processes: %v\n", err)
+			}
+		}()
+	}
+
+	devices := newDeviceList()
+	ctl := &control{
+		Matcher:    m,
+		cfg:        cfg,
+		configPath: configPath,
+		overlays:   enabledOverlays,
+		devices:    devices,
+		loopState:  loopState,
+		outputs:    outputs,
+		started:    started,
+		restart: func() {
+			reloadRequested.Store(true)
+			restart()
+		},
+	}
+
 	go func() {
-		if err := ipc.Serve(ctx, sockPath, outputs, loopState); err != nil {
+		if err := ipc.Serve(ctx, sockPath, outputs, loopState, ctl); err != nil {
 			fmt.Fprintf(os.Stderr, "IPC server error: %v\n", err)
 		}
 	}()
+	go serveDBus(ctx, outputs, loopState, ctl)
+	if addr := cfg.Settings.MetricsListen; addr != "" {
+		go func() {
+			if err := stats.ServeMetrics(ctx, addr); err != nil {
+				fmt.Fprintf(os.Stderr, "Metrics server error: %v\n", err)
+			}
+		}()
+	}
 
 	var wg sync.WaitGroup
 
 	// Registry for thread-safe StateMap collection and mouse click cancellation
 	registry := timers.NewStateMapRegistry()
 
-	// Launch keyboard listeners with unified handler and reconnect support
-	for _, pair := range result.Pairs {
+	findKeyboards := func() (listener.DeviceResult, error) {
+		return listener.FindKeyboards(excludedDevices)
+	}
+	declaredDevices := cfg.Settings.Devices
+	findDeclared := func() (listener.DeviceResult, error) {
+		return listener.FindDeclaredDevices(declaredDevices, excludedDevices)
+	}
+
+	// listen starts the listener of a grabbed device with unified handler and
+	// reconnect support. A lost device comes back through hotplug events, or
+	// findFn polling without them, however long it takes.
+	listen := func(p listener.KeyboardPair, kind string, findFn func() (listener.DeviceResult, error)) {
+		devName, _ := p.Physical.Name()
+		devices.addPair(p, kind)
+		await := listener.PollDevice(findFn)
+		if hotplug != nil {
+			hotplug.Track(p.Physical.Path(), kind)
+			await = hotplug.Await
+		}
+
 		wg.Add(1)
-		name, _ := pair.Physical.Name()
-		go func(p listener.KeyboardPair, devName string) {
+		go func() {
 			defer wg.Done()
 			stateMap := timers.NewStateMap()
 			registry.Register(stateMap)
@@ -366,67 +534,99 @@ func run(ctx context.Context, configPath, sockPath string) error {
 				Config:    cfg,
 			}
 
-			handler := newDeviceEventHandler(m, cfg, loopState, outputs, p.Virtual, stateMap, emittedTracker,
-				absInfoMap, accumulators, prevValues, execCtx, translator)
-			if err := listener.ListenWithReconnect(p, handler, listener.FindKeyboards, devName); err != nil {
+			var gestures *handlers.GestureState
+			if cfg.HasGestures() {
+				gestures = handlers.NewGestureState(absInfoMap, cfg.Settings, func(name string) {
+					handlers.FireGesture(name, m, cfg, execCtx)
+				})
+			}
+
+			handler := newDeviceEventHandler(devName, m, cfg, loopState, outputs, p.Virtual, stateMap, emittedTracker,
+				absInfoMap, accumulators, prevValues, execCtx, translator, gestures)
+			filter := listener.NewChatterFilter(cfg.ChatterFilterFor(devName))
+			if err := listener.ListenWithReconnect(p, handler, filter, func(name, lostPath string) (listener.KeyboardPair, bool) {
+				devices.remove(lostPath)
+				pair, ok := await(name, lostPath)
+				if ok {
+					devices.addPair(pair, kind)
+				}
+				return pair, ok
+			}, devName); err != nil {
 				fmt.Fprintf(os.Stderr, "Listener error: %v\n", err)
 			}
-		}(pair, name)
+		}()
 	}
 
-	// Launch declared device listeners
-	declaredDeviceNames := cfg.Settings.Devices
-	for _, pair := range declaredResult.Pairs {
+	// listenMouse monitors a mouse for tap cancellation until it goes away;
+	// plugged in again, it is a new device to hotplug detection.
+	listenMouse := func(dev *evdev.InputDevice) {
+		path := dev.Path()
+		devices.add(dev, listener.UseMouse)
+		if hotplug != nil {
+			hotplug.Track(path, listener.UseMouse)
+		}
+
 		wg.Add(1)
-		name, _ := pair.Physical.Name()
-		go func(p listener.KeyboardPair, devName string) {
+		go func() {
 			defer wg.Done()
-			stateMap := timers.NewStateMap()
-			registry.Register(stateMap)
-			emittedTracker := timers.NewEmittedModifierTracker()
-			translator := handlers.NewTranslator(p.Virtual)
-
-			// ABS handler state (per device)
-			absInfoMap := handlers.BuildAbsInfoMap(p.Physical)
-			accumulators := make(handlers.AccumulatorMap)
-			prevValues := make(handlers.PrevValuesMap)
-
-			execCtx := executor.ExecContext{
-				Modifiers: m.GetCurrentModifiers(),
-				LoopState: loopState,
-				Outputs:   outputs,
-				Virtual:   p.Virtual,
-				Config:    cfg,
+			if err := listener.ListenMouse(dev, func() {
+				tapState.Clear()
+				registry.CancelAllModifierLadders()
+			}); err != nil {
+				fmt.Fprintf(os.Stderr, "Mouse listener error: %v\n", err)
 			}
-
-			handler := newDeviceEventHandler(m, cfg, loopState, outputs, p.Virtual, stateMap, emittedTracker,
-				absInfoMap, accumulators, prevValues, execCtx, translator)
-			if err := listener.ListenWithReconnect(p, handler, func() (listener.DeviceResult, error) {
-				return listener.FindDeclaredDevices(declaredDeviceNames)
-			}, devName); err != nil {
-				fmt.Fprintf(os.Stderr, "Listener error: %v\n", err)
+			dev.Close()
+			devices.remove(path)
+			if hotplug != nil {
+				hotplug.Release(path)
 			}
-		}(pair, name)
+		}()
+	}
+
+	// Launch keyboard listeners
+	for _, pair := range result.Pairs {
+		listen(pair, listener.UseKeyboard, findKeyboards)
+	}
+
+	// Launch declared device listeners
+	for _, pair := range declaredResult.Pairs {
+		listen(pair, listener.UseDeclared, findDeclared)
 	}
 
 	// Launch mouse listeners (if tapState is active)
 	if tapState != nil {
 		for _, mouse := range mice {
-			wg.Add(1)
-			go func(dev evdev.InputDevice) {
-				defer wg.Done()
-				if err := listener.ListenMouse(&dev, func() {
-					tapState.Clear()
-					registry.CancelAllModifierLadders()
-				}); err != nil {
-					fmt.Fprintf(os.Stderr, "Mouse listener error: %v\n", err)
-				}
-			}(*mouse)
+			listenMouse(mouse)
 		}
 	}
 
-	// Listener goroutines block on device reads and only return on their
-	// own (e.g. every device disconnected); ctx.Done() is what actually
+	// Launch listeners for devices plugged in from now on
+	if hotplug != nil {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			err := hotplug.Run(ctx, func(added listener.Added) {
+				switch added.Kind {
+				case listener.UseMouse:
+					listenMouse(added.Mouse)
+				case listener.UseDeclared:
+					listen(added.Pair, listener.UseDeclared, findDeclared)
+				default:
+					listen(added.Pair, listener.UseKeyboard, findKeyboards)
+				}
+				fmt.Printf("  %s+ %s%s %s(connected)%s\n", green, added.Name, reset, dim, reset)
+			}, func(fail listener.FailedGrab) {
+				fmt.Printf("  %s- %s%s %s(%s)%s\n", purple, fail.Name, reset, dim, fail.Reason, reset)
+			})
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Hotplug detection error: %v\n", err)
+			}
+		}()
+	}
+
+	// Listener goroutines block on device reads or wait for their device to
+	// come back, and only return on their own on errors (e.g. every listener
+	// failing without hotplug detection); ctx.Done() is what actually
 	// signals a normal shutdown (SIGTERM/SIGINT). Either way, returning
 	// here lets the deferred injector cleanup above run - unlike os.Exit,
 	// which would skip it.
@@ -438,16 +638,33 @@ func run(ctx context.Context, configPath, sockPath string) error {
 
 	select {
 	case <-ctx.Done():
-		fmt.Fprintf(os.Stderr, "\nShutting down...\n")
-		for _, pair := range allPairs {
-			listener.Cleanup(pair)
+		if reloadRequested.Load() {
+			fmt.Fprintf(os.Stderr, "\nReloading...\n")
+		} else {
+			fmt.Fprintf(os.Stderr, "\nShutting down...\n")
 		}
+		devices.cleanup()
 	case <-listenersDone:
 	}
 
 	return nil
 }
 
+// serveDBus publishes the daemon on the session bus, if there is one, until
+// ctx is cancelled.
+func serveDBus(ctx context.Context, outputs executor.Outputs, loopState *executor.LoopState, ctl *control) {
+	conn, err := godbus.ConnectSessionBus()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: no D-Bus session bus (%v)\n", err)
+		return
+	}
+	defer conn.Close()
+
+	if err := dbus.Serve(ctx, conn, outputs, loopState, ctl); err != nil {
+		fmt.Fprintf(os.Stderr, "D-Bus service error: %v\n", err)
+	}
+}
+
 func destroyInjector(kind string, device *evdev.InputDevice) {
 	if err := evdev.DestroyDevice(device); err != nil {
 		fmt.Fprintf(os.Stderr, "Failed to destroy %s injector: %v\n", kind, err)
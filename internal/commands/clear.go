@@ -7,7 +7,7 @@ import (
 	"github.com/deprecatedluar/akeyshually/internal/config"
 )
 
-// Clear disables all overlays and restarts the daemon
+// Clear disables all overlays and reloads the daemon
 func Clear() {
 	if err := config.ClearAllOverlays(); err != nil {
 		fmt.Fprintf(os.Stderr, "Failed to clear overlays: %v\n", err)
@@ -15,5 +15,7 @@ func Clear() {
 	}
 
 	fmt.Println("All overlays disabled")
-	restartIfRunning()
+	if daemonRunning() {
+		Reload()
+	}
 }
@@ -0,0 +1,124 @@
+package commands
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/deprecatedluar/akeyshually/internal/config"
+	"github.com/deprecatedluar/akeyshually/internal/listener"
+)
+
+const devicesUsage = "Usage: akeyshually devices [--json]"
+
+// Devices lists every input device with its identity, capabilities, the
+// verdict of each detection classifier and whether the running daemon holds
+// it. A device detection would grab but the daemon does not hold is
+// test-grabbed to name the process in the way.
+func Devices(args []string) {
+	asJSON := false
+	switch {
+	case len(args) == 0:
+	case len(args) == 1 && args[0] == "--json":
+		asJSON = true
+	default:
+		fmt.Fprintln(os.Stderr, devicesUsage)
+		os.Exit(1)
+	}
+
+	var declared, exclude []config.DeviceSelector
+	enabledOverlays, _ := config.ReadEnabledState()
+	if cfg, err := config.LoadWithOverlays(enabledOverlays); err == nil {
+		declared, exclude = cfg.Settings.Devices, cfg.Settings.ExcludeDevices
+	} else {
+		fmt.Fprintf(os.Stderr, "Warning: config not loaded, declared and excluded devices are not matched: %v\n", err)
+	}
+
+	var daemon map[string]string
+	if daemonRunning() {
+		infos, err := daemonClient().Devices()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: daemon devices unavailable: %v\n", err)
+		}
+		daemon = make(map[string]string, len(infos))
+		for _, info := range infos {
+			daemon[info.Path] = info.Kind
+		}
+	}
+
+	reports, err := listener.ProbeDevices(declared, exclude, daemon)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "akeyshually: %v\n", err)
+		os.Exit(1)
+	}
+
+	if asJSON {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		enc.Encode(reports)
+		return
+	}
+	for i, r := range reports {
+		if i > 0 {
+			fmt.Println()
+		}
+		printDevice(r, daemon != nil)
+	}
+}
+
+func printDevice(r listener.DeviceReport, withDaemon bool) {
+	fmt.Printf("%s%s%s  %s\n", ansiBold, r.Name, ansiReset, r.Path)
+	if r.Error != "" {
+		fmt.Printf("  %scannot open: %s%s\n", ansiDim, r.Error, ansiReset)
+		return
+	}
+
+	id := fmt.Sprintf("%04x:%04x", r.Vendor, r.Product)
+	if r.Phys != "" {
+		id += "  phys " + r.Phys
+	}
+	if r.Uniq != "" {
+		id += "  uniq " + r.Uniq
+	}
+	fmt.Printf("  %sid %s%s\n", ansiDim, id, ansiReset)
+	for _, link := range r.Links {
+		fmt.Printf("  %s%s%s\n", ansiDim, link, ansiReset)
+	}
+	fmt.Printf("  %s%s%s\n", ansiDim, strings.Join(r.Capabilities, " "), ansiReset)
+
+	var checks []string
+	for _, c := range r.Checks {
+		if c.Accepted {
+			checks = append(checks, c.Classifier+": yes")
+		} else {
+			checks = append(checks, fmt.Sprintf("%s: no (%s)", c.Classifier, c.Reason))
+		}
+	}
+	if len(checks) > 0 {
+		fmt.Printf("  %s\n", strings.Join(checks, "  "))
+	}
+
+	switch {
+	case r.Use == listener.UseOwn:
+		fmt.Println("  -> akeyshually virtual device")
+	case r.Daemon == "mouse":
+		fmt.Println("  -> mouse, read by the running daemon")
+	case r.Daemon != "":
+		fmt.Printf("  -> %s, grabbed by the running daemon\n", r.Daemon)
+	case r.Use == listener.UseExcluded:
+		fmt.Println("  -> excluded by exclude_devices")
+	case r.Use == "" && r.Note != "":
+		fmt.Printf("  -> ignored: %s\n", r.Note)
+	case r.Use == "":
+		fmt.Println("  -> ignored")
+	case r.Use == listener.UseMouse:
+		fmt.Println("  -> mouse, read for tap cancellation when tap shortcuts exist")
+	case r.GrabError != "":
+		fmt.Printf("  -> %s, cannot be grabbed: %s\n", r.Use, r.GrabError)
+	case withDaemon:
+		fmt.Printf("  -> %s, grabbable but not held by the running daemon\n", r.Use)
+	default:
+		fmt.Printf("  -> %s, grabbable\n", r.Use)
+	}
+}
@@ -8,7 +8,7 @@ import (
 	"github.com/deprecatedluar/akeyshually/internal/config"
 )
 
-// Disable removes an overlay from the enabled list and restarts the daemon
+// Disable removes an overlay from the enabled list and reloads the daemon
 func Disable(filename string) {
 	if !strings.HasSuffix(filename, ".toml") {
 		filename += ".toml"
@@ -34,11 +34,15 @@ func Disable(filename string) {
 		os.Exit(1)
 	}
 
-	if err := config.RemoveOverlay(filename); err != nil {
+	if daemonRunning() {
+		err = daemonClient().DisableOverlay(filename)
+	} else {
+		err = config.RemoveOverlay(filename)
+	}
+	if err != nil {
 		fmt.Fprintf(os.Stderr, "Failed to disable overlay: %v\n", err)
 		os.Exit(1)
 	}
 
 	notifyOverlayChange(fmt.Sprintf("Disabled %s", filename))
-	restartIfRunning()
 }
@@ -1,9 +1,7 @@
 package commands
 
 import (
-	"bufio"
 	"fmt"
-	"net"
 	"os"
 	"strings"
 
@@ -11,6 +9,7 @@ import (
 
 	"github.com/deprecatedluar/akeyshually/internal/common"
 	"github.com/deprecatedluar/akeyshually/internal/config"
+	"github.com/deprecatedluar/akeyshually/internal/ipc/client"
 )
 
 // Emit validates a whitespace-separated remap token sequence, sends it to
@@ -31,25 +30,14 @@ func Emit(cmd string) {
 		}
 	}
 
-	d := daemon.New(common.AppName)
-	conn, err := net.Dial("unix", d.RuntimePath(".sock"))
-	if err != nil {
-		fmt.Fprintf(os.Stderr, "akeyshually: daemon not running: %v\n", err)
-		os.Exit(1)
-	}
-	defer conn.Close()
-
-	fmt.Fprintf(conn, "%s\n", strings.Join(tokens, " "))
-
-	reply, err := bufio.NewReader(conn).ReadString('\n')
-	if err != nil {
-		fmt.Fprintf(os.Stderr, "akeyshually: no reply from daemon: %v\n", err)
+	if err := daemonClient().Emit(tokens); err != nil {
+		fmt.Fprintf(os.Stderr, "akeyshually: %v\n", err)
 		os.Exit(1)
 	}
+}
 
-	reply = strings.TrimSpace(reply)
-	if strings.HasPrefix(reply, "err:") {
-		fmt.Fprintln(os.Stderr, reply)
-		os.Exit(1)
-	}
+// daemonClient returns a client for the running daemon's IPC socket.
+func daemonClient() *client.Client {
+	d := daemon.New(common.AppName)
+	return client.New(d.RuntimePath(".sock"))
 }
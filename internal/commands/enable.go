@@ -9,7 +9,7 @@ import (
 	"github.com/deprecatedluar/akeyshually/internal/config"
 )
 
-// Enable adds an overlay to the enabled list and restarts the daemon
+// Enable adds an overlay to the enabled list and reloads the daemon
 func Enable(filename string) {
 	// Validate filename ends with .toml
 	if !strings.HasSuffix(filename, ".toml") {
@@ -29,12 +29,17 @@ func Enable(filename string) {
 		os.Exit(1)
 	}
 
-	// Add to enabled state
-	if err := config.AddOverlay(filename); err != nil {
+	// A running daemon updates the enabled state itself and reloads,
+	// keeping the old state if the overlay does not load
+	if daemonRunning() {
+		err = daemonClient().EnableOverlay(filename)
+	} else {
+		err = config.AddOverlay(filename)
+	}
+	if err != nil {
 		fmt.Fprintf(os.Stderr, "Failed to enable overlay: %v\n", err)
 		os.Exit(1)
 	}
 
 	notifyOverlayChange(fmt.Sprintf("Enabled %s", filename))
-	restartIfRunning()
 }
@@ -11,6 +11,7 @@ var (
 			gohelp.Item("(no command)", "Run the daemon in the foreground"),
 			gohelp.Item("stop", "Stop running daemon"),
 			gohelp.Item("restart", "Restart daemon (requires the systemd unit)"),
+			gohelp.Item("reload", "Reload config files in the running daemon (kept on a config error)"),
 			gohelp.Item("update", "Check for and install updates"),
 			gohelp.Item("config [file]", "Edit config file in $EDITOR"),
 			gohelp.Item("enable <file>", "Enable config overlay"),
@@ -22,6 +23,13 @@ var (
 			gohelp.Item("tap <keys>", "Tap a key/combo (alias: key, press)"),
 			gohelp.Item("hold <keys>", "Hold a key/combo until released (alias: keydown)"),
 			gohelp.Item("release [keys]", "Release a key, or all held keys with no args (alias: keyup)"),
+			gohelp.Item("switch get|set|reset <combo>", "Inspect or move a .switch cycle via the running daemon"),
+			gohelp.Item("trigger <combo> [--behavior b] [--duration d]", "Fire a configured shortcut via the running daemon, as if pressed"),
+			gohelp.Item("stats [--json | --reset]", "Show how often each shortcut fires, its latency and the ones never used"),
+			gohelp.Item("watch [--format json|waybar]", "Stream daemon events (shortcuts, overlays, held keys, devices)"),
+			gohelp.Item("inspect [--json]", "Show each key's name, combo and matching shortcuts as you press it"),
+			gohelp.Item("devices [--json]", "List input devices, how detection classifies them and why a grab fails"),
+			gohelp.Item("last [n]", "Show the most recent command executions with exit status and output"),
 			gohelp.Item("help [topic]", "Show this help message"),
 			gohelp.Item("version", "Show version information"),
 		).
@@ -37,9 +45,25 @@ var (
 			gohelp.Item("default_interval", "Default interval for repeat behaviors (milliseconds)", "default_interval = 150"),
 			gohelp.Item("disable_media_keys", "Forward media keys to system", "disable_media_keys = false"),
 			gohelp.Item("shell", "Shell to use for commands (default: $SHELL, fallback: sh)", "shell = \"/bin/bash\""),
-			gohelp.Item("env_file", "File to source before command execution", "env_file = \"~/.profile\""),
+			gohelp.Item("session_env", "Import the graphical session's variables: \"systemd\" or a KEY=value file", "session_env = \"systemd\""),
+			gohelp.Item("env_file", "Environment file applied to every command, re-read when it changes", "env_file = \"~/.profile\""),
 			gohelp.Item("notify_on_overlay_change", "Desktop notifications when overlays change", "notify_on_overlay_change = true"),
-			gohelp.Item("devices", "List of device name substrings to grab (case-insensitive)", "devices = [\"Huion\", \"Xbox Controller\"]"),
+			gohelp.Item("devices", "Devices to grab: name substrings (case-insensitive) or selector tables", "devices = [\"Huion\", { vendor = 0x256c, product = 0x006d }]"),
+			gohelp.Item("exclude_devices", "Devices never grabbed, even when auto-detected (same selectors)", "exclude_devices = [{ phys = \"usb-0000:00:14.0-2/*\" }]"),
+			gohelp.Item("gesture_swipe_distance", "Touchpad swipe travel in percent of the pad (default: 15)", "gesture_swipe_distance = 15"),
+			gohelp.Item("gesture_pinch_distance", "Touchpad pinch spread change in percent (default: 20)", "gesture_pinch_distance = 20"),
+			gohelp.Item("gesture_hold_time", "Touchpad hold time in milliseconds (default: 500)", "gesture_hold_time = 500"),
+			gohelp.Item("suppress_gestures", "Hide pointer motion while 3+ fingers are down", "suppress_gestures = true"),
+			gohelp.Item("chord_window", "Time for all keys of a chord to go down in milliseconds (default: 50)", "chord_window = 50"),
+			gohelp.Item("chatter_filter_ms", "Drop release+press pairs of a key closer than this in milliseconds (default: 0 = off)", "chatter_filter_ms = 10"),
+			gohelp.Item("notify_on_failure", "Desktop notification when a command exits non-zero", "notify_on_failure = true"),
+			gohelp.Item("command_timeout", "Stop commands still running after this many milliseconds (default: 0 = no limit; .taphold commands are exempt)", "command_timeout = 30000"),
+			gohelp.Item("log_commands", "Log executions to $XDG_STATE_HOME/akeyshually/commands.log", "log_commands = true"),
+			gohelp.Item("metrics_listen", "Serve usage counters as OpenMetrics on a Unix socket or loopback port", "metrics_listen = \"127.0.0.1:9464\""),
+		).
+		Section("[device.\"<name>\"]",
+			gohelp.Item("Per-device settings", "Apply to devices whose name contains <name> (case-insensitive, longest match wins)"),
+			gohelp.Item("chatter_filter_ms", "Overrides the [settings] value for this device", "[device.\"Keychron\"] chatter_filter_ms = 15"),
 		).
 		Section("[virtual_keys]",
 			gohelp.Item("Virtual keys", "Unify multiple physical keys into a single virtual key name"),
@@ -49,18 +73,24 @@ var (
 			gohelp.Item("Expansion", "Shortcuts using virtual keys expand at config load time", "\"media.hold\" = \"cmd\" → \"playcd.hold\" + \"pausecd.hold\""),
 			gohelp.Item("File-scoped", "Virtual keys only expand within the config/overlay where they're defined"),
 		).
+		Section("[modifiers]",
+			gohelp.Item("Custom modifiers", "Turn any key into a modifier for combos", "hyper = \"capslock\""),
+			gohelp.Item("Combos", "Use the declared name or the key itself", "\"hyper+h\" = \">left\" or \"capslock+h\" = \">left\""),
+			gohelp.Item("Tap", "The key is held back while pressed and sent as a normal tap if released alone"),
+		).
 		Section("[shortcuts]",
-			gohelp.Item("Key modifiers", "super, ctrl, alt, shift (lowercase, no left/right distinction)"),
+			gohelp.Item("Key modifiers", "super, ctrl, alt, shift (lowercase, no left/right distinction), plus [modifiers]"),
 			gohelp.Item("Keys", "lowercase letters, numbers, function keys, navigation, etc. (see 'help keys')"),
 			gohelp.Item("Axis inputs", "lx, ly, rx, ry, rz, abs_x, abs_y, etc. with +/- direction (see 'help axis')"),
 			gohelp.Item("Remap output", ">key, >lclick, >scrollup - inject key/mouse/scroll events (see 'help remap')"),
 			gohelp.Item("Syntax", "Use + to separate modifiers and key", "\"super+t\" = \"alacritty\""),
 			gohelp.Item("Triggers", ".onpress (default), .hold, .doubletap, .taphold, .pressrelease, .longpress, etc."),
-			gohelp.Item("Modifiers", ".switch, .repeat, .passthrough"),
+			gohelp.Item("Modifiers", ".switch, .repeat, .autorepeat, .cooldown, .ratelimit, .single, .restart, .queue, .toggleprocess, .timeout, .passthrough"),
 		).
 		Section("Examples",
 			gohelp.Item("Tap modifier key", "Execute command on modifier release", "\"super.pressrelease\" = [\"\", \"rofi\"]"),
 			gohelp.Item("Launch terminal", "Simple key combo", "\"super+t\" = \"alacritty\""),
+			gohelp.Item("Without a shell", "Run the program directly, with env_file applied once", "\"super+t\" = { exec = [\"kitty\", \"--single-instance\"] }"),
 			gohelp.Item("Toggle auto-clicker", "Remap key to mouse click, repeat on press", "\"f9.onpress.repeat\" = \">lclick\""),
 			gohelp.Item("Axis scrolling", "Touchstrip or peripheral axis input", "\"rx+\" = \">scrollup\""),
 			gohelp.Item("Key to mouse button", "Remap any key to mouse click", "\"f1\" = \">lclick\""),
@@ -85,8 +115,8 @@ var (
 			gohelp.Item("3. Auto-reload", "Enabled overlays are watched for changes"),
 		).
 		Section("Commands",
-			gohelp.Item("enable gaming.toml", "Enable overlay and restart daemon", "akeyshually enable gaming.toml"),
-			gohelp.Item("disable gaming.toml", "Disable overlay and restart daemon", "akeyshually disable gaming.toml"),
+			gohelp.Item("enable gaming.toml", "Enable overlay and reload daemon", "akeyshually enable gaming.toml"),
+			gohelp.Item("disable gaming.toml", "Disable overlay and reload daemon", "akeyshually disable gaming.toml"),
 			gohelp.Item("list", "Show all config files and their status", "akeyshually list"),
 			gohelp.Item("clear", "Disable all overlays", "akeyshually clear"),
 			gohelp.Item("config gaming", "Edit gaming.toml overlay", "akeyshually config gaming"),
@@ -101,6 +131,7 @@ var (
 			gohelp.Item(".onpress", "Execute on key press (default, can be omitted)", "\"super+t\" = \"terminal\""),
 			gohelp.Item(".hold(ms)", "Fire once after held for duration (no process management)", "\"super+m.hold(500)\" = \"mute\""),
 			gohelp.Item(".doubletap(ms)", "Execute on confirmed double-tap; single keys only", "\"super.doubletap(270)\" = \"rofi -show drun\""),
+			gohelp.Item(".tap(n, ms)", "Execute on confirmed N-tap (also .taptaptap); ms is the window between taps", "\"f12.tap(3)\" = \"notify-send three\""),
 			gohelp.Item(".pressrelease", "Different commands on press and release (2-command array)", "\"mute.pressrelease\" = [\"mic-on\", \"mic-off\"]"),
 			gohelp.Item(".taphold(tap_ms, hold_ms)", "Tap once, then tap-and-hold fires command on next press", "\"super+t.taphold(200, 500)\" = \"hold-cmd\""),
 			gohelp.Item(".longpress(ms)", "Fire once after threshold (one-shot)", "\"super+h.longpress(1000)\" = \"shutdown\""),
@@ -108,15 +139,30 @@ var (
 			gohelp.Item(".taplongpress(tap_ms, long_ms)", "Tap fires first, tap-then-longpress fires second (2-command array)", "\"super+space.taplongpress\" = [\"quick\", \"long\"]"),
 			gohelp.Item(".tappressrelease(tap_ms)", "Tap then press fires first, release fires second (2-command array)", "\"mute.tappressrelease(200)\" = [\"start\", \"stop\"]"),
 			gohelp.Item(".tapholdrelease(tap_ms, hold_ms)", "Tap then hold fires first, release fires second (2-command array)", "\"f1.tapholdrelease\" = [\"hold-start\", \"hold-end\"]"),
+			gohelp.Item(".tapmod(ms)", "Dual-role key: tap remap, or hold remap once another key is tapped inside it", "\"capslock.tapmod\" = [\">esc\", \">ctrl\"]"),
 		).
 		Section("Modifiers",
 			gohelp.Item(".switch", "Cycle through array of commands on each press", "\"f2.switch\" = [\"cmd1\", \"cmd2\", \"cmd3\"]"),
+			gohelp.Item(".switch(reset=ms)", "Cycle, starting over at the first command after ms without a press", "\"f2.switch(reset=3000)\" = [\"cmd1\", \"cmd2\"]"),
 			gohelp.Item(".repeat", "Loop command: with .hold (while held) or .onpress (toggle)", "\"f9.onpress.repeat\" = \"xdotool click 1\""),
+			gohelp.Item(".autorepeat", "Fire again on every keyboard autorepeat while held (sole trigger on the key)", "\"f10.autorepeat\" = \"brightnessctl set 5%+\""),
+			gohelp.Item(".cooldown(ms, forward)", "Refuse to fire again within ms; with forward the key reaches the system instead", "\"print.cooldown(1000)\" = \"screenshot\""),
+			gohelp.Item(".ratelimit(n/window, forward)", "Fire at most n times per window (ms, s or m units)", "\"super+q.ratelimit(3/10s)\" = \"kill-window\""),
+			gohelp.Item(".single", "Skip the fire while the previous command still runs", "\"super+d.single\" = \"rofi -show drun\""),
+			gohelp.Item(".restart", "Stop the previous command (and its children), then start again", "\"super+v.restart\" = \"copyq toggle\""),
+			gohelp.Item(".queue", "Start once the previous command has exited (up to 16 waiting)", "\"f8.queue\" = \"play-sound ding\""),
+			gohelp.Item(".toggleprocess(signal, ms)", "Press starts the command, next press stops it (default SIGINT, SIGKILL after 5000ms)", "\"super+r.toggleprocess\" = \"wf-recorder\""),
+			gohelp.Item(".timeout(n)", "Stop the command and everything it started after n (ms, s or m; overrides command_timeout)", "\"super+w.timeout(10s)\" = \"weather-notify\""),
 			gohelp.Item(".passthrough", "Match regardless of modifier state", "\"v.passthrough\" = \"copyq toggle\""),
 		).
+		Section("Chords",
+			gohelp.Item("Syntax", "Two or more regular keys pressed together, in any order", "\"j+k\" = \">escape\""),
+			gohelp.Item("Window", "All keys must go down within chord_window, otherwise they are replayed as typed"),
+			gohelp.Item("Triggers", "Default, .hold, .longpress, .pressrelease and .holdrelease; no modifiers"),
+		).
 		Section("Restrictions",
-			gohelp.Item("Single keys only", ".doubletap and .taphold only work on single keys (no combos)"),
-			gohelp.Item("Array commands", ".switch, .pressrelease, .holdrelease, .taplongpress, .tappressrelease, and .tapholdrelease require 2+ commands"),
+			gohelp.Item("Single keys only", ".doubletap, .tap(n), .taphold and .tapmod only work on single keys (no combos)"),
+			gohelp.Item("Array commands", ".switch, .pressrelease, .holdrelease, .taplongpress, .tappressrelease, .tapholdrelease and .tapmod require 2+ commands"),
 		)
 
 	helpAxis = gohelp.NewPage("axis", "absolute axis and peripheral support").
@@ -139,6 +185,14 @@ var (
 		Section("Device Detection",
 			gohelp.Item("Auto-detection", "Most devices auto-detected by capability flags"),
 			gohelp.Item("Explicit grab", "Add device name substring to [settings] devices array", "devices = [\"Tablet Monitor Touch Strip\"]"),
+			gohelp.Item("Hotplug", "Devices plugged in later are detected with the same rules; unplugged ones are waited for"),
+		).
+		Section("Touchpad Gestures",
+			gohelp.Item("Swipes", "3 or 4 finger swipes by direction", "\"swipe3_left\", \"swipe4_up\""),
+			gohelp.Item("Pinch", "2+ fingers closing or spreading", "\"pinch_in\", \"pinch_out\""),
+			gohelp.Item("Hold", "3 or 4 fingers resting without movement", "\"hold3\", \"hold4\""),
+			gohelp.Item("Modifiers", "Held modifiers prefix the gesture", "\"super+swipe3_left\" = \"cmd\""),
+			gohelp.Item("Device", "Touchpads must be listed in [settings] devices", "devices = [\"Touchpad\"]"),
 		)
 
 	helpRemap = gohelp.NewPage("remap", "key and mouse button injection").
@@ -0,0 +1,170 @@
+package commands
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	evdev "github.com/holoplot/go-evdev"
+
+	"github.com/deprecatedluar/akeyshually/internal/common"
+	"github.com/deprecatedluar/akeyshually/internal/config"
+	"github.com/deprecatedluar/akeyshually/internal/events"
+	"github.com/deprecatedluar/akeyshually/internal/handlers"
+	"github.com/deprecatedluar/akeyshually/internal/matcher"
+)
+
+const inspectUsage = "Usage: akeyshually inspect [--json]"
+
+// Inspect shows every key and axis event as it arrives: the device, the name
+// akeyshually knows it by, the combo it builds and the configured shortcuts
+// it would fire. With the daemon running the events come from its listeners;
+// otherwise the devices are opened read-only (not grabbed) and matched
+// against the config files.
+func Inspect(args []string) {
+	asJSON := false
+	switch {
+	case len(args) == 0:
+	case len(args) == 1 && args[0] == "--json":
+		asJSON = true
+	default:
+		fmt.Fprintln(os.Stderr, inspectUsage)
+		os.Exit(1)
+	}
+
+	show := printInput
+	if asJSON {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetEscapeHTML(false)
+		show = func(e events.Event) error { return enc.Encode(e) }
+	}
+
+	var err error
+	if daemonRunning() {
+		fmt.Fprintln(os.Stderr, "Inspecting through the running daemon, press Ctrl+C to stop")
+		err = daemonClient().Inspect(show)
+	} else {
+		err = inspectDevices(show)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "akeyshually: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+// inspectDevices reads every input device without grabbing it and passes
+// the described events to show.
+func inspectDevices(show func(events.Event) error) error {
+	enabledOverlays, err := config.ReadEnabledState()
+	if err != nil {
+		enabledOverlays = nil
+	}
+	cfg, err := config.LoadWithOverlays(enabledOverlays)
+	if err != nil {
+		return fmt.Errorf("config error: %w", err)
+	}
+	m := matcher.New(cfg.ParsedShortcuts)
+	m.SetCustomModifiers(cfg.Modifiers)
+
+	paths, err := evdev.ListDevicePaths()
+	if err != nil {
+		return fmt.Errorf("failed to list input devices: %w", err)
+	}
+
+	type input struct {
+		device string
+		event  evdev.InputEvent
+	}
+	inputs := make(chan input)
+	opened := 0
+	for _, path := range paths {
+		dev, err := evdev.Open(path.Path)
+		if err != nil {
+			continue
+		}
+		name, _ := dev.Name()
+		// Our own virtual devices repeat what the daemon forwards
+		if strings.Contains(strings.ToLower(name), common.AppName) {
+			dev.Close()
+			continue
+		}
+		opened++
+		go func() {
+			defer dev.Close()
+			for {
+				event, err := dev.ReadOne()
+				if err != nil {
+					return
+				}
+				inputs <- input{name, *event}
+			}
+		}()
+	}
+	if opened == 0 {
+		return errors.New("no readable input devices (is your user in the input group?)")
+	}
+	fmt.Fprintf(os.Stderr, "Inspecting %d device(s) read-only, press Ctrl+C to stop\n", opened)
+
+	for in := range inputs {
+		e, ok := handlers.Describe(in.device, in.event, m, cfg)
+		if !ok {
+			continue
+		}
+		e.Time = time.Now()
+		// The daemon's handlers track modifiers; here nothing else does
+		if in.event.Type == evdev.EV_KEY && in.event.Value != keyRepeatValue {
+			m.UpdateModifierState(uint16(in.event.Code), in.event.Value == keyPressValue)
+		}
+		if err := show(e); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+const (
+	keyReleaseValue = 0
+	keyPressValue   = 1
+	keyRepeatValue  = 2
+)
+
+// printInput writes one Input event as a line such as
+// "12:00:01.25  AT Translated Set 2 keyboard  press    t  ctrl+t  -> ctrl+t (normal)".
+func printInput(e events.Event) error {
+	in := e.Input
+	if in == nil {
+		return nil
+	}
+
+	var action, name string
+	switch {
+	case in.Kind == "axis":
+		action = fmt.Sprintf("axis %d", in.Value)
+	case in.Value == keyPressValue:
+		action = "press"
+	case in.Value == keyReleaseValue:
+		action = "release"
+	case in.Value == keyRepeatValue:
+		action = "repeat"
+	default:
+		action = fmt.Sprintf("value %d", in.Value)
+	}
+	if in.Named {
+		name = fmt.Sprintf("%s (code %d)", e.Key, in.Code)
+	} else {
+		name = fmt.Sprintf("code %d, no name (cannot be bound)", in.Code)
+	}
+
+	line := fmt.Sprintf("%s  %s  %-9s %s", e.Time.Local().Format("15:04:05.00"), e.Device, action, name)
+	if in.Named && e.Combo != e.Key {
+		line += "  combo " + e.Combo
+	}
+	if len(in.Matches) > 0 {
+		line += "  -> " + strings.Join(in.Matches, ", ")
+	}
+	_, err := fmt.Println(line)
+	return err
+}
@@ -0,0 +1,53 @@
+package commands
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const defaultLastCount = 10
+
+// Last prints the most recent command executions recorded by the running
+// daemon: time, shortcut, exit status, duration and command, with output below.
+func Last(args []string) {
+	if len(args) > 1 {
+		fmt.Fprintf(os.Stderr, "Usage: akeyshually last [n]\n")
+		os.Exit(1)
+	}
+	n := defaultLastCount
+	if len(args) == 1 {
+		parsed, err := strconv.Atoi(args[0])
+		if err != nil || parsed < 1 {
+			fmt.Fprintf(os.Stderr, "akeyshually: invalid count %q\n", args[0])
+			os.Exit(1)
+		}
+		n = parsed
+	}
+
+	executions, err := daemonClient().Last(n)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "akeyshually: %v\n", err)
+		os.Exit(1)
+	}
+	if len(executions) == 0 {
+		fmt.Println("No commands have run yet")
+		return
+	}
+
+	for _, e := range executions {
+		combo := e.Combo
+		if combo == "" {
+			combo = "-"
+		}
+		duration := time.Duration(e.DurationMs) * time.Millisecond
+		fmt.Printf("%s  %s  %s  %v  %s\n", e.Started.Local().Format("15:04:05"), combo, e.Status, duration, e.Command)
+		if output := strings.TrimRight(e.Output, "\n"); output != "" {
+			for _, line := range strings.Split(output, "\n") {
+				fmt.Printf("    %s\n", line)
+			}
+		}
+	}
+}
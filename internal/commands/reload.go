@@ -0,0 +1,23 @@
+package commands
+
+import (
+	"fmt"
+	"os"
+
+	daemon "github.com/deprecatedluar/luar-daemonator"
+
+	"github.com/deprecatedluar/akeyshually/internal/common"
+)
+
+// Reload makes the running daemon load its config files again. The daemon
+// checks them first and keeps running on the old config if they fail.
+func Reload() {
+	if err := daemonClient().Reload(); err != nil {
+		fmt.Fprintf(os.Stderr, "Reload failed: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+func daemonRunning() bool {
+	return daemon.New(common.AppName).IsRunning()
+}
@@ -5,19 +5,10 @@ import (
 	"os"
 	"os/exec"
 
-	daemon "github.com/deprecatedluar/luar-daemonator"
-
 	"github.com/deprecatedluar/akeyshually/internal/common"
 	"github.com/deprecatedluar/akeyshually/internal/config"
 )
 
-func restartIfRunning() {
-	d := daemon.New(common.AppName)
-	if d.IsRunning() {
-		Restart()
-	}
-}
-
 func notifyOverlayChange(message string) {
 	if cfg, err := config.Load(); err == nil && cfg.Settings.NotifyOnOverlayChange {
 		common.NotifyInfo(common.AppName, message)
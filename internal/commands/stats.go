@@ -0,0 +1,114 @@
+package commands
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"text/tabwriter"
+	"time"
+
+	"github.com/deprecatedluar/akeyshually/internal/stats"
+)
+
+const statsUsage = "Usage: akeyshually stats [--json | --reset]"
+
+// Stats prints the running daemon's usage counters: fires and press-to-fire
+// latency per shortcut, the configured shortcuts that never fired, failed
+// commands, device events and repeat loop iterations.
+func Stats(args []string) {
+	if len(args) > 1 {
+		fmt.Fprintln(os.Stderr, statsUsage)
+		os.Exit(1)
+	}
+	c := daemonClient()
+
+	if len(args) == 1 && args[0] == "--reset" {
+		if err := c.ResetStats(); err != nil {
+			fmt.Fprintf(os.Stderr, "akeyshually: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Println("Statistics reset")
+		return
+	}
+	if len(args) == 1 && args[0] != "--json" {
+		fmt.Fprintln(os.Stderr, statsUsage)
+		os.Exit(1)
+	}
+
+	st, err := c.Stats()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "akeyshually: %v\n", err)
+		os.Exit(1)
+	}
+	if len(args) == 1 {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		enc.Encode(st)
+		return
+	}
+
+	fmt.Printf("Since %s\n", st.Since.Local().Format("2006-01-02 15:04"))
+
+	shortcuts := st.Shortcuts
+	sort.SliceStable(shortcuts, func(i, j int) bool { return shortcuts[i].Fires > shortcuts[j].Fires })
+	fmt.Println()
+	if len(shortcuts) == 0 {
+		fmt.Println("No shortcuts have fired yet")
+	} else {
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "SHORTCUT\tBEHAVIOR\tFIRES\tAVG LATENCY\tMAX LATENCY\tLAST FIRED")
+		for _, s := range shortcuts {
+			fmt.Fprintf(w, "%s\t%s\t%d\t%v\t%v\t%s\n", s.Combo, s.Behavior, s.Fires,
+				msDuration(s.LatencyAvgMs()), msDuration(s.LatencyMaxMs), s.LastFired.Local().Format("2006-01-02 15:04"))
+		}
+		w.Flush()
+	}
+
+	// Configured shortcuts come from the daemon too; without them the rest
+	// of the report still stands
+	if configured, err := c.Shortcuts(); err == nil {
+		fired := make(map[[2]string]bool, len(shortcuts))
+		for _, s := range shortcuts {
+			fired[[2]string{s.Combo, s.Behavior}] = true
+		}
+		var never []string
+		for _, s := range configured {
+			if !fired[[2]string{s.Combo, s.Behavior}] {
+				never = append(never, s.Combo+" ("+s.Behavior+")")
+			}
+		}
+		printList("Never fired", never)
+	}
+
+	printCounts("Failed commands", st.CommandsFailed)
+	printCounts("Device events", st.DeviceEvents)
+	printCounts("Chatter events filtered", st.ChatterEvents)
+	printCounts("Repeat loop iterations", st.LoopIterations)
+}
+
+func printList(title string, items []string) {
+	if len(items) == 0 {
+		return
+	}
+	fmt.Printf("\n%s:\n", title)
+	for _, item := range items {
+		fmt.Printf("  %s\n", item)
+	}
+}
+
+func printCounts(title string, counts []stats.Count) {
+	if len(counts) == 0 {
+		return
+	}
+	fmt.Printf("\n%s:\n", title)
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	for _, c := range counts {
+		fmt.Fprintf(w, "  %s\t%d\n", c.Name, c.Count)
+	}
+	w.Flush()
+}
+
+func msDuration(ms float64) time.Duration {
+	return time.Duration(ms * float64(time.Millisecond)).Round(time.Millisecond)
+}
@@ -0,0 +1,46 @@
+package commands
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+
+	"github.com/deprecatedluar/akeyshually/internal/ipc"
+)
+
+const switchUsage = "Usage: akeyshually switch get|set|reset <combo> [index]"
+
+// Switch inspects or moves a running .switch cycle over the daemon's IPC socket.
+// "get" prints the index the next press fires (0-based).
+func Switch(args []string) {
+	valid := len(args) == 2 && (args[0] == "get" || args[0] == "reset") ||
+		len(args) == 3 && args[0] == "set"
+	if !valid {
+		fmt.Fprintln(os.Stderr, switchUsage)
+		os.Exit(1)
+	}
+
+	c := daemonClient()
+	combo := args[1]
+	var err error
+	switch args[0] {
+	case "get":
+		var info ipc.SwitchInfo
+		if info, err = c.SwitchIndex(combo); err == nil {
+			fmt.Println(info.Next)
+		}
+	case "set":
+		idx, convErr := strconv.Atoi(args[2])
+		if convErr != nil {
+			fmt.Fprintf(os.Stderr, "akeyshually: invalid index %q\n", args[2])
+			os.Exit(1)
+		}
+		err = c.SetSwitchIndex(combo, idx)
+	case "reset":
+		err = c.ResetSwitch(combo)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "akeyshually: %v\n", err)
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,64 @@
+package commands
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"time"
+)
+
+const triggerUsage = "Usage: akeyshually trigger <combo> [--behavior <name>] [--duration <time>]"
+
+// Trigger has the running daemon fire a configured shortcut as if its key
+// had been pressed, so bars and touch buttons can reuse keyboard bindings.
+func Trigger(args []string) {
+	var combo, behavior string
+	var hold time.Duration
+	for i := 0; i < len(args); i++ {
+		switch args[i] {
+		case "--behavior", "--duration":
+			if i+1 >= len(args) {
+				fmt.Fprintln(os.Stderr, triggerUsage)
+				os.Exit(1)
+			}
+			i++
+			if args[i-1] == "--behavior" {
+				behavior = args[i]
+				continue
+			}
+			d, err := parseHoldDuration(args[i])
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "akeyshually: %v\n", err)
+				os.Exit(1)
+			}
+			hold = d
+		default:
+			if combo != "" {
+				fmt.Fprintln(os.Stderr, triggerUsage)
+				os.Exit(1)
+			}
+			combo = args[i]
+		}
+	}
+	if combo == "" {
+		fmt.Fprintln(os.Stderr, triggerUsage)
+		os.Exit(1)
+	}
+
+	if err := daemonClient().Trigger(combo, behavior, hold); err != nil {
+		fmt.Fprintf(os.Stderr, "akeyshually: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+// parseHoldDuration reads "800ms", "1.5s" or a bare number of milliseconds.
+func parseHoldDuration(s string) (time.Duration, error) {
+	if n, err := strconv.ParseFloat(s, 64); err == nil && n >= 0 {
+		return time.Duration(n * float64(time.Millisecond)), nil
+	}
+	d, err := time.ParseDuration(s)
+	if err != nil || d < 0 {
+		return 0, fmt.Errorf("invalid duration %q (e.g. 800ms, 2s)", s)
+	}
+	return d, nil
+}
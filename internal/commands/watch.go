@@ -0,0 +1,153 @@
+package commands
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/deprecatedluar/akeyshually/internal/events"
+	"github.com/deprecatedluar/akeyshually/internal/ipc/client"
+)
+
+const (
+	watchUsage = "Usage: akeyshually watch [--format json|waybar]"
+	watchRetry = time.Second // between reconnects while the daemon is down
+)
+
+// Watch prints the running daemon's events as they happen, one JSON object
+// per line, or with --format waybar a custom module line whenever the active
+// overlays or held keys change. It keeps reconnecting across daemon restarts
+// and reloads.
+func Watch(args []string) {
+	format := "json"
+	switch {
+	case len(args) == 0:
+	case len(args) == 2 && args[0] == "--format":
+		format = args[1]
+	case len(args) == 1 && strings.HasPrefix(args[0], "--format="):
+		format = strings.TrimPrefix(args[0], "--format=")
+	default:
+		fmt.Fprintln(os.Stderr, watchUsage)
+		os.Exit(1)
+	}
+
+	var handle func(events.Event) error
+	var offline func()
+	switch format {
+	case "json":
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetEscapeHTML(false)
+		handle = func(e events.Event) error { return enc.Encode(e) }
+		offline = func() {}
+	case "waybar":
+		module := &waybarModule{out: os.Stdout}
+		handle = module.update
+		offline = module.offline
+	default:
+		fmt.Fprintf(os.Stderr, "akeyshually: unknown format %q (want json or waybar)\n", format)
+		os.Exit(1)
+	}
+
+	c := daemonClient()
+	for {
+		err := c.Subscribe(handle)
+		if !errors.Is(err, client.ErrNotRunning) {
+			fmt.Fprintf(os.Stderr, "akeyshually: %v\n", err)
+			os.Exit(1)
+		}
+		offline()
+		time.Sleep(watchRetry)
+	}
+}
+
+// waybarModule renders the active overlays and the keys held with >> as a
+// waybar custom module ("return-type": "json").
+type waybarModule struct {
+	out      io.Writer
+	overlays []string
+	held     []string
+	last     string
+}
+
+type waybarOutput struct {
+	Text    string   `json:"text"`
+	Tooltip string   `json:"tooltip"`
+	Class   []string `json:"class"`
+}
+
+func (w *waybarModule) update(e events.Event) error {
+	switch e.Type {
+	case events.State:
+		w.overlays = nil
+		for _, name := range e.Overlays {
+			w.overlays = append(w.overlays, strings.TrimSuffix(name, ".toml"))
+		}
+		w.held = slices.Clone(e.Held)
+	case events.OverlayEnabled:
+		w.overlays = appendUnique(w.overlays, strings.TrimSuffix(e.Overlay, ".toml"))
+	case events.OverlayDisabled:
+		w.overlays = slices.DeleteFunc(w.overlays, func(s string) bool { return s == strings.TrimSuffix(e.Overlay, ".toml") })
+	case events.KeyHeld:
+		w.held = appendUnique(w.held, e.Key)
+	case events.KeyReleased:
+		w.held = slices.DeleteFunc(w.held, func(s string) bool { return s == e.Key })
+	default:
+		return nil
+	}
+	return w.render(true)
+}
+
+// offline shows the daemon as not running until it can be reached again.
+func (w *waybarModule) offline() {
+	w.overlays, w.held = nil, nil
+	if err := w.render(false); err != nil {
+		os.Exit(1)
+	}
+}
+
+// render writes the module line if it changed since the last one.
+func (w *waybarModule) render(connected bool) error {
+	out := waybarOutput{Class: []string{}}
+	var text, tooltip []string
+	if !connected {
+		out.Class = append(out.Class, "offline")
+		tooltip = append(tooltip, "akeyshually is not running")
+	}
+	if len(w.overlays) > 0 {
+		out.Class = append(out.Class, "overlay")
+		text = append(text, w.overlays...)
+		tooltip = append(tooltip, "Overlays: "+strings.Join(w.overlays, ", "))
+	}
+	if len(w.held) > 0 {
+		out.Class = append(out.Class, "held")
+		for _, key := range w.held {
+			text = append(text, "["+key+"]")
+		}
+		tooltip = append(tooltip, "Held: "+strings.Join(w.held, ", "))
+	}
+	if connected && len(tooltip) == 0 {
+		tooltip = append(tooltip, "No overlays or held keys")
+	}
+	out.Text = strings.Join(text, " ")
+	out.Tooltip = strings.Join(tooltip, "\n")
+
+	line, err := json.Marshal(out)
+	if err != nil || string(line) == w.last {
+		return err
+	}
+	w.last = string(line)
+	_, err = fmt.Fprintf(w.out, "%s\n", line)
+	return err
+}
+
+func appendUnique(list []string, s string) []string {
+	if slices.Contains(list, s) {
+		return list
+	}
+	return append(list, s)
+}
@@ -6,8 +6,10 @@ import (
 	"os"
 	"path/filepath"
 	"regexp"
+	"slices"
 	"strconv"
 	"strings"
+	"syscall"
 
 	"github.com/BurntSushi/toml"
 	"github.com/deprecatedluar/akeyshually/internal/keys"
@@ -17,16 +19,37 @@ import (
 var embeddedConfigs embed.FS
 
 type Settings struct {
-	DefaultInterval       float64  `toml:"default_interval"`         // >= 10 = milliseconds, < 10 = seconds (default: 150ms)
-	DisableMediaKeys      bool     `toml:"disable_media_keys"`       // Forward media keys to system (default: false)
-	Shell                 string   `toml:"shell"`                    // Optional: override $SHELL
-	EnvFile               string   `toml:"env_file"`                 // Optional: source before commands
-	NotifyOnOverlayChange bool     `toml:"notify_on_overlay_change"` // Desktop notifications for overlay changes
-	Devices               []string `toml:"devices"`                  // Device name substrings to grab (case-insensitive)
+	DefaultInterval       float64          `toml:"default_interval"`         // >= 10 = milliseconds, < 10 = seconds (default: 150ms)
+	DisableMediaKeys      bool             `toml:"disable_media_keys"`       // Forward media keys to system (default: false)
+	Shell                 string           `toml:"shell"`                    // Optional: override $SHELL
+	EnvFile               string           `toml:"env_file"`                 // Optional: source before commands
+	NotifyOnOverlayChange bool             `toml:"notify_on_overlay_change"` // Desktop notifications for overlay changes
+	Devices               []DeviceSelector `toml:"devices"`                  // Devices to grab: name substrings (case-insensitive) or selector tables
+	ExcludeDevices        []DeviceSelector `toml:"exclude_devices"`          // Devices never grabbed, even when auto-detected
+	GestureSwipeDistance  float64          `toml:"gesture_swipe_distance"`   // Percent of touchpad size a swipe must travel (default: 15)
+	GesturePinchDistance  float64          `toml:"gesture_pinch_distance"`   // Percent change in finger spread for a pinch (default: 20)
+	GestureHoldTime       float64          `toml:"gesture_hold_time"`        // >= 10 = milliseconds, < 10 = seconds (default: 500ms)
+	SuppressGestures      bool             `toml:"suppress_gestures"`        // Hide 3+ finger motion from the compositor (default: false)
+	ChordWindow           float64          `toml:"chord_window"`             // >= 10 = milliseconds, < 10 = seconds (default: 50ms)
+	ChatterFilterMs       float64          `toml:"chatter_filter_ms"`        // Drop release+press pairs closer than this (default: 0 = off)
+	NotifyOnFailure       bool             `toml:"notify_on_failure"`        // Desktop notification when a command exits non-zero
+	LogCommands           bool             `toml:"log_commands"`             // Append every finished command to $XDG_STATE_HOME/akeyshually/commands.log
+	CommandTimeout        float64          `toml:"command_timeout"`          // >= 10 = milliseconds, < 10 = seconds (default: 0 = no limit)
+	SessionEnv            string           `toml:"session_env"`              // "systemd" or a KEY=value file: graphical session variables for commands
+	MetricsListen         string           `toml:"metrics_listen"`           // "unix:<path>" or loopback host:port serving OpenMetrics (default: off)
+}
+
+// DeviceSettings holds tuning for devices whose name contains the table key.
+type DeviceSettings struct {
+	ChatterFilterMs float64 `toml:"chatter_filter_ms"` // overrides settings.chatter_filter_ms for this device
 }
 
 const (
 	defaultIntervalMs          = 150.0 // milliseconds, used when default_interval is not set in config
+	defaultGestureSwipePercent = 15.0  // percent of touchpad width/height
+	defaultGesturePinchPercent = 20.0  // percent change in average finger spread
+	defaultGestureHoldMs       = 500.0 // milliseconds of stationary 3+ finger contact
+	defaultChordWindowMs       = 50.0  // milliseconds for every key of a chord to go down
 	normalizeIntervalThreshold = 10.0  // values below this are treated as seconds, not milliseconds
 	configDirPerm              = 0755
 	configFilePerm             = 0644
@@ -48,7 +71,20 @@ const (
 	BehaviorTapLongPress    // tap fires Commands[0], tap-then-longpress fires Commands[1] once
 	BehaviorTapPressRelease // tap, then Commands[0] on second press, Commands[1] on release
 	BehaviorTapHoldRelease  // tap, then Commands[0] at hold threshold, Commands[1] on release
+	BehaviorTapMod          // dual-role key: Commands[0] remap on tap, Commands[1] remap while held
+	BehaviorMultiTap        // TapCount presses within the tap window (three or more; two is doubletap)
 	BehaviorEscapePending   // pseudo-candidate: prevents early resolution when escape hatches exist
+	BehaviorChordPending    // pseudo-candidate: withholds a key while it may still grow into a chord
+)
+
+// ProcessPolicy decides what a fire does while the shortcut's previous shell command still runs.
+type ProcessPolicy int
+
+const (
+	PolicyNone    ProcessPolicy = iota // start another instance
+	PolicySingle                       // skip the fire
+	PolicyRestart                      // stop the running instance, then start
+	PolicyQueue                        // start once the running instance exits
 )
 
 type TimingMode int
@@ -62,30 +98,55 @@ type ParsedShortcut struct {
 	KeyCombo        string // "super+k" (without suffix)
 	Behavior        BehaviorMode
 	Timing          TimingMode
-	Repeat          bool     // stacks on any trigger; stop condition follows trigger semantics
-	Interval        float64  // Milliseconds (0 = use default) — tap window for taphold
-	HoldInterval    float64  // Milliseconds (0 = use default) — hold threshold for taphold
-	Commands        []string // Single command OR switch array
-	Passthrough     bool     // Ignore modifiers when matching
-	AliasGroup      string   // Canonical key for shared state (e.g. "f1/f2.switch"), empty if not an alias
-	Direction       string   // For axis shortcuts: "+", "-", or "" (both)
-	Sensitivity     float64  // For axis shortcuts: fires per full sweep (0 = use default)
-	ExplicitOnPress bool     // true if ".onpress" was written explicitly, distinguishes from bare for remap translation
+	Repeat          bool           // stacks on any trigger; stop condition follows trigger semantics
+	Interval        float64        // Milliseconds (0 = use default) — tap window for taphold
+	HoldInterval    float64        // Milliseconds (0 = use default) — hold threshold for taphold
+	Commands        []string       // Single command OR switch array
+	Passthrough     bool           // Ignore modifiers when matching
+	AliasGroup      string         // Canonical key for shared state (e.g. "f1/f2.switch"), empty if not an alias
+	Direction       string         // For axis shortcuts: "+", "-", or "" (both)
+	Sensitivity     float64        // For axis shortcuts: fires per full sweep (0 = use default)
+	ExplicitOnPress bool           // true if ".onpress" was written explicitly, distinguishes from bare for remap translation
+	AutoRepeat      bool           // Fire again on every kernel autorepeat event while the key is held
+	TapCount        int            // Presses needed by a multitap trigger (.tap(3), .taptaptap)
+	RateLimit       int            // Fires allowed per RateWindow (0 = unlimited); .cooldown(N) is 1 per N ms
+	RateWindow      float64        // Milliseconds the rate limit applies over
+	ThrottleForward bool           // Forward the key instead when the rate limit refuses a fire
+	SwitchReset     float64        // Milliseconds of inactivity after which a .switch cycle restarts (0 = never)
+	Policy          ProcessPolicy  // .single, .restart or .queue
+	ToggleProcess   bool           // Press starts the command, next press stops it
+	StopSignal      syscall.Signal // Signal a .toggleprocess stop sends first (default: SIGINT)
+	StopTimeout     float64        // Milliseconds before a stopping process is killed with SIGKILL
+	Timeout         float64        // Milliseconds a shell command may run before it is stopped (0 = settings.command_timeout)
 }
 
 type Config struct {
 	Settings    Settings               `toml:"settings"`
 	VirtualKeys map[string]interface{} `toml:"virtual_keys"`      // Virtual key definitions
-	Shortcuts   map[string]interface{} `toml:"shortcuts"`         // Can be string or []interface{}
+	Modifiers   map[string]string      `toml:"modifiers"`         // Custom modifiers: name -> key
+	Shortcuts   map[string]interface{} `toml:"shortcuts"`         // Can be string, { exec = [...] } or []interface{}
 	Commands    map[string]string      `toml:"command_variables"` // Command aliases
+	// Device maps a device name substring (case-insensitive) to per-device settings
+	Device map[string]DeviceSettings `toml:"device"`
 
 	// Parsed shortcuts grouped by key combo
 	ParsedShortcuts map[string][]*ParsedShortcut
 	// EscapeMap tracks which combos have child escape hatches (e.g. "super" -> true if "super+w" exists)
 	EscapeMap map[string]bool
+	// ChordMap marks partial chords that can still grow (e.g. "j" and "k" -> true if "j+k" exists)
+	ChordMap map[string]bool
 	// RemapTable maps a combo string (e.g. "capslock", "ctrl+r") to its remap target name,
 	// for shortcuts eligible for input-stage translation rather than ladder resolution.
 	RemapTable map[string]string
+	// DualRoleTable maps a key name to its .tapmod roles, resolved at the input stage
+	DualRoleTable map[string]DualRole
+}
+
+// DualRole is a key that acts as Tap when tapped and as Hold while held (.tapmod).
+type DualRole struct {
+	Tap      string  // remap target emitted on a quick release
+	Hold     string  // remap target held while the key is down
+	Interval float64 // milliseconds before a lone hold resolves to Hold
 }
 
 // normalizeInterval converts interval values based on heuristic:
@@ -245,8 +306,8 @@ func expandCombo(combo string, virtualKeyMap map[string][]string) []string {
 
 // parseShortcutsInto parses a raw shortcut key (possibly with / aliases) into the map.
 // Aliases share an AliasGroup so switch state is shared across all combos in the group.
-func parseShortcutsInto(dst map[string][]*ParsedShortcut, key string, value interface{}) error {
-	aliases := strings.Split(key, "/")
+func parseShortcutsInto(dst map[string][]*ParsedShortcut, key string, value interface{}, custom map[string]string) error {
+	aliases := splitAliases(key)
 	aliasGroup := ""
 	if len(aliases) > 1 {
 		aliasGroup = key
@@ -268,7 +329,7 @@ func parseShortcutsInto(dst map[string][]*ParsedShortcut, key string, value inte
 			fullKey = strings.TrimSpace(alias) + modifiers
 		}
 
-		parsed, err := ParseShortcut(fullKey, value)
+		parsed, err := parseShortcut(fullKey, value, custom)
 		if err != nil {
 			return err
 		}
@@ -283,6 +344,27 @@ func parseShortcutsInto(dst map[string][]*ParsedShortcut, key string, value inte
 	return nil
 }
 
+// splitAliases splits a shortcut key on the "/" between aliases, leaving the
+// ones inside dot-modifier arguments such as .ratelimit(5/10s) alone.
+func splitAliases(key string) []string {
+	var aliases []string
+	depth, start := 0, 0
+	for i, r := range key {
+		switch r {
+		case '(':
+			depth++
+		case ')':
+			depth--
+		case '/':
+			if depth == 0 {
+				aliases = append(aliases, key[start:i])
+				start = i + 1
+			}
+		}
+	}
+	return append(aliases, key[start:])
+}
+
 func loadFromFile(configPath string) (*Config, error) {
 	cfg := &Config{
 		Shortcuts: make(map[string]interface{}),
@@ -303,6 +385,7 @@ func loadFromFile(configPath string) (*Config, error) {
 	if err := expandVirtualKeys(cfg); err != nil {
 		return nil, fmt.Errorf("failed to expand virtual keys: %w", err)
 	}
+	cfg.Modifiers = normalizeModifiers(cfg.Modifiers)
 
 	// Validate config before processing
 	if err := validateConfig(cfg, configPath, &meta); err != nil {
@@ -315,18 +398,27 @@ func loadFromFile(configPath string) (*Config, error) {
 	} else {
 		cfg.Settings.DefaultInterval = normalizeInterval(cfg.Settings.DefaultInterval)
 	}
+	applyGestureDefaults(&cfg.Settings)
+	if cfg.Settings.ChordWindow == 0 {
+		cfg.Settings.ChordWindow = defaultChordWindowMs
+	} else {
+		cfg.Settings.ChordWindow = normalizeInterval(cfg.Settings.ChordWindow)
+	}
+	cfg.Settings.CommandTimeout = normalizeInterval(cfg.Settings.CommandTimeout)
 
 	// Parse shortcuts
 	cfg.ParsedShortcuts = make(map[string][]*ParsedShortcut)
 	for key, value := range cfg.Shortcuts {
-		if err := parseShortcutsInto(cfg.ParsedShortcuts, key, value); err != nil {
+		if err := parseShortcutsInto(cfg.ParsedShortcuts, key, value, cfg.Modifiers); err != nil {
 			return nil, fmt.Errorf("failed to parse shortcut '%s': %w", key, err)
 		}
 	}
 
 	// Build escape map
-	cfg.EscapeMap = buildEscapeMap(cfg.ParsedShortcuts)
+	cfg.EscapeMap = buildEscapeMap(cfg.ParsedShortcuts, cfg.Modifiers)
+	cfg.ChordMap = buildChordMap(cfg.ParsedShortcuts, cfg.Modifiers)
 	cfg.RemapTable = cfg.buildRemapTable()
+	cfg.DualRoleTable = cfg.buildDualRoleTable()
 
 	return cfg, nil
 }
@@ -367,30 +459,117 @@ func (c *Config) Merge(overlay *Config) {
 		c.Settings.DefaultInterval = overlay.Settings.DefaultInterval
 	}
 
-	// Merge devices (deduplicated, case-insensitive)
-	existing := make(map[string]bool, len(c.Settings.Devices))
-	for _, d := range c.Settings.Devices {
-		existing[strings.ToLower(d)] = true
+	// Merge gesture tuning if overlay specifies it
+	if overlay.Settings.GestureSwipeDistance != 0 {
+		c.Settings.GestureSwipeDistance = overlay.Settings.GestureSwipeDistance
 	}
-	for _, d := range overlay.Settings.Devices {
-		if !existing[strings.ToLower(d)] {
-			c.Settings.Devices = append(c.Settings.Devices, d)
-			existing[strings.ToLower(d)] = true
-		}
+	if overlay.Settings.GesturePinchDistance != 0 {
+		c.Settings.GesturePinchDistance = overlay.Settings.GesturePinchDistance
+	}
+	if overlay.Settings.GestureHoldTime != 0 {
+		c.Settings.GestureHoldTime = normalizeInterval(overlay.Settings.GestureHoldTime)
+	}
+	if overlay.Settings.SuppressGestures {
+		c.Settings.SuppressGestures = true
+	}
+	if overlay.Settings.ChordWindow != 0 {
+		c.Settings.ChordWindow = normalizeInterval(overlay.Settings.ChordWindow)
+	}
+
+	if overlay.Settings.NotifyOnFailure {
+		c.Settings.NotifyOnFailure = true
+	}
+	if overlay.Settings.LogCommands {
+		c.Settings.LogCommands = true
 	}
+	if overlay.Settings.SessionEnv != "" {
+		c.Settings.SessionEnv = overlay.Settings.SessionEnv
+	}
+	if overlay.Settings.MetricsListen != "" {
+		c.Settings.MetricsListen = overlay.Settings.MetricsListen
+	}
+	if overlay.Settings.CommandTimeout != 0 {
+		c.Settings.CommandTimeout = normalizeInterval(overlay.Settings.CommandTimeout)
+	}
+	if overlay.Settings.ChatterFilterMs != 0 {
+		c.Settings.ChatterFilterMs = overlay.Settings.ChatterFilterMs
+	}
+
+	// Merge per-device settings (overlay overrides base)
+	if len(overlay.Device) > 0 && c.Device == nil {
+		c.Device = make(map[string]DeviceSettings, len(overlay.Device))
+	}
+	for match, settings := range overlay.Device {
+		c.Device[match] = settings
+	}
+
+	// Merge custom modifiers (overlay overrides base)
+	if len(overlay.Modifiers) > 0 && c.Modifiers == nil {
+		c.Modifiers = make(map[string]string, len(overlay.Modifiers))
+	}
+	for name, key := range overlay.Modifiers {
+		c.Modifiers[name] = key
+	}
+
+	// Merge devices (deduplicated, case-insensitive)
+	c.Settings.Devices = mergeDeviceSelectors(c.Settings.Devices, overlay.Settings.Devices)
+	c.Settings.ExcludeDevices = mergeDeviceSelectors(c.Settings.ExcludeDevices, overlay.Settings.ExcludeDevices)
 
 	// Rebuild ParsedShortcuts after merge
 	// Note: All shortcuts were already validated, so errors here indicate a bug
 	c.ParsedShortcuts = make(map[string][]*ParsedShortcut)
 	for key, value := range c.Shortcuts {
-		if err := parseShortcutsInto(c.ParsedShortcuts, key, value); err != nil {
+		if err := parseShortcutsInto(c.ParsedShortcuts, key, value, c.Modifiers); err != nil {
 			panic(fmt.Sprintf("BUG: validated shortcut failed to parse during merge: '%s': %v", key, err))
 		}
 	}
 
 	// Rebuild escape map
-	c.EscapeMap = buildEscapeMap(c.ParsedShortcuts)
+	c.EscapeMap = buildEscapeMap(c.ParsedShortcuts, c.Modifiers)
+	c.ChordMap = buildChordMap(c.ParsedShortcuts, c.Modifiers)
 	c.RemapTable = c.buildRemapTable()
+	c.DualRoleTable = c.buildDualRoleTable()
+}
+
+// applyGestureDefaults fills in unset gesture thresholds.
+func applyGestureDefaults(s *Settings) {
+	if s.GestureSwipeDistance == 0 {
+		s.GestureSwipeDistance = defaultGestureSwipePercent
+	}
+	if s.GesturePinchDistance == 0 {
+		s.GesturePinchDistance = defaultGesturePinchPercent
+	}
+	if s.GestureHoldTime == 0 {
+		s.GestureHoldTime = defaultGestureHoldMs
+	} else {
+		s.GestureHoldTime = normalizeInterval(s.GestureHoldTime)
+	}
+}
+
+// HasGestures reports whether any shortcut binds a touchpad gesture.
+func (c *Config) HasGestures() bool {
+	for combo := range c.ParsedShortcuts {
+		parts := strings.Split(combo, "+")
+		if keys.IsGestureName(parts[len(parts)-1]) {
+			return true
+		}
+	}
+	return false
+}
+
+// ChatterFilterFor returns the chatter filter window in milliseconds for a device.
+// The longest [device] key contained in the name wins; settings.chatter_filter_ms is the fallback.
+func (c *Config) ChatterFilterFor(deviceName string) float64 {
+	nameLower := strings.ToLower(deviceName)
+	window := c.Settings.ChatterFilterMs
+	best := -1
+	for match, settings := range c.Device {
+		if len(match) > best && strings.Contains(nameLower, strings.ToLower(match)) {
+			best = len(match)
+			window = settings.ChatterFilterMs
+		}
+	}
+	return window
 }
 
 // loadOverlay loads an overlay config file from the config directory
@@ -417,6 +596,7 @@ func loadOverlay(filename string) (*Config, error) {
 	if err := expandVirtualKeys(cfg); err != nil {
 		return nil, fmt.Errorf("failed to expand virtual keys: %w", err)
 	}
+	cfg.Modifiers = normalizeModifiers(cfg.Modifiers)
 
 	// Validate overlay before returning
 	if err := validateConfig(cfg, overlayPath, &meta); err != nil {
@@ -433,6 +613,52 @@ func (c *Config) ResolveCommand(ref string) string {
 	return ref
 }
 
+// normalizeModifiers lowercases custom modifier names and resolves aliases in
+// the keys they are declared on (e.g. hyper = "CapsLock" -> "capslock").
+func normalizeModifiers(raw map[string]string) map[string]string {
+	if len(raw) == 0 {
+		return nil
+	}
+	modifiers := make(map[string]string, len(raw))
+	for name, key := range raw {
+		modifiers[strings.ToLower(strings.TrimSpace(name))] = normalizeKey(key)
+	}
+	return modifiers
+}
+
+// IsModifier returns true for a built-in modifier name or a custom modifier
+// declared in [modifiers].
+func (c *Config) IsModifier(name string) bool {
+	if isModifierName(name) {
+		return true
+	}
+	_, ok := c.Modifiers[name]
+	return ok
+}
+
+// ResolveKey resolves a key name to its key code, mapping a custom modifier
+// name to the key it is declared on (e.g. "hyper" -> capslock).
+func (c *Config) ResolveKey(name string) (uint16, bool) {
+	if key, ok := c.Modifiers[name]; ok {
+		name = key
+	}
+	return keys.ResolveKeyCode(name)
+}
+
+// customModifierName returns the custom modifier a combo part stands for,
+// given either its declared name or the key it is declared on.
+func customModifierName(custom map[string]string, part string) (string, bool) {
+	if _, ok := custom[part]; ok {
+		return part, true
+	}
+	for name, key := range custom {
+		if key == part {
+			return name, true
+		}
+	}
+	return "", false
+}
+
 // normalizeKey converts key name aliases to their canonical form
 func normalizeKey(key string) string {
 	key = strings.ToLower(strings.TrimSpace(key))
@@ -472,30 +698,46 @@ func normalizeKey(key string) string {
 	return key
 }
 
+// NormalizeCombo returns combo as the ParsedShortcuts key it names, so
+// "Shift+Ctrl+T" finds the shortcut written as "ctrl+shift+t".
+func (c *Config) NormalizeCombo(combo string) string {
+	return normalizeKeyCombo(combo, c.Modifiers)
+}
+
 // normalizeKeyCombo normalizes all keys in a combo string and reorders modifiers
-// into canonical order: super → ctrl → alt → shift → key
-func normalizeKeyCombo(combo string) string {
+// into canonical order: super → ctrl → alt → shift → custom modifiers → key.
+// Custom modifiers are named as declared (with hyper = "capslock", "capslock+h"
+// is "hyper+h") and sorted. Two or more regular keys form a chord and are
+// sorted, so "k+j" and "j+k" name the same chord.
+func normalizeKeyCombo(combo string, custom map[string]string) string {
 	parts := strings.Split(combo, "+")
 
 	// Normalize each part
 	for i, part := range parts {
 		parts[i] = normalizeKey(part)
+		if name, ok := customModifierName(custom, parts[i]); ok {
+			parts[i] = name
+		}
 	}
 
-	// Separate modifiers from regular key
+	// Separate modifiers from regular keys
 	var modifiers []string
-	var regularKey string
+	var customModifiers []string
+	var regularKeys []string
 
 	for _, part := range parts {
-		switch part {
-		case "super", "ctrl", "alt", "shift":
+		if isModifierName(part) {
 			modifiers = append(modifiers, part)
-		default:
-			regularKey = part
+		} else if _, ok := custom[part]; ok {
+			if !slices.Contains(customModifiers, part) {
+				customModifiers = append(customModifiers, part)
+			}
+		} else if part != "" && !slices.Contains(regularKeys, part) {
+			regularKeys = append(regularKeys, part)
 		}
 	}
 
-	// Build result in canonical order: super → ctrl → alt → shift → key
+	// Build result in canonical order: super → ctrl → alt → shift → custom → key
 	var result []string
 	for _, mod := range []string{"super", "ctrl", "alt", "shift"} {
 		for _, m := range modifiers {
@@ -505,19 +747,68 @@ func normalizeKeyCombo(combo string) string {
 			}
 		}
 	}
+	slices.Sort(customModifiers)
+	result = append(result, customModifiers...)
 
-	// Append regular key last (if present)
-	if regularKey != "" {
-		result = append(result, regularKey)
-	}
+	// Append regular keys last (if present)
+	slices.Sort(regularKeys)
+	result = append(result, regularKeys...)
 
 	return strings.Join(result, "+")
 }
 
+// isModifierName returns true for the canonical modifier names
+func isModifierName(name string) bool {
+	switch name {
+	case "super", "ctrl", "alt", "shift":
+		return true
+	}
+	return false
+}
+
+// isChord returns true if a normalized combo names two or more regular keys
+func isChord(combo string, custom map[string]string) bool {
+	regular := 0
+	for _, part := range strings.Split(combo, "+") {
+		if _, ok := custom[part]; !ok && !isModifierName(part) {
+			regular++
+		}
+	}
+	return regular > 1
+}
+
+// hasCustomModifier returns true if a normalized combo includes a custom modifier
+func hasCustomModifier(combo string, custom map[string]string) bool {
+	for _, part := range strings.Split(combo, "+") {
+		if _, ok := custom[part]; ok {
+			return true
+		}
+	}
+	return false
+}
+
+// ChordWith returns the chord formed by adding key to a modifier-free combo,
+// in canonical order, or "" if key is already part of it.
+// Example: ChordWith("k", "j") -> "j+k"
+func ChordWith(combo, key string) string {
+	parts := strings.Split(combo, "+")
+	if slices.Contains(parts, key) {
+		return ""
+	}
+	parts = append(parts, key)
+	slices.Sort(parts)
+	return strings.Join(parts, "+")
+}
+
 // ParseShortcut parses a shortcut key with dot syntax into a ParsedShortcut
 // Format: "keycombo[.behavior][.timing]"
 // Examples: "super+k", "super+k.whileheld", "super+k.repeat-whileheld(100).onrelease"
 func ParseShortcut(key string, value interface{}) (*ParsedShortcut, error) {
+	return parseShortcut(key, value, nil)
+}
+
+// parseShortcut is ParseShortcut with the custom modifiers declared in [modifiers]
+func parseShortcut(key string, value interface{}, custom map[string]string) (*ParsedShortcut, error) {
 	parts := strings.Split(key, ".")
 	if len(parts) == 0 {
 		return nil, fmt.Errorf("empty shortcut key")
@@ -543,7 +834,7 @@ func ParseShortcut(key string, value interface{}) (*ParsedShortcut, error) {
 	}
 
 	shortcut := &ParsedShortcut{
-		KeyCombo:    normalizeKeyCombo(combo),
+		KeyCombo:    normalizeKeyCombo(combo, custom),
 		Behavior:    BehaviorNormal,
 		Timing:      TimingPress,
 		Interval:    0, // 0 means use default
@@ -552,30 +843,41 @@ func ParseShortcut(key string, value interface{}) (*ParsedShortcut, error) {
 		Sensitivity: 0, // 0 means use default
 	}
 
-	// Parse value (string or array)
+	// Parse value (string, { exec = [...] } or array of either)
 	switch v := value.(type) {
-	case string:
-		shortcut.Commands = []string{v}
+	case string, map[string]interface{}:
+		cmd, err := parseCommandValue(v)
+		if err != nil {
+			return nil, err
+		}
+		shortcut.Commands = []string{cmd}
 	case []interface{}:
 		commands := make([]string, len(v))
-		for i, cmd := range v {
-			if s, ok := cmd.(string); ok {
-				commands[i] = s
-			} else {
-				return nil, fmt.Errorf("array value must contain strings")
+		for i, item := range v {
+			cmd, err := parseCommandValue(item)
+			if err != nil {
+				return nil, err
 			}
+			commands[i] = cmd
 		}
 		shortcut.Commands = commands
 	default:
-		return nil, fmt.Errorf("value must be string or array of strings")
+		return nil, fmt.Errorf("value must be string or array of strings, or an { exec = [...] } table")
 	}
 
 	// Parse modifiers (behavior and timing)
-	intervalRegex := regexp.MustCompile(`^(hold|longpress|doubletap|holdrelease)\((\d+\.?\d*|\d*\.\d+)\)$`)
+	intervalRegex := regexp.MustCompile(`^(hold|longpress|doubletap|holdrelease|tapmod)\((\d+\.?\d*|\d*\.\d+)\)$`)
 	tapHoldRegex := regexp.MustCompile(`^tap(?:\((\d+\.?\d*|\d*\.\d+)\))?hold(?:\((\d+\.?\d*|\d*\.\d+)\))?$`)
 	tapLongPressRegex := regexp.MustCompile(`^tap(?:\((\d+\.?\d*|\d*\.\d+)\))?longpress(?:\((\d+\.?\d*|\d*\.\d+)\))?$`)
 	tapPressReleaseRegex := regexp.MustCompile(`^tap(?:\((\d+\.?\d*|\d*\.\d+)\))?pressrelease$`)
 	tapHoldReleaseRegex := regexp.MustCompile(`^tap(?:\((\d+\.?\d*|\d*\.\d+)\))?holdrelease(?:\((\d+\.?\d*|\d*\.\d+)\))?$`)
+	multiTapRegex := regexp.MustCompile(`^tap\((\d+)(?:,\s*(\d+\.?\d*|\d*\.\d+))?\)$`)
+	tapRunRegex := regexp.MustCompile(`^((?:tap){2,})(?:\((\d+\.?\d*|\d*\.\d+)\))?$`)
+	cooldownRegex := regexp.MustCompile(`^cooldown\((\d+\.?\d*|\d*\.\d+)(,\s*forward)?\)$`)
+	rateLimitRegex := regexp.MustCompile(`^ratelimit\((\d+)/(\d+\.?\d*|\d*\.\d+)(ms|s|m)?(,\s*forward)?\)$`)
+	toggleProcessRegex := regexp.MustCompile(`^toggleprocess(?:\((\w+)(?:,\s*(\d+\.?\d*|\d*\.\d+))?\))?$`)
+	timeoutRegex := regexp.MustCompile(`^timeout\((\d+\.?\d*|\d*\.\d+)(ms|s|m)?\)$`)
+	switchResetRegex := regexp.MustCompile(`^switch\(reset=(\d+\.?\d*|\d*\.\d+)\)$`)
 
 	for i := 1; i < len(parts); i++ {
 		part := strings.ToLower(parts[i])
@@ -632,6 +934,85 @@ func ParseShortcut(key string, value interface{}) (*ParsedShortcut, error) {
 			continue
 		}
 
+		// Check for N-tap with optional interval: tap(N), tap(N, ms)
+		if matches := multiTapRegex.FindStringSubmatch(part); matches != nil {
+			taps, _ := strconv.Atoi(matches[1])
+			if err := setTapCount(shortcut, taps, matches[2]); err != nil {
+				return nil, err
+			}
+			continue
+		}
+
+		// Check for N-tap spelled out, one "tap" per press: taptaptap, taptaptap(N)
+		if matches := tapRunRegex.FindStringSubmatch(part); matches != nil {
+			if err := setTapCount(shortcut, len(matches[1])/len("tap"), matches[2]); err != nil {
+				return nil, err
+			}
+			continue
+		}
+
+		// Check for cooldown: cooldown(N), cooldown(N, forward)
+		if matches := cooldownRegex.FindStringSubmatch(part); matches != nil {
+			if shortcut.RateLimit > 0 {
+				return nil, fmt.Errorf("use either .cooldown or .ratelimit, not both")
+			}
+			window, _ := strconv.ParseFloat(matches[1], 64)
+			shortcut.RateLimit = 1
+			shortcut.RateWindow = normalizeInterval(window)
+			shortcut.ThrottleForward = matches[2] != ""
+			continue
+		}
+
+		// Check for rate limit: ratelimit(N/window), ratelimit(N/window, forward)
+		if matches := rateLimitRegex.FindStringSubmatch(part); matches != nil {
+			if shortcut.RateLimit > 0 {
+				return nil, fmt.Errorf("use either .cooldown or .ratelimit, not both")
+			}
+			limit, _ := strconv.Atoi(matches[1])
+			if limit < 1 {
+				return nil, fmt.Errorf("ratelimit must allow at least 1 fire per window")
+			}
+			window, _ := strconv.ParseFloat(matches[2], 64)
+			shortcut.RateLimit = limit
+			shortcut.RateWindow = intervalWithUnit(window, matches[3])
+			shortcut.ThrottleForward = matches[4] != ""
+			continue
+		}
+
+		// Check for process toggle: toggleprocess, toggleprocess(signal), toggleprocess(signal, N)
+		if matches := toggleProcessRegex.FindStringSubmatch(part); matches != nil {
+			shortcut.ToggleProcess = true
+			shortcut.StopSignal = syscall.SIGINT
+			shortcut.StopTimeout = defaultStopTimeoutMs
+			if matches[1] != "" {
+				sig, ok := stopSignals[strings.TrimPrefix(matches[1], "sig")]
+				if !ok {
+					return nil, fmt.Errorf("unknown signal %q for toggleprocess", matches[1])
+				}
+				shortcut.StopSignal = sig
+			}
+			if matches[2] != "" {
+				timeout, _ := strconv.ParseFloat(matches[2], 64)
+				shortcut.StopTimeout = normalizeInterval(timeout)
+			}
+			continue
+		}
+
+		// Check for command timeout: timeout(N), timeout(Nms|Ns|Nm)
+		if matches := timeoutRegex.FindStringSubmatch(part); matches != nil {
+			timeout, _ := strconv.ParseFloat(matches[1], 64)
+			shortcut.Timeout = intervalWithUnit(timeout, matches[2])
+			continue
+		}
+
+		// Check for switch with inactivity reset: switch(reset=N)
+		if matches := switchResetRegex.FindStringSubmatch(part); matches != nil {
+			reset, _ := strconv.ParseFloat(matches[1], 64)
+			shortcut.Behavior = BehaviorSwitch
+			shortcut.SwitchReset = normalizeInterval(reset)
+			continue
+		}
+
 		// Check for interval notation: hold(N), longpress(N), doubletap(N)
 		if matches := intervalRegex.FindStringSubmatch(part); matches != nil {
 			modifierName := matches[1]
@@ -646,6 +1027,8 @@ func ParseShortcut(key string, value interface{}) (*ParsedShortcut, error) {
 				shortcut.Behavior = BehaviorDoubleTap
 			case "holdrelease":
 				shortcut.Behavior = BehaviorHoldRelease
+			case "tapmod":
+				shortcut.Behavior = BehaviorTapMod
 			}
 			shortcut.Interval = normalizeInterval(interval)
 			continue
@@ -661,6 +1044,8 @@ func ParseShortcut(key string, value interface{}) (*ParsedShortcut, error) {
 			shortcut.Behavior = BehaviorLongPress
 		case "repeat":
 			shortcut.Repeat = true
+		case "autorepeat":
+			shortcut.AutoRepeat = true
 		case "switch":
 			shortcut.Behavior = BehaviorSwitch
 		case "doubletap":
@@ -671,6 +1056,8 @@ func ParseShortcut(key string, value interface{}) (*ParsedShortcut, error) {
 			shortcut.Behavior = BehaviorTapPressRelease
 		case "tapholdrelease":
 			shortcut.Behavior = BehaviorTapHoldRelease
+		case "tapmod":
+			shortcut.Behavior = BehaviorTapMod
 		case "onrelease":
 			return nil, fmt.Errorf("onrelease removed: use .pressrelease = [\"\", \"cmd\"]")
 		case "onpress":
@@ -678,6 +1065,11 @@ func ParseShortcut(key string, value interface{}) (*ParsedShortcut, error) {
 			shortcut.ExplicitOnPress = true
 		case "passthrough":
 			shortcut.Passthrough = true
+		case "single", "restart", "queue":
+			if shortcut.Policy != PolicyNone {
+				return nil, fmt.Errorf("use only one of .single, .restart and .queue")
+			}
+			shortcut.Policy = processPolicies[part]
 		default:
 			return nil, fmt.Errorf("unknown modifier: %s", part)
 		}
@@ -687,6 +1079,60 @@ func ParseShortcut(key string, value interface{}) (*ParsedShortcut, error) {
 	return shortcut, nil
 }
 
+// defaultStopTimeoutMs is how long a .toggleprocess stop waits before SIGKILL.
+const defaultStopTimeoutMs = 5000.0
+
+// stopSignals are the signals .toggleprocess accepts, by name without "sig".
+var stopSignals = map[string]syscall.Signal{
+	"int":  syscall.SIGINT,
+	"term": syscall.SIGTERM,
+	"hup":  syscall.SIGHUP,
+	"quit": syscall.SIGQUIT,
+	"usr1": syscall.SIGUSR1,
+	"usr2": syscall.SIGUSR2,
+	"kill": syscall.SIGKILL,
+}
+
+var processPolicies = map[string]ProcessPolicy{
+	"single":  PolicySingle,
+	"restart": PolicyRestart,
+	"queue":   PolicyQueue,
+}
+
+// intervalWithUnit converts a .ratelimit window or .timeout to milliseconds.
+// Without a unit it is an interval like any other (values < 10 are seconds).
+func intervalWithUnit(value float64, unit string) float64 {
+	switch unit {
+	case "ms":
+		return value
+	case "s":
+		return value * 1000
+	case "m":
+		return value * 60 * 1000
+	default:
+		return normalizeInterval(value)
+	}
+}
+
+// setTapCount applies an N-tap trigger with an optional tap window. Two taps
+// is plain doubletap; three or more is multitap.
+func setTapCount(shortcut *ParsedShortcut, taps int, interval string) error {
+	switch {
+	case taps < 2:
+		return fmt.Errorf("tap(%d) needs at least 2 taps", taps)
+	case taps == 2:
+		shortcut.Behavior = BehaviorDoubleTap
+	default:
+		shortcut.Behavior = BehaviorMultiTap
+		shortcut.TapCount = taps
+	}
+	if interval != "" {
+		value, _ := strconv.ParseFloat(interval, 64)
+		shortcut.Interval = normalizeInterval(value)
+	}
+	return nil
+}
+
 func behaviorName(b BehaviorMode) string {
 	switch b {
 	case BehaviorNormal:
@@ -711,19 +1157,39 @@ func behaviorName(b BehaviorMode) string {
 		return "tappressrelease"
 	case BehaviorTapHoldRelease:
 		return "tapholdrelease"
+	case BehaviorTapMod:
+		return "tapmod"
+	case BehaviorMultiTap:
+		return "multitap"
 	default:
 		return "unknown"
 	}
 }
 
+// String returns the modifier name of the behavior ("hold", "doubletap", ...).
+func (b BehaviorMode) String() string {
+	return behaviorName(b)
+}
+
 // buildEscapeMap creates a map of combo prefixes that have child escape hatches.
 // For "super+w", marks "super" -> true. For "super+shift+b", marks both "super" and "super+shift" -> true.
-func buildEscapeMap(shortcuts map[string][]*ParsedShortcut) map[string]bool {
+func buildEscapeMap(shortcuts map[string][]*ParsedShortcut, custom map[string]string) map[string]bool {
 	escapeMap := make(map[string]bool)
 	for combo, shortcutList := range shortcuts {
 		if len(shortcutList) > 0 && shortcutList[0].Direction != "" {
 			continue
 		}
+		// Chord members are not prefixes: "j+k" must not withhold "j" as an escape hatch
+		if isChord(combo, custom) {
+			continue
+		}
+		// A custom modifier is withheld whenever it is part of a combo, whatever
+		// modifiers were pressed before it (e.g. "super+hyper+h" marks "hyper")
+		for _, part := range strings.Split(combo, "+") {
+			if _, ok := custom[part]; ok && part != combo {
+				escapeMap[part] = true
+			}
+		}
 		// Find last '+' and extract prefix
 		lastPlus := strings.LastIndex(combo, "+")
 		if lastPlus == -1 {
@@ -745,6 +1211,32 @@ func buildEscapeMap(shortcuts map[string][]*ParsedShortcut) map[string]bool {
 	return escapeMap
 }
 
+// buildChordMap marks every partial chord so its keys are withheld while the rest
+// of the chord may still arrive. For "j+k+l", marks "j", "k", "l", "j+k", "j+l" and "k+l".
+func buildChordMap(shortcuts map[string][]*ParsedShortcut, custom map[string]string) map[string]bool {
+	chordMap := make(map[string]bool)
+	for combo, shortcutList := range shortcuts {
+		if len(shortcutList) > 0 && shortcutList[0].Direction != "" {
+			continue
+		}
+		if !isChord(combo, custom) {
+			continue
+		}
+		members := strings.Split(combo, "+") // already sorted by normalizeKeyCombo
+		// Every non-empty proper subset, selected by bitmask
+		for mask := 1; mask < 1<<len(members)-1; mask++ {
+			var subset []string
+			for i, member := range members {
+				if mask&(1<<i) != 0 {
+					subset = append(subset, member)
+				}
+			}
+			chordMap[strings.Join(subset, "+")] = true
+		}
+	}
+	return chordMap
+}
+
 // scrollAliasTargets are KeyCodeMap entries that resolve to REL codes for scroll/wheel remap
 // output (see keys.KeyCodeMap), not real keys. They must stay on the existing one-shot
 // emitScrollWheel path and never enter RemapTable, which expects EV_KEY targets.
@@ -767,9 +1259,21 @@ func (c *Config) buildRemapTable() map[string]string {
 		if s.Direction != "" {
 			continue
 		}
+		// Chords and keys that may start one resolve in the ladder, not at input
+		if isChord(combo, c.Modifiers) || c.ChordMap[combo] {
+			continue
+		}
+		// Custom modifiers are withheld by their ladder, which must see the combo key
+		if hasCustomModifier(combo, c.Modifiers) {
+			continue
+		}
 		if s.Behavior != BehaviorNormal || s.ExplicitOnPress || s.Repeat {
 			continue
 		}
+		// Rate limits are enforced when the ladder fires
+		if s.RateLimit > 0 {
+			continue
+		}
 		if len(s.Commands) != 1 {
 			continue
 		}
@@ -792,6 +1296,38 @@ func (c *Config) buildRemapTable() map[string]string {
 	return remapTable
 }
 
+// buildDualRoleTable collects .tapmod keys with both roles resolved to their
+// remap target names.
+func (c *Config) buildDualRoleTable() map[string]DualRole {
+	dualRoles := make(map[string]DualRole)
+	for combo, shortcutList := range c.ParsedShortcuts {
+		for _, s := range shortcutList {
+			if s.Behavior != BehaviorTapMod || len(s.Commands) != 2 {
+				continue
+			}
+			tap, tapOK := remapTarget(s.Commands[0])
+			hold, holdOK := remapTarget(s.Commands[1])
+			if !tapOK || !holdOK {
+				continue
+			}
+			interval := s.Interval
+			if interval == 0 {
+				interval = c.Settings.DefaultInterval
+			}
+			dualRoles[combo] = DualRole{Tap: tap, Hold: hold, Interval: interval}
+		}
+	}
+	return dualRoles
+}
+
+// remapTarget returns the target of a plain ">target" remap command
+func remapTarget(cmd string) (string, bool) {
+	if !strings.HasPrefix(cmd, ">") || strings.HasPrefix(cmd, ">>") || len(cmd) == 1 {
+		return "", false
+	}
+	return cmd[1:], true
+}
+
 func GetConfigDir() (string, error) {
 	if xdgConfig := os.Getenv("XDG_CONFIG_HOME"); xdgConfig != "" {
 		return filepath.Join(xdgConfig, "akeyshually"), nil
@@ -803,6 +1339,18 @@ func GetConfigDir() (string, error) {
 	return filepath.Join(home, ".config", "akeyshually"), nil
 }
 
+// GetStateDir returns the directory for runtime records such as the command log.
+func GetStateDir() (string, error) {
+	if xdgState := os.Getenv("XDG_STATE_HOME"); xdgState != "" {
+		return filepath.Join(xdgState, "akeyshually"), nil
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to get home directory: %w", err)
+	}
+	return filepath.Join(home, ".local", "state", "akeyshually"), nil
+}
+
 func getConfigDir() (string, error) {
 	return GetConfigDir()
 }
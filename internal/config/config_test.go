@@ -1,12 +1,17 @@
 package config
 
 import (
+	"os"
+	"path/filepath"
+	"syscall"
 	"testing"
+
+	"github.com/deprecatedluar/akeyshually/internal/keys"
 )
 
 func TestAliasGroupParsing(t *testing.T) {
 	dst := make(map[string][]*ParsedShortcut)
-	err := parseShortcutsInto(dst, "f6/f7/f8.switch", []interface{}{"cmd1", "cmd2", "cmd3"})
+	err := parseShortcutsInto(dst, "f6/f7/f8.switch", []interface{}{"cmd1", "cmd2", "cmd3"}, nil)
 	if err != nil {
 		t.Fatalf("parseShortcutsInto error: %v", err)
 	}
@@ -34,7 +39,7 @@ func TestAliasGroupParsing(t *testing.T) {
 
 func TestNonAliasSwitchHasNoGroup(t *testing.T) {
 	dst := make(map[string][]*ParsedShortcut)
-	err := parseShortcutsInto(dst, "f1.switch", []interface{}{"cmd1", "cmd2"})
+	err := parseShortcutsInto(dst, "f1.switch", []interface{}{"cmd1", "cmd2"}, nil)
 	if err != nil {
 		t.Fatalf("parseShortcutsInto error: %v", err)
 	}
@@ -52,13 +57,13 @@ func TestMergeDeduplicatesDevices(t *testing.T) {
 		Shortcuts:       map[string]interface{}{"f1": "echo base"},
 		Commands:        make(map[string]string),
 		ParsedShortcuts: make(map[string][]*ParsedShortcut),
-		Settings:        Settings{Devices: []string{"Huion"}},
+		Settings:        Settings{Devices: []DeviceSelector{{Name: "Huion"}}},
 	}
 
 	overlay := &Config{
 		Shortcuts: make(map[string]interface{}),
 		Commands:  make(map[string]string),
-		Settings:  Settings{Devices: []string{"huion", "Xbox Controller"}}, // "huion" duplicates "Huion"
+		Settings:  Settings{Devices: []DeviceSelector{{Name: "huion"}, {Name: "Xbox Controller"}}}, // "huion" duplicates "Huion"
 	}
 
 	base.Merge(overlay)
@@ -70,7 +75,7 @@ func TestMergeDeduplicatesDevices(t *testing.T) {
 
 func TestRemapParsing(t *testing.T) {
 	dst := make(map[string][]*ParsedShortcut)
-	if err := parseShortcutsInto(dst, "btn_0", ">ctrl+z"); err != nil {
+	if err := parseShortcutsInto(dst, "btn_0", ">ctrl+z", nil); err != nil {
 		t.Fatalf("remap shortcut should parse without error: %v", err)
 	}
 	s := dst["btn_0"][0]
@@ -81,7 +86,7 @@ func TestRemapParsing(t *testing.T) {
 
 func TestBuildRemapTableIncludesEligibleShortcut(t *testing.T) {
 	dst := make(map[string][]*ParsedShortcut)
-	if err := parseShortcutsInto(dst, "capslock", ">super"); err != nil {
+	if err := parseShortcutsInto(dst, "capslock", ">super", nil); err != nil {
 		t.Fatalf("parseShortcutsInto error: %v", err)
 	}
 	cfg := &Config{ParsedShortcuts: dst}
@@ -97,7 +102,7 @@ func TestBuildRemapTableIncludesEligibleShortcut(t *testing.T) {
 // RemapTable, which treats every target as an EV_KEY code to translate.
 func TestBuildRemapTableExcludesScrollAliases(t *testing.T) {
 	dst := make(map[string][]*ParsedShortcut)
-	if err := parseShortcutsInto(dst, "f5", ">scrollup"); err != nil {
+	if err := parseShortcutsInto(dst, "f5", ">scrollup", nil); err != nil {
 		t.Fatalf("parseShortcutsInto error: %v", err)
 	}
 	cfg := &Config{ParsedShortcuts: dst}
@@ -113,10 +118,10 @@ func TestBuildRemapTableExcludesScrollAliases(t *testing.T) {
 // which trigger actually fires.
 func TestBuildRemapTableExcludesCompetingTriggers(t *testing.T) {
 	dst := make(map[string][]*ParsedShortcut)
-	if err := parseShortcutsInto(dst, "capslock", ">super"); err != nil {
+	if err := parseShortcutsInto(dst, "capslock", ">super", nil); err != nil {
 		t.Fatalf("parseShortcutsInto error: %v", err)
 	}
-	if err := parseShortcutsInto(dst, "capslock.doubletap", "notify-send test"); err != nil {
+	if err := parseShortcutsInto(dst, "capslock.doubletap", "notify-send test", nil); err != nil {
 		t.Fatalf("parseShortcutsInto error: %v", err)
 	}
 	cfg := &Config{ParsedShortcuts: dst}
@@ -129,7 +134,7 @@ func TestBuildRemapTableExcludesCompetingTriggers(t *testing.T) {
 
 func TestBuildRemapTableExcludesExplicitOnPress(t *testing.T) {
 	dst := make(map[string][]*ParsedShortcut)
-	if err := parseShortcutsInto(dst, "f2.onpress", ">lclick"); err != nil {
+	if err := parseShortcutsInto(dst, "f2.onpress", ">lclick", nil); err != nil {
 		t.Fatalf("parseShortcutsInto error: %v", err)
 	}
 	cfg := &Config{ParsedShortcuts: dst}
@@ -140,9 +145,35 @@ func TestBuildRemapTableExcludesExplicitOnPress(t *testing.T) {
 	}
 }
 
+func TestChatterFilterFor(t *testing.T) {
+	cfg := &Config{
+		Settings: Settings{ChatterFilterMs: 5},
+		Device: map[string]DeviceSettings{
+			"keychron":        {ChatterFilterMs: 15},
+			"keychron k2 pro": {ChatterFilterMs: 25},
+			"remote":          {ChatterFilterMs: 0},
+		},
+	}
+
+	tests := []struct {
+		device string
+		want   float64
+	}{
+		{"Keychron K8", 15},
+		{"Keychron K2 Pro Keyboard", 25}, // longest match wins
+		{"BT Remote Control", 0},         // device entry can turn the filter off
+		{"AT Translated Set 2 keyboard", 5},
+	}
+	for _, tt := range tests {
+		if got := cfg.ChatterFilterFor(tt.device); got != tt.want {
+			t.Errorf("ChatterFilterFor(%q) = %v, want %v", tt.device, got, tt.want)
+		}
+	}
+}
+
 func TestDevicesFieldParses(t *testing.T) {
 	dst := make(map[string][]*ParsedShortcut)
-	err := parseShortcutsInto(dst, "btn_south", "notify-send test")
+	err := parseShortcutsInto(dst, "btn_south", "notify-send test", nil)
 	if err != nil {
 		t.Fatalf("btn_south should parse without error: %v", err)
 	}
@@ -169,7 +200,7 @@ func TestPressReleaseParsing(t *testing.T) {
 }
 
 func TestPressReleaseSingleCommandRejected(t *testing.T) {
-	err := validateShortcutEntry("super+m.pressrelease", "mic-on", "test.toml", 0)
+	err := validateShortcutEntry("super+m.pressrelease", "mic-on", "test.toml", 0, nil)
 	if err == nil {
 		t.Fatal("expected error for single command, got nil")
 	}
@@ -186,7 +217,7 @@ func TestHoldBehavior(t *testing.T) {
 }
 
 func TestHoldTwoCommandsRejected(t *testing.T) {
-	err := validateShortcutEntry("super+h.hold", []interface{}{"start", "stop"}, "test.toml", 0)
+	err := validateShortcutEntry("super+h.hold", []interface{}{"start", "stop"}, "test.toml", 0, nil)
 	if err == nil {
 		t.Fatal("expected error for 2-command hold, got nil")
 	}
@@ -313,8 +344,195 @@ func TestDoubleTapSwitchParsing(t *testing.T) {
 	}
 }
 
+func TestSwitchResetParsing(t *testing.T) {
+	ps, err := ParseShortcut("f1.switch(reset=3000)", []interface{}{"scene-a", "scene-b"})
+	if err != nil {
+		t.Fatalf("expected success, got: %v", err)
+	}
+	if ps.Behavior != BehaviorSwitch {
+		t.Errorf("Behavior = %v, want BehaviorSwitch", ps.Behavior)
+	}
+	if ps.SwitchReset != 3000 {
+		t.Errorf("SwitchReset = %v, want 3000", ps.SwitchReset)
+	}
+
+	ps, err = ParseShortcut("f1.switch(reset=3)", []interface{}{"scene-a", "scene-b"})
+	if err != nil {
+		t.Fatalf("expected success, got: %v", err)
+	}
+	if ps.SwitchReset != 3000 {
+		t.Errorf("SwitchReset = %v, want 3000 (seconds form)", ps.SwitchReset)
+	}
+}
+
+func TestToggleProcessParsing(t *testing.T) {
+	tests := []struct {
+		key     string
+		signal  syscall.Signal
+		timeout float64
+	}{
+		{"f9.toggleprocess", syscall.SIGINT, 5000},
+		{"f9.toggleprocess(sigterm)", syscall.SIGTERM, 5000},
+		{"f9.toggleprocess(INT, 3000)", syscall.SIGINT, 3000},
+	}
+	for _, tt := range tests {
+		ps, err := ParseShortcut(tt.key, "wf-recorder")
+		if err != nil {
+			t.Fatalf("%s: expected success, got: %v", tt.key, err)
+		}
+		if !ps.ToggleProcess || ps.StopSignal != tt.signal || ps.StopTimeout != tt.timeout {
+			t.Errorf("%s: got toggle=%v signal=%v timeout=%v, want signal=%v timeout=%v",
+				tt.key, ps.ToggleProcess, ps.StopSignal, ps.StopTimeout, tt.signal, tt.timeout)
+		}
+	}
+
+	if _, err := ParseShortcut("f9.toggleprocess(sigfoo)", "wf-recorder"); err == nil {
+		t.Error("unknown signal should be rejected")
+	}
+}
+
+func TestTimeoutParsing(t *testing.T) {
+	tests := []struct {
+		key  string
+		want float64
+	}{
+		{"super+w.timeout(5s)", 5000},
+		{"super+w.timeout(500ms)", 500},
+		{"super+w.timeout(2m)", 120000},
+		{"super+w.timeout(5)", 5000},
+		{"super+w.timeout(2500)", 2500},
+		{"super+w", 0},
+	}
+	for _, tt := range tests {
+		ps, err := ParseShortcut(tt.key, "fetch-weather")
+		if err != nil {
+			t.Fatalf("%s: expected success, got: %v", tt.key, err)
+		}
+		if ps.Timeout != tt.want {
+			t.Errorf("%s: Timeout = %v, want %v", tt.key, ps.Timeout, tt.want)
+		}
+	}
+}
+
+func TestMultiTapParsing(t *testing.T) {
+	tests := []struct {
+		key          string
+		wantBehavior BehaviorMode
+		wantTaps     int
+		wantInterval float64
+	}{
+		{"f1.tap(3)", BehaviorMultiTap, 3, 0},
+		{"f1.tap(4, 250)", BehaviorMultiTap, 4, 250},
+		{"f1.taptaptap", BehaviorMultiTap, 3, 0},
+		{"f1.taptaptaptap(300)", BehaviorMultiTap, 4, 300},
+		{"f1.tap(2)", BehaviorDoubleTap, 0, 0},
+		{"f1.taptap", BehaviorDoubleTap, 0, 0},
+	}
+	for _, tt := range tests {
+		ps, err := ParseShortcut(tt.key, "notify")
+		if err != nil {
+			t.Errorf("%s: expected success, got: %v", tt.key, err)
+			continue
+		}
+		if ps.Behavior != tt.wantBehavior || ps.TapCount != tt.wantTaps || ps.Interval != tt.wantInterval {
+			t.Errorf("%s: got behavior=%v taps=%d interval=%v, want %v/%d/%v",
+				tt.key, ps.Behavior, ps.TapCount, ps.Interval, tt.wantBehavior, tt.wantTaps, tt.wantInterval)
+		}
+	}
+
+	for _, key := range []string{"f1.tap(1)", "f1.tap(0)", "f1.tap"} {
+		if _, err := ParseShortcut(key, "notify"); err == nil {
+			t.Errorf("%s: expected error, got nil", key)
+		}
+	}
+}
+
+func TestRateLimitParsing(t *testing.T) {
+	tests := []struct {
+		key         string
+		wantLimit   int
+		wantWindow  float64
+		wantForward bool
+	}{
+		{"print.cooldown(1000)", 1, 1000, false},
+		{"print.cooldown(2)", 1, 2000, false},
+		{"print.cooldown(500, forward)", 1, 500, true},
+		{"super+q.ratelimit(5/10s)", 5, 10000, false},
+		{"super+q.ratelimit(3/500ms, forward)", 3, 500, true},
+		{"super+q.ratelimit(10/1m)", 10, 60000, false},
+		{"super+q.doubletap.cooldown(800)", 1, 800, false},
+	}
+	for _, tt := range tests {
+		ps, err := ParseShortcut(tt.key, "cmd")
+		if err != nil {
+			t.Errorf("%s: expected success, got: %v", tt.key, err)
+			continue
+		}
+		if ps.RateLimit != tt.wantLimit || ps.RateWindow != tt.wantWindow || ps.ThrottleForward != tt.wantForward {
+			t.Errorf("%s: got limit=%d window=%v forward=%v, want %d/%v/%v",
+				tt.key, ps.RateLimit, ps.RateWindow, ps.ThrottleForward, tt.wantLimit, tt.wantWindow, tt.wantForward)
+		}
+	}
+
+	for _, key := range []string{"print.ratelimit(0/10s)", "print.cooldown(500).ratelimit(2/1s)", "print.cooldown", "print.ratelimit(5)"} {
+		if _, err := ParseShortcut(key, "cmd"); err == nil {
+			t.Errorf("%s: expected error, got nil", key)
+		}
+	}
+}
+
+func TestLoadRateLimitKeys(t *testing.T) {
+	configPath := filepath.Join(t.TempDir(), "test.toml")
+	configContent := `[shortcuts]
+"super+q.ratelimit(5/10s)" = "kill-window"
+"f1/f2.ratelimit(2/1s, forward)" = "notify"
+`
+	if err := os.WriteFile(configPath, []byte(configContent), 0644); err != nil {
+		t.Fatalf("Failed to create test config: %v", err)
+	}
+
+	cfg, err := LoadFromPath(configPath)
+	if err != nil {
+		t.Fatalf("Failed to load config: %v", err)
+	}
+	for combo, want := range map[string]int{"super+q": 5, "f1": 2, "f2": 2} {
+		shortcuts := cfg.ParsedShortcuts[combo]
+		if len(shortcuts) != 1 || shortcuts[0].RateLimit != want {
+			t.Errorf("%s: got %+v, want one shortcut limited to %d", combo, shortcuts, want)
+		}
+	}
+}
+
+func TestMergeRateLimitKey(t *testing.T) {
+	base := &Config{
+		Shortcuts:       map[string]interface{}{"f1": "echo base"},
+		Commands:        make(map[string]string),
+		ParsedShortcuts: make(map[string][]*ParsedShortcut),
+	}
+	overlay := &Config{
+		Shortcuts: map[string]interface{}{"super+q.ratelimit(5/10s)": "kill-window"},
+		Commands:  make(map[string]string),
+	}
+
+	base.Merge(overlay)
+
+	if shortcuts := base.ParsedShortcuts["super+q"]; len(shortcuts) != 1 || shortcuts[0].RateWindow != 10000 {
+		t.Errorf("super+q: got %+v, want one shortcut with a 10s window", shortcuts)
+	}
+}
+
+func TestBuildRemapTableExcludesRateLimited(t *testing.T) {
+	cfg := &Config{ParsedShortcuts: make(map[string][]*ParsedShortcut)}
+	if err := parseShortcutsInto(cfg.ParsedShortcuts, "f4.cooldown(500)", ">a", nil); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := cfg.buildRemapTable()["f4"]; ok {
+		t.Error("rate-limited remap must resolve in the ladder, not at input")
+	}
+}
+
 func TestLongPressRepeatRejected(t *testing.T) {
-	err := validateShortcutEntry("super+h.longpress.repeat", "cmd", "test.toml", 0)
+	err := validateShortcutEntry("super+h.longpress.repeat", "cmd", "test.toml", 0, nil)
 	if err == nil {
 		t.Fatal("expected error for longpress.repeat, got nil")
 	}
@@ -374,3 +592,170 @@ func TestParseShortcutNormalizesAbsoluteAxisPrefix(t *testing.T) {
 		}
 	}
 }
+
+func TestHasGestures(t *testing.T) {
+	cfg := &Config{ParsedShortcuts: make(map[string][]*ParsedShortcut)}
+	if err := parseShortcutsInto(cfg.ParsedShortcuts, "super+k", "cmd", nil); err != nil {
+		t.Fatalf("parseShortcutsInto error: %v", err)
+	}
+	if cfg.HasGestures() {
+		t.Fatal("HasGestures() = true without gesture bindings")
+	}
+	if err := parseShortcutsInto(cfg.ParsedShortcuts, "super+swipe3_left", "cmd", nil); err != nil {
+		t.Fatalf("parseShortcutsInto error: %v", err)
+	}
+	if !cfg.HasGestures() {
+		t.Fatal("HasGestures() = false with super+swipe3_left bound")
+	}
+}
+
+func TestGestureDefaults(t *testing.T) {
+	s := Settings{GestureHoldTime: 1}
+	applyGestureDefaults(&s)
+	if s.GestureSwipeDistance != defaultGestureSwipePercent || s.GesturePinchDistance != defaultGesturePinchPercent {
+		t.Errorf("distances = %v/%v, want defaults", s.GestureSwipeDistance, s.GesturePinchDistance)
+	}
+	if s.GestureHoldTime != 1000 {
+		t.Errorf("GestureHoldTime = %v, want 1000 (1 normalized to seconds)", s.GestureHoldTime)
+	}
+}
+
+func TestChordParsing(t *testing.T) {
+	for _, key := range []string{"j+k", "k+j", "K+J"} {
+		parsed, err := ParseShortcut(key, "cmd")
+		if err != nil {
+			t.Fatalf("ParseShortcut(%q) error: %v", key, err)
+		}
+		if parsed.KeyCombo != "j+k" {
+			t.Errorf("ParseShortcut(%q) KeyCombo = %q, want j+k", key, parsed.KeyCombo)
+		}
+	}
+	if got := ChordWith("k", "j"); got != "j+k" {
+		t.Errorf("ChordWith(k, j) = %q, want j+k", got)
+	}
+	if got := ChordWith("j+k", "k"); got != "" {
+		t.Errorf("ChordWith(j+k, k) = %q, want empty", got)
+	}
+}
+
+func TestBuildChordMap(t *testing.T) {
+	dst := make(map[string][]*ParsedShortcut)
+	for _, key := range []string{"j+k+l", "super+w", "x"} {
+		if err := parseShortcutsInto(dst, key, "cmd", nil); err != nil {
+			t.Fatalf("parseShortcutsInto(%q, nil) error: %v", key, err)
+		}
+	}
+
+	chordMap := buildChordMap(dst, nil)
+	for _, partial := range []string{"j", "k", "l", "j+k", "j+l", "k+l"} {
+		if !chordMap[partial] {
+			t.Errorf("ChordMap[%q] = false, want true", partial)
+		}
+	}
+	for _, other := range []string{"j+k+l", "super", "w", "x"} {
+		if chordMap[other] {
+			t.Errorf("ChordMap[%q] = true, want false", other)
+		}
+	}
+}
+
+// Chord members must stay out of the escape map (or they would be withheld as
+// modifier prefixes) and out of RemapTable (or translation would bypass the chord).
+func TestChordExcludedFromEscapeMapAndRemapTable(t *testing.T) {
+	dst := make(map[string][]*ParsedShortcut)
+	if err := parseShortcutsInto(dst, "j+k", "cmd", nil); err != nil {
+		t.Fatalf("parseShortcutsInto error: %v", err)
+	}
+	if err := parseShortcutsInto(dst, "j", ">escape", nil); err != nil {
+		t.Fatalf("parseShortcutsInto error: %v", err)
+	}
+	cfg := &Config{ParsedShortcuts: dst}
+	cfg.EscapeMap = buildEscapeMap(dst, nil)
+	cfg.ChordMap = buildChordMap(dst, nil)
+
+	if cfg.EscapeMap["j"] {
+		t.Error("chord member j should not be an escape map prefix")
+	}
+	if _, ok := cfg.buildRemapTable()["j"]; ok {
+		t.Error("key that may start a chord should not enter RemapTable")
+	}
+}
+
+func TestCustomModifierParsing(t *testing.T) {
+	custom := map[string]string{"hyper": "capslock"}
+	tests := []struct {
+		key  string
+		want string
+	}{
+		{"capslock+h", "hyper+h"},
+		{"hyper+h", "hyper+h"},
+		{"h+hyper", "hyper+h"},
+		{"hyper+shift+h", "shift+hyper+h"},
+		{"capslock", "hyper"},
+	}
+	for _, tt := range tests {
+		parsed, err := parseShortcut(tt.key, "cmd", custom)
+		if err != nil {
+			t.Fatalf("parseShortcut(%q) error: %v", tt.key, err)
+		}
+		if parsed.KeyCombo != tt.want {
+			t.Errorf("parseShortcut(%q).KeyCombo = %q, want %q", tt.key, parsed.KeyCombo, tt.want)
+		}
+		if isChord(parsed.KeyCombo, custom) {
+			t.Errorf("%q should not be a chord", parsed.KeyCombo)
+		}
+	}
+}
+
+// Combos with a custom modifier resolve through its ladder: the modifier is an
+// escape prefix wherever it appears, and the combo stays out of RemapTable.
+func TestCustomModifierEscapeMapAndRemapTable(t *testing.T) {
+	custom := map[string]string{"hyper": "capslock"}
+	dst := make(map[string][]*ParsedShortcut)
+	for key, value := range map[string]string{"capslock+h": ">left", "super+capslock+l": "cmd"} {
+		if err := parseShortcutsInto(dst, key, value, custom); err != nil {
+			t.Fatalf("parseShortcutsInto error: %v", err)
+		}
+	}
+	cfg := &Config{ParsedShortcuts: dst, Modifiers: custom}
+	cfg.EscapeMap = buildEscapeMap(dst, custom)
+	cfg.ChordMap = buildChordMap(dst, custom)
+
+	for _, prefix := range []string{"hyper", "super", "super+hyper"} {
+		if !cfg.EscapeMap[prefix] {
+			t.Errorf("EscapeMap[%q] = false, want true", prefix)
+		}
+	}
+	if len(cfg.ChordMap) != 0 {
+		t.Errorf("ChordMap = %v, want empty", cfg.ChordMap)
+	}
+	if _, ok := cfg.buildRemapTable()["hyper+h"]; ok {
+		t.Error("combo with a custom modifier should not enter RemapTable")
+	}
+	capslock, _ := keys.ResolveKeyCode("capslock")
+	if code, ok := cfg.ResolveKey("hyper"); !ok || code != capslock {
+		t.Errorf("ResolveKey(hyper) = %d, %v; want %d", code, ok, capslock)
+	}
+}
+
+func TestBuildDualRoleTable(t *testing.T) {
+	dst := make(map[string][]*ParsedShortcut)
+	if err := parseShortcutsInto(dst, "f.tapmod", []interface{}{">f", ">super"}, nil); err != nil {
+		t.Fatalf("parseShortcutsInto error: %v", err)
+	}
+	if err := parseShortcutsInto(dst, "capslock.tapmod(200)", []interface{}{">esc", ">ctrl"}, nil); err != nil {
+		t.Fatalf("parseShortcutsInto error: %v", err)
+	}
+	cfg := &Config{ParsedShortcuts: dst, Settings: Settings{DefaultInterval: 150}}
+	table := cfg.buildDualRoleTable()
+
+	if got, want := table["f"], (DualRole{Tap: "f", Hold: "super", Interval: 150}); got != want {
+		t.Errorf("DualRoleTable[f] = %+v, want %+v", got, want)
+	}
+	if got, want := table["capslock"], (DualRole{Tap: "esc", Hold: "ctrl", Interval: 200}); got != want {
+		t.Errorf("DualRoleTable[capslock] = %+v, want %+v", got, want)
+	}
+	if len(cfg.buildRemapTable()) != 0 {
+		t.Error("tapmod keys should not enter RemapTable")
+	}
+}
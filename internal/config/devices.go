@@ -0,0 +1,247 @@
+package config
+
+import (
+	"fmt"
+	"path"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/deprecatedluar/akeyshually/internal/keys"
+)
+
+// DeviceSelector picks input devices for settings.devices and
+// settings.exclude_devices. A plain string is a case-insensitive name
+// substring; a table combines any of the fields below, all of which must match:
+//
+//	{ vendor = 0x256c, product = 0x006d, phys = "usb-0000:00:14.0-2/*" }
+type DeviceSelector struct {
+	Name         string         // name: case-insensitive substring of the device name
+	NameRegex    *regexp.Regexp // name_regex: regular expression on the device name
+	Vendor       *uint16        // vendor: vendor id
+	Product      *uint16        // product: product id
+	Phys         string         // phys: physical path, glob patterns allowed
+	Uniq         string         // uniq: unique id (serial number, Bluetooth address)
+	Path         string         // path: device node or /dev/input/by-id, by-path symlink
+	Capabilities []string       // capabilities: event types or codes the device must support
+
+	caps []keys.Capability
+}
+
+// DeviceIdentity is what a DeviceSelector is matched against.
+type DeviceIdentity struct {
+	Path         string // /dev/input/eventN
+	Name         string
+	Phys         string
+	Uniq         string
+	Vendor       uint16
+	Product      uint16
+	Capabilities map[uint16][]uint16 // event type -> supported codes
+}
+
+const deviceSelectorFields = "name, name_regex, vendor, product, phys, uniq, path or capabilities"
+
+// UnmarshalTOML reads a device name string or a selector table.
+func (s *DeviceSelector) UnmarshalTOML(value any) error {
+	switch v := value.(type) {
+	case string:
+		if v == "" {
+			return fmt.Errorf("device name cannot be empty")
+		}
+		*s = DeviceSelector{Name: v}
+		return nil
+	case map[string]any:
+		return s.parseTable(v)
+	default:
+		return fmt.Errorf("device selectors must be name strings or tables of %s", deviceSelectorFields)
+	}
+}
+
+func (s *DeviceSelector) parseTable(table map[string]any) error {
+	if len(table) == 0 {
+		return fmt.Errorf("empty device selector would match every device")
+	}
+	*s = DeviceSelector{}
+	for field, value := range table {
+		var err error
+		switch field {
+		case "name":
+			s.Name, err = selectorString(field, value)
+		case "name_regex":
+			var pattern string
+			if pattern, err = selectorString(field, value); err == nil {
+				if s.NameRegex, err = regexp.Compile(pattern); err != nil {
+					err = fmt.Errorf("invalid name_regex: %w", err)
+				}
+			}
+		case "vendor":
+			s.Vendor, err = selectorID(field, value)
+		case "product":
+			s.Product, err = selectorID(field, value)
+		case "phys":
+			if s.Phys, err = selectorString(field, value); err == nil {
+				if _, err = path.Match(s.Phys, ""); err != nil {
+					err = fmt.Errorf("invalid phys pattern %q: %w", s.Phys, err)
+				}
+			}
+		case "uniq":
+			s.Uniq, err = selectorString(field, value)
+		case "path":
+			s.Path, err = selectorString(field, value)
+		case "capabilities":
+			err = s.parseCapabilities(value)
+		default:
+			err = fmt.Errorf("unknown device field %q (want %s)", field, deviceSelectorFields)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *DeviceSelector) parseCapabilities(value any) error {
+	list, ok := value.([]any)
+	if !ok {
+		return fmt.Errorf("capabilities must be an array of event type or code names")
+	}
+	for _, item := range list {
+		name, ok := item.(string)
+		if !ok {
+			return fmt.Errorf("capabilities must be an array of event type or code names")
+		}
+		capability, ok := keys.ResolveCapability(name)
+		if !ok {
+			return fmt.Errorf("unknown capability %q (want an event type such as EV_ABS or a code such as KEY_VOLUMEUP)", name)
+		}
+		s.Capabilities = append(s.Capabilities, strings.ToUpper(name))
+		s.caps = append(s.caps, capability)
+	}
+	return nil
+}
+
+func selectorString(field string, value any) (string, error) {
+	s, ok := value.(string)
+	if !ok || s == "" {
+		return "", fmt.Errorf("%s must be a non-empty string", field)
+	}
+	return s, nil
+}
+
+func selectorID(field string, value any) (*uint16, error) {
+	n, ok := value.(int64)
+	if !ok || n < 0 || n > 0xffff {
+		return nil, fmt.Errorf("%s must be a number from 0 to 0xffff", field)
+	}
+	id := uint16(n)
+	return &id, nil
+}
+
+// Matches reports whether dev satisfies every field of the selector.
+func (s DeviceSelector) Matches(dev DeviceIdentity) bool {
+	if s.Name != "" && !strings.Contains(strings.ToLower(dev.Name), strings.ToLower(s.Name)) {
+		return false
+	}
+	if s.NameRegex != nil && !s.NameRegex.MatchString(dev.Name) {
+		return false
+	}
+	if s.Vendor != nil && *s.Vendor != dev.Vendor {
+		return false
+	}
+	if s.Product != nil && *s.Product != dev.Product {
+		return false
+	}
+	if s.Phys != "" {
+		if ok, _ := path.Match(s.Phys, dev.Phys); !ok {
+			return false
+		}
+	}
+	if s.Uniq != "" && s.Uniq != dev.Uniq {
+		return false
+	}
+	if s.Path != "" && !sameDeviceNode(s.Path, dev.Path) {
+		return false
+	}
+	for _, c := range s.caps {
+		codes, ok := dev.Capabilities[c.Type]
+		if !ok || (!c.AnyCode && !slices.Contains(codes, c.Code)) {
+			return false
+		}
+	}
+	return true
+}
+
+// sameDeviceNode reports whether the configured path (possibly a by-id or
+// by-path symlink) currently points at the device node.
+func sameDeviceNode(configured, node string) bool {
+	if configured == node {
+		return true
+	}
+	resolved, err := filepath.EvalSymlinks(configured)
+	return err == nil && resolved == node
+}
+
+// MatchDevice reports whether any of the selectors matches dev.
+func MatchDevice(selectors []DeviceSelector, dev DeviceIdentity) bool {
+	for _, s := range selectors {
+		if s.Matches(dev) {
+			return true
+		}
+	}
+	return false
+}
+
+// String returns the selector as written in the config: the name for a
+// plain name selector, a TOML inline table otherwise.
+func (s DeviceSelector) String() string {
+	var fields []string
+	if s.Name != "" {
+		fields = append(fields, fmt.Sprintf("name = %q", s.Name))
+	}
+	if s.NameRegex != nil {
+		fields = append(fields, fmt.Sprintf("name_regex = %q", s.NameRegex.String()))
+	}
+	if s.Vendor != nil {
+		fields = append(fields, fmt.Sprintf("vendor = 0x%04x", *s.Vendor))
+	}
+	if s.Product != nil {
+		fields = append(fields, fmt.Sprintf("product = 0x%04x", *s.Product))
+	}
+	if s.Phys != "" {
+		fields = append(fields, fmt.Sprintf("phys = %q", s.Phys))
+	}
+	if s.Uniq != "" {
+		fields = append(fields, fmt.Sprintf("uniq = %q", s.Uniq))
+	}
+	if s.Path != "" {
+		fields = append(fields, fmt.Sprintf("path = %q", s.Path))
+	}
+	if len(s.Capabilities) > 0 {
+		quoted := make([]string, len(s.Capabilities))
+		for i, c := range s.Capabilities {
+			quoted[i] = fmt.Sprintf("%q", c)
+		}
+		fields = append(fields, "capabilities = ["+strings.Join(quoted, ", ")+"]")
+	}
+	if len(fields) == 1 && s.Name != "" {
+		return s.Name
+	}
+	return "{ " + strings.Join(fields, ", ") + " }"
+}
+
+// mergeDeviceSelectors appends the overlay's selectors that base does not
+// have yet (names compared case-insensitively).
+func mergeDeviceSelectors(base, overlay []DeviceSelector) []DeviceSelector {
+	existing := make(map[string]bool, len(base))
+	for _, s := range base {
+		existing[strings.ToLower(s.String())] = true
+	}
+	for _, s := range overlay {
+		if key := strings.ToLower(s.String()); !existing[key] {
+			base = append(base, s)
+			existing[key] = true
+		}
+	}
+	return base
+}
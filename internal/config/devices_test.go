@@ -0,0 +1,123 @@
+package config
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/BurntSushi/toml"
+	evdev "github.com/holoplot/go-evdev"
+)
+
+func TestDeviceSelectorParsing(t *testing.T) {
+	var cfg Config
+	_, err := toml.Decode(`
+[settings]
+devices = [
+  "Huion",
+  { vendor = 0x256c, product = 0x006d, phys = "usb-0000:00:14.0-2/*" },
+  { name_regex = "^Xbox .*Controller$", capabilities = ["EV_ABS", "btn_south"] },
+]
+exclude_devices = [{ path = "/dev/input/by-id/usb-Yubico-event-kbd" }]
+`, &cfg)
+	if err != nil {
+		t.Fatalf("decode failed: %v", err)
+	}
+
+	devices := cfg.Settings.Devices
+	if len(devices) != 3 {
+		t.Fatalf("expected 3 device selectors, got %d", len(devices))
+	}
+	if got := devices[0].String(); got != "Huion" {
+		t.Errorf("plain selector String() = %q, want %q", got, "Huion")
+	}
+	if devices[1].Vendor == nil || *devices[1].Vendor != 0x256c || devices[1].Product == nil || *devices[1].Product != 0x006d {
+		t.Errorf("vendor/product not parsed: %s", devices[1])
+	}
+	if devices[2].NameRegex == nil || len(devices[2].caps) != 2 {
+		t.Errorf("name_regex/capabilities not parsed: %s", devices[2])
+	}
+	if len(cfg.Settings.ExcludeDevices) != 1 || cfg.Settings.ExcludeDevices[0].Path == "" {
+		t.Errorf("exclude_devices not parsed: %v", cfg.Settings.ExcludeDevices)
+	}
+}
+
+func TestDeviceSelectorRejectsInvalid(t *testing.T) {
+	tests := []struct {
+		entry string
+		want  string
+	}{
+		{`""`, "cannot be empty"},
+		{`42`, "must be name strings or tables"},
+		{`{}`, "would match every device"},
+		{`{ serial = "x" }`, "unknown device field"},
+		{`{ vendor = 0x10000 }`, "vendor must be a number"},
+		{`{ product = "006d" }`, "product must be a number"},
+		{`{ name_regex = "(" }`, "invalid name_regex"},
+		{`{ phys = "[" }`, "invalid phys pattern"},
+		{`{ capabilities = ["KEY_NOPE"] }`, "unknown capability"},
+		{`{ capabilities = "EV_ABS" }`, "must be an array"},
+	}
+	for _, tt := range tests {
+		var cfg Config
+		_, err := toml.Decode("[settings]\ndevices = ["+tt.entry+"]\n", &cfg)
+		if err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("devices = [%s]: error %v, want it to mention %q", tt.entry, err, tt.want)
+		}
+	}
+}
+
+func TestDeviceSelectorMatches(t *testing.T) {
+	left := DeviceIdentity{
+		Path:    "/dev/input/event5",
+		Name:    "Keychron K2",
+		Phys:    "usb-0000:00:14.0-1/input0",
+		Vendor:  0x05ac,
+		Product: 0x024f,
+		Capabilities: map[uint16][]uint16{
+			evdev.EV_KEY: {evdev.KEY_A, evdev.KEY_VOLUMEUP},
+			evdev.EV_REP: nil,
+		},
+	}
+	right := left
+	right.Path = "/dev/input/event9"
+	right.Phys = "usb-0000:00:14.0-2/input0"
+
+	vendor, product := uint16(0x05ac), uint16(0x024f)
+	tests := []struct {
+		name        string
+		sel         DeviceSelector
+		left, right bool
+	}{
+		{"name substring", DeviceSelector{Name: "keychron"}, true, true},
+		{"vendor and product", DeviceSelector{Vendor: &vendor, Product: &product}, true, true},
+		{"phys glob tells identical keyboards apart", DeviceSelector{Vendor: &vendor, Phys: "usb-0000:00:14.0-2/*"}, false, true},
+		{"path", DeviceSelector{Path: "/dev/input/event5"}, true, false},
+		{"uniq", DeviceSelector{Uniq: "AA:BB"}, false, false},
+	}
+	for _, tt := range tests {
+		if got := tt.sel.Matches(left); got != tt.left {
+			t.Errorf("%s: Matches(left) = %v, want %v", tt.name, got, tt.left)
+		}
+		if got := tt.sel.Matches(right); got != tt.right {
+			t.Errorf("%s: Matches(right) = %v, want %v", tt.name, got, tt.right)
+		}
+	}
+
+	var cfg Config
+	if _, err := toml.Decode(`
+[settings]
+devices = [
+  { capabilities = ["EV_REP", "KEY_VOLUMEUP"] },
+  { capabilities = ["EV_ABS"] },
+  { capabilities = ["KEY_MUTE"] },
+]
+`, &cfg); err != nil {
+		t.Fatalf("decode failed: %v", err)
+	}
+	want := []bool{true, false, false}
+	for i, sel := range cfg.Settings.Devices {
+		if got := sel.Matches(left); got != want[i] {
+			t.Errorf("%s: Matches = %v, want %v", sel, got, want[i])
+		}
+	}
+}
@@ -0,0 +1,91 @@
+package config
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// execPrefix marks a command written in argv form ({ exec = [...] }). The
+// arguments follow it NUL-separated, so the command travels through the same
+// string paths as shell commands and remaps (switch arrays, loops, policies).
+const execPrefix = "\x00"
+
+// ExecCommand encodes argv as an argv-form command.
+func ExecCommand(argv []string) string {
+	return execPrefix + strings.Join(argv, execPrefix)
+}
+
+// ExecArgv returns the arguments of an argv-form command, or false for a
+// shell command or remap.
+func ExecArgv(cmd string) ([]string, bool) {
+	if !strings.HasPrefix(cmd, execPrefix) {
+		return nil, false
+	}
+	return strings.Split(cmd[len(execPrefix):], execPrefix), true
+}
+
+// DisplayCommand returns cmd as written in a shell, for logs and history.
+func DisplayCommand(cmd string) string {
+	argv, ok := ExecArgv(cmd)
+	if !ok {
+		return cmd
+	}
+	quoted := make([]string, len(argv))
+	for i, arg := range argv {
+		if arg == "" || strings.ContainsAny(arg, " \t\n'\"\\$`&|;<>()*?[]#~") {
+			arg = strconv.Quote(arg)
+		}
+		quoted[i] = arg
+	}
+	return strings.Join(quoted, " ")
+}
+
+// parseCommandValue reads one command of a shortcut value: a shell command
+// or remap string, or an { exec = [...] } table.
+func parseCommandValue(value interface{}) (string, error) {
+	switch v := value.(type) {
+	case string:
+		return v, nil
+	case map[string]interface{}:
+		return parseExecTable(v)
+	default:
+		return "", fmt.Errorf("array value must contain strings or { exec = [...] } tables")
+	}
+}
+
+// parseExecTable reads { exec = ["program", "arg", ...] }. A single string is
+// a program without arguments.
+func parseExecTable(table map[string]interface{}) (string, error) {
+	for key := range table {
+		if key != "exec" {
+			return "", fmt.Errorf("unknown command field %q (only exec is supported)", key)
+		}
+	}
+
+	var argv []string
+	switch v := table["exec"].(type) {
+	case string:
+		argv = []string{v}
+	case []interface{}:
+		for _, arg := range v {
+			s, ok := arg.(string)
+			if !ok {
+				return "", fmt.Errorf("exec must contain strings")
+			}
+			argv = append(argv, s)
+		}
+	default:
+		return "", fmt.Errorf("exec must be an array of strings")
+	}
+
+	if len(argv) == 0 || argv[0] == "" {
+		return "", fmt.Errorf("exec needs a program to run")
+	}
+	for _, arg := range argv {
+		if strings.Contains(arg, execPrefix) {
+			return "", fmt.Errorf("exec arguments cannot contain NUL characters")
+		}
+	}
+	return ExecCommand(argv), nil
+}
@@ -0,0 +1,79 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+func TestExecCommandIntegration(t *testing.T) {
+	configPath := filepath.Join(t.TempDir(), "test.toml")
+	configContent := `[shortcuts]
+"super+t" = { exec = ["kitty", "--single-instance"] }
+"super+e.switch" = ["thunar", { exec = ["nautilus", "--new-window"] }]
+"f9.hold.repeat" = { exec = "brightnessctl" }
+`
+	if err := os.WriteFile(configPath, []byte(configContent), 0644); err != nil {
+		t.Fatalf("Failed to create test config: %v", err)
+	}
+
+	cfg, err := LoadFromPath(configPath)
+	if err != nil {
+		t.Fatalf("Failed to load config: %v", err)
+	}
+
+	tests := []struct {
+		combo string
+		index int
+		want  []string
+	}{
+		{"super+t", 0, []string{"kitty", "--single-instance"}},
+		{"super+e", 1, []string{"nautilus", "--new-window"}},
+		{"f9", 0, []string{"brightnessctl"}},
+	}
+	for _, tt := range tests {
+		shortcuts := cfg.ParsedShortcuts[tt.combo]
+		if len(shortcuts) != 1 {
+			t.Fatalf("%s: got %d shortcuts, want 1", tt.combo, len(shortcuts))
+		}
+		argv, ok := ExecArgv(shortcuts[0].Commands[tt.index])
+		if !ok || !slices.Equal(argv, tt.want) {
+			t.Errorf("%s: ExecArgv(Commands[%d]) = %q, %v, want %q", tt.combo, tt.index, argv, ok, tt.want)
+		}
+	}
+	if _, ok := ExecArgv(cfg.ParsedShortcuts["super+e"][0].Commands[0]); ok {
+		t.Error("shell command parsed as argv form")
+	}
+}
+
+func TestExecTableRejected(t *testing.T) {
+	invalid := []map[string]interface{}{
+		{"exec": []interface{}{}},
+		{"exec": []interface{}{"kitty", 1}},
+		{"exec": ""},
+		{"run": []interface{}{"kitty"}},
+		{"exec": []interface{}{"kitty"}, "env": "FOO=1"},
+	}
+	for _, table := range invalid {
+		if _, err := ParseShortcut("super+t", table); err == nil {
+			t.Errorf("%v unexpectedly parsed", table)
+		}
+	}
+}
+
+func TestDisplayCommand(t *testing.T) {
+	tests := []struct {
+		cmd  string
+		want string
+	}{
+		{"notify-send hi", "notify-send hi"},
+		{ExecCommand([]string{"kitty", "--single-instance"}), "kitty --single-instance"},
+		{ExecCommand([]string{"notify-send", "hello world", ""}), `notify-send "hello world" ""`},
+	}
+	for _, tt := range tests {
+		if got := DisplayCommand(tt.cmd); got != tt.want {
+			t.Errorf("DisplayCommand(%q) = %q, want %q", tt.cmd, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,102 @@
+package config
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const switchStateFile = ".switch-state"
+
+// SwitchPosition is the persisted position of one .switch cycle.
+type SwitchPosition struct {
+	Next      int       // index of the command the next press fires
+	LastFired time.Time // zero if the cycle was set or reset rather than fired
+}
+
+func GetSwitchStatePath() (string, error) {
+	configDir, err := GetConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(configDir, switchStateFile), nil
+}
+
+// ReadSwitchState loads switch positions keyed by switch state key.
+// Each line is "<next> <last fired, unix ms> <key>"; malformed lines are skipped.
+func ReadSwitchState() (map[string]SwitchPosition, error) {
+	positions := make(map[string]SwitchPosition)
+
+	statePath, err := GetSwitchStatePath()
+	if err != nil {
+		return nil, err
+	}
+
+	file, err := os.Open(statePath)
+	if os.IsNotExist(err) {
+		return positions, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		fields := strings.SplitN(strings.TrimSpace(scanner.Text()), " ", 3)
+		if len(fields) != 3 {
+			continue
+		}
+		next, err := strconv.Atoi(fields[0])
+		if err != nil || next < 0 {
+			continue
+		}
+		lastMs, err := strconv.ParseInt(fields[1], 10, 64)
+		if err != nil {
+			continue
+		}
+		pos := SwitchPosition{Next: next}
+		if lastMs > 0 {
+			pos.LastFired = time.UnixMilli(lastMs)
+		}
+		positions[fields[2]] = pos
+	}
+
+	return positions, scanner.Err()
+}
+
+// WriteSwitchState replaces the state file with the given positions.
+func WriteSwitchState(positions map[string]SwitchPosition) error {
+	statePath, err := GetSwitchStatePath()
+	if err != nil {
+		return err
+	}
+
+	keys := make([]string, 0, len(positions))
+	for key := range positions {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	var content strings.Builder
+	for _, key := range keys {
+		pos := positions[key]
+		var lastMs int64
+		if !pos.LastFired.IsZero() {
+			lastMs = pos.LastFired.UnixMilli()
+		}
+		fmt.Fprintf(&content, "%d %d %s\n", pos.Next, lastMs, key)
+	}
+
+	tmpPath := statePath + ".tmp"
+	if err := os.WriteFile(tmpPath, []byte(content.String()), configFilePerm); err != nil {
+		return err
+	}
+
+	return os.Rename(tmpPath, statePath)
+}
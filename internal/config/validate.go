@@ -115,25 +115,121 @@ func validateConfig(cfg *Config, filePath string, meta *toml.MetaData) error {
 	// Build line number map from source file
 	lineNumbers := getLineNumbers(filePath)
 
+	for name, key := range cfg.Modifiers {
+		if err := validateModifier(name, key, cfg.Modifiers); err != nil {
+			errors = append(errors, ValidationError{
+				File:    filePath,
+				Key:     name,
+				Message: err.Error(),
+			})
+		}
+	}
+
+	if cfg.Settings.ChatterFilterMs < 0 {
+		errors = append(errors, ValidationError{
+			File:    filePath,
+			Key:     "chatter_filter_ms",
+			Message: "chatter_filter_ms cannot be negative",
+		})
+	}
+	if cfg.Settings.CommandTimeout < 0 {
+		errors = append(errors, ValidationError{
+			File:    filePath,
+			Key:     "command_timeout",
+			Message: "command_timeout cannot be negative",
+		})
+	}
+	for match, settings := range cfg.Device {
+		if settings.ChatterFilterMs < 0 {
+			errors = append(errors, ValidationError{
+				File:    filePath,
+				Key:     "device." + match,
+				Message: "chatter_filter_ms cannot be negative",
+			})
+		}
+	}
+
 	for key, value := range cfg.Shortcuts {
 		line := lineNumbers[key]
-		if err := validateShortcutEntry(key, value, filePath, line); err != nil {
+		if err := validateShortcutEntry(key, value, filePath, line, cfg.Modifiers); err != nil {
 			if ve, ok := err.(ValidationError); ok {
 				errors = append(errors, ve)
 			}
 		}
 	}
 
+	if len(errors) == 0 {
+		errors = append(errors, validateAutoRepeatOwnership(cfg, filePath)...)
+	}
+
 	if len(errors) > 0 {
 		return ValidationErrors{Errors: errors}
 	}
 	return nil
 }
 
+// validateAutoRepeatOwnership rejects .autorepeat on a combo that has other
+// triggers too: repeats arrive while those are still being resolved, and would
+// fire the press command after another trigger already won.
+func validateAutoRepeatOwnership(cfg *Config, filePath string) []ValidationError {
+	parsed := make(map[string][]*ParsedShortcut)
+	for key, value := range cfg.Shortcuts {
+		if err := parseShortcutsInto(parsed, key, value, cfg.Modifiers); err != nil {
+			return nil // already reported per entry
+		}
+	}
+
+	var errors []ValidationError
+	for combo, shortcuts := range parsed {
+		if len(shortcuts) < 2 {
+			continue
+		}
+		for _, s := range shortcuts {
+			if s.AutoRepeat {
+				errors = append(errors, ValidationError{
+					File:    filePath,
+					Key:     combo + ".autorepeat",
+					Message: "autorepeat must be the only trigger on its key",
+				})
+				break
+			}
+		}
+	}
+	return errors
+}
+
+// validateModifier checks a custom modifier declared in [modifiers]: a new name
+// bound to a real key that is not already a modifier.
+func validateModifier(name, key string, custom map[string]string) error {
+	if name == "" || strings.ContainsAny(name, "+./ ") {
+		return fmt.Errorf("modifier name cannot be empty or contain +, ., / or spaces")
+	}
+	if isModifierName(normalizeKey(name)) {
+		return fmt.Errorf("%s is a built-in modifier", name)
+	}
+	if _, ok := keys.ResolveKeyCode(name); ok || keys.IsGestureName(name) {
+		return fmt.Errorf("modifier name %s is already a key name", name)
+	}
+	code, ok := keys.ResolveKeyCode(key)
+	if !ok {
+		return fmt.Errorf("unknown key: %s", key)
+	}
+	if isModifierName(keys.GetKeyName(code)) {
+		return fmt.Errorf("%s is already a modifier", key)
+	}
+	// Report a key declared twice once, on the later name
+	for other, otherKey := range custom {
+		if other < name && otherKey == key {
+			return fmt.Errorf("key %s is already declared as modifier %s", key, other)
+		}
+	}
+	return nil
+}
+
 // validateShortcutEntry validates a single shortcut entry using the real parser
-func validateShortcutEntry(key string, value interface{}, filePath string, line int) error {
-	// Use ParseShortcut as single source of truth for all syntax validation
-	parsed, err := ParseShortcut(key, value)
+func validateShortcutEntry(key string, value interface{}, filePath string, line int, custom map[string]string) error {
+	// Use parseShortcut as single source of truth for all syntax validation
+	parsed, err := parseShortcut(key, value, custom)
 	if err != nil {
 		return ValidationError{
 			File:    filePath,
@@ -145,7 +241,17 @@ func validateShortcutEntry(key string, value interface{}, filePath string, line
 
 	// Validate all keys in the combo exist (use original key to preserve +/- suffix)
 	comboToValidate := strings.Split(key, ".")[0] // Get combo part before behavior modifiers
-	if err := validateKeysExist(comboToValidate); err != nil {
+	if err := validateKeysExist(comboToValidate, custom); err != nil {
+		return ValidationError{
+			File:    filePath,
+			Line:    line,
+			Key:     key,
+			Message: err.Error(),
+		}
+	}
+
+	// Validate chords (two or more regular keys) per alias
+	if err := validateChords(comboToValidate, parsed.Behavior, custom); err != nil {
 		return ValidationError{
 			File:    filePath,
 			Line:    line,
@@ -169,7 +275,7 @@ func validateShortcutEntry(key string, value interface{}, filePath string, line
 
 // validateKeysExist checks if all keys in a combo are valid
 // Handles both single combos ("super+k") and aliases ("f1/f2/f3")
-func validateKeysExist(combo string) error {
+func validateKeysExist(combo string, custom map[string]string) error {
 	// Split on / for aliases first
 	aliases := strings.Split(combo, "/")
 	for _, alias := range aliases {
@@ -192,6 +298,12 @@ func validateKeysExist(combo string) error {
 				if _, ok := keys.ResolveAbsCode(keyName); !ok {
 					return fmt.Errorf("unknown axis: %s", keyName)
 				}
+			} else if !isAxis && i == len(parts)-1 && keys.IsGestureName(keyName) {
+				// Touchpad gestures are bound by name (e.g. "super+swipe3_left")
+				continue
+			} else if _, ok := custom[keyName]; ok && !isAxis {
+				// Custom modifiers are bound by their declared name (e.g. "hyper+h")
+				continue
 			} else {
 				// Regular key validation
 				if _, ok := keys.ResolveKeyCode(keyName); !ok {
@@ -203,6 +315,42 @@ func validateKeysExist(combo string) error {
 	return nil
 }
 
+// chordBehaviors are the behaviors a chord supports. Chords resolve on a single
+// press of the whole chord, so tap-based behaviors and .switch are excluded.
+var chordBehaviors = map[BehaviorMode]bool{
+	BehaviorNormal:       true,
+	BehaviorHold:         true,
+	BehaviorLongPress:    true,
+	BehaviorPressRelease: true,
+	BehaviorHoldRelease:  true,
+}
+
+// validateChords checks that every alias naming two or more regular keys ("j+k")
+// is a plain chord: no modifiers, no gestures, and a supported behavior.
+func validateChords(combo string, behavior BehaviorMode, custom map[string]string) error {
+	for _, alias := range strings.Split(combo, "/") {
+		if strings.HasSuffix(alias, "+") || strings.HasSuffix(alias, "-") {
+			continue // Axis shortcut
+		}
+		normalized := normalizeKeyCombo(alias, custom)
+		if !isChord(normalized, custom) {
+			continue
+		}
+		for _, part := range strings.Split(normalized, "+") {
+			if _, ok := custom[part]; ok || isModifierName(part) {
+				return fmt.Errorf("chord %s cannot include modifier %s", normalized, part)
+			}
+			if keys.IsGestureName(part) {
+				return fmt.Errorf("chord %s cannot include gesture %s", normalized, part)
+			}
+		}
+		if !chordBehaviors[behavior] {
+			return fmt.Errorf("chord %s only supports normal, hold, longpress, pressrelease and holdrelease", normalized)
+		}
+	}
+	return nil
+}
+
 // isRemapCommand checks if a command string uses remap syntax (>, >>, <, <<)
 func isRemapCommand(cmd string) bool {
 	return strings.HasPrefix(cmd, ">") || strings.HasPrefix(cmd, "<")
@@ -210,8 +358,39 @@ func isRemapCommand(cmd string) bool {
 
 // validateBehaviorRequirements routes to appropriate validator based on behavior type
 func validateBehaviorRequirements(parsed *ParsedShortcut) error {
-	// Validate remap syntax if detected
-	if len(parsed.Commands) == 1 && isRemapCommand(parsed.Commands[0]) {
+	if parsed.AutoRepeat {
+		if err := validateAutoRepeat(parsed); err != nil {
+			return err
+		}
+	}
+	if parsed.RateLimit > 0 {
+		if err := validateRateLimit(parsed); err != nil {
+			return err
+		}
+	}
+	if parsed.Policy != PolicyNone {
+		if err := validateProcessPolicy(parsed); err != nil {
+			return err
+		}
+	}
+	if parsed.ToggleProcess {
+		if err := validateToggleProcess(parsed); err != nil {
+			return err
+		}
+	}
+	if parsed.Timeout != 0 {
+		if err := validateTimeout(parsed); err != nil {
+			return err
+		}
+	}
+	if bindsGesture(parsed.KeyCombo) {
+		if err := validateGesture(parsed); err != nil {
+			return err
+		}
+	}
+
+	// Validate remap syntax if detected (tapmod validates its own pair of remaps)
+	if len(parsed.Commands) == 1 && isRemapCommand(parsed.Commands[0]) && parsed.Behavior != BehaviorTapMod {
 		return ValidateRemapToken(parsed.Commands[0])
 	}
 
@@ -222,8 +401,13 @@ func validateBehaviorRequirements(parsed *ParsedShortcut) error {
 	return validator(parsed)
 }
 
-// ValidateRemapToken validates remap syntax and ensures non-empty, valid targets
+// ValidateRemapToken validates remap syntax and ensures non-empty, valid targets.
+// Anything that is not a remap token (a shell command) is rejected.
 func ValidateRemapToken(cmd string) error {
+	if !isRemapCommand(cmd) {
+		return fmt.Errorf("not a remap token: %q (want >key, >>key, <key or <<)", cmd)
+	}
+
 	var target string
 
 	switch {
@@ -248,11 +432,128 @@ func ValidateRemapToken(cmd string) error {
 
 	// Validate the target combo contains valid keys
 	if target != "" {
-		return validateKeysExist(target)
+		return validateKeysExist(target, nil)
+	}
+	return nil
+}
+
+// validateAutoRepeat checks that .autorepeat sits on a plain press trigger of a
+// regular key: kernel autorepeat only exists for keys, and remaps repeat their
+// target on their own.
+func validateAutoRepeat(p *ParsedShortcut) error {
+	if p.Behavior != BehaviorNormal {
+		return fmt.Errorf("autorepeat only works on a plain press trigger (got .%s)", behaviorName(p.Behavior))
+	}
+	if p.Repeat {
+		return fmt.Errorf("autorepeat cannot be combined with .repeat")
+	}
+	if len(p.Commands) == 1 && isRemapCommand(p.Commands[0]) {
+		return fmt.Errorf("remapped keys already repeat their target, drop .autorepeat")
+	}
+	parts := strings.Split(p.KeyCombo, "+")
+	base := parts[len(parts)-1]
+	if _, ok := keys.ResolveKeyCode(base); !ok || isModifierName(base) || p.Direction != "" {
+		return fmt.Errorf("autorepeat needs a regular key, %s never repeats", base)
+	}
+	return nil
+}
+
+// validateRateLimit checks .cooldown and .ratelimit, which are enforced where
+// key and gesture shortcuts fire: axis shortcuts and .tapmod never get there.
+func validateRateLimit(p *ParsedShortcut) error {
+	if p.RateWindow <= 0 {
+		return fmt.Errorf("cooldown/ratelimit window must be positive")
+	}
+	if p.Direction != "" {
+		return fmt.Errorf("cooldown/ratelimit does not apply to axis shortcuts")
+	}
+	if p.Behavior == BehaviorTapMod {
+		return fmt.Errorf("tapmod cannot be combined with .cooldown or .ratelimit")
+	}
+	parts := strings.Split(p.KeyCombo, "+")
+	if base := parts[len(parts)-1]; p.ThrottleForward && keys.IsGestureName(base) {
+		return fmt.Errorf("forward needs a key to forward, %s is a gesture", base)
+	}
+	return nil
+}
+
+// validateProcessPolicy checks .single, .restart and .queue, which track the
+// shell processes a shortcut launches: remaps launch none.
+func validateProcessPolicy(p *ParsedShortcut) error {
+	switch p.Behavior {
+	case BehaviorTapMod:
+		return fmt.Errorf("tapmod cannot be combined with .single, .restart or .queue")
+	case BehaviorTapHold:
+		return fmt.Errorf("taphold already stops its process on release, .single, .restart and .queue do not apply")
+	}
+	for _, cmd := range p.Commands {
+		if cmd != "" && !isRemapCommand(cmd) {
+			return nil
+		}
+	}
+	return fmt.Errorf(".single, .restart and .queue need a shell command to track")
+}
+
+// validateToggleProcess checks .toggleprocess, which owns the process it starts
+// from one press to the next, so it takes a plain press of a key.
+func validateToggleProcess(p *ParsedShortcut) error {
+	if p.Behavior != BehaviorNormal {
+		return fmt.Errorf("toggleprocess only works on a plain press trigger (got .%s)", behaviorName(p.Behavior))
+	}
+	if p.Repeat || p.AutoRepeat || p.Policy != PolicyNone {
+		return fmt.Errorf("toggleprocess cannot be combined with .repeat, .autorepeat, .single, .restart or .queue")
+	}
+	if len(p.Commands) != 1 || p.Commands[0] == "" || isRemapCommand(p.Commands[0]) {
+		return fmt.Errorf("toggleprocess needs exactly 1 shell command")
+	}
+	parts := strings.Split(p.KeyCombo, "+")
+	if base := parts[len(parts)-1]; p.Direction != "" || keys.IsGestureName(base) {
+		return fmt.Errorf("toggleprocess needs a key, %s is not one", base)
 	}
 	return nil
 }
 
+// bindsGesture reports whether any alias of combo ends in a touchpad gesture.
+func bindsGesture(combo string) bool {
+	for _, alias := range strings.Split(combo, "/") {
+		parts := strings.Split(alias, "+")
+		if keys.IsGestureName(strings.TrimSpace(parts[len(parts)-1])) {
+			return true
+		}
+	}
+	return false
+}
+
+// validateGesture checks a touchpad gesture shortcut. A gesture fires once
+// when recognized, with no press or release to time, so it takes a plain
+// trigger and one command.
+func validateGesture(p *ParsedShortcut) error {
+	if p.Behavior != BehaviorNormal {
+		return fmt.Errorf("gestures only fire once when recognized (got .%s)", behaviorName(p.Behavior))
+	}
+	if p.Repeat || p.Timing == TimingRelease {
+		return fmt.Errorf("gestures cannot be combined with .repeat or .onrelease")
+	}
+	if len(p.Commands) != 1 {
+		return fmt.Errorf("gesture requires exactly 1 command")
+	}
+	return nil
+}
+
+// validateTimeout checks .timeout, which stops the shell commands a shortcut
+// starts: remaps start none.
+func validateTimeout(p *ParsedShortcut) error {
+	if p.Behavior == BehaviorTapMod {
+		return fmt.Errorf("tapmod cannot be combined with .timeout")
+	}
+	for _, cmd := range p.Commands {
+		if cmd != "" && !isRemapCommand(cmd) {
+			return nil
+		}
+	}
+	return fmt.Errorf(".timeout needs a shell command to stop")
+}
+
 // Lookup table mapping behaviors to their validation functions
 var behaviorValidators = map[BehaviorMode]func(*ParsedShortcut) error{
 	BehaviorNormal:          validateNormal,
@@ -266,6 +567,8 @@ var behaviorValidators = map[BehaviorMode]func(*ParsedShortcut) error{
 	BehaviorTapLongPress:    validateTapLongPress,
 	BehaviorTapPressRelease: validateTapPressRelease,
 	BehaviorTapHoldRelease:  validateTapHoldRelease,
+	BehaviorTapMod:          validateTapMod,
+	BehaviorMultiTap:        validateMultiTap,
 }
 
 // Individual behavior validators - each validates command count and behavior-specific rules
@@ -349,3 +652,32 @@ func validateTapHoldRelease(p *ParsedShortcut) error {
 	}
 	return nil
 }
+
+func validateMultiTap(p *ParsedShortcut) error {
+	if len(p.Commands) != 1 {
+		return fmt.Errorf("tap(%d) behavior requires exactly 1 command", p.TapCount)
+	}
+	return nil
+}
+
+func validateTapMod(p *ParsedShortcut) error {
+	if len(p.Commands) != 2 {
+		return fmt.Errorf("tapmod behavior requires exactly 2 commands")
+	}
+	if strings.Contains(p.KeyCombo, "+") {
+		return fmt.Errorf("tapmod only works on single keys (no combos)")
+	}
+	if p.Repeat || p.Passthrough {
+		return fmt.Errorf("tapmod cannot be combined with .repeat or .passthrough")
+	}
+	for _, cmd := range p.Commands {
+		target, ok := remapTarget(cmd)
+		if !ok {
+			return fmt.Errorf("tapmod commands must be key remaps like \">esc\" (got %q)", cmd)
+		}
+		if _, ok := keys.ResolveKeyCode(target); !ok || scrollAliasTargets[target] {
+			return fmt.Errorf("tapmod target must be a key or mouse button: %s", target)
+		}
+	}
+	return nil
+}
@@ -8,7 +8,7 @@ import (
 )
 
 func TestValidateShortcutEntry_UnknownKeyInCombo(t *testing.T) {
-	err := validateShortcutEntry("super+unknownkey", "echo test", "test.toml", 0)
+	err := validateShortcutEntry("super+unknownkey", "echo test", "test.toml", 0, nil)
 	if err == nil {
 		t.Fatal("expected error for unknown key, got nil")
 	}
@@ -25,7 +25,7 @@ func TestValidateShortcutEntry_UnknownKeyInCombo(t *testing.T) {
 }
 
 func TestValidateShortcutEntry_UnknownKeyInRemapTarget(t *testing.T) {
-	err := validateShortcutEntry("super+t", ">unknownkey", "test.toml", 0)
+	err := validateShortcutEntry("super+t", ">unknownkey", "test.toml", 0, nil)
 	if err == nil {
 		t.Fatal("expected error for unknown key in remap target, got nil")
 	}
@@ -39,7 +39,7 @@ func TestValidateShortcutEntry_UnknownKeyInRemapTarget(t *testing.T) {
 }
 
 func TestValidateShortcutEntry_EmptyRemapTarget(t *testing.T) {
-	err := validateShortcutEntry("super+t", ">", "test.toml", 0)
+	err := validateShortcutEntry("super+t", ">", "test.toml", 0, nil)
 	if err == nil {
 		t.Fatal("expected error for empty remap target, got nil")
 	}
@@ -68,7 +68,7 @@ func TestValidateShortcutEntry_WrongCommandCount(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.key, func(t *testing.T) {
-			err := validateShortcutEntry(tt.key, tt.value, "test.toml", 0)
+			err := validateShortcutEntry(tt.key, tt.value, "test.toml", 0, nil)
 			if err == nil {
 				t.Fatal("expected error for wrong command count, got nil")
 			}
@@ -104,7 +104,7 @@ func TestValidateShortcutEntry_ValidConfig(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.key, func(t *testing.T) {
-			err := validateShortcutEntry(tt.key, tt.value, "test.toml", 0)
+			err := validateShortcutEntry(tt.key, tt.value, "test.toml", 0, nil)
 			if err != nil {
 				t.Errorf("unexpected error for valid config: %v", err)
 			}
@@ -113,7 +113,7 @@ func TestValidateShortcutEntry_ValidConfig(t *testing.T) {
 }
 
 func TestValidateShortcutEntry_LongpressRepeatRejected(t *testing.T) {
-	err := validateShortcutEntry("super+t.longpress.repeat", "echo test", "test.toml", 0)
+	err := validateShortcutEntry("super+t.longpress.repeat", "echo test", "test.toml", 0, nil)
 	if err == nil {
 		t.Fatal("expected error for longpress.repeat, got nil")
 	}
@@ -127,7 +127,7 @@ func TestValidateShortcutEntry_LongpressRepeatRejected(t *testing.T) {
 }
 
 func TestValidateShortcutEntry_InvalidValueType(t *testing.T) {
-	err := validateShortcutEntry("super+t", 123, "test.toml", 0)
+	err := validateShortcutEntry("super+t", 123, "test.toml", 0, nil)
 	if err == nil {
 		t.Fatal("expected error for invalid value type, got nil")
 	}
@@ -141,7 +141,7 @@ func TestValidateShortcutEntry_InvalidValueType(t *testing.T) {
 }
 
 func TestValidateShortcutEntry_ArrayWithNonString(t *testing.T) {
-	err := validateShortcutEntry("super+t", []interface{}{"cmd1", 123}, "test.toml", 0)
+	err := validateShortcutEntry("super+t", []interface{}{"cmd1", 123}, "test.toml", 0, nil)
 	if err == nil {
 		t.Fatal("expected error for array with non-string, got nil")
 	}
@@ -223,7 +223,7 @@ func TestValidationError_Format(t *testing.T) {
 
 func TestValidateShortcutEntry_AliasValidation(t *testing.T) {
 	// All aliases should be validated
-	err := validateShortcutEntry("f1/unknownkey/f3.switch", []interface{}{"cmd1", "cmd2"}, "test.toml", 0)
+	err := validateShortcutEntry("f1/unknownkey/f3.switch", []interface{}{"cmd1", "cmd2"}, "test.toml", 0, nil)
 	if err == nil {
 		t.Fatal("expected error for unknown key in alias, got nil")
 	}
@@ -253,7 +253,7 @@ func TestValidateShortcutEntry_GamepadWMBindings(t *testing.T) {
 	}
 
 	for shortcut, command := range bindings {
-		if err := validateShortcutEntry(shortcut, command, "gamepad-wm.toml", 0); err != nil {
+		if err := validateShortcutEntry(shortcut, command, "gamepad-wm.toml", 0, nil); err != nil {
 			t.Errorf("binding %q failed validation: %v", shortcut, err)
 		}
 	}
@@ -261,8 +261,287 @@ func TestValidateShortcutEntry_GamepadWMBindings(t *testing.T) {
 
 func TestValidateShortcutEntryRejectsBareKeyboardAxisName(t *testing.T) {
 	for _, shortcut := range []string{"x+", "y-", "z+"} {
-		if err := validateShortcutEntry(shortcut, "command", "test.toml", 0); err == nil {
+		if err := validateShortcutEntry(shortcut, "command", "test.toml", 0, nil); err == nil {
 			t.Errorf("ambiguous axis shortcut %q unexpectedly validated", shortcut)
 		}
 	}
 }
+
+func TestValidateShortcutEntry_GestureBindings(t *testing.T) {
+	bindings := map[string]interface{}{
+		"swipe3_left":       ">super+left",
+		"super+swipe4_up":   "wofi --show drun",
+		"pinch_in":          ">ctrl+minus",
+		"hold3.passthrough": "notify-send held",
+	}
+	for shortcut, command := range bindings {
+		if err := validateShortcutEntry(shortcut, command, "test.toml", 0, nil); err != nil {
+			t.Errorf("gesture binding %q failed validation: %v", shortcut, err)
+		}
+	}
+
+	// Gestures are bound by name only; they cannot prefix a key
+	if err := validateShortcutEntry("swipe3_left+k", "cmd", "test.toml", 0, nil); err == nil {
+		t.Error("gesture used as a modifier unexpectedly validated")
+	}
+
+	// A gesture fires once when recognized: no press timing, no cycling
+	invalid := map[string]interface{}{
+		"swipe3_left.doubletap":         "cmd",
+		"hold3.hold":                    "cmd",
+		"swipe4_up.switch":              []interface{}{"cmd1", "cmd2"},
+		"pinch_in.pressrelease":         []interface{}{"down", "up"},
+		"super+swipe3_right.repeat":     "cmd",
+		"swipe3_down.onrelease":         "cmd",
+		"super+pinch_out.tapmod":        []interface{}{">a", ">b"},
+		"swipe3_left/swipe3_right.hold": "cmd",
+	}
+	for shortcut, command := range invalid {
+		if err := validateShortcutEntry(shortcut, command, "test.toml", 0, nil); err == nil {
+			t.Errorf("gesture binding %q unexpectedly validated", shortcut)
+		}
+	}
+}
+
+func TestValidateShortcutEntry_Chords(t *testing.T) {
+	valid := map[string]interface{}{
+		"j+k":              "cmd",
+		"gp_lb+gp_a.hold":  "cmd",
+		"j+k+l.longpress":  "cmd",
+		"ctrl+j/k+l":       "cmd",
+		"a+s.pressrelease": []interface{}{"down", "up"},
+	}
+	for shortcut, command := range valid {
+		if err := validateShortcutEntry(shortcut, command, "test.toml", 0, nil); err != nil {
+			t.Errorf("chord %q failed validation: %v", shortcut, err)
+		}
+	}
+
+	invalid := map[string]interface{}{
+		"ctrl+j+k":         "cmd",
+		"j+k.doubletap":    "cmd",
+		"j+k.switch":       []interface{}{"a", "b"},
+		"k+swipe3_left":    "cmd",
+		"j+k.taplongpress": []interface{}{"a", "b"},
+	}
+	for shortcut, command := range invalid {
+		if err := validateShortcutEntry(shortcut, command, "test.toml", 0, nil); err == nil {
+			t.Errorf("chord %q unexpectedly validated", shortcut)
+		}
+	}
+}
+
+func TestValidateShortcutEntry_CustomModifiers(t *testing.T) {
+	custom := map[string]string{"hyper": "capslock", "lb": "gp_lb"}
+	valid := []string{"hyper+h", "capslock+h", "super+hyper+h", "gp_lb+gp_a", "hyper.doubletap"}
+	for _, shortcut := range valid {
+		if err := validateShortcutEntry(shortcut, "cmd", "test.toml", 0, custom); err != nil {
+			t.Errorf("%q failed validation: %v", shortcut, err)
+		}
+	}
+
+	if err := validateShortcutEntry("hyper+h", "cmd", "test.toml", 0, nil); err == nil {
+		t.Error("undeclared custom modifier unexpectedly validated")
+	}
+	if err := validateShortcutEntry("hyper+j+k", "cmd", "test.toml", 0, custom); err == nil {
+		t.Error("chord with a custom modifier unexpectedly validated")
+	}
+}
+
+func TestValidateRemapToken(t *testing.T) {
+	tests := []struct {
+		token   string
+		wantErr bool
+	}{
+		{">a", false},
+		{">>shift", false},
+		{"<shift", false},
+		{"<<", false},
+		{">ctrl+c", false},
+		{">", true},
+		{">nosuchkey", true},
+		{"rm -rf ~", true}, // shell command
+		{"a", true},
+	}
+	for _, tt := range tests {
+		if err := ValidateRemapToken(tt.token); (err != nil) != tt.wantErr {
+			t.Errorf("ValidateRemapToken(%q) error = %v, wantErr %v", tt.token, err, tt.wantErr)
+		}
+	}
+}
+
+func TestValidateModifier(t *testing.T) {
+	tests := []struct {
+		name    string
+		key     string
+		wantErr bool
+	}{
+		{"hyper", "capslock", false},
+		{"lb", "gp_lb", false},
+		{"meta", "capslock", true},    // built-in modifier alias
+		{"escape", "capslock", true},  // already a key name
+		{"hyper+x", "capslock", true}, // invalid characters
+		{"hyper", "nosuchkey", true},
+		{"hyper", "ctl", true}, // already a modifier key
+	}
+	for _, tt := range tests {
+		err := validateModifier(tt.name, tt.key, map[string]string{tt.name: tt.key})
+		if (err != nil) != tt.wantErr {
+			t.Errorf("validateModifier(%q, %q) error = %v, wantErr %v", tt.name, tt.key, err, tt.wantErr)
+		}
+	}
+
+	// The same key declared twice is reported once, on the later name
+	custom := map[string]string{"hyper": "capslock", "super2": "capslock"}
+	if err := validateModifier("hyper", "capslock", custom); err != nil {
+		t.Errorf("first declaration reported: %v", err)
+	}
+	if err := validateModifier("super2", "capslock", custom); err == nil {
+		t.Error("duplicate key declaration not reported")
+	}
+}
+
+func TestValidateShortcutEntry_TapMod(t *testing.T) {
+	valid := map[string]interface{}{
+		"f.tapmod":             []interface{}{">f", ">super"},
+		"capslock.tapmod(200)": []interface{}{">esc", ">ctrl"},
+	}
+	for shortcut, command := range valid {
+		if err := validateShortcutEntry(shortcut, command, "test.toml", 0, nil); err != nil {
+			t.Errorf("%q failed validation: %v", shortcut, err)
+		}
+	}
+
+	invalid := map[string]interface{}{
+		"ctrl+f.tapmod":   []interface{}{">f", ">super"},
+		"f.tapmod.repeat": []interface{}{">f", ">super"},
+		"g.tapmod":        []interface{}{">g"},
+		"h.tapmod":        []interface{}{"echo hi", ">super"},
+		"j.tapmod":        []interface{}{">j", ">>super"},
+		"k.tapmod":        []interface{}{">k", ">scrollup"},
+	}
+	for shortcut, command := range invalid {
+		if err := validateShortcutEntry(shortcut, command, "test.toml", 0, nil); err == nil {
+			t.Errorf("%q unexpectedly validated", shortcut)
+		}
+	}
+}
+
+func TestValidateShortcutEntry_AutoRepeat(t *testing.T) {
+	valid := map[string]interface{}{
+		"f5.autorepeat":              "notify-send tick",
+		"ctrl+j.autorepeat":          "echo down",
+		"kp6.passthrough.autorepeat": "echo right",
+	}
+	for shortcut, command := range valid {
+		if err := validateShortcutEntry(shortcut, command, "test.toml", 0, nil); err != nil {
+			t.Errorf("%q failed validation: %v", shortcut, err)
+		}
+	}
+
+	invalid := map[string]interface{}{
+		"f5.hold.autorepeat":   "echo held",
+		"f6.repeat.autorepeat": "echo twice",
+		"f7.autorepeat":        ">a",
+		"super.autorepeat":     "echo super",
+		"swipe3_up.autorepeat": "echo swipe",
+	}
+	for shortcut, command := range invalid {
+		if err := validateShortcutEntry(shortcut, command, "test.toml", 0, nil); err == nil {
+			t.Errorf("%q unexpectedly validated", shortcut)
+		}
+	}
+}
+
+func TestValidateAutoRepeatOwnership(t *testing.T) {
+	cfg := &Config{Shortcuts: map[string]interface{}{
+		"f5.autorepeat": "echo tick",
+		"f6.autorepeat": "echo tick",
+		"f6.doubletap":  "echo double",
+	}}
+	errors := validateAutoRepeatOwnership(cfg, "test.toml")
+	if len(errors) != 1 || errors[0].Key != "f6.autorepeat" {
+		t.Fatalf("errors = %+v, want one for f6.autorepeat", errors)
+	}
+}
+
+func TestValidateShortcutEntry_RateLimit(t *testing.T) {
+	valid := map[string]interface{}{
+		"print.cooldown(1000)":             "screenshot",
+		"super+q.ratelimit(2/5s, forward)": "kill-window",
+		"swipe3_up.cooldown(500)":          "overview",
+		"f2.switch.cooldown(300)":          []interface{}{"a", "b"},
+	}
+	for shortcut, command := range valid {
+		if err := validateShortcutEntry(shortcut, command, "test.toml", 0, nil); err != nil {
+			t.Errorf("%q failed validation: %v", shortcut, err)
+		}
+	}
+
+	invalid := map[string]interface{}{
+		"capslock.tapmod.cooldown(500)":    []interface{}{">esc", ">ctrl"},
+		"swipe3_up.cooldown(500, forward)": "overview",
+		"ABS_X+.cooldown(500)":             "right",
+		"print.cooldown(0)":                "screenshot",
+	}
+	for shortcut, command := range invalid {
+		if err := validateShortcutEntry(shortcut, command, "test.toml", 0, nil); err == nil {
+			t.Errorf("%q unexpectedly validated", shortcut)
+		}
+	}
+}
+
+func TestValidateShortcutEntry_ProcessPolicy(t *testing.T) {
+	valid := map[string]interface{}{
+		"super+d.single":         "rofi -show drun",
+		"super+v.restart":        "copyq toggle",
+		"f9.hold.repeat.queue":   "notify-send tick",
+		"f2.switch.single":       []interface{}{"a", "b"},
+		"swipe3_up.single":       "overview",
+		"f3.pressrelease.queue":  []interface{}{"", "notify-send up"},
+		"f7.toggleprocess(term)": "wf-recorder",
+	}
+	for shortcut, command := range valid {
+		if err := validateShortcutEntry(shortcut, command, "test.toml", 0, nil); err != nil {
+			t.Errorf("%q failed validation: %v", shortcut, err)
+		}
+	}
+
+	invalid := map[string]interface{}{
+		"super+d.single.restart":  "rofi -show drun",
+		"capslock.tapmod.single":  []interface{}{">esc", ">ctrl"},
+		"f4.taphold.queue":        "kitty",
+		"f5.single":               ">ctrl+c",
+		"f6.pressrelease.restart": []interface{}{">>shift", "<shift"},
+	}
+	for shortcut, command := range invalid {
+		if err := validateShortcutEntry(shortcut, command, "test.toml", 0, nil); err == nil {
+			t.Errorf("%q unexpectedly validated", shortcut)
+		}
+	}
+}
+
+func TestValidateShortcutEntry_Timeout(t *testing.T) {
+	valid := map[string]interface{}{
+		"super+w.timeout(5s)":           "fetch-weather",
+		"f9.hold.timeout(30000)":        "arecord /tmp/memo.wav",
+		"f2.switch.restart.timeout(2m)": []interface{}{"sync-a", "sync-b"},
+		"f7.toggleprocess.timeout(10m)": "wf-recorder",
+	}
+	for shortcut, command := range valid {
+		if err := validateShortcutEntry(shortcut, command, "test.toml", 0, nil); err != nil {
+			t.Errorf("%q failed validation: %v", shortcut, err)
+		}
+	}
+
+	invalid := map[string]interface{}{
+		"capslock.tapmod.timeout(5s)": []interface{}{">esc", ">ctrl"},
+		"f5.timeout(5s)":              ">ctrl+c",
+		"f6.timeout(5h)":              "sleep 1",
+	}
+	for shortcut, command := range invalid {
+		if err := validateShortcutEntry(shortcut, command, "test.toml", 0, nil); err == nil {
+			t.Errorf("%q unexpectedly validated", shortcut)
+		}
+	}
+}
@@ -0,0 +1,170 @@
+// Package dbus publishes the running daemon on the session bus, so desktop
+// tooling (shell extensions, busctl scripts) can drive it without knowing
+// where the IPC socket lives.
+package dbus
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+
+	godbus "github.com/godbus/dbus/v5"
+	"github.com/godbus/dbus/v5/introspect"
+
+	"github.com/deprecatedluar/akeyshually/internal/events"
+	"github.com/deprecatedluar/akeyshually/internal/executor"
+	"github.com/deprecatedluar/akeyshually/internal/ipc"
+)
+
+const (
+	Name      = "io.github.akeyshually"
+	Interface = "io.github.akeyshually"
+	Path      = godbus.ObjectPath("/io/github/akeyshually")
+)
+
+// ErrorName is the D-Bus error every failed method replies with; its message
+// is the daemon's error.
+const ErrorName = Interface + ".Error"
+
+// restartDelay holds back a reload until the method reply, which godbus sends
+// after the method returns, is on the bus.
+const restartDelay = 100 * time.Millisecond
+
+const introspection = introspect.IntrospectDeclarationString + `<node>
+	<interface name="` + Interface + `">
+		<method name="Emit">
+			<arg name="tokens" type="as" direction="in"/>
+		</method>
+		<method name="Trigger">
+			<arg name="combo" type="s" direction="in"/>
+			<arg name="behavior" type="s" direction="in"/>
+			<arg name="duration_ms" type="u" direction="in"/>
+		</method>
+		<method name="EnableOverlay">
+			<arg name="name" type="s" direction="in"/>
+		</method>
+		<method name="DisableOverlay">
+			<arg name="name" type="s" direction="in"/>
+		</method>
+		<method name="Reload"/>
+		<signal name="ShortcutFired">
+			<arg name="combo" type="s"/>
+			<arg name="behavior" type="s"/>
+			<arg name="command" type="s"/>
+		</signal>
+		<signal name="OverlaysChanged">
+			<arg name="overlays" type="as"/>
+		</signal>
+	</interface>` + introspect.IntrospectDataString + `</node>`
+
+// service holds the exported methods. Each mirrors the IPC request of the
+// same name.
+type service struct {
+	outputs   executor.Outputs
+	loopState *executor.LoopState
+	daemon    ipc.Daemon
+}
+
+func (s *service) Emit(tokens []string) *godbus.Error {
+	if len(tokens) == 0 {
+		return replyError(errors.New("emit needs at least one token"))
+	}
+	return replyError(ipc.Emit(tokens, s.outputs, s.loopState))
+}
+
+func (s *service) Trigger(combo, behavior string, durationMs uint32) *godbus.Error {
+	return replyError(s.daemon.Trigger(combo, behavior, time.Duration(durationMs)*time.Millisecond))
+}
+
+func (s *service) EnableOverlay(name string) *godbus.Error {
+	return restartAfterReply(s.daemon.EnableOverlay(name))
+}
+
+func (s *service) DisableOverlay(name string) *godbus.Error {
+	return restartAfterReply(s.daemon.DisableOverlay(name))
+}
+
+func (s *service) Reload() *godbus.Error {
+	return restartAfterReply(s.daemon.Reload())
+}
+
+func restartAfterReply(restart func(), err error) *godbus.Error {
+	if err != nil {
+		return replyError(err)
+	}
+	time.AfterFunc(restartDelay, restart)
+	return nil
+}
+
+func replyError(err error) *godbus.Error {
+	if err == nil {
+		return nil
+	}
+	return godbus.NewError(ErrorName, []any{err.Error()})
+}
+
+// Serve exports the daemon on conn and owns Name until ctx is cancelled,
+// emitting ShortcutFired for every shortcut that fires and OverlaysChanged,
+// with the overlays enabled from then on, just before an overlay reload.
+func Serve(ctx context.Context, conn *godbus.Conn, outputs executor.Outputs, loopState *executor.LoopState, daemon ipc.Daemon) error {
+	stream, unsubscribe := events.Subscribe()
+	defer unsubscribe()
+
+	svc := &service{outputs: outputs, loopState: loopState, daemon: daemon}
+	if err := conn.Export(svc, Path, Interface); err != nil {
+		return fmt.Errorf("export %s: %w", Interface, err)
+	}
+	if err := conn.Export(introspect.Introspectable(introspection), Path, "org.freedesktop.DBus.Introspectable"); err != nil {
+		return fmt.Errorf("export introspection: %w", err)
+	}
+
+	reply, err := conn.RequestName(Name, godbus.NameFlagDoNotQueue)
+	if err != nil {
+		return fmt.Errorf("request bus name %s: %w", Name, err)
+	}
+	if reply != godbus.RequestNameReplyPrimaryOwner {
+		return fmt.Errorf("bus name %s is already taken", Name)
+	}
+	defer conn.ReleaseName(Name)
+
+	overlays := slices.Clone(daemon.Status().Overlays)
+	for {
+		select {
+		case <-ctx.Done():
+			// Pass on what was published before the shutdown, such as the
+			// overlay change a reload is for
+			for {
+				select {
+				case e := <-stream:
+					overlays = signal(conn, e, overlays)
+				default:
+					return nil
+				}
+			}
+		case e := <-stream:
+			overlays = signal(conn, e, overlays)
+		}
+	}
+}
+
+// signal emits the D-Bus signal for e, if it has one, and returns the
+// enabled overlays after it.
+func signal(conn *godbus.Conn, e events.Event, overlays []string) []string {
+	switch e.Type {
+	case events.ShortcutFired:
+		conn.Emit(Path, Interface+".ShortcutFired", e.Combo, e.Behavior, e.Command)
+		return overlays
+	case events.OverlayEnabled:
+		if !slices.Contains(overlays, e.Overlay) {
+			overlays = append(slices.Clone(overlays), e.Overlay)
+		}
+	case events.OverlayDisabled:
+		overlays = slices.DeleteFunc(slices.Clone(overlays), func(name string) bool { return name == e.Overlay })
+	default:
+		return overlays
+	}
+	conn.Emit(Path, Interface+".OverlaysChanged", overlays)
+	return overlays
+}
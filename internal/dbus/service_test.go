@@ -0,0 +1,220 @@
+package dbus
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	godbus "github.com/godbus/dbus/v5"
+	evdev "github.com/holoplot/go-evdev"
+
+	"github.com/deprecatedluar/akeyshually/internal/config"
+	"github.com/deprecatedluar/akeyshually/internal/events"
+	"github.com/deprecatedluar/akeyshually/internal/executor"
+	"github.com/deprecatedluar/akeyshually/internal/ipc"
+	"github.com/deprecatedluar/akeyshually/internal/matcher"
+)
+
+const busConfig = `<!DOCTYPE busconfig PUBLIC "-//freedesktop//DTD D-Bus Bus Configuration 1.0//EN"
+ "http://www.freedesktop.org/standards/dbus/1.0/busconfig.dtd">
+<busconfig>
+  <type>session</type>
+  <listen>unix:path=%SOCKET%</listen>
+  <auth>EXTERNAL</auth>
+  <policy context="default">
+    <allow send_destination="*" eavesdrop="true"/>
+    <allow eavesdrop="true"/>
+    <allow own="*"/>
+  </policy>
+</busconfig>
+`
+
+type fakeWriter struct{}
+
+func (fakeWriter) WriteOne(*evdev.InputEvent) error { return nil }
+
+type fakeDaemon struct {
+	*matcher.Matcher
+	triggered chan string
+	restarted chan struct{}
+}
+
+func (d *fakeDaemon) Trigger(combo, _ string, _ time.Duration) error {
+	if combo != "f1" {
+		return errors.New("nothing bound to " + combo)
+	}
+	d.triggered <- combo
+	return nil
+}
+
+func (d *fakeDaemon) Status() ipc.Status            { return ipc.Status{Overlays: []string{"work.toml"}} }
+func (d *fakeDaemon) Shortcuts() []ipc.ShortcutInfo { return nil }
+func (d *fakeDaemon) Devices() []ipc.DeviceInfo     { return nil }
+func (d *fakeDaemon) Reload() (func(), error)       { return func() { close(d.restarted) }, nil }
+func (d *fakeDaemon) EnableOverlay(name string) (func(), error) {
+	return nil, errors.New("overlay not found: " + name)
+}
+func (d *fakeDaemon) DisableOverlay(name string) (func(), error) {
+	return nil, errors.New("overlay not enabled: " + name)
+}
+
+// privateBus starts a dbus-daemon of its own and returns its address.
+func privateBus(t *testing.T) string {
+	t.Helper()
+	if _, err := exec.LookPath("dbus-daemon"); err != nil {
+		t.Skip("dbus-daemon not installed")
+	}
+	dir := t.TempDir()
+	conf := filepath.Join(dir, "bus.conf")
+	if err := os.WriteFile(conf, []byte(strings.Replace(busConfig, "%SOCKET%", filepath.Join(dir, "bus"), 1)), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	cmd := exec.Command("dbus-daemon", "--config-file="+conf, "--nofork", "--print-address")
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := cmd.Start(); err != nil {
+		t.Fatalf("start dbus-daemon: %v", err)
+	}
+	t.Cleanup(func() {
+		cmd.Process.Kill()
+		cmd.Wait()
+	})
+
+	addr, err := bufio.NewReader(stdout).ReadString('\n')
+	if err != nil {
+		t.Fatalf("read bus address: %v", err)
+	}
+	return strings.TrimSpace(addr)
+}
+
+func connect(t *testing.T, addr string) *godbus.Conn {
+	t.Helper()
+	conn, err := godbus.Connect(addr)
+	if err != nil {
+		t.Fatalf("connect to bus: %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return conn
+}
+
+// startService serves a fake daemon on a private bus and returns a client
+// connection to it and the daemon.
+func startService(t *testing.T) (*godbus.Conn, *fakeDaemon) {
+	t.Helper()
+	addr := privateBus(t)
+	daemon := &fakeDaemon{
+		Matcher: matcher.New(map[string][]*config.ParsedShortcut{
+			"f1": {{KeyCombo: "f1", Behavior: config.BehaviorNormal, Commands: []string{"true"}}},
+		}),
+		triggered: make(chan string, 1),
+		restarted: make(chan struct{}),
+	}
+	outputs := executor.Outputs{
+		Keyboard: executor.NewEventSink(fakeWriter{}),
+		Pointer:  executor.NewEventSink(fakeWriter{}),
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	go Serve(ctx, connect(t, addr), outputs, executor.NewLoopState(), daemon)
+
+	client := connect(t, addr)
+	for range 100 {
+		var owned bool
+		if client.BusObject().Call("org.freedesktop.DBus.NameHasOwner", 0, Name).Store(&owned) == nil && owned {
+			return client, daemon
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatalf("%s never appeared on the bus", Name)
+	return nil, nil
+}
+
+func TestServeMethods(t *testing.T) {
+	client, daemon := startService(t)
+	obj := client.Object(Name, Path)
+
+	if err := obj.Call(Interface+".Emit", 0, []string{">>shift", ">a", "<shift"}).Err; err != nil {
+		t.Fatalf("Emit: %v", err)
+	}
+	if err := obj.Call(Interface+".Trigger", 0, "f1", "", uint32(0)).Err; err != nil {
+		t.Fatalf("Trigger: %v", err)
+	}
+	if combo := <-daemon.triggered; combo != "f1" {
+		t.Errorf("triggered %q, want f1", combo)
+	}
+	if err := obj.Call(Interface+".Reload", 0).Err; err != nil {
+		t.Fatalf("Reload: %v", err)
+	}
+	select {
+	case <-daemon.restarted:
+	case <-time.After(time.Second):
+		t.Fatal("Reload did not restart the daemon")
+	}
+}
+
+func TestServeMethodErrors(t *testing.T) {
+	client, _ := startService(t)
+	obj := client.Object(Name, Path)
+
+	tests := []struct {
+		method string
+		args   []any
+		want   string
+	}{
+		{"Emit", []any{[]string{}}, "emit needs at least one token"},
+		{"Emit", []any{[]string{">nosuchkey"}}, "nosuchkey"},
+		{"Emit", []any{[]string{"rm -rf ~"}}, "not a remap token"},
+		{"Trigger", []any{"f9", "", uint32(0)}, "nothing bound to f9"},
+		{"EnableOverlay", []any{"gaming"}, "overlay not found: gaming"},
+		{"DisableOverlay", []any{"gaming"}, "overlay not enabled: gaming"},
+	}
+	for _, tt := range tests {
+		err := obj.Call(Interface+"."+tt.method, 0, tt.args...).Err
+		var dbusErr godbus.Error
+		if !errors.As(err, &dbusErr) || dbusErr.Name != ErrorName || !strings.Contains(dbusErr.Error(), tt.want) {
+			t.Errorf("%s%v error = %v, want %s containing %q", tt.method, tt.args, err, ErrorName, tt.want)
+		}
+	}
+}
+
+func TestServeSignals(t *testing.T) {
+	client, _ := startService(t)
+	if err := client.AddMatchSignal(godbus.WithMatchInterface(Interface)); err != nil {
+		t.Fatalf("AddMatchSignal: %v", err)
+	}
+	signals := make(chan *godbus.Signal, 10)
+	client.Signal(signals)
+
+	events.Fired("ctrl+t", "normal", "kitty")
+	events.Publish(events.Event{Type: events.OverlayEnabled, Overlay: "gaming.toml"})
+	events.Publish(events.Event{Type: events.KeyHeld, Key: "shift"}) // no signal
+
+	want := []struct {
+		name string
+		body []any
+	}{
+		{Interface + ".ShortcutFired", []any{"ctrl+t", "normal", "kitty"}},
+		{Interface + ".OverlaysChanged", []any{[]string{"work.toml", "gaming.toml"}}},
+	}
+	for _, w := range want {
+		select {
+		case sig := <-signals:
+			if sig.Name != w.name || !reflect.DeepEqual(sig.Body, w.body) {
+				t.Errorf("signal %s%v, want %s%v", sig.Name, sig.Body, w.name, w.body)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("no %s signal", w.name)
+		}
+	}
+}
@@ -0,0 +1,143 @@
+// Package events is the daemon's in-process event bus. Subsystems publish
+// what happens (shortcuts firing, keys held, devices coming and going) and
+// IPC subscribers receive it as a stream.
+package events
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Event types.
+const (
+	ShortcutFired      = "shortcut"            // Combo, Behavior, Command
+	OverlayEnabled     = "overlay-enabled"     // Overlay
+	OverlayDisabled    = "overlay-disabled"    // Overlay
+	ModeChanged        = "mode"                // Combo, Index, Command: a .switch cycle moved
+	DeviceConnected    = "device-connected"    // Device, Path
+	DeviceDisconnected = "device-disconnected" // Device, Path
+	LoopStarted        = "loop-started"        // Combo
+	LoopStopped        = "loop-stopped"        // Combo
+	KeyHeld            = "key-held"            // Key: held with >>
+	KeyReleased        = "key-released"        // Key
+	State              = "state"               // Overlays, Held: sent first on every subscription
+	Input              = "input"               // Device, Key, Combo, Input: only to SubscribeInput
+)
+
+// subscriberBuffer is how many events a subscriber may fall behind by before
+// further events to it are dropped.
+const subscriberBuffer = 256
+
+// Event is one thing that happened in the daemon. Fields unused by its Type
+// are left empty.
+type Event struct {
+	Time     time.Time  `json:"time"`
+	Type     string     `json:"type"`
+	Combo    string     `json:"combo,omitempty"`
+	Behavior string     `json:"behavior,omitempty"`
+	Command  string     `json:"command,omitempty"`
+	Overlay  string     `json:"overlay,omitempty"`
+	Index    *int       `json:"index,omitempty"` // position a .switch cycle fires next (0-based)
+	Device   string     `json:"device,omitempty"`
+	Path     string     `json:"path,omitempty"`
+	Key      string     `json:"key,omitempty"`
+	Overlays []string   `json:"overlays,omitempty"`
+	Held     []string   `json:"held,omitempty"`
+	Input    *InputInfo `json:"input,omitempty"`
+}
+
+// InputInfo is the raw side of an Input event.
+type InputInfo struct {
+	Kind    string   `json:"kind"` // "key" or "axis"
+	Code    uint16   `json:"code"`
+	Value   int32    `json:"value"`             // keys: 1 press, 0 release, 2 autorepeat
+	Named   bool     `json:"named"`             // false if akeyshually has no name for the code
+	Matches []string `json:"matches,omitempty"` // configured shortcuts the event would reach
+}
+
+var bus = &broker{subs: make(map[chan Event]bool)}
+
+type broker struct {
+	mu         sync.Mutex
+	subs       map[chan Event]bool // true for input subscribers
+	count      atomic.Int32        // len(subs), read without the lock on the publish path
+	inputCount atomic.Int32        // input subscribers among them
+}
+
+// Publish sends e to every subscriber, stamping it with the current time.
+// It never blocks: a subscriber that is not keeping up misses the event.
+func Publish(e Event) {
+	if bus.count.Load() == 0 {
+		return
+	}
+	if e.Time.IsZero() {
+		e.Time = time.Now()
+	}
+
+	bus.mu.Lock()
+	defer bus.mu.Unlock()
+	for ch, input := range bus.subs {
+		if input != (e.Type == Input) {
+			continue
+		}
+		select {
+		case ch <- e:
+		default:
+		}
+	}
+}
+
+// Subscribe returns a channel receiving every event published from now on,
+// except Input events, and the function that ends the subscription and
+// closes the channel.
+func Subscribe() (<-chan Event, func()) {
+	return bus.subscribe(false)
+}
+
+// SubscribeInput is Subscribe for Input events only.
+func SubscribeInput() (<-chan Event, func()) {
+	return bus.subscribe(true)
+}
+
+// Inspecting reports whether anyone subscribes to Input events, so that
+// listeners only describe their events when they will be read.
+func Inspecting() bool {
+	return bus.inputCount.Load() > 0
+}
+
+func (b *broker) subscribe(input bool) (<-chan Event, func()) {
+	ch := make(chan Event, subscriberBuffer)
+	b.mu.Lock()
+	b.subs[ch] = input
+	b.updateCounts()
+	b.mu.Unlock()
+
+	var once sync.Once
+	return ch, func() {
+		once.Do(func() {
+			b.mu.Lock()
+			delete(b.subs, ch)
+			b.updateCounts()
+			b.mu.Unlock()
+			close(ch)
+		})
+	}
+}
+
+// updateCounts refreshes the lock-free counts. Caller holds mu.
+func (b *broker) updateCounts() {
+	var inputs int32
+	for _, input := range b.subs {
+		if input {
+			inputs++
+		}
+	}
+	b.count.Store(int32(len(b.subs)))
+	b.inputCount.Store(inputs)
+}
+
+// Fired publishes a ShortcutFired event.
+func Fired(combo, behavior, command string) {
+	Publish(Event{Type: ShortcutFired, Combo: combo, Behavior: behavior, Command: command})
+}
@@ -0,0 +1,75 @@
+package events
+
+import "testing"
+
+func TestPublishReachesSubscribers(t *testing.T) {
+	a, unsubA := Subscribe()
+	defer unsubA()
+	b, unsubB := Subscribe()
+	defer unsubB()
+
+	Fired("ctrl+t", "normal", "kitty")
+
+	for _, ch := range []<-chan Event{a, b} {
+		e := <-ch
+		if e.Type != ShortcutFired || e.Combo != "ctrl+t" || e.Command != "kitty" {
+			t.Errorf("got %+v, want the fired shortcut", e)
+		}
+		if e.Time.IsZero() {
+			t.Error("event was not timestamped")
+		}
+	}
+}
+
+func TestPublishDropsForSlowSubscriber(t *testing.T) {
+	ch, unsubscribe := Subscribe()
+	defer unsubscribe()
+
+	for range subscriberBuffer + 10 {
+		Publish(Event{Type: KeyHeld, Key: "shift"})
+	}
+	if len(ch) != subscriberBuffer {
+		t.Fatalf("buffered %d events, want %d", len(ch), subscriberBuffer)
+	}
+}
+
+func TestUnsubscribeClosesChannel(t *testing.T) {
+	ch, unsubscribe := Subscribe()
+	unsubscribe()
+	unsubscribe() // safe to call twice
+
+	if _, ok := <-ch; ok {
+		t.Fatal("channel still open after unsubscribe")
+	}
+	Publish(Event{Type: LoopStarted, Combo: "f5"}) // must not panic on the closed channel
+	if n := bus.count.Load(); n != 0 {
+		t.Fatalf("%d subscribers left, want 0", n)
+	}
+}
+
+func TestInputEventsOnlyReachInputSubscribers(t *testing.T) {
+	plain, unsubPlain := Subscribe()
+	defer unsubPlain()
+	input, unsubInput := SubscribeInput()
+
+	if !Inspecting() {
+		t.Fatal("Inspecting() = false with an input subscriber")
+	}
+	Publish(Event{Type: Input, Key: "a"})
+	Fired("ctrl+t", "normal", "kitty")
+
+	if e := <-plain; e.Type != ShortcutFired {
+		t.Fatalf("plain subscriber got %+v, want only the fired shortcut", e)
+	}
+	if e := <-input; e.Type != Input || e.Key != "a" {
+		t.Fatalf("input subscriber got %+v, want only the input event", e)
+	}
+	if len(plain) != 0 || len(input) != 0 {
+		t.Fatalf("left over: %d plain, %d input events", len(plain), len(input))
+	}
+
+	unsubInput()
+	if Inspecting() {
+		t.Fatal("Inspecting() = true after the input subscriber left")
+	}
+}
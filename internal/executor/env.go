@@ -0,0 +1,293 @@
+package executor
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"os"
+	"os/exec"
+	"regexp"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/deprecatedluar/akeyshually/internal/common"
+	"github.com/deprecatedluar/akeyshually/internal/config"
+)
+
+// envCache holds the environment commands run with, so settings.env_file is
+// read once rather than on every press. A file that cannot be used keeps its
+// error until it changes, so it is reported once.
+var envCache struct {
+	mu      sync.Mutex
+	loaded  bool
+	file    string
+	modTime time.Time // zero if the file could not be stat'ed
+	session []string  // session environment the file was applied over
+	env     []string
+	err     error
+}
+
+var envNameRegex = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// LoadEnvironment reads settings.session_env and settings.env_file ahead of
+// the first command, reporting either one that cannot be used.
+func LoadEnvironment(cfg *config.Config) error {
+	if _, err := sessionEnvironment(cfg); err != nil {
+		return fmt.Errorf("session_env: %w", err)
+	}
+	if _, _, err := loadEnvironment(cfg); err != nil {
+		return fmt.Errorf("env_file: %w", err)
+	}
+	return nil
+}
+
+// commandEnvironment returns the environment commands run with: the daemon's
+// own, then the graphical session's, then settings.env_file. A source that
+// cannot be used is skipped, and reported once until it changes.
+func commandEnvironment(cfg *config.Config) []string {
+	env, changed, err := loadEnvironment(cfg)
+	if err != nil && changed {
+		fmt.Fprintf(os.Stderr, "env_file: %v\n", err)
+	}
+	return env
+}
+
+// loadEnvironment returns the cached environment and its env file error,
+// building both again only when the file's path or mtime, or the session
+// environment, changes. changed reports a rebuild.
+func loadEnvironment(cfg *config.Config) (env []string, changed bool, err error) {
+	session, err := sessionEnvironment(cfg)
+	if err != nil {
+		// Reported at startup; the session may not have started yet
+		common.LogDebug("session_env: %v", err)
+	}
+
+	envFile := ""
+	if cfg.Settings.EnvFile != "" {
+		envFile = expandHome(cfg.Settings.EnvFile)
+	}
+	var modTime time.Time
+	var statErr error
+	if envFile != "" {
+		info, err := os.Stat(envFile)
+		if err == nil {
+			modTime = info.ModTime()
+		}
+		statErr = err
+	}
+
+	envCache.mu.Lock()
+	defer envCache.mu.Unlock()
+	if envCache.loaded && envCache.file == envFile && envCache.modTime.Equal(modTime) && slices.Equal(envCache.session, session) {
+		return envCache.env, false, envCache.err
+	}
+
+	env = mergeEnv(os.Environ(), session)
+	err = statErr
+	if envFile != "" && err == nil {
+		var fileEnv []string
+		if fileEnv, err = readEnvFile(envFile, resolveShell(cfg), env); err == nil {
+			env = fileEnv
+		}
+	}
+	envCache.loaded = true
+	envCache.file = envFile
+	envCache.modTime = modTime
+	envCache.session = session
+	envCache.env = env
+	envCache.err = err
+	return env, true, err
+}
+
+// readEnvFile applies envFile to base. Plain assignment files are parsed
+// natively; anything else (conditionals, functions, sourcing other files, as
+// in a typical ~/.profile) is sourced once in shell and the resulting
+// environment captured.
+func readEnvFile(envFile, shell string, base []string) ([]string, error) {
+	data, err := os.ReadFile(envFile)
+	if err != nil {
+		return nil, err
+	}
+	env, err := parseEnvFile(data, base)
+	if err == nil {
+		common.LogDebug("env_file: parsed %s", envFile)
+		return env, nil
+	}
+
+	common.LogDebug("env_file: %v, sourcing %s with %s instead", err, envFile, shell)
+	env, err = sourceEnvFile(shell, envFile, base)
+	if err != nil {
+		return nil, fmt.Errorf("source %s: %w", envFile, err)
+	}
+	return env, nil
+}
+
+// sourceEnvFile sources envFile in shell, started with base, and returns the
+// resulting environment.
+func sourceEnvFile(shell, envFile string, base []string) ([]string, error) {
+	cmd := exec.Command(shell, "-c", `. "$1" >/dev/null && exec env -0`, shell, envFile)
+	cmd.Env = base
+	out, err := cmd.Output()
+	if err != nil {
+		return nil, err
+	}
+	var env []string
+	for _, entry := range bytes.Split(out, []byte{0}) {
+		if bytes.IndexByte(entry, '=') > 0 {
+			env = append(env, string(entry))
+		}
+	}
+	return env, nil
+}
+
+// parseEnvFile applies the assignments in data to base. Each line is blank, a
+// comment or [export] KEY=value, where value follows shell quoting: '...' is
+// literal, "..." and bare words expand $VAR and ${VAR}, bare words expand a
+// leading ~, and a backslash escapes the next character. Returns an error on any other line.
+func parseEnvFile(data []byte, base []string) ([]string, error) {
+	vars := make(map[string]string, len(base))
+	var order []string
+	for _, entry := range base {
+		if name, value, ok := strings.Cut(entry, "="); ok {
+			if _, seen := vars[name]; !seen {
+				order = append(order, name)
+			}
+			vars[name] = value
+		}
+	}
+
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	lineNum := 0
+	for scanner.Scan() {
+		lineNum++
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		line = strings.TrimSpace(strings.TrimPrefix(line, "export "))
+
+		name, raw, ok := strings.Cut(line, "=")
+		if !ok || !envNameRegex.MatchString(name) {
+			return nil, fmt.Errorf("line %d is not a KEY=value assignment", lineNum)
+		}
+		value, err := parseEnvValue(raw, vars)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", lineNum, err)
+		}
+		if _, seen := vars[name]; !seen {
+			order = append(order, name)
+		}
+		vars[name] = value
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	env := make([]string, len(order))
+	for i, name := range order {
+		env[i] = name + "=" + vars[name]
+	}
+	return env, nil
+}
+
+// parseEnvValue unquotes and expands the value of one assignment.
+func parseEnvValue(raw string, vars map[string]string) (string, error) {
+	var value strings.Builder
+	for i := 0; i < len(raw); i++ {
+		switch c := raw[i]; c {
+		case '\'':
+			end := strings.IndexByte(raw[i+1:], '\'')
+			if end < 0 {
+				return "", fmt.Errorf("unterminated single quote")
+			}
+			value.WriteString(raw[i+1 : i+1+end])
+			i += end + 1
+		case '"':
+			i++
+			for ; i < len(raw) && raw[i] != '"'; i++ {
+				switch {
+				case raw[i] == '\\' && i+1 < len(raw) && strings.IndexByte("\"\\$`", raw[i+1]) >= 0:
+					i++
+					value.WriteByte(raw[i])
+				case raw[i] == '$':
+					n, err := expandEnvVar(raw[i:], vars, &value)
+					if err != nil {
+						return "", err
+					}
+					i += n - 1
+				case raw[i] == '`':
+					return "", fmt.Errorf("command substitution is not supported")
+				default:
+					value.WriteByte(raw[i])
+				}
+			}
+			if i == len(raw) {
+				return "", fmt.Errorf("unterminated double quote")
+			}
+		case '\\':
+			if i+1 < len(raw) {
+				i++
+				value.WriteByte(raw[i])
+			}
+		case '$':
+			n, err := expandEnvVar(raw[i:], vars, &value)
+			if err != nil {
+				return "", err
+			}
+			i += n - 1
+		case ' ', '\t':
+			if rest := strings.TrimSpace(raw[i:]); rest != "" && !strings.HasPrefix(rest, "#") {
+				return "", fmt.Errorf("unquoted space in value")
+			}
+			return value.String(), nil
+		case '`', ';', '&', '|', '<', '>', '(', ')':
+			return "", fmt.Errorf("unsupported %q in value", c)
+		case '~':
+			// Tilde expands at the start of the value and after each ':' (PATH-style)
+			if (i == 0 || raw[i-1] == ':') && (i+1 == len(raw) || raw[i+1] == '/' || raw[i+1] == ':') {
+				home, err := os.UserHomeDir()
+				if err == nil {
+					value.WriteString(home)
+					continue
+				}
+			}
+			value.WriteByte(c)
+		default:
+			value.WriteByte(c)
+		}
+	}
+	return value.String(), nil
+}
+
+// expandEnvVar writes the value of the $VAR or ${VAR} at the start of s and
+// returns how many bytes it spans. A lone $ is kept literally.
+func expandEnvVar(s string, vars map[string]string, dst *strings.Builder) (int, error) {
+	if strings.HasPrefix(s, "${") {
+		end := strings.IndexByte(s, '}')
+		if end < 0 {
+			return 0, fmt.Errorf("unterminated ${")
+		}
+		name := s[2:end]
+		if !envNameRegex.MatchString(name) {
+			return 0, fmt.Errorf("unsupported expansion ${%s}", name)
+		}
+		dst.WriteString(vars[name])
+		return end + 1, nil
+	}
+
+	n := 1
+	for n < len(s) && (s[n] == '_' || s[n] >= 'A' && s[n] <= 'Z' || s[n] >= 'a' && s[n] <= 'z' || n > 1 && s[n] >= '0' && s[n] <= '9') {
+		n++
+	}
+	if n == 1 {
+		if n < len(s) && s[n] == '(' {
+			return 0, fmt.Errorf("command substitution is not supported")
+		}
+		dst.WriteByte('$')
+		return 1, nil
+	}
+	dst.WriteString(vars[s[1:n]])
+	return n, nil
+}
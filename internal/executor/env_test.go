@@ -0,0 +1,119 @@
+package executor
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseEnvFile(t *testing.T) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		t.Fatalf("could not get home dir: %v", err)
+	}
+
+	data := `# comment
+export EDITOR=nvim
+PATH="$HOME/bin:${PATH}"
+GREETING='hello $USER'   # literal
+QUOTED="say \"hi\" for \$5"
+MIXED=a"b c"'d'
+EMPTY=
+LOCAL=~/.local:~/bin
+`
+	base := []string{"HOME=/home/test", "PATH=/usr/bin", "USER=test"}
+	env, err := parseEnvFile([]byte(data), base)
+	if err != nil {
+		t.Fatalf("parseEnvFile() error: %v", err)
+	}
+
+	want := []string{
+		"HOME=/home/test",
+		"PATH=/home/test/bin:/usr/bin",
+		"USER=test",
+		"EDITOR=nvim",
+		"GREETING=hello $USER",
+		`QUOTED=say "hi" for $5`,
+		"MIXED=ab cd",
+		"EMPTY=",
+		"LOCAL=" + home + "/.local:" + home + "/bin",
+	}
+	if !slices.Equal(env, want) {
+		t.Errorf("parseEnvFile() =\n%s\nwant\n%s", strings.Join(env, "\n"), strings.Join(want, "\n"))
+	}
+}
+
+func TestParseEnvFileRejectsShellSyntax(t *testing.T) {
+	for _, line := range []string{
+		"if [ -f ~/.bashrc ]; then . ~/.bashrc; fi",
+		"source ~/.env",
+		"export PATH",
+		"NOW=$(date)",
+		"NOW=`date`",
+		"NAME=two words",
+		"DEFAULT=${EDITOR:-vi}",
+		`OPEN="unterminated`,
+	} {
+		if _, err := parseEnvFile([]byte(line+"\n"), nil); err == nil {
+			t.Errorf("%q unexpectedly parsed", line)
+		}
+	}
+}
+
+func TestEnvironmentRefreshesWhenFileChanges(t *testing.T) {
+	envFile := filepath.Join(t.TempDir(), "env")
+	cfg := policyTestConfig()
+	cfg.Settings.EnvFile = envFile
+
+	lookup := func() string {
+		t.Helper()
+		env, _, err := loadEnvironment(cfg)
+		if err != nil {
+			t.Fatalf("loadEnvironment() error: %v", err)
+		}
+		for _, entry := range env {
+			if value, ok := strings.CutPrefix(entry, "AKS_ENV_TEST="); ok {
+				return value
+			}
+		}
+		return ""
+	}
+
+	os.WriteFile(envFile, []byte("AKS_ENV_TEST=one\n"), 0644)
+	if got := lookup(); got != "one" {
+		t.Fatalf("AKS_ENV_TEST = %q, want one", got)
+	}
+
+	// A file the parser does not understand is sourced with the shell instead
+	os.WriteFile(envFile, []byte("if true; then export AKS_ENV_TEST=two; fi\n"), 0644)
+	later := time.Now().Add(time.Second)
+	os.Chtimes(envFile, later, later)
+	if got := lookup(); got != "two" {
+		t.Errorf("AKS_ENV_TEST = %q after the file changed, want two", got)
+	}
+}
+
+func TestEnvironmentKeepsFileErrorUntilChange(t *testing.T) {
+	envFile := filepath.Join(t.TempDir(), "env")
+	os.WriteFile(envFile, []byte("false\n"), 0644) // sourcing it fails
+	cfg := policyTestConfig()
+	cfg.Settings.EnvFile = envFile
+
+	env, changed, err := loadEnvironment(cfg)
+	if err == nil || !changed || len(env) == 0 {
+		t.Fatalf("loadEnvironment() = %d vars, changed %v, %v; want the daemon's environment and an error", len(env), changed, err)
+	}
+	if _, changed, err := loadEnvironment(cfg); err == nil || changed {
+		t.Errorf("second loadEnvironment() changed %v, %v; want the cached error", changed, err)
+	}
+
+	os.WriteFile(envFile, []byte("AKS_ENV_TEST=fixed\n"), 0644)
+	later := time.Now().Add(time.Second)
+	os.Chtimes(envFile, later, later)
+	if _, changed, err := loadEnvironment(cfg); err != nil || !changed {
+		t.Errorf("loadEnvironment() after a fix changed %v, %v; want a rebuild without error", changed, err)
+	}
+}
@@ -18,6 +18,9 @@ type ExecContext struct {
 	Modifiers matcher.ModifierState
 	Config    *config.Config
 	LoopState *LoopState
+	Combo     string               // shortcut that fired, for its Policy
+	Policy    config.ProcessPolicy // how shell commands treat the combo's running process
+	Timeout   float64              // combo's .timeout in milliseconds (0 = settings.command_timeout)
 }
 
 func Run(cmd string, ctx ExecContext) error {
@@ -0,0 +1,291 @@
+package executor
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/sys/unix"
+
+	"github.com/deprecatedluar/akeyshually/internal/common"
+	"github.com/deprecatedluar/akeyshually/internal/config"
+	"github.com/deprecatedluar/akeyshually/internal/stats"
+)
+
+const (
+	historyPerCombo    = 10             // executions kept per shortcut
+	outputTailBytes    = 4096           // captured output kept per execution
+	failureTailLines   = 5              // stderr lines shown in a failure notification
+	commandLogFile     = "commands.log" // under the state directory, with settings.log_commands
+	commandLogMaxBytes = 1 << 20        // rotated to commands.log.1 beyond this
+	stateDirPerm       = 0755
+	commandLogPerm     = 0644
+)
+
+// Execution is a finished shell command, as kept in the history.
+type Execution struct {
+	Combo    string // shortcut that fired it, empty if unknown
+	Command  string
+	Started  time.Time
+	Duration time.Duration
+	ExitCode int    // -1 if killed by a signal
+	Status   string // "exit status 1", "signal: terminated", ...
+	TimedOut bool   // stopped for running past its timeout
+	output   *tailBuffer
+	stderr   *tailBuffer
+}
+
+// Output returns the tail of the command's stdout followed by its stderr.
+func (e Execution) Output() string {
+	return e.output.String()
+}
+
+// history is the daemon-wide record of recent executions.
+var history = &executionHistory{byCombo: make(map[string][]Execution)}
+
+type executionHistory struct {
+	mu      sync.Mutex
+	byCombo map[string][]Execution // ring of the last historyPerCombo, oldest first
+}
+
+// RecentExecutions returns up to n finished executions, most recent first.
+func RecentExecutions(n int) []Execution {
+	history.mu.Lock()
+	var all []Execution
+	for _, execs := range history.byCombo {
+		all = append(all, execs...)
+	}
+	history.mu.Unlock()
+
+	sort.Slice(all, func(i, j int) bool { return all[i].Started.After(all[j].Started) })
+	if n >= 0 && len(all) > n {
+		all = all[:n]
+	}
+	return all
+}
+
+// record stores a finished command, logs it when settings.log_commands is on
+// and notifies on a non-zero exit or timeout when settings.notify_on_failure is on.
+func (h *executionHistory) record(combo, command string, started time.Time, state *os.ProcessState, timedOut bool, capture *outputCapture, cfg *config.Config) {
+	capture.collect()
+
+	e := Execution{
+		Combo:    combo,
+		Command:  command,
+		Started:  started,
+		Duration: time.Since(started),
+		ExitCode: -1,
+		Status:   "unknown",
+		TimedOut: timedOut,
+		output:   capture.output,
+		stderr:   capture.stderr,
+	}
+	if state != nil {
+		e.ExitCode = state.ExitCode()
+		e.Status = state.String()
+	}
+	if timedOut {
+		e.Status = "timed out (" + e.Status + ")"
+	}
+	common.LogDebug("Command %q finished: %s after %v", command, e.Status, e.Duration.Round(time.Millisecond))
+
+	h.mu.Lock()
+	execs := append(h.byCombo[combo], e)
+	if len(execs) > historyPerCombo {
+		execs = execs[len(execs)-historyPerCombo:]
+	}
+	h.byCombo[combo] = execs
+	h.mu.Unlock()
+
+	if cfg.Settings.LogCommands {
+		if err := appendCommandLog(e); err != nil {
+			common.LogDebug("Command log: %v", err)
+		}
+	}
+	if e.ExitCode > 0 || e.TimedOut {
+		stats.CommandFailed(combo)
+		if cfg.Settings.NotifyOnFailure {
+			go notifyFailure(e)
+		}
+	}
+}
+
+func notifyFailure(e Execution) {
+	label := e.Combo
+	if label == "" {
+		label = e.Command
+	}
+	message := lastLines(e.stderr.String(), failureTailLines)
+	if message == "" {
+		message = e.Command
+	}
+	title := fmt.Sprintf("%s failed (exit %d)", label, e.ExitCode)
+	if e.TimedOut {
+		title = fmt.Sprintf("%s timed out after %v", label, e.Duration.Round(time.Second))
+	}
+	common.NotifyError(title, message)
+}
+
+func appendCommandLog(e Execution) error {
+	stateDir, err := config.GetStateDir()
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(stateDir, stateDirPerm); err != nil {
+		return err
+	}
+
+	logPath := filepath.Join(stateDir, commandLogFile)
+	if info, err := os.Stat(logPath); err == nil && info.Size() > commandLogMaxBytes {
+		os.Rename(logPath, logPath+".1")
+	}
+
+	f, err := os.OpenFile(logPath, os.O_APPEND|os.O_CREATE|os.O_WRONLY, commandLogPerm)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	fmt.Fprintf(f, "%s [%s] %s (%v): %s\n", e.Started.Format(time.RFC3339), e.Combo, e.Status,
+		e.Duration.Round(time.Millisecond), e.Command)
+	if output := strings.TrimRight(e.Output(), "\n"); output != "" {
+		for _, line := range strings.Split(output, "\n") {
+			fmt.Fprintf(f, "  | %s\n", line)
+		}
+	}
+	return nil
+}
+
+func lastLines(s string, n int) string {
+	lines := strings.Split(strings.TrimRight(s, "\n"), "\n")
+	if len(lines) > n {
+		lines = lines[len(lines)-n:]
+	}
+	return strings.TrimSpace(strings.Join(lines, "\n"))
+}
+
+// outputCapture collects a command's stdout and stderr in memory files rather
+// than pipes. Nobody has to keep reading a file, so children that outlive the
+// command, the record or the daemon itself (reloads exec a new one) are never
+// blocked or broken by a closed pipe. Once the command exits the files are
+// emptied and sealed: later writes fail, so they cannot grow.
+type outputCapture struct {
+	output   *tailBuffer // stdout, then stderr
+	stderr   *tailBuffer
+	files    []*os.File // stdout and stderr files, read back by collect
+	children []*os.File // write ends handed to the command
+}
+
+// startCapture attaches output files to cmd. On error the command runs with
+// its output discarded, and the capture stays empty.
+func startCapture(cmd *exec.Cmd) (*outputCapture, error) {
+	c := &outputCapture{
+		output: newTailBuffer(outputTailBytes),
+		stderr: newTailBuffer(outputTailBytes),
+	}
+	for range 2 {
+		f, w, err := captureFile()
+		if err != nil {
+			c.abort()
+			c.files, c.children = nil, nil
+			return c, err
+		}
+		c.files = append(c.files, f)
+		c.children = append(c.children, w)
+	}
+	cmd.Stdout = c.children[0]
+	cmd.Stderr = c.children[1]
+	return c, nil
+}
+
+// captureFile creates a sealable memory file and an append-only write end
+// for a command.
+func captureFile() (f, w *os.File, err error) {
+	fd, err := unix.MemfdCreate(common.AppName+"-output", unix.MFD_CLOEXEC|unix.MFD_ALLOW_SEALING)
+	if err != nil {
+		return nil, nil, err
+	}
+	f = os.NewFile(uintptr(fd), "output")
+	w, err = os.OpenFile(fmt.Sprintf("/proc/self/fd/%d", fd), os.O_WRONLY|os.O_APPEND, 0)
+	if err != nil {
+		f.Close()
+		return nil, nil, err
+	}
+	return f, w, nil
+}
+
+// started closes the parent's copies of the write ends.
+func (c *outputCapture) started() {
+	for _, f := range c.children {
+		f.Close()
+	}
+	c.children = nil
+}
+
+// abort releases the files of a command that failed to start.
+func (c *outputCapture) abort() {
+	for _, f := range append(c.children, c.files...) {
+		f.Close()
+	}
+}
+
+// collect reads the tail of what the command wrote into the buffers, then
+// empties, seals and closes the files: writes of children still holding them
+// fail with EPERM from then on instead of filling memory.
+func (c *outputCapture) collect() {
+	for i, f := range c.files {
+		tail := readTail(f, outputTailBytes)
+		c.output.Write(tail)
+		if i == 1 {
+			c.stderr.Write(tail)
+		}
+		f.Truncate(0)
+		unix.FcntlInt(f.Fd(), unix.F_ADD_SEALS, unix.F_SEAL_SHRINK|unix.F_SEAL_GROW|unix.F_SEAL_WRITE)
+		f.Close()
+	}
+	c.files = nil
+}
+
+// readTail returns up to limit bytes from the end of f.
+func readTail(f *os.File, limit int64) []byte {
+	info, err := f.Stat()
+	if err != nil {
+		return nil
+	}
+	offset := max(info.Size()-limit, 0)
+	buf := make([]byte, info.Size()-offset)
+	n, _ := f.ReadAt(buf, offset)
+	return buf[:n]
+}
+
+// tailBuffer keeps the last limit bytes written to it.
+type tailBuffer struct {
+	mu    sync.Mutex
+	data  []byte
+	limit int
+}
+
+func newTailBuffer(limit int) *tailBuffer {
+	return &tailBuffer{limit: limit}
+}
+
+func (b *tailBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.data = append(b.data, p...)
+	if len(b.data) > b.limit {
+		b.data = append([]byte(nil), b.data[len(b.data)-b.limit:]...)
+	}
+	return len(p), nil
+}
+
+func (b *tailBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return string(b.data)
+}
@@ -0,0 +1,136 @@
+package executor
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/deprecatedluar/akeyshually/internal/config"
+)
+
+func lastExecutionOf(t *testing.T, combo string) Execution {
+	t.Helper()
+	for _, e := range RecentExecutions(-1) {
+		if e.Combo == combo {
+			return e
+		}
+	}
+	t.Fatalf("no execution recorded for %s", combo)
+	return Execution{}
+}
+
+func TestHistoryRecordsExitCodeAndOutput(t *testing.T) {
+	proc := startShell("history-exit", "echo out; echo err >&2; exit 3", 0, policyTestConfig())
+	if proc == nil {
+		t.Fatal("startShell() failed")
+	}
+	select {
+	case <-proc.done:
+	case <-time.After(2 * time.Second):
+		t.Fatal("command did not finish")
+	}
+
+	e := lastExecutionOf(t, "history-exit")
+	if e.ExitCode != 3 {
+		t.Errorf("ExitCode = %d, want 3", e.ExitCode)
+	}
+	if out := e.Output(); !strings.Contains(out, "out") || !strings.Contains(out, "err") {
+		t.Errorf("Output() = %q, want stdout and stderr", out)
+	}
+	if got := e.stderr.String(); got != "err\n" {
+		t.Errorf("stderr = %q, want %q", got, "err\n")
+	}
+}
+
+func TestChildOutlivesOutputReader(t *testing.T) {
+	marker := filepath.Join(t.TempDir(), "survived")
+	proc := startShell("history-orphan", "(sleep 0.3; echo late; echo late >&2; touch "+marker+") & echo early", 0, policyTestConfig())
+	if proc == nil {
+		t.Fatal("startShell() failed")
+	}
+	// Once the command is recorded the daemon no longer reads its output,
+	// as after a restart: the background child's writes fail but must not
+	// stop it.
+	<-proc.done
+	if out := lastExecutionOf(t, "history-orphan").Output(); out != "early\n" {
+		t.Errorf("Output() = %q, want %q", out, "early\n")
+	}
+
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		if _, err := os.Stat(marker); err == nil {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("background child did not survive writing its output")
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+}
+
+func TestCollectSealsOutput(t *testing.T) {
+	cmd := exec.Command("true")
+	capture, err := startCapture(cmd)
+	if err != nil {
+		t.Fatalf("startCapture() = %v", err)
+	}
+	child := cmd.Stdout.(*os.File)
+	defer cmd.Stderr.(*os.File).Close()
+	defer child.Close()
+
+	child.WriteString("early\n")
+	capture.collect()
+	if _, err := child.WriteString("late\n"); err == nil {
+		t.Error("write after collect succeeded")
+	}
+	if info, err := child.Stat(); err != nil {
+		t.Errorf("stat output file: %v", err)
+	} else if info.Size() != 0 {
+		t.Errorf("output file holds %d bytes after collect, want 0", info.Size())
+	}
+	if out := capture.output.String(); out != "early\n" {
+		t.Errorf("output = %q, want %q", out, "early\n")
+	}
+}
+
+func TestHistoryKeepsLastPerCombo(t *testing.T) {
+	h := &executionHistory{byCombo: make(map[string][]Execution)}
+	capture := &outputCapture{output: newTailBuffer(8), stderr: newTailBuffer(8)}
+
+	for range historyPerCombo + 3 {
+		h.record("f1", "true", time.Now(), nil, false, capture, policyTestConfig())
+	}
+	if got := len(h.byCombo["f1"]); got != historyPerCombo {
+		t.Errorf("kept %d executions, want %d", got, historyPerCombo)
+	}
+}
+
+func TestTailBufferKeepsTail(t *testing.T) {
+	b := newTailBuffer(4)
+	b.Write([]byte("abc"))
+	b.Write([]byte("defg"))
+	if got := b.String(); got != "defg" {
+		t.Errorf("String() = %q, want %q", got, "defg")
+	}
+}
+
+func TestCommandLog(t *testing.T) {
+	t.Setenv("XDG_STATE_HOME", t.TempDir())
+	cfg := policyTestConfig()
+	cfg.Settings.LogCommands = true
+
+	proc := startShell("log-test", "echo logged", 0, cfg)
+	<-proc.done
+
+	stateDir, _ := config.GetStateDir()
+	data, err := os.ReadFile(filepath.Join(stateDir, commandLogFile))
+	if err != nil {
+		t.Fatalf("read command log: %v", err)
+	}
+	if log := string(data); !strings.Contains(log, "[log-test] exit status 0") || !strings.Contains(log, "  | logged") {
+		t.Errorf("command log = %q", log)
+	}
+}
@@ -5,12 +5,16 @@ import (
 	"fmt"
 	"os"
 	"os/exec"
+	"sort"
 	"strings"
 	"sync"
 	"time"
 
 	"github.com/deprecatedluar/akeyshually/internal/common"
 	"github.com/deprecatedluar/akeyshually/internal/config"
+	"github.com/deprecatedluar/akeyshually/internal/events"
+	"github.com/deprecatedluar/akeyshually/internal/stats"
+	evdev "github.com/holoplot/go-evdev"
 )
 
 type heldOutput struct {
@@ -31,6 +35,10 @@ type LoopState struct {
 	HeldKeys       map[string]heldOutput // sustained remap hold keys
 	PersistentHeld map[string]heldOutput // >> persistent remap keys
 	nextLoopID     uint64
+
+	fired     map[*config.ParsedShortcut][]time.Time // recent fires of rate-limited shortcuts
+	processes map[string]*trackedProcess             // running commands of .single/.restart/.queue shortcuts
+	toggled   map[string]*trackedProcess             // processes started by .toggleprocess
 }
 
 func NewLoopState() *LoopState {
@@ -39,6 +47,9 @@ func NewLoopState() *LoopState {
 		HeldProcesses:  make(map[string]*exec.Cmd),
 		HeldKeys:       make(map[string]heldOutput),
 		PersistentHeld: make(map[string]heldOutput),
+		fired:          make(map[*config.ParsedShortcut][]time.Time),
+		processes:      make(map[string]*trackedProcess),
+		toggled:        make(map[string]*trackedProcess),
 	}
 }
 
@@ -56,11 +67,15 @@ func (s *LoopState) StartLoop(combo string, shortcut *config.ParsedShortcut, exe
 	s.nextLoopID++
 	loopID := s.nextLoopID
 	s.Active[combo] = activeLoop{cancel: cancel, id: loopID}
+	events.Publish(events.Event{Type: events.LoopStarted, Combo: combo})
 
 	resolvedCmd := execCtx.Config.ResolveCommand(shortcut.Commands[0])
 	common.LogTrigger(resolvedCmd)
 	go func() {
-		err := runTickerLoop(ctx, interval, func() error { return run(resolvedCmd, execCtx) })
+		err := runTickerLoop(ctx, interval, func() error {
+			stats.LoopIteration(combo)
+			return run(resolvedCmd, execCtx)
+		})
 		if err != nil {
 			fmt.Fprintf(os.Stderr, "Repeat loop stopped: %v\n", err)
 		}
@@ -68,6 +83,7 @@ func (s *LoopState) StartLoop(combo string, shortcut *config.ParsedShortcut, exe
 		s.Mu.Lock()
 		if active, exists := s.Active[combo]; exists && active.id == loopID {
 			delete(s.Active, combo)
+			events.Publish(events.Event{Type: events.LoopStopped, Combo: combo})
 		}
 		s.Mu.Unlock()
 	}()
@@ -81,7 +97,21 @@ func (s *LoopState) StopLoop(combo string) {
 	if active, exists := s.Active[combo]; exists {
 		active.cancel()
 		delete(s.Active, combo)
+		events.Publish(events.Event{Type: events.LoopStopped, Combo: combo})
+	}
+}
+
+// PersistentKeys returns the targets currently held with >>, sorted.
+func (s *LoopState) PersistentKeys() []string {
+	s.Mu.Lock()
+	defer s.Mu.Unlock()
+
+	held := make([]string, 0, len(s.PersistentHeld))
+	for target := range s.PersistentHeld {
+		held = append(held, target)
 	}
+	sort.Strings(held)
+	return held
 }
 
 // StartHeldProcess starts a sustained process or remap for the given combo
@@ -118,7 +148,7 @@ func (s *LoopState) StartHeldProcess(combo string, shortcut *config.ParsedShortc
 	}
 
 	common.LogTrigger(resolvedCmd)
-	cmd := ExecuteTracked(resolvedCmd, execCtx.Config)
+	cmd := ExecuteTracked(combo, resolvedCmd, execCtx.Timeout, execCtx.Config)
 	if cmd != nil {
 		s.HeldProcesses[combo] = cmd
 	}
@@ -166,6 +196,24 @@ func (s *LoopState) StopHeldProcess(combo string) error {
 	return nil
 }
 
+// RepeatHeldKey mirrors a kernel autorepeat of combo's trigger onto the key
+// its sustained remap holds down (the last of the held codes; modifiers in
+// the target do not repeat). Returns false if combo holds no key.
+func (s *LoopState) RepeatHeldKey(combo string) (bool, error) {
+	s.Mu.Lock()
+	defer s.Mu.Unlock()
+
+	held, exists := s.HeldKeys[combo]
+	if !exists || len(held.Codes) == 0 {
+		return false, nil
+	}
+	code := held.Codes[len(held.Codes)-1]
+	if isPointerButton(code) {
+		return true, nil
+	}
+	return true, held.Output.WriteFrame(evdev.InputEvent{Type: evdev.EV_KEY, Code: evdev.EvCode(code), Value: 2})
+}
+
 // ToggleLoop toggles a repeat loop on/off for the given combo
 func (s *LoopState) ToggleLoop(combo string, shortcut *config.ParsedShortcut, execCtx ExecContext) {
 	s.Mu.Lock()
@@ -41,3 +41,27 @@ func TestStartHeldProcessSustainsSingleArrowRemap(t *testing.T) {
 		t.Fatalf("held key state not cleared: %+v", loopState.HeldKeys)
 	}
 }
+
+func TestRepeatHeldKeyRepeatsTargetKey(t *testing.T) {
+	outputs, keyboard, _ := testOutputs()
+	loopState := NewLoopState()
+	shortcut := &config.ParsedShortcut{Commands: []string{">>ctrl+a"}}
+	execCtx := ExecContext{Outputs: outputs, LoopState: loopState, Config: &config.Config{}}
+
+	if held, _ := loopState.RepeatHeldKey("f9"); held {
+		t.Fatal("RepeatHeldKey reported a hold before any was started")
+	}
+	if err := loopState.StartHeldProcess("f9", shortcut, execCtx); err != nil {
+		t.Fatalf("StartHeldProcess: %v", err)
+	}
+	held, err := loopState.RepeatHeldKey("f9")
+	if !held || err != nil {
+		t.Fatalf("RepeatHeldKey = %v, %v; want true, nil", held, err)
+	}
+
+	events := keyboard.snapshot()
+	repeat := events[len(events)-2]
+	if repeat.Code != evdev.KEY_A || repeat.Value != 2 {
+		t.Fatalf("repeat event = %+v, want KEY_A repeat (ctrl does not repeat)", repeat)
+	}
+}
@@ -0,0 +1,135 @@
+package executor
+
+import (
+	"syscall"
+	"time"
+
+	"github.com/deprecatedluar/akeyshually/internal/common"
+	"github.com/deprecatedluar/akeyshually/internal/config"
+)
+
+const (
+	restartGrace      = 2 * time.Second // SIGTERM to SIGKILL when .restart stops a process
+	timeoutGrace      = 2 * time.Second // SIGTERM to SIGKILL when a command outlives its timeout
+	maxQueuedLaunches = 16              // .queue drops fires beyond this many waiting
+)
+
+// trackedProcess is the running shell command of a shortcut with a process policy.
+type trackedProcess struct {
+	*shellProcess
+	queue []string // commands to start, in order, once cmd exits
+}
+
+// Launch starts command for combo according to policy: .single skips it while
+// combo's previous command still runs, .restart stops that one first and
+// .queue starts it once every earlier one has exited. timeout is the
+// shortcut's .timeout in milliseconds (0 = settings.command_timeout).
+func (s *LoopState) Launch(combo string, policy config.ProcessPolicy, command string, timeout float64, cfg *config.Config) {
+	if policy == config.PolicyNone {
+		Execute(combo, command, timeout, cfg)
+		return
+	}
+
+	s.Mu.Lock()
+	defer s.Mu.Unlock()
+
+	running, exists := s.processes[combo]
+	if !exists {
+		s.startProcess(combo, command, nil, commandTimeout(timeout, cfg), cfg)
+		return
+	}
+
+	switch policy {
+	case config.PolicySingle:
+		common.LogDebug("%s: previous command still running, skipped (.single)", combo)
+	case config.PolicyRestart:
+		common.LogDebug("%s: stopping previous command (.restart)", combo)
+		running.queue = []string{command}
+		stopProcessGroup(running.shellProcess, syscall.SIGTERM, restartGrace)
+	case config.PolicyQueue:
+		if len(running.queue) >= maxQueuedLaunches {
+			common.LogDebug("%s: %d commands already queued, dropped (.queue)", combo, len(running.queue))
+			return
+		}
+		running.queue = append(running.queue, command)
+	}
+}
+
+// startProcess starts command as combo's tracked process, carrying over the
+// commands still queued behind it. Caller holds Mu.
+func (s *LoopState) startProcess(combo, command string, queue []string, limit time.Duration, cfg *config.Config) {
+	started := startShell(combo, command, limit, cfg)
+	if started == nil {
+		return
+	}
+
+	proc := &trackedProcess{shellProcess: started, queue: queue}
+	s.processes[combo] = proc
+
+	go func() {
+		<-proc.done
+
+		s.Mu.Lock()
+		defer s.Mu.Unlock()
+		if s.processes[combo] != proc {
+			return
+		}
+		delete(s.processes, combo)
+		if len(proc.queue) > 0 {
+			s.startProcess(combo, proc.queue[0], proc.queue[1:], limit, cfg)
+		}
+	}()
+}
+
+// ToggleProcess starts the shortcut's command for combo, or stops it if the
+// one a previous press started is still running.
+func (s *LoopState) ToggleProcess(combo string, shortcut *config.ParsedShortcut, execCtx ExecContext) {
+	s.Mu.Lock()
+	defer s.Mu.Unlock()
+
+	if running, exists := s.toggled[combo]; exists {
+		common.LogDebug("%s: stopping toggled process (signal %d)", combo, shortcut.StopSignal)
+		delete(s.toggled, combo)
+		stopProcessGroup(running.shellProcess, shortcut.StopSignal, time.Duration(shortcut.StopTimeout*float64(time.Millisecond)))
+		return
+	}
+
+	resolvedCmd := execCtx.Config.ResolveCommand(shortcut.Commands[0])
+	common.LogTrigger(resolvedCmd)
+	started := startShell(combo, resolvedCmd, commandTimeout(shortcut.Timeout, execCtx.Config), execCtx.Config)
+	if started == nil {
+		return
+	}
+
+	proc := &trackedProcess{shellProcess: started}
+	s.toggled[combo] = proc
+	s.watchToggled(combo, proc)
+}
+
+// watchToggled forgets combo's toggled process once it exits on its own, so
+// the next press starts it again.
+func (s *LoopState) watchToggled(combo string, proc *trackedProcess) {
+	go func() {
+		<-proc.done
+
+		s.Mu.Lock()
+		defer s.Mu.Unlock()
+		if s.toggled[combo] == proc {
+			delete(s.toggled, combo)
+		}
+	}()
+}
+
+// stopProcessGroup sends sig to the process and everything it spawned
+// (commands run in their own session), and SIGKILL if it outlives grace.
+func stopProcessGroup(proc *shellProcess, sig syscall.Signal, grace time.Duration) {
+	pgid := -proc.cmd.Process.Pid
+	syscall.Kill(pgid, sig)
+	time.AfterFunc(grace, func() {
+		select {
+		case <-proc.done:
+		default:
+			syscall.Kill(pgid, syscall.SIGKILL)
+		}
+	})
+}
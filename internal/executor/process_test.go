@@ -0,0 +1,287 @@
+package executor
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/deprecatedluar/akeyshually/internal/config"
+)
+
+func policyTestConfig() *config.Config {
+	return &config.Config{Settings: config.Settings{Shell: "sh"}}
+}
+
+// waitForFile polls until path holds want, or fails after a second.
+func waitForFile(t *testing.T, path, want string) {
+	t.Helper()
+	var got []byte
+	for range 100 {
+		got, _ = os.ReadFile(path)
+		if string(got) == want {
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatalf("%s = %q, want %q", filepath.Base(path), got, want)
+}
+
+func waitForProgram(t *testing.T, pid int, name string) {
+	t.Helper()
+	comm := filepath.Join("/proc", strconv.Itoa(pid), "comm")
+	var got []byte
+	for range 100 {
+		got, _ = os.ReadFile(comm)
+		if strings.TrimSpace(string(got)) == name {
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatalf("process %d runs %q, want %q", pid, strings.TrimSpace(string(got)), name)
+}
+
+func waitForIdle(t *testing.T, s *LoopState, combo string) {
+	t.Helper()
+	for range 200 {
+		s.Mu.Lock()
+		_, running := s.processes[combo]
+		s.Mu.Unlock()
+		if !running {
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatalf("%s still running", combo)
+}
+
+func TestLaunchSingleSkipsWhileRunning(t *testing.T) {
+	out := filepath.Join(t.TempDir(), "out")
+	s := NewLoopState()
+	cmd := "echo run >> " + out + "; sleep 0.3"
+
+	s.Launch("f1", config.PolicySingle, cmd, 0, policyTestConfig())
+	s.Launch("f1", config.PolicySingle, cmd, 0, policyTestConfig())
+	waitForIdle(t, s, "f1")
+	waitForFile(t, out, "run\n")
+
+	s.Launch("f1", config.PolicySingle, cmd, 0, policyTestConfig())
+	waitForIdle(t, s, "f1")
+	waitForFile(t, out, "run\nrun\n")
+}
+
+func TestLaunchRestartStopsPrevious(t *testing.T) {
+	out := filepath.Join(t.TempDir(), "out")
+	s := NewLoopState()
+
+	s.Launch("f1", config.PolicyRestart, "sleep 5; echo first >> "+out, 0, policyTestConfig())
+	s.Launch("f1", config.PolicyRestart, "echo second >> "+out, 0, policyTestConfig())
+	waitForIdle(t, s, "f1")
+	waitForFile(t, out, "second\n")
+}
+
+func TestLaunchQueueRunsInOrder(t *testing.T) {
+	out := filepath.Join(t.TempDir(), "out")
+	s := NewLoopState()
+
+	for _, word := range []string{"one", "two", "three"} {
+		s.Launch("f1", config.PolicyQueue, "sleep 0.05; echo "+word+" >> "+out, 0, policyTestConfig())
+	}
+	waitForIdle(t, s, "f1")
+	got, _ := os.ReadFile(out)
+	if lines := strings.Fields(string(got)); strings.Join(lines, " ") != "one two three" {
+		t.Errorf("queued commands ran as %v, want [one two three]", lines)
+	}
+}
+
+func toggledProcess(s *LoopState, combo string) *trackedProcess {
+	s.Mu.Lock()
+	defer s.Mu.Unlock()
+	return s.toggled[combo]
+}
+
+func TestToggleProcessStartsAndStops(t *testing.T) {
+	s := NewLoopState()
+	shortcut := &config.ParsedShortcut{Commands: []string{"exec sleep 5"}, StopSignal: syscall.SIGINT, StopTimeout: 5000}
+	execCtx := ExecContext{Config: policyTestConfig()}
+
+	s.ToggleProcess("f9", shortcut, execCtx)
+	proc := toggledProcess(s, "f9")
+	if proc == nil {
+		t.Fatal("first press did not start the process")
+	}
+	// A shell interrupted while it forks can lose the signal
+	waitForProgram(t, proc.cmd.Process.Pid, "sleep")
+
+	s.ToggleProcess("f9", shortcut, execCtx)
+	if toggledProcess(s, "f9") != nil {
+		t.Fatal("second press did not stop the process")
+	}
+	select {
+	case <-proc.done:
+	case <-time.After(time.Second):
+		t.Fatal("process survived SIGINT")
+	}
+}
+
+func TestToggleProcessEscalatesToKill(t *testing.T) {
+	ready := filepath.Join(t.TempDir(), "ready")
+	s := NewLoopState()
+	shortcut := &config.ParsedShortcut{Commands: []string{"trap '' INT; echo up > " + ready + "; sleep 5"}, StopSignal: syscall.SIGINT, StopTimeout: 100}
+	execCtx := ExecContext{Config: policyTestConfig()}
+
+	s.ToggleProcess("f9", shortcut, execCtx)
+	proc := toggledProcess(s, "f9")
+	waitForFile(t, ready, "up\n") // the trap is installed
+	s.ToggleProcess("f9", shortcut, execCtx)
+
+	select {
+	case <-proc.done:
+	case <-time.After(2 * time.Second):
+		t.Fatal("process ignoring SIGINT was not killed after the timeout")
+	}
+}
+
+func TestCommandTimeoutFallsBackToSetting(t *testing.T) {
+	cfg := policyTestConfig()
+	cfg.Settings.CommandTimeout = 30000
+
+	if got := commandTimeout(500, cfg); got != 500*time.Millisecond {
+		t.Errorf("commandTimeout(500) = %v, want 500ms", got)
+	}
+	if got := commandTimeout(0, cfg); got != 30*time.Second {
+		t.Errorf("commandTimeout(0) = %v, want the 30s setting", got)
+	}
+	if got := commandTimeout(0, policyTestConfig()); got != 0 {
+		t.Errorf("commandTimeout(0) without a setting = %v, want no limit", got)
+	}
+}
+
+func TestCommandTimeoutStopsPolicyCommands(t *testing.T) {
+	cfg := policyTestConfig()
+	cfg.Settings.CommandTimeout = 50
+
+	s := NewLoopState()
+	s.Launch("timeout-single", config.PolicySingle, "sleep 5", 0, cfg)
+	waitForIdle(t, s, "timeout-single")
+	if e := lastExecutionOf(t, "timeout-single"); !e.TimedOut {
+		t.Errorf("execution not marked as timed out: %q", e.Status)
+	}
+}
+
+// A .taphold command runs until its key is released.
+func TestExecuteHeldIgnoresCommandTimeout(t *testing.T) {
+	cfg := policyTestConfig()
+	cfg.Settings.CommandTimeout = 50
+	out := filepath.Join(t.TempDir(), "held")
+
+	if ExecuteHeld("f2", "sleep 0.2; echo done > "+out, 0, cfg) == nil {
+		t.Fatal("ExecuteHeld() failed")
+	}
+	waitForFile(t, out, "done\n")
+}
+
+func TestCommandTimeoutStopsProcessGroup(t *testing.T) {
+	pidFile := filepath.Join(t.TempDir(), "pid")
+	proc := startShell("timeout-test", "sleep 5 & echo $! > "+pidFile+"; wait", 100*time.Millisecond, policyTestConfig())
+	if proc == nil {
+		t.Fatal("startShell() failed")
+	}
+
+	select {
+	case <-proc.done:
+	case <-time.After(2 * time.Second):
+		t.Fatal("command outlived its timeout")
+	}
+	if e := lastExecutionOf(t, "timeout-test"); !e.TimedOut {
+		t.Errorf("execution not marked as timed out: %q", e.Status)
+	}
+
+	data, err := os.ReadFile(pidFile)
+	if err != nil {
+		t.Fatalf("read child pid: %v", err)
+	}
+	var pid int
+	if _, err := fmt.Sscan(string(data), &pid); err != nil {
+		t.Fatalf("parse child pid %q: %v", data, err)
+	}
+	for range 100 {
+		if processGone(pid) {
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Errorf("background child %d survived the timeout", pid)
+}
+
+// processGone reports whether pid has exited. The orphaned child may linger
+// as a zombie until whoever adopted it reaps it.
+func processGone(pid int) bool {
+	stat, err := os.ReadFile(fmt.Sprintf("/proc/%d/stat", pid))
+	if err != nil {
+		return true
+	}
+	fields := strings.Fields(string(stat[strings.LastIndexByte(string(stat), ')')+1:]))
+	return len(fields) > 0 && fields[0] == "Z"
+}
+
+func TestToggledProcessSurvivesRestart(t *testing.T) {
+	path := filepath.Join(t.TempDir(), toggledFile)
+	shortcut := &config.ParsedShortcut{Commands: []string{"exec sleep 5"}, StopSignal: syscall.SIGTERM, StopTimeout: 5000}
+	execCtx := ExecContext{Config: policyTestConfig()}
+
+	before := NewLoopState()
+	before.ToggleProcess("f9", shortcut, execCtx)
+	proc := toggledProcess(before, "f9")
+	if proc == nil {
+		t.Fatal("first press did not start the process")
+	}
+	waitForProgram(t, proc.cmd.Process.Pid, "sleep")
+	if err := before.SaveToggled(path); err != nil {
+		t.Fatalf("SaveToggled() = %v", err)
+	}
+
+	after := NewLoopState()
+	if err := after.RestoreToggled(path); err != nil {
+		t.Fatalf("RestoreToggled() = %v", err)
+	}
+	if toggledProcess(after, "f9") == nil {
+		t.Fatal("running process was not adopted")
+	}
+	after.ToggleProcess("f9", shortcut, execCtx)
+	if toggledProcess(after, "f9") != nil {
+		t.Fatal("press after the restart did not stop the process")
+	}
+	select {
+	case <-proc.done:
+	case <-time.After(time.Second):
+		t.Fatal("adopted process survived SIGTERM")
+	}
+}
+
+func TestRestoreToggledSkipsReusedPID(t *testing.T) {
+	path := filepath.Join(t.TempDir(), toggledFile)
+	start, err := processStartTime(os.Getpid())
+	if err != nil {
+		t.Fatalf("processStartTime() = %v", err)
+	}
+	saved := fmt.Sprintf(`[{"combo": "f9", "pid": %d, "start_time": %d}]`, os.Getpid(), start+1)
+	if err := os.WriteFile(path, []byte(saved), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	s := NewLoopState()
+	if err := s.RestoreToggled(path); err != nil {
+		t.Fatalf("RestoreToggled() = %v", err)
+	}
+	if toggledProcess(s, "f9") != nil {
+		t.Error("adopted a different process that reused the PID")
+	}
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Error("state file was not consumed")
+	}
+}
@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"strings"
 
+	"github.com/deprecatedluar/akeyshually/internal/events"
 	"github.com/deprecatedluar/akeyshually/internal/keys"
 	"github.com/deprecatedluar/akeyshually/internal/matcher"
 	evdev "github.com/holoplot/go-evdev"
@@ -32,6 +33,7 @@ func runRemap(cmd string, ctx ExecContext) error {
 				releaseErrors = append(releaseErrors, fmt.Errorf("release %q: %w", key, err))
 			}
 			delete(ctx.LoopState.PersistentHeld, key)
+			events.Publish(events.Event{Type: events.KeyReleased, Key: key})
 		}
 		return errors.Join(releaseErrors...)
 
@@ -52,6 +54,7 @@ func runRemap(cmd string, ctx ExecContext) error {
 		}
 		if len(codes) > 0 {
 			ctx.LoopState.PersistentHeld[target] = heldOutput{Output: output, Codes: codes}
+			events.Publish(events.Event{Type: events.KeyHeld, Key: target})
 		}
 		return nil
 
@@ -74,7 +77,10 @@ func runRemap(cmd string, ctx ExecContext) error {
 		}
 		if ctx.LoopState != nil {
 			ctx.LoopState.Mu.Lock()
-			delete(ctx.LoopState.PersistentHeld, target)
+			if _, held := ctx.LoopState.PersistentHeld[target]; held {
+				delete(ctx.LoopState.PersistentHeld, target)
+				events.Publish(events.Event{Type: events.KeyReleased, Key: target})
+			}
 			ctx.LoopState.Mu.Unlock()
 		}
 		return nil
@@ -205,13 +211,13 @@ func remapHoldTarget(cmd string) (target string, ok bool) {
 func isModifierHeld(code uint16, held matcher.ModifierState) bool {
 	switch evdev.EvCode(code) {
 	case evdev.KEY_LEFTMETA, evdev.KEY_RIGHTMETA:
-		return held.Super
+		return held.Has("super")
 	case evdev.KEY_LEFTCTRL, evdev.KEY_RIGHTCTRL:
-		return held.Ctrl
+		return held.Has("ctrl")
 	case evdev.KEY_LEFTALT, evdev.KEY_RIGHTALT:
-		return held.Alt
+		return held.Has("alt")
 	case evdev.KEY_LEFTSHIFT, evdev.KEY_RIGHTSHIFT:
-		return held.Shift
+		return held.Has("shift")
 	}
 	return false
 }
@@ -0,0 +1,167 @@
+package executor
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/deprecatedluar/akeyshually/internal/config"
+)
+
+const sessionEnvSystemd = "systemd" // settings.session_env value for the systemd user manager
+
+// showEnvironmentCommand prints the systemd user manager's environment, which
+// the compositor or session startup fills in with dbus-update-activation-environment.
+var showEnvironmentCommand = []string{"systemctl", "--user", "show-environment"}
+
+// sessionEnvMaxAge is how old the imported systemd environment may get before
+// a launch imports it again.
+var sessionEnvMaxAge = 2 * time.Second
+
+// sessionCache holds the last imported session environment. The systemd
+// environment is refreshed in the background by a launch that finds it older
+// than sessionEnvMaxAge, a file is read again once its mtime changes. A
+// failed import keeps the previous environment.
+var sessionCache struct {
+	mu         sync.Mutex
+	source     string
+	generation int // bumped when source changes, discarding a refresh of the old one
+	modTime    time.Time
+	imported   time.Time // when the systemd environment was last imported
+	refreshing bool      // a refresh is running
+	env        []string
+	err        error // last systemd import failure
+}
+
+// sessionEnvironment returns the graphical session's variables to apply to
+// commands, per settings.session_env: "systemd" imports the systemd user
+// manager's environment, any other value names a KEY=value file.
+func sessionEnvironment(cfg *config.Config) ([]string, error) {
+	source := cfg.Settings.SessionEnv
+	if source == "" {
+		return nil, nil
+	}
+	if source != sessionEnvSystemd {
+		source = expandHome(source)
+	}
+
+	sessionCache.mu.Lock()
+	defer sessionCache.mu.Unlock()
+	if sessionCache.source != source {
+		sessionCache.source = source
+		sessionCache.generation++
+		sessionCache.modTime = time.Time{}
+		sessionCache.refreshing = false
+		sessionCache.env = nil
+		sessionCache.err = nil
+		if source == sessionEnvSystemd {
+			// Imported once here (at startup, by LoadEnvironment), then
+			// refreshed behind launches so none of them waits on systemctl
+			sessionCache.env, sessionCache.err = importSystemdEnv(showEnvironmentCommand)
+			sessionCache.imported = time.Now()
+		}
+	}
+	if source == sessionEnvSystemd {
+		if !sessionCache.refreshing && time.Since(sessionCache.imported) >= sessionEnvMaxAge {
+			sessionCache.refreshing = true
+			go refreshSystemdEnv(sessionCache.generation, showEnvironmentCommand)
+		}
+		return sessionCache.env, sessionCache.err
+	}
+
+	info, err := os.Stat(source)
+	if err != nil {
+		return sessionCache.env, err
+	}
+	if sessionCache.env != nil && sessionCache.modTime.Equal(info.ModTime()) {
+		return sessionCache.env, nil
+	}
+	data, err := os.ReadFile(source)
+	if err != nil {
+		return sessionCache.env, err
+	}
+	sessionCache.modTime = info.ModTime()
+	sessionCache.env = parseSessionEnv(data)
+	return sessionCache.env, nil
+}
+
+// refreshSystemdEnv imports the systemd environment again for the next
+// launches, unless settings.session_env has named another source meanwhile.
+func refreshSystemdEnv(generation int, command []string) {
+	env, err := importSystemdEnv(command)
+
+	sessionCache.mu.Lock()
+	defer sessionCache.mu.Unlock()
+	if sessionCache.generation != generation {
+		return
+	}
+	if err == nil {
+		sessionCache.env = env
+	}
+	sessionCache.err = err
+	sessionCache.imported = time.Now()
+	sessionCache.refreshing = false
+}
+
+// importSystemdEnv runs command (showEnvironmentCommand) and parses its output.
+func importSystemdEnv(command []string) ([]string, error) {
+	out, err := exec.Command(command[0], command[1:]...).Output()
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", strings.Join(command, " "), err)
+	}
+	return parseSessionEnv(out), nil
+}
+
+// parseSessionEnv reads KEY=value lines as printed by systemctl show-environment,
+// which writes values with special characters as $'...' with C escapes.
+func parseSessionEnv(data []byte) []string {
+	var env []string
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		name, value, ok := strings.Cut(scanner.Text(), "=")
+		if !ok || !envNameRegex.MatchString(name) {
+			continue
+		}
+		if strings.HasPrefix(value, "$'") && strings.HasSuffix(value, "'") && len(value) >= 3 {
+			inner := strings.ReplaceAll(value[2:len(value)-1], `\'`, `'`)
+			unquoted, err := strconv.Unquote(`"` + strings.ReplaceAll(inner, `"`, `\"`) + `"`)
+			if err != nil {
+				continue
+			}
+			value = unquoted
+		}
+		env = append(env, name+"="+value)
+	}
+	return env
+}
+
+// mergeEnv returns base with the variables in overrides set, replacing any
+// with the same name.
+func mergeEnv(base, overrides []string) []string {
+	if len(overrides) == 0 {
+		return base
+	}
+	index := make(map[string]int, len(base))
+	merged := make([]string, 0, len(base)+len(overrides))
+	for _, entry := range base {
+		name, _, _ := strings.Cut(entry, "=")
+		index[name] = len(merged)
+		merged = append(merged, entry)
+	}
+	for _, entry := range overrides {
+		name, _, _ := strings.Cut(entry, "=")
+		if i, ok := index[name]; ok {
+			merged[i] = entry
+			continue
+		}
+		index[name] = len(merged)
+		merged = append(merged, entry)
+	}
+	return merged
+}
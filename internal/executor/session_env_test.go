@@ -0,0 +1,139 @@
+package executor
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseSessionEnv(t *testing.T) {
+	out := `DISPLAY=:0
+WAYLAND_DISPLAY=wayland-1
+XDG_SESSION_DESKTOP=$'Hyprland'
+MOTD=$'line one\nit\'s "two"'
+not a variable
+`
+	want := []string{
+		"DISPLAY=:0",
+		"WAYLAND_DISPLAY=wayland-1",
+		"XDG_SESSION_DESKTOP=Hyprland",
+		"MOTD=line one\nit's \"two\"",
+	}
+	if got := parseSessionEnv([]byte(out)); !slices.Equal(got, want) {
+		t.Errorf("parseSessionEnv() = %q, want %q", got, want)
+	}
+}
+
+func TestMergeEnvOverrides(t *testing.T) {
+	got := mergeEnv([]string{"PATH=/usr/bin", "HOME=/home/test"}, []string{"DISPLAY=:0", "PATH=/bin"})
+	want := []string{"PATH=/bin", "HOME=/home/test", "DISPLAY=:0"}
+	if !slices.Equal(got, want) {
+		t.Errorf("mergeEnv() = %q, want %q", got, want)
+	}
+}
+
+func lookupEnv(env []string, name string) string {
+	for _, entry := range env {
+		if value, ok := strings.CutPrefix(entry, name+"="); ok {
+			return value
+		}
+	}
+	return ""
+}
+
+func TestSessionEnvFromSystemd(t *testing.T) {
+	dir := t.TempDir()
+	out := filepath.Join(dir, "show-environment")
+	os.WriteFile(out, []byte("WAYLAND_DISPLAY=wayland-1\n"), 0644)
+	useShowEnvironment(t, "cat", out)
+
+	envFile := filepath.Join(dir, "env")
+	os.WriteFile(envFile, []byte("AKS_SESSION_DISPLAY=$WAYLAND_DISPLAY\n"), 0644)
+	cfg := policyTestConfig()
+	cfg.Settings.SessionEnv = "systemd"
+	cfg.Settings.EnvFile = envFile
+
+	env := commandEnvironment(cfg)
+	if got := lookupEnv(env, "WAYLAND_DISPLAY"); got != "wayland-1" {
+		t.Errorf("WAYLAND_DISPLAY = %q, want wayland-1", got)
+	}
+	if got := lookupEnv(env, "AKS_SESSION_DISPLAY"); got != "wayland-1" {
+		t.Errorf("env_file did not see the session variable: AKS_SESSION_DISPLAY = %q", got)
+	}
+
+	// A session starting later is picked up by a refresh behind the next
+	// launches, which keep using the last import meanwhile
+	sessionCache.mu.Lock()
+	showEnvironmentCommand = []string{"sh", "-c", "sleep 1; cat " + out}
+	sessionCache.mu.Unlock()
+	time.Sleep(100 * time.Millisecond) // an import already running finishes
+	os.WriteFile(out, []byte("WAYLAND_DISPLAY=wayland-2\n"), 0644)
+	launched := time.Now()
+	if got := lookupEnv(commandEnvironment(cfg), "WAYLAND_DISPLAY"); got != "wayland-1" {
+		t.Errorf("WAYLAND_DISPLAY = %q while the import runs, want the cached wayland-1", got)
+	}
+	if waited := time.Since(launched); waited > 500*time.Millisecond {
+		t.Errorf("commandEnvironment() waited %v for systemctl", waited)
+	}
+	for deadline := time.Now().Add(3 * time.Second); ; {
+		if got := lookupEnv(commandEnvironment(cfg), "AKS_SESSION_DISPLAY"); got == "wayland-2" {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("session change was not picked up")
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+}
+
+func TestSessionEnvRefreshesOnlyOnLaunch(t *testing.T) {
+	count := filepath.Join(t.TempDir(), "count")
+	useShowEnvironment(t, "sh", "-c", "echo >> "+count+"; echo DISPLAY=:0")
+	cfg := policyTestConfig()
+	cfg.Settings.SessionEnv = "systemd"
+
+	commandEnvironment(cfg)
+	time.Sleep(10 * sessionEnvMaxAge)
+	if data, _ := os.ReadFile(count); strings.Count(string(data), "\n") != 1 {
+		t.Errorf("systemctl ran %d times without a launch, want 1", strings.Count(string(data), "\n"))
+	}
+}
+
+// useShowEnvironment imports the systemd environment from command for the
+// rest of the test, refreshing it after 20ms.
+func useShowEnvironment(t *testing.T, command ...string) {
+	// A running refresh reads these under the cache lock
+	sessionCache.mu.Lock()
+	saved, savedMaxAge := showEnvironmentCommand, sessionEnvMaxAge
+	showEnvironmentCommand = command
+	sessionEnvMaxAge = 20 * time.Millisecond
+	sessionCache.mu.Unlock()
+	t.Cleanup(func() {
+		sessionCache.mu.Lock()
+		defer sessionCache.mu.Unlock()
+		sessionCache.source = ""
+		sessionCache.generation++
+		showEnvironmentCommand, sessionEnvMaxAge = saved, savedMaxAge
+	})
+}
+
+func TestSessionEnvFromFile(t *testing.T) {
+	sessionFile := filepath.Join(t.TempDir(), "session.env")
+	os.WriteFile(sessionFile, []byte("DISPLAY=:1\n"), 0644)
+	cfg := policyTestConfig()
+	cfg.Settings.SessionEnv = sessionFile
+
+	if got := lookupEnv(commandEnvironment(cfg), "DISPLAY"); got != ":1" {
+		t.Fatalf("DISPLAY = %q, want :1", got)
+	}
+
+	os.WriteFile(sessionFile, []byte("DISPLAY=:2\n"), 0644)
+	later := time.Now().Add(time.Second)
+	os.Chtimes(sessionFile, later, later)
+	if got := lookupEnv(commandEnvironment(cfg), "DISPLAY"); got != ":2" {
+		t.Errorf("DISPLAY = %q after the file changed, want :2", got)
+	}
+}
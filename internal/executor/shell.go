@@ -6,8 +6,11 @@ import (
 	"os/exec"
 	"path/filepath"
 	"strings"
+	"sync/atomic"
 	"syscall"
+	"time"
 
+	"github.com/deprecatedluar/akeyshually/internal/common"
 	"github.com/deprecatedluar/akeyshually/internal/config"
 )
 
@@ -17,53 +20,139 @@ const (
 )
 
 // runShell executes a shell command via the unified Run() entry point.
+// Commands fired by a shortcut with a process policy go through LoopState.
 func runShell(command string, ctx ExecContext) {
-	Execute(command, ctx.Config)
+	if ctx.Policy != config.PolicyNone && ctx.LoopState != nil {
+		ctx.LoopState.Launch(ctx.Combo, ctx.Policy, command, ctx.Timeout, ctx.Config)
+		return
+	}
+	Execute(ctx.Combo, command, ctx.Timeout, ctx.Config)
 }
 
-// Execute starts a command in fire-and-forget mode.
-func Execute(command string, cfg *config.Config) {
-	ExecuteTracked(command, cfg)
+// Execute starts a command in fire-and-forget mode. combo labels it in the
+// execution history; timeout is the shortcut's .timeout in milliseconds
+// (0 = settings.command_timeout).
+func Execute(combo, command string, timeout float64, cfg *config.Config) {
+	startShell(combo, command, commandTimeout(timeout, cfg), cfg)
 }
 
 // ExecuteTracked starts a command and returns the exec.Cmd for process lifecycle management.
 // Returns nil if the command fails to start.
-func ExecuteTracked(command string, cfg *config.Config) *exec.Cmd {
-	shell := cfg.Settings.Shell
-	if shell == "" {
-		shell = os.Getenv("SHELL")
-		if shell == "" {
-			shell = fallbackShell
-		}
-	}
+func ExecuteTracked(combo, command string, timeout float64, cfg *config.Config) *exec.Cmd {
+	return startedCmd(startShell(combo, command, commandTimeout(timeout, cfg), cfg))
+}
 
-	fullCommand := cdPrefix + command
+// ExecuteHeld starts a .taphold command, which runs until its key is
+// released: only the shortcut's .timeout limits it, not
+// settings.command_timeout. Returns nil if the command fails to start.
+func ExecuteHeld(combo, command string, timeout float64, cfg *config.Config) *exec.Cmd {
+	return startedCmd(startShell(combo, command, shortcutTimeout(timeout), cfg))
+}
 
-	if cfg.Settings.EnvFile != "" {
-		envFile := expandHome(cfg.Settings.EnvFile)
-		fullCommand = fmt.Sprintf("source %s && %s", envFile, fullCommand)
+func startedCmd(proc *shellProcess) *exec.Cmd {
+	if proc == nil {
+		return nil
 	}
+	return proc.cmd
+}
+
+// commandTimeout returns how long a command may run: the shortcut's .timeout
+// in milliseconds, else settings.command_timeout. Zero means no limit.
+func commandTimeout(timeout float64, cfg *config.Config) time.Duration {
+	if timeout == 0 {
+		timeout = cfg.Settings.CommandTimeout
+	}
+	return shortcutTimeout(timeout)
+}
+
+// shortcutTimeout converts a .timeout in milliseconds (0 = no limit).
+func shortcutTimeout(timeout float64) time.Duration {
+	return time.Duration(timeout * float64(time.Millisecond))
+}
 
-	cmd := exec.Command(shell, "-c", fullCommand)
+// shellProcess is a started command; done closes once it has exited and
+// its execution has been recorded.
+type shellProcess struct {
+	cmd      *exec.Cmd
+	done     chan struct{}
+	timedOut atomic.Bool
+}
+
+// startShell starts a command in its own session with its output captured,
+// and reaps it in the background. A command still running after limit is
+// stopped with its whole process group (no limit if zero).
+// Returns nil if the command fails to start.
+func startShell(combo, command string, limit time.Duration, cfg *config.Config) *shellProcess {
+	cmd := buildCommand(command, cfg)
 	cmd.SysProcAttr = &syscall.SysProcAttr{Setsid: true}
+	command = config.DisplayCommand(command)
+
+	capture, err := startCapture(cmd)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to capture output of '%s': %v\n", command, err)
+	}
 
 	if err := cmd.Start(); err != nil {
+		capture.abort()
 		fmt.Fprintf(os.Stderr, "Failed to execute '%s': %v\n", command, err)
 		return nil
 	}
+	capture.started()
 
-	go cmd.Wait()
+	proc := &shellProcess{cmd: cmd, done: make(chan struct{})}
+	started := time.Now()
+	var timer *time.Timer
+	if limit > 0 {
+		timer = time.AfterFunc(limit, func() {
+			common.LogDebug("Command %q still running after %v, stopping it", command, limit)
+			proc.timedOut.Store(true)
+			stopProcessGroup(proc, syscall.SIGTERM, timeoutGrace)
+		})
+	}
+	go func() {
+		cmd.Wait()
+		if timer != nil {
+			timer.Stop()
+		}
+		history.record(combo, command, started, cmd.ProcessState, proc.timedOut.Load(), capture, cfg)
+		close(proc.done)
+	}()
+	return proc
+}
 
+// buildCommand returns the process for a command: argv-form commands run
+// directly from the home directory, everything else through the shell.
+// Both get the environment with settings.env_file applied.
+func buildCommand(command string, cfg *config.Config) *exec.Cmd {
+	var cmd *exec.Cmd
+	if argv, ok := config.ExecArgv(command); ok {
+		cmd = exec.Command(argv[0], argv[1:]...)
+		cmd.Dir, _ = os.UserHomeDir()
+	} else {
+		cmd = exec.Command(resolveShell(cfg), "-c", cdPrefix+command)
+	}
+	cmd.Env = commandEnvironment(cfg)
 	return cmd
 }
 
-// StopProcess sends SIGTERM to a tracked process.
+func resolveShell(cfg *config.Config) string {
+	if cfg.Settings.Shell != "" {
+		return cfg.Settings.Shell
+	}
+	if shell := os.Getenv("SHELL"); shell != "" {
+		return shell
+	}
+	return fallbackShell
+}
+
+// StopProcess sends SIGTERM to a tracked process and everything it spawned:
+// the command runs in its own session, so its process group is the whole job.
+// The process is reaped by the goroutine that started it.
 func StopProcess(cmd *exec.Cmd) {
 	if cmd == nil || cmd.Process == nil {
 		return
 	}
-	cmd.Process.Signal(syscall.SIGTERM)
-	cmd.Wait()
+	syscall.Kill(-cmd.Process.Pid, syscall.SIGTERM)
 }
 
 func expandHome(path string) string {
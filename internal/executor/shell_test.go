@@ -4,6 +4,8 @@ import (
 	"os"
 	"path/filepath"
 	"testing"
+
+	"github.com/deprecatedluar/akeyshually/internal/config"
 )
 
 func TestExpandHome(t *testing.T) {
@@ -31,3 +33,53 @@ func TestExpandHome(t *testing.T) {
 		}
 	}
 }
+
+func TestExecCommandRunsWithoutShell(t *testing.T) {
+	envFile := filepath.Join(t.TempDir(), "env")
+	if err := os.WriteFile(envFile, []byte("export AKS_EXEC_TEST='from env file'\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	cfg := policyTestConfig()
+	cfg.Settings.EnvFile = envFile
+
+	proc := startShell("exec-test", config.ExecCommand([]string{"printenv", "AKS_EXEC_TEST"}), 0, cfg)
+	if proc == nil {
+		t.Fatal("startShell() failed")
+	}
+	<-proc.done
+
+	e := lastExecutionOf(t, "exec-test")
+	if e.ExitCode != 0 || e.Output() != "from env file\n" {
+		t.Errorf("got exit %d, output %q; want the env file's value", e.ExitCode, e.Output())
+	}
+	if e.Command != "printenv AKS_EXEC_TEST" {
+		t.Errorf("Command = %q, want it displayed as a shell line", e.Command)
+	}
+}
+
+// Start-to-exit latency of a trivial command, through the shell versus
+// exec'd directly. Both get the cached env_file environment.
+func benchmarkCommand(b *testing.B, command string) {
+	envFile := filepath.Join(b.TempDir(), "env")
+	if err := os.WriteFile(envFile, []byte("export AKS_BENCH=1\n"), 0644); err != nil {
+		b.Fatal(err)
+	}
+	cfg := policyTestConfig()
+	cfg.Settings.Shell = "bash"
+	cfg.Settings.EnvFile = envFile
+
+	for b.Loop() {
+		cmd := buildCommand(command, cfg)
+		if err := cmd.Run(); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkShellCommand(b *testing.B) {
+	benchmarkCommand(b, "true")
+}
+
+func BenchmarkExecCommand(b *testing.B) {
+	benchmarkCommand(b, config.ExecCommand([]string{"true"}))
+}
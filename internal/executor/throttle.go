@@ -0,0 +1,38 @@
+package executor
+
+import (
+	"time"
+
+	"github.com/deprecatedluar/akeyshually/internal/config"
+)
+
+// AllowFire enforces a shortcut's .cooldown or .ratelimit: it records a fire
+// and returns true while fewer than RateLimit fires happened within the last
+// RateWindow, and returns false otherwise. Refused fires are not recorded, so
+// a key held down by bounce does not keep extending the window. Shortcuts
+// without a rate limit always fire.
+func (s *LoopState) AllowFire(shortcut *config.ParsedShortcut) bool {
+	if shortcut.RateLimit <= 0 {
+		return true
+	}
+	return s.allowFireAt(shortcut, time.Now())
+}
+
+func (s *LoopState) allowFireAt(shortcut *config.ParsedShortcut, now time.Time) bool {
+	s.Mu.Lock()
+	defer s.Mu.Unlock()
+
+	cutoff := now.Add(-time.Duration(shortcut.RateWindow * float64(time.Millisecond)))
+	recent := s.fired[shortcut][:0]
+	for _, at := range s.fired[shortcut] {
+		if at.After(cutoff) {
+			recent = append(recent, at)
+		}
+	}
+	if len(recent) >= shortcut.RateLimit {
+		s.fired[shortcut] = recent
+		return false
+	}
+	s.fired[shortcut] = append(recent, now)
+	return true
+}
@@ -0,0 +1,57 @@
+package executor
+
+import (
+	"testing"
+	"time"
+
+	"github.com/deprecatedluar/akeyshually/internal/config"
+)
+
+func TestAllowFireCooldown(t *testing.T) {
+	loopState := NewLoopState()
+	shortcut := &config.ParsedShortcut{RateLimit: 1, RateWindow: 1000}
+	start := time.Now()
+
+	if !loopState.allowFireAt(shortcut, start) {
+		t.Fatal("first fire should be allowed")
+	}
+	if loopState.allowFireAt(shortcut, start.Add(200*time.Millisecond)) {
+		t.Fatal("fire within the cooldown should be refused")
+	}
+	// The refused fire did not restart the window
+	if !loopState.allowFireAt(shortcut, start.Add(1001*time.Millisecond)) {
+		t.Fatal("fire after the cooldown should be allowed")
+	}
+}
+
+func TestAllowFireRateLimitSlidingWindow(t *testing.T) {
+	loopState := NewLoopState()
+	shortcut := &config.ParsedShortcut{RateLimit: 3, RateWindow: 10000}
+	start := time.Now()
+
+	for i := range 3 {
+		if !loopState.allowFireAt(shortcut, start.Add(time.Duration(i)*time.Second)) {
+			t.Fatalf("fire %d should be allowed", i+1)
+		}
+	}
+	if loopState.allowFireAt(shortcut, start.Add(5*time.Second)) {
+		t.Fatal("fourth fire within the window should be refused")
+	}
+	// The first fire leaves the window, making room for one more
+	if !loopState.allowFireAt(shortcut, start.Add(10500*time.Millisecond)) {
+		t.Fatal("fire should be allowed once the oldest left the window")
+	}
+	if loopState.allowFireAt(shortcut, start.Add(10600*time.Millisecond)) {
+		t.Fatal("window is full again")
+	}
+}
+
+func TestAllowFireUnlimited(t *testing.T) {
+	loopState := NewLoopState()
+	shortcut := &config.ParsedShortcut{}
+	for range 10 {
+		if !loopState.AllowFire(shortcut) {
+			t.Fatal("shortcut without a rate limit should always fire")
+		}
+	}
+}
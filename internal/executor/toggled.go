@@ -0,0 +1,140 @@
+package executor
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/deprecatedluar/akeyshually/internal/common"
+)
+
+const (
+	toggledFile = "toggled.json" // under the state directory
+	adoptedPoll = time.Second    // how often an adopted process we cannot wait for is checked
+	toggledPerm = 0644
+)
+
+// savedToggle is a running .toggleprocess command as kept across restarts.
+type savedToggle struct {
+	Combo     string `json:"combo"`
+	PID       int    `json:"pid"`        // also its process group: commands run in their own session
+	StartTime uint64 `json:"start_time"` // from /proc/<pid>/stat, so a reused PID is not taken for it
+}
+
+// ToggledStatePath returns the file toggled processes are kept in across
+// reloads and restarts.
+func ToggledStatePath(stateDir string) string {
+	return filepath.Join(stateDir, toggledFile)
+}
+
+// SaveToggled writes the processes .toggleprocess started that still run to
+// path, so the daemon that replaces this one can stop them on the next press.
+func (s *LoopState) SaveToggled(path string) error {
+	s.Mu.Lock()
+	var saved []savedToggle
+	for combo, proc := range s.toggled {
+		pid := proc.cmd.Process.Pid
+		if start, err := processStartTime(pid); err == nil {
+			saved = append(saved, savedToggle{Combo: combo, PID: pid, StartTime: start})
+		}
+	}
+	s.Mu.Unlock()
+
+	if len(saved) == 0 {
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		return nil
+	}
+	data, err := json.MarshalIndent(saved, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), stateDirPerm); err != nil {
+		return err
+	}
+	tmpPath := path + ".tmp"
+	if err := os.WriteFile(tmpPath, data, toggledPerm); err != nil {
+		return err
+	}
+	return os.Rename(tmpPath, path)
+}
+
+// RestoreToggled adopts the toggled processes saved at path that still run,
+// so the next press of their shortcut stops them instead of starting a second
+// copy. A missing file is not an error.
+func (s *LoopState) RestoreToggled(path string) error {
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	os.Remove(path)
+
+	var saved []savedToggle
+	if err := json.Unmarshal(data, &saved); err != nil {
+		return err
+	}
+
+	s.Mu.Lock()
+	defer s.Mu.Unlock()
+	for _, t := range saved {
+		if start, err := processStartTime(t.PID); err != nil || start != t.StartTime {
+			common.LogDebug("%s: toggled process %d is gone", t.Combo, t.PID)
+			continue
+		}
+		common.LogDebug("%s: adopted toggled process %d", t.Combo, t.PID)
+		proc := &trackedProcess{shellProcess: adoptProcess(t.PID, t.StartTime)}
+		s.toggled[t.Combo] = proc
+		s.watchToggled(t.Combo, proc)
+	}
+	return nil
+}
+
+// adoptProcess tracks a process a previous daemon started. After a reload
+// the daemon was exec'd in place and still is its parent; after a restart
+// the process was reparented and is polled for until it exits.
+func adoptProcess(pid int, startTime uint64) *shellProcess {
+	process, _ := os.FindProcess(pid) // always succeeds on Unix
+	proc := &shellProcess{cmd: &exec.Cmd{Process: process}, done: make(chan struct{})}
+	go func() {
+		defer close(proc.done)
+		if _, err := process.Wait(); err == nil {
+			return
+		}
+		for {
+			if start, err := processStartTime(pid); err != nil || start != startTime {
+				return
+			}
+			time.Sleep(adoptedPoll)
+		}
+	}()
+	return proc
+}
+
+// processStartTime returns when the process started, in clock ticks since
+// boot: together with the PID it identifies the process.
+func processStartTime(pid int) (uint64, error) {
+	data, err := os.ReadFile(filepath.Join("/proc", strconv.Itoa(pid), "stat"))
+	if err != nil {
+		return 0, err
+	}
+	// pid (comm) state ppid ...: comm may contain spaces and parentheses,
+	// starttime is the 20th field after it
+	stat := string(data)
+	fields := strings.Fields(stat[strings.LastIndexByte(stat, ')')+1:])
+	if len(fields) < 20 {
+		return 0, fmt.Errorf("unexpected /proc/%d/stat format", pid)
+	}
+	if fields[0] == "Z" {
+		return 0, fmt.Errorf("process %d has exited", pid)
+	}
+	return strconv.ParseUint(fields[19], 10, 64)
+}
@@ -226,8 +226,12 @@ func FlushAbs(
 			numFires := int(accumulated / threshold)
 			common.LogDebug("[ABS] %s: threshold crossed! accumulated=%.2f threshold=%.2f numFires=%d", combo, accumulated, threshold, numFires)
 			if len(matchedShortcut.Commands) > 0 {
+				fireCtx := execCtx
+				fireCtx.Combo = comboKey
+				fireCtx.Policy = matchedShortcut.Policy
+				fireCtx.Timeout = matchedShortcut.Timeout
 				for i := 0; i < numFires; i++ {
-					executor.Run(matchedShortcut.Commands[0], execCtx)
+					executor.Run(matchedShortcut.Commands[0], fireCtx)
 				}
 			}
 
@@ -0,0 +1,114 @@
+package handlers
+
+import (
+	"context"
+
+	"github.com/deprecatedluar/akeyshually/internal/common"
+	"github.com/deprecatedluar/akeyshually/internal/config"
+	"github.com/deprecatedluar/akeyshually/internal/executor"
+	"github.com/deprecatedluar/akeyshually/internal/keys"
+	"github.com/deprecatedluar/akeyshually/internal/ladder"
+	"github.com/deprecatedluar/akeyshually/internal/matcher"
+	"github.com/deprecatedluar/akeyshually/internal/timers"
+	evdev "github.com/holoplot/go-evdev"
+)
+
+// pendingChord returns the ladder whose keys are withheld for a chord that is
+// still open to more keys, if any.
+func pendingChord(stateMap *timers.StateMap) (string, *timers.ComboState) {
+	combo := stateMap.Chord()
+	if combo == "" {
+		return "", nil
+	}
+	state := stateMap.Get(combo)
+	if state == nil || state.Chord == nil || !state.Chord.Pending() {
+		return "", nil
+	}
+	return combo, state
+}
+
+// extendChord grows a pending chord with the pressed key when the grown chord
+// is bound or can still grow, handing the withheld keys to a new ladder for it.
+// Returns true if the press was absorbed by the chord.
+func extendChord(code uint16, value int32, cfg *config.Config, loopState *executor.LoopState, outputs executor.Outputs, virtual *evdev.InputDevice, modifiers matcher.ModifierState, stateMap *timers.StateMap, emittedTracker *timers.EmittedModifierTracker) bool {
+	pending, state := pendingChord(stateMap)
+	if state == nil {
+		return false
+	}
+	chord := config.ChordWith(pending, keys.GetKeyName(code))
+	if chord == "" || (len(cfg.ParsedShortcuts[chord]) == 0 && !cfg.ChordMap[chord]) {
+		return false
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	next := timers.NewComboState(cancel)
+	if !state.Chord.Handoff(state, next, code) {
+		// Chord resolved while this key was arriving
+		cancel()
+		return false
+	}
+	next.Chord = state.Chord
+	state.Cancel()
+	stateMap.Delete(pending)
+
+	common.LogDebug("Chord %s growing to %s", pending, chord)
+	stateMap.Set(chord, next)
+	stateMap.SetChord(chord)
+	go ladder.Run(ctx, next, chord, code, value, timers.BuildCandidates(cfg.ParsedShortcuts[chord]), cfg,
+		loopState, outputs, virtual, modifiers, stateMap, emittedTracker, cfg.ParsedShortcuts)
+	return true
+}
+
+// breakChord ends a pending chord that the pressed key cannot extend. Withheld
+// keys with no shortcut of their own are replayed right away, so they reach the
+// system before the key that broke the chord; otherwise their ladder resolves
+// them as if no chord had been possible.
+func breakChord(cfg *config.Config, virtual *evdev.InputDevice, stateMap *timers.StateMap) {
+	pending, state := pendingChord(stateMap)
+	if state == nil {
+		return
+	}
+	if len(timers.BuildCandidates(cfg.ParsedShortcuts[pending])) > 0 {
+		state.SignalChordBreak()
+		return
+	}
+	flushChord(pending, state, stateMap, virtual)
+}
+
+// releaseChord routes the release of a chord key to the ladder that owns it.
+// Returns true if the key belongs to that ladder, in which case the release is
+// suppressed like any other key whose press went to a ladder.
+func releaseChord(code uint16, cfg *config.Config, virtual *evdev.InputDevice, stateMap *timers.StateMap) bool {
+	combo := stateMap.Chord()
+	if combo == "" {
+		return false
+	}
+	state := stateMap.Get(combo)
+	if state == nil || state.Chord == nil {
+		return false
+	}
+	member, withheld := state.Chord.Release(code)
+	if !member {
+		return false
+	}
+	if withheld && len(timers.BuildCandidates(cfg.ParsedShortcuts[combo])) == 0 {
+		flushChord(combo, state, stateMap, virtual)
+		return true
+	}
+	state.SignalRelease()
+	return true
+}
+
+// flushChord cancels a chord ladder that has nothing else to resolve and
+// replays its withheld keys from the event handler, keeping them in order
+// with the event that ended the chord.
+func flushChord(combo string, state *timers.ComboState, stateMap *timers.StateMap, virtual *evdev.InputDevice) {
+	withheld, ok := state.Chord.Close(state)
+	if !ok {
+		return // Ladder already resolved it
+	}
+	state.Cancel()
+	stateMap.Delete(combo)
+	common.LogDebug("Chord %s did not complete, replaying withheld keys", combo)
+	ladder.ReplayChord(virtual, withheld)
+}
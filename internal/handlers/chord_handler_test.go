@@ -0,0 +1,141 @@
+package handlers
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/deprecatedluar/akeyshually/internal/config"
+	"github.com/deprecatedluar/akeyshually/internal/executor"
+	"github.com/deprecatedluar/akeyshually/internal/matcher"
+	"github.com/deprecatedluar/akeyshually/internal/timers"
+	evdev "github.com/holoplot/go-evdev"
+)
+
+// chordTestConfig binds "j+k" to a command that creates marker.
+func chordTestConfig(t *testing.T, marker string) *config.Config {
+	t.Helper()
+	shortcut := &config.ParsedShortcut{
+		KeyCombo: "j+k",
+		Behavior: config.BehaviorNormal,
+		Commands: []string{"touch " + marker},
+	}
+	return &config.Config{
+		Settings:        config.Settings{ChordWindow: 50, DefaultInterval: 150},
+		ParsedShortcuts: map[string][]*config.ParsedShortcut{"j+k": {shortcut}},
+		EscapeMap:       map[string]bool{},
+		ChordMap:        map[string]bool{"j": true, "k": true},
+	}
+}
+
+func waitForFile(t *testing.T, path string) {
+	t.Helper()
+	deadline := time.Now().Add(time.Second)
+	for time.Now().Before(deadline) {
+		if _, err := os.Stat(path); err == nil {
+			return
+		}
+		time.Sleep(time.Millisecond)
+	}
+	t.Fatalf("%s was not created within timeout", path)
+}
+
+func TestChordFiresWhenAllKeysPressed(t *testing.T) {
+	marker := filepath.Join(t.TempDir(), "fired")
+	cfg := chordTestConfig(t, marker)
+	m := matcher.New(cfg.ParsedShortcuts)
+	stateMap := timers.NewStateMap()
+	emittedTracker := timers.NewEmittedModifierTracker()
+	loopState := executor.NewLoopState()
+
+	// Order does not matter: k first, then j
+	if !HandlePress(uint16(evdev.KEY_K), 1, m, cfg, loopState, executor.Outputs{}, nil, stateMap, emittedTracker, nil) {
+		t.Fatal("first chord key should be withheld")
+	}
+	if !HandlePress(uint16(evdev.KEY_J), 1, m, cfg, loopState, executor.Outputs{}, nil, stateMap, emittedTracker, nil) {
+		t.Fatal("second chord key should be suppressed")
+	}
+	if stateMap.Get("k") != nil {
+		t.Error("partial chord ladder should be replaced by the j+k ladder")
+	}
+	waitForLadderDone(t, stateMap, "j+k")
+	waitForFile(t, marker)
+}
+
+// A key that cannot extend the chord replays the withheld key first, from the
+// event handler itself, so the system still sees them in typing order.
+func TestChordBrokenByOtherKey(t *testing.T) {
+	cfg := chordTestConfig(t, filepath.Join(t.TempDir(), "fired"))
+	m := matcher.New(cfg.ParsedShortcuts)
+	stateMap := timers.NewStateMap()
+	emittedTracker := timers.NewEmittedModifierTracker()
+	loopState := executor.NewLoopState()
+
+	HandlePress(uint16(evdev.KEY_J), 1, m, cfg, loopState, executor.Outputs{}, nil, stateMap, emittedTracker, nil)
+	if suppressed := HandlePress(uint16(evdev.KEY_X), 1, m, cfg, loopState, executor.Outputs{}, nil, stateMap, emittedTracker, nil); suppressed {
+		t.Fatal("unbound key breaking a chord should be forwarded")
+	}
+	if stateMap.Get("j") != nil || stateMap.Chord() != "" {
+		t.Fatal("broken chord should be flushed before the breaking key is forwarded")
+	}
+
+	// j was replayed while held, so its release is forwarded as usual
+	if suppressed := HandleRelease(uint16(evdev.KEY_J), 0, m, cfg, loopState, executor.Outputs{}, nil, stateMap, emittedTracker, nil); suppressed {
+		t.Error("release of a replayed chord key should be forwarded")
+	}
+}
+
+func TestChordKeyReleasedBeforeChordCompletes(t *testing.T) {
+	cfg := chordTestConfig(t, filepath.Join(t.TempDir(), "fired"))
+	m := matcher.New(cfg.ParsedShortcuts)
+	stateMap := timers.NewStateMap()
+	emittedTracker := timers.NewEmittedModifierTracker()
+	loopState := executor.NewLoopState()
+
+	HandlePress(uint16(evdev.KEY_J), 1, m, cfg, loopState, executor.Outputs{}, nil, stateMap, emittedTracker, nil)
+	if !HandleRelease(uint16(evdev.KEY_J), 0, m, cfg, loopState, executor.Outputs{}, nil, stateMap, emittedTracker, nil) {
+		t.Fatal("release of a withheld key should be suppressed (it is replayed with the press)")
+	}
+	if stateMap.Get("j") != nil {
+		t.Fatal("chord should be flushed on release")
+	}
+}
+
+func TestChordWindowExpires(t *testing.T) {
+	cfg := chordTestConfig(t, filepath.Join(t.TempDir(), "fired"))
+	m := matcher.New(cfg.ParsedShortcuts)
+	stateMap := timers.NewStateMap()
+	emittedTracker := timers.NewEmittedModifierTracker()
+	loopState := executor.NewLoopState()
+
+	HandlePress(uint16(evdev.KEY_J), 1, m, cfg, loopState, executor.Outputs{}, nil, stateMap, emittedTracker, nil)
+	waitForLadderDone(t, stateMap, "j")
+
+	// Too late: k starts a new chord instead of completing j+k
+	HandlePress(uint16(evdev.KEY_K), 1, m, cfg, loopState, executor.Outputs{}, nil, stateMap, emittedTracker, nil)
+	if stateMap.Get("j+k") != nil {
+		t.Error("k after the chord window should not complete j+k")
+	}
+	if stateMap.Chord() != "k" {
+		t.Errorf("Chord() = %q, want k", stateMap.Chord())
+	}
+}
+
+// A partial chord with its own shortcut fires it once the chord window passes.
+func TestPartialChordFiresOwnShortcut(t *testing.T) {
+	marker := filepath.Join(t.TempDir(), "fired")
+	cfg := chordTestConfig(t, filepath.Join(t.TempDir(), "chord"))
+	cfg.ParsedShortcuts["j"] = []*config.ParsedShortcut{{
+		KeyCombo: "j",
+		Behavior: config.BehaviorNormal,
+		Commands: []string{"touch " + marker},
+	}}
+	m := matcher.New(cfg.ParsedShortcuts)
+	stateMap := timers.NewStateMap()
+
+	HandlePress(uint16(evdev.KEY_J), 1, m, cfg, executor.NewLoopState(), executor.Outputs{}, nil,
+		stateMap, timers.NewEmittedModifierTracker(), nil)
+	waitForLadderDone(t, stateMap, "j")
+	waitForFile(t, marker)
+}
@@ -0,0 +1,132 @@
+package handlers
+
+import (
+	"github.com/deprecatedluar/akeyshually/internal/common"
+	"github.com/deprecatedluar/akeyshually/internal/config"
+	"github.com/deprecatedluar/akeyshually/internal/executor"
+	"github.com/deprecatedluar/akeyshually/internal/keys"
+	"github.com/deprecatedluar/akeyshually/internal/matcher"
+	"github.com/deprecatedluar/akeyshually/internal/timers"
+	evdev "github.com/holoplot/go-evdev"
+)
+
+// Key event values as reported by evdev
+const (
+	keyReleaseValue = 0
+	keyPressValue   = 1
+	keyRepeatValue  = 2
+)
+
+// pendingDualRole is a .tapmod key that is down but not yet resolved to its
+// tap or hold role. Key events arriving meanwhile are buffered and replayed,
+// in order, once it resolves.
+type pendingDualRole struct {
+	code     uint16
+	role     config.DualRole
+	buffered []evdev.InputEvent
+	pressed  map[uint16]bool // keys pressed since the dual-role key went down
+	timer    *timers.Timer
+
+	m              *matcher.Matcher
+	virtual        *evdev.InputDevice
+	outputs        executor.Outputs
+	emittedTracker *timers.EmittedModifierTracker
+}
+
+// SetDispatch sets the keyboard's event handler, used to replay key events
+// buffered while a dual-role key was unresolved. Events it does not suppress
+// are forwarded to the virtual device, as the listener would have done.
+func (t *Translator) SetDispatch(dispatch func(evdev.InputEvent) bool) {
+	t.dispatch = dispatch
+}
+
+// TryDualRole resolves .tapmod keys with permissive hold: the key acts as
+// its hold role as soon as another key is pressed and released while it is
+// down, or once it has been held for the role's interval, and as its tap
+// role when released before either happens. Returns true if the event was
+// taken: the press of a dual-role key, or any key event while one is pending.
+func (t *Translator) TryDualRole(code uint16, value int32, cfg *config.Config, m *matcher.Matcher, virtual *evdev.InputDevice, outputs executor.Outputs, emittedTracker *timers.EmittedModifierTracker) bool {
+	if p := t.dual; p != nil {
+		if code == p.code {
+			if value == keyReleaseValue {
+				t.resolveDualRole(p, false)
+			}
+			return true
+		}
+		if value == keyRepeatValue {
+			// Nothing repeats while the dual-role key is unresolved
+			return true
+		}
+		p.buffered = append(p.buffered, evdev.InputEvent{Type: evdev.EV_KEY, Code: evdev.EvCode(code), Value: value})
+		if value == keyPressValue {
+			p.pressed[code] = true
+		} else if value == keyReleaseValue && p.pressed[code] {
+			// Another key tapped within the hold: permissive hold
+			t.resolveDualRole(p, true)
+		}
+		return true
+	}
+
+	if value != keyPressValue {
+		return false
+	}
+	role, ok := cfg.DualRoleTable[keys.GetKeyName(code)]
+	if !ok {
+		return false
+	}
+
+	p := &pendingDualRole{
+		code:           code,
+		role:           role,
+		pressed:        make(map[uint16]bool),
+		m:              m,
+		virtual:        virtual,
+		outputs:        outputs,
+		emittedTracker: emittedTracker,
+	}
+	t.dual = p
+	common.LogDebug("Dual-role %s pending (tap=%s hold=%s)", keys.GetKeyName(code), role.Tap, role.Hold)
+	p.timer = timers.Start(role.Interval, func() {
+		t.Lock()
+		defer t.Unlock()
+		if t.dual == p {
+			t.resolveDualRole(p, true)
+		}
+	})
+	return true
+}
+
+// resolveDualRole emits the resolved role for a pending dual-role key and
+// replays the key events buffered behind it. A tap is a complete press and
+// release around the replay; a hold stays down until the key is released.
+func (t *Translator) resolveDualRole(p *pendingDualRole, hold bool) {
+	t.dual = nil
+	p.timer.Cancel()
+
+	name := keys.GetKeyName(p.code)
+	target := p.role.Tap
+	if hold {
+		target = p.role.Hold
+	}
+	common.LogDebug("Dual-role %s resolved to %s (hold=%v), replaying %d event(s)", name, target, hold, len(p.buffered))
+
+	pressed := t.pressTarget(p.code, name, target, p.m, p.virtual, p.outputs, p.emittedTracker)
+	for _, event := range p.buffered {
+		t.replay(event)
+	}
+	if pressed && !hold {
+		t.TryRelease(p.code, p.m, p.virtual, p.emittedTracker)
+	}
+}
+
+// replay runs a buffered key event through the keyboard's event handler, so a
+// replayed key can still match shortcuts (e.g. "super+j" after a dual-role
+// key resolved to super) or start another dual-role key.
+func (t *Translator) replay(event evdev.InputEvent) {
+	if t.dispatch != nil && t.dispatch(event) {
+		return
+	}
+	if t.virtual != nil {
+		emitKeyToVirtual(t.virtual, uint16(event.Code), event.Value)
+	}
+}
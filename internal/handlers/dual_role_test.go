@@ -0,0 +1,132 @@
+package handlers
+
+import (
+	"testing"
+	"time"
+
+	"github.com/deprecatedluar/akeyshually/internal/config"
+	"github.com/deprecatedluar/akeyshually/internal/executor"
+	"github.com/deprecatedluar/akeyshually/internal/matcher"
+	"github.com/deprecatedluar/akeyshually/internal/timers"
+	evdev "github.com/holoplot/go-evdev"
+)
+
+// dualRoleTest drives a keyboard whose f key is "f.tapmod" = [">esc", ">leftctrl"].
+// Replayed events are recorded on the keyboard output too, so a single stream
+// shows the order of translated and replayed keys.
+type dualRoleTest struct {
+	t          *testing.T
+	cfg        *config.Config
+	m          *matcher.Matcher
+	outputs    executor.Outputs
+	keyboard   *recordingWriter
+	translator *Translator
+	stateMap   *timers.StateMap
+	tracker    *timers.EmittedModifierTracker
+	loopState  *executor.LoopState
+}
+
+func newDualRoleTest(t *testing.T, interval float64) *dualRoleTest {
+	cfg := &config.Config{
+		ParsedShortcuts: map[string][]*config.ParsedShortcut{},
+		DualRoleTable:   map[string]config.DualRole{"f": {Tap: "esc", Hold: "ctrl", Interval: interval}},
+	}
+	outputs, keyboard, _ := testOutputs()
+	translator := NewTranslator(nil)
+	translator.SetDispatch(func(event evdev.InputEvent) bool {
+		keyboard.WriteOne(&event)
+		return true
+	})
+	return &dualRoleTest{
+		t:          t,
+		cfg:        cfg,
+		m:          matcher.New(cfg.ParsedShortcuts),
+		outputs:    outputs,
+		keyboard:   keyboard,
+		translator: translator,
+		stateMap:   timers.NewStateMap(),
+		tracker:    timers.NewEmittedModifierTracker(),
+		loopState:  executor.NewLoopState(),
+	}
+}
+
+// key feeds one physical key event the way the device handler would.
+func (d *dualRoleTest) key(code evdev.EvCode, value int32) bool {
+	d.translator.Lock()
+	defer d.translator.Unlock()
+	if value == keyPressValue {
+		return HandlePress(uint16(code), value, d.m, d.cfg, d.loopState, d.outputs, nil, d.stateMap, d.tracker, d.translator)
+	}
+	return HandleRelease(uint16(code), value, d.m, d.cfg, d.loopState, d.outputs, nil, d.stateMap, d.tracker, d.translator)
+}
+
+func (d *dualRoleTest) expect(want ...evdev.InputEvent) {
+	d.t.Helper()
+	got := keyEvents(d.keyboard.snapshot())
+	if len(got) != len(want) {
+		d.t.Fatalf("got %d key events %+v, want %+v", len(got), got, want)
+	}
+	for i := range want {
+		if got[i].Code != want[i].Code || got[i].Value != want[i].Value {
+			d.t.Fatalf("event %d = code %d value %d, want code %d value %d (all: %+v)",
+				i, got[i].Code, got[i].Value, want[i].Code, want[i].Value, got)
+		}
+	}
+}
+
+func keyEvent(code evdev.EvCode, value int32) evdev.InputEvent {
+	return evdev.InputEvent{Type: evdev.EV_KEY, Code: code, Value: value}
+}
+
+func TestDualRoleTap(t *testing.T) {
+	d := newDualRoleTest(t, 1000)
+	if !d.key(evdev.KEY_F, 1) {
+		t.Fatal("dual-role press should be withheld")
+	}
+	d.expect()
+	d.key(evdev.KEY_F, 0)
+	d.expect(keyEvent(evdev.KEY_ESC, 1), keyEvent(evdev.KEY_ESC, 0))
+}
+
+// Another key tapped inside the dual-role key resolves it to hold right away,
+// and the tapped key is replayed after the hold key went down.
+func TestDualRolePermissiveHold(t *testing.T) {
+	d := newDualRoleTest(t, 1000)
+	d.key(evdev.KEY_F, 1)
+	if !d.key(evdev.KEY_J, 1) {
+		t.Fatal("key pressed during a pending dual-role key should be buffered")
+	}
+	d.expect()
+	d.key(evdev.KEY_J, 0)
+	d.expect(keyEvent(evdev.KEY_LEFTCTRL, 1), keyEvent(evdev.KEY_J, 1), keyEvent(evdev.KEY_J, 0))
+	if !d.m.GetCurrentModifiers().Has("ctrl") {
+		t.Error("hold role ctrl should be tracked as a held modifier")
+	}
+	d.key(evdev.KEY_F, 0)
+	d.expect(keyEvent(evdev.KEY_LEFTCTRL, 1), keyEvent(evdev.KEY_J, 1), keyEvent(evdev.KEY_J, 0), keyEvent(evdev.KEY_LEFTCTRL, 0))
+}
+
+// Rolling over (f down, j down, f up) is typing: tap, with j replayed in between.
+func TestDualRoleRollIsTap(t *testing.T) {
+	d := newDualRoleTest(t, 1000)
+	d.key(evdev.KEY_F, 1)
+	d.key(evdev.KEY_J, 1)
+	d.key(evdev.KEY_F, 0)
+	d.expect(keyEvent(evdev.KEY_ESC, 1), keyEvent(evdev.KEY_J, 1), keyEvent(evdev.KEY_ESC, 0))
+}
+
+func TestDualRoleHoldAfterInterval(t *testing.T) {
+	d := newDualRoleTest(t, 10)
+	d.key(evdev.KEY_F, 1)
+
+	deadline := time.Now().Add(time.Second)
+	for len(keyEvents(d.keyboard.snapshot())) == 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("dual-role key did not resolve to hold after its interval")
+		}
+		time.Sleep(time.Millisecond)
+	}
+	d.expect(keyEvent(evdev.KEY_LEFTCTRL, 1))
+	d.key(evdev.KEY_F, 0)
+	d.expect(keyEvent(evdev.KEY_LEFTCTRL, 1), keyEvent(evdev.KEY_LEFTCTRL, 0))
+}
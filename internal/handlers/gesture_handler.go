@@ -0,0 +1,328 @@
+package handlers
+
+import (
+	"fmt"
+	"math"
+	"sync"
+
+	"github.com/deprecatedluar/akeyshually/internal/common"
+	"github.com/deprecatedluar/akeyshually/internal/config"
+	"github.com/deprecatedluar/akeyshually/internal/events"
+	"github.com/deprecatedluar/akeyshually/internal/executor"
+	"github.com/deprecatedluar/akeyshually/internal/matcher"
+	"github.com/deprecatedluar/akeyshually/internal/stats"
+	"github.com/deprecatedluar/akeyshually/internal/timers"
+	evdev "github.com/holoplot/go-evdev"
+)
+
+const (
+	minPinchFingers    = 2 // pinch is recognized from two fingers up
+	minSwipeFingers    = 3 // swipes and holds need three; two is compositor scrolling
+	maxGestureFingers  = 4 // five-finger contact reports as the four-finger gesture
+	holdMovementFactor = 2 // hold is cancelled after moving 1/2 of the swipe distance
+	percent            = 100.0
+)
+
+// touchPoint is the last known position of one multitouch slot.
+type touchPoint struct {
+	x, y   int32
+	active bool
+}
+
+// GestureState recognizes multi-finger touchpad gestures for one device.
+// It reads ABS_MT slot updates and the BTN_TOOL_* finger count, evaluates
+// on every SYN_REPORT, and fires at most one gesture per contact: fingers
+// must lift below two before the next gesture can be recognized.
+type GestureState struct {
+	mu sync.Mutex
+
+	xRange, yRange float64
+	swipeFraction  float64
+	pinchFraction  float64
+	holdTime       float64
+	suppress       bool
+	fire           func(name string)
+
+	slot    int32
+	slots   map[int32]*touchPoint
+	tools   map[uint16]bool
+	fingers int
+
+	tracking    bool // baseline captured for the current finger count
+	resolved    bool // a gesture already fired during this contact
+	baseFingers int
+	startX      float64
+	startY      float64
+	startSpread float64
+	holdTimer   *timers.Timer
+}
+
+// fingerTools maps BTN_TOOL_* codes to the finger count they report.
+var fingerTools = map[uint16]int{
+	evdev.BTN_TOOL_FINGER:    1,
+	evdev.BTN_TOOL_DOUBLETAP: 2,
+	evdev.BTN_TOOL_TRIPLETAP: 3,
+	evdev.BTN_TOOL_QUADTAP:   4,
+	evdev.BTN_TOOL_QUINTTAP:  5,
+}
+
+// NewGestureState returns a recognizer for a multitouch device, or nil if the
+// device does not report ABS_MT positions. fire is called with the gesture
+// name (e.g. "swipe3_left") and must not block for long.
+func NewGestureState(absInfoMap AbsInfoMap, settings config.Settings, fire func(name string)) *GestureState {
+	xInfo, hasX := absInfoMap[evdev.ABS_MT_POSITION_X]
+	yInfo, hasY := absInfoMap[evdev.ABS_MT_POSITION_Y]
+	if !hasX || !hasY {
+		return nil
+	}
+	xRange := float64(xInfo.Maximum - xInfo.Minimum)
+	yRange := float64(yInfo.Maximum - yInfo.Minimum)
+	if xRange <= 0 || yRange <= 0 {
+		return nil
+	}
+	return &GestureState{
+		xRange:        xRange,
+		yRange:        yRange,
+		swipeFraction: settings.GestureSwipeDistance / percent,
+		pinchFraction: settings.GesturePinchDistance / percent,
+		holdTime:      settings.GestureHoldTime,
+		suppress:      settings.SuppressGestures,
+		fire:          fire,
+		slots:         make(map[int32]*touchPoint),
+		tools:         make(map[uint16]bool),
+	}
+}
+
+// IsMultitouchAxis returns true for ABS_MT_* codes, which only the gesture
+// recognizer consumes and which are never bindable as plain axes.
+func IsMultitouchAxis(code uint16) bool {
+	return code >= evdev.ABS_MT_SLOT && code <= evdev.ABS_MT_TOOL_Y
+}
+
+// HandleKey tracks the BTN_TOOL_* finger count. Finger count events are never
+// suppressed: the compositor must always see contacts land and lift.
+func (g *GestureState) HandleKey(code uint16, value int32) {
+	if _, ok := fingerTools[code]; !ok {
+		return
+	}
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	g.tools[code] = value != 0
+	g.fingers = 0
+	for tool, down := range g.tools {
+		if down && fingerTools[tool] > g.fingers {
+			g.fingers = fingerTools[tool]
+		}
+	}
+}
+
+// HandleAbs records slot updates. Returns true if the event should be
+// suppressed, which only happens for pointer motion while three or more
+// fingers are down and suppress_gestures is enabled.
+func (g *GestureState) HandleAbs(code uint16, value int32) bool {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	switch code {
+	case evdev.ABS_MT_SLOT:
+		g.slot = value
+		return false
+	case evdev.ABS_MT_TRACKING_ID:
+		point := g.point(g.slot)
+		point.active = value >= 0
+		return false
+	case evdev.ABS_MT_POSITION_X:
+		g.point(g.slot).x = value
+	case evdev.ABS_MT_POSITION_Y:
+		g.point(g.slot).y = value
+	case evdev.ABS_X, evdev.ABS_Y:
+		// Single-touch emulation of the same contact; suppressed alongside it
+	default:
+		return false
+	}
+	return g.suppress && g.fingers >= minSwipeFingers
+}
+
+// Sync evaluates the current frame. Called on every SYN_REPORT.
+func (g *GestureState) Sync() {
+	g.mu.Lock()
+	name := g.evaluate()
+	g.mu.Unlock()
+
+	if name != "" {
+		g.fire(name)
+	}
+}
+
+func (g *GestureState) point(slot int32) *touchPoint {
+	p, ok := g.slots[slot]
+	if !ok {
+		p = &touchPoint{}
+		g.slots[slot] = p
+	}
+	return p
+}
+
+// evaluate compares the frame against the baseline captured when the current
+// finger count began and returns a gesture name once a threshold is crossed.
+func (g *GestureState) evaluate() string {
+	if g.fingers < minPinchFingers {
+		g.reset()
+		return ""
+	}
+	if g.resolved {
+		return ""
+	}
+
+	cx, cy, spread, ok := g.centroid()
+	if !ok {
+		return ""
+	}
+
+	// Finger count changed: start over from the new contact shape
+	if !g.tracking || g.fingers != g.baseFingers {
+		g.tracking = true
+		g.baseFingers = g.fingers
+		g.startX, g.startY, g.startSpread = cx, cy, spread
+		g.armHold()
+		return ""
+	}
+
+	dx := (cx - g.startX) / g.xRange
+	dy := (cy - g.startY) / g.yRange
+
+	if g.fingers >= minSwipeFingers && math.Max(math.Abs(dx), math.Abs(dy)) >= g.swipeFraction {
+		return g.resolve(swipeName(g.fingers, dx, dy))
+	}
+
+	if g.startSpread > 0 && spread > 0 {
+		ratio := spread / g.startSpread
+		if ratio <= 1-g.pinchFraction {
+			return g.resolve("pinch_in")
+		}
+		if ratio >= 1+g.pinchFraction {
+			return g.resolve("pinch_out")
+		}
+	}
+
+	// Moving fingers are not holding
+	if math.Max(math.Abs(dx), math.Abs(dy)) >= g.swipeFraction/holdMovementFactor {
+		g.cancelHold()
+	}
+	return ""
+}
+
+// centroid returns the average position of the active slots and their mean
+// distance from it, normalized per axis so pads with non-square ranges weigh
+// both directions equally. spread is 0 with fewer than two tracked slots.
+func (g *GestureState) centroid() (x, y, spread float64, ok bool) {
+	var n float64
+	for _, p := range g.slots {
+		if !p.active {
+			continue
+		}
+		x += float64(p.x)
+		y += float64(p.y)
+		n++
+	}
+	if n == 0 {
+		return 0, 0, 0, false
+	}
+	x /= n
+	y /= n
+	if n < 2 {
+		return x, y, 0, true
+	}
+	for _, p := range g.slots {
+		if !p.active {
+			continue
+		}
+		spread += math.Hypot((float64(p.x)-x)/g.xRange, (float64(p.y)-y)/g.yRange)
+	}
+	return x, y, spread / n, true
+}
+
+func (g *GestureState) resolve(name string) string {
+	g.resolved = true
+	g.cancelHold()
+	return name
+}
+
+// armHold starts the stationary-contact timer for three or more fingers.
+func (g *GestureState) armHold() {
+	g.cancelHold()
+	if g.fingers < minSwipeFingers {
+		return
+	}
+	fingers := g.fingers
+	g.holdTimer = timers.Start(g.holdTime, func() {
+		g.mu.Lock()
+		if g.resolved || !g.tracking || g.fingers != fingers || g.holdTimer == nil {
+			g.mu.Unlock()
+			return
+		}
+		g.resolved = true
+		g.holdTimer = nil
+		g.mu.Unlock()
+
+		g.fire(fmt.Sprintf("hold%d", min(fingers, maxGestureFingers)))
+	})
+}
+
+func (g *GestureState) cancelHold() {
+	if g.holdTimer != nil {
+		g.holdTimer.Cancel()
+		g.holdTimer = nil
+	}
+}
+
+func (g *GestureState) reset() {
+	g.cancelHold()
+	g.tracking = false
+	g.resolved = false
+	g.baseFingers = 0
+}
+
+// swipeName names a swipe by finger count and dominant direction.
+// Touchpad Y grows downward, so negative dy is "up".
+func swipeName(fingers int, dx, dy float64) string {
+	fingers = min(fingers, maxGestureFingers)
+	direction := "right"
+	switch {
+	case math.Abs(dx) >= math.Abs(dy) && dx < 0:
+		direction = "left"
+	case math.Abs(dy) > math.Abs(dx) && dy < 0:
+		direction = "up"
+	case math.Abs(dy) > math.Abs(dx):
+		direction = "down"
+	}
+	return fmt.Sprintf("swipe%d_%s", fingers, direction)
+}
+
+// FireGesture runs the shortcut bound to a recognized gesture, honouring held
+// modifiers ("super+swipe3_left"). Gestures are one-shot: validation leaves
+// them a plain trigger and one command, which runs under the shortcut's
+// cooldown and process policy.
+func FireGesture(name string, m *matcher.Matcher, cfg *config.Config, execCtx executor.ExecContext) {
+	combo := m.GetComboForName(name)
+	shortcuts := m.GetShortcuts(combo)
+	if len(shortcuts) == 0 || len(shortcuts[0].Commands) == 0 {
+		common.LogDebug("[GESTURE] %s: no shortcut bound", combo)
+		return
+	}
+	if execCtx.LoopState != nil && !execCtx.LoopState.AllowFire(shortcuts[0]) {
+		common.LogDebug("[GESTURE] %s: refused by cooldown/ratelimit", combo)
+		return
+	}
+	resolvedCmd := cfg.ResolveCommand(shortcuts[0].Commands[0])
+	common.LogMatch(combo, "gesture")
+	common.LogTrigger(resolvedCmd)
+	events.Fired(combo, "gesture", config.DisplayCommand(resolvedCmd))
+	stats.Fired(combo, shortcuts[0].Behavior.String(), 0)
+	execCtx.Modifiers = m.GetCurrentModifiers()
+	execCtx.Combo = combo
+	execCtx.Policy = shortcuts[0].Policy
+	execCtx.Timeout = shortcuts[0].Timeout
+	executor.Run(resolvedCmd, execCtx)
+}
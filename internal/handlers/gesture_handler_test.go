@@ -0,0 +1,238 @@
+package handlers
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/deprecatedluar/akeyshually/internal/config"
+	evdev "github.com/holoplot/go-evdev"
+)
+
+// gestureRecorder collects fired gesture names (hold fires from a timer goroutine).
+type gestureRecorder struct {
+	mu    sync.Mutex
+	names []string
+}
+
+func (r *gestureRecorder) fire(name string) {
+	r.mu.Lock()
+	r.names = append(r.names, name)
+	r.mu.Unlock()
+}
+
+func (r *gestureRecorder) fired() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]string(nil), r.names...)
+}
+
+func testTouchpadInfo() AbsInfoMap {
+	return AbsInfoMap{
+		uint16(evdev.ABS_MT_POSITION_X): {Minimum: 0, Maximum: 1000},
+		uint16(evdev.ABS_MT_POSITION_Y): {Minimum: 0, Maximum: 1000},
+	}
+}
+
+func testGestureSettings() config.Settings {
+	return config.Settings{
+		GestureSwipeDistance: 15,
+		GesturePinchDistance: 20,
+		GestureHoldTime:      10000, // out of the way unless a test lowers it
+	}
+}
+
+// touch places each finger (slot i) at points[i] and ends the frame.
+func touch(g *GestureState, points [][2]int32) {
+	for i, p := range points {
+		g.HandleAbs(evdev.ABS_MT_SLOT, int32(i))
+		g.HandleAbs(evdev.ABS_MT_TRACKING_ID, int32(i+1))
+		g.HandleAbs(evdev.ABS_MT_POSITION_X, p[0])
+		g.HandleAbs(evdev.ABS_MT_POSITION_Y, p[1])
+	}
+	g.Sync()
+}
+
+func setFingers(g *GestureState, tool uint16) {
+	for code := range fingerTools {
+		value := int32(0)
+		if code == tool {
+			value = 1
+		}
+		g.HandleKey(code, value)
+	}
+}
+
+func TestNewGestureStateRequiresMultitouch(t *testing.T) {
+	if g := NewGestureState(testAnalogInfo(), testGestureSettings(), func(string) {}); g != nil {
+		t.Fatal("expected nil recognizer for a device without ABS_MT positions")
+	}
+}
+
+func TestGestureSwipeDirections(t *testing.T) {
+	tests := []struct {
+		name   string
+		tool   uint16
+		dx, dy int32
+		want   string
+	}{
+		{"three left", evdev.BTN_TOOL_TRIPLETAP, -200, 0, "swipe3_left"},
+		{"three right", evdev.BTN_TOOL_TRIPLETAP, 200, 0, "swipe3_right"},
+		{"three up", evdev.BTN_TOOL_TRIPLETAP, 0, -200, "swipe3_up"},
+		{"four down", evdev.BTN_TOOL_QUADTAP, 0, 200, "swipe4_down"},
+		{"five fingers report as four", evdev.BTN_TOOL_QUINTTAP, 200, 0, "swipe4_right"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := &gestureRecorder{}
+			g := NewGestureState(testTouchpadInfo(), testGestureSettings(), rec.fire)
+			setFingers(g, tt.tool)
+
+			start := [][2]int32{{400, 400}, {500, 400}, {600, 400}}
+			touch(g, start)
+			moved := make([][2]int32, len(start))
+			for i, p := range start {
+				moved[i] = [2]int32{p[0] + tt.dx, p[1] + tt.dy}
+			}
+			touch(g, moved)
+
+			got := rec.fired()
+			if len(got) != 1 || got[0] != tt.want {
+				t.Fatalf("fired %v, want [%s]", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestGestureSwipeBelowThresholdDoesNotFire(t *testing.T) {
+	rec := &gestureRecorder{}
+	g := NewGestureState(testTouchpadInfo(), testGestureSettings(), rec.fire)
+	setFingers(g, evdev.BTN_TOOL_TRIPLETAP)
+
+	touch(g, [][2]int32{{400, 400}, {500, 400}, {600, 400}})
+	touch(g, [][2]int32{{300, 400}, {400, 400}, {500, 400}}) // 10% < 15%
+
+	if got := rec.fired(); len(got) != 0 {
+		t.Fatalf("fired %v below swipe threshold", got)
+	}
+}
+
+func TestGestureFiresOncePerContact(t *testing.T) {
+	rec := &gestureRecorder{}
+	g := NewGestureState(testTouchpadInfo(), testGestureSettings(), rec.fire)
+	setFingers(g, evdev.BTN_TOOL_TRIPLETAP)
+
+	touch(g, [][2]int32{{400, 400}, {500, 400}, {600, 400}})
+	touch(g, [][2]int32{{200, 400}, {300, 400}, {400, 400}})
+	touch(g, [][2]int32{{0, 400}, {100, 400}, {200, 400}})
+
+	if got := rec.fired(); len(got) != 1 {
+		t.Fatalf("fired %v, want exactly one swipe", got)
+	}
+
+	// Lifting all fingers re-arms the recognizer
+	setFingers(g, 0)
+	g.Sync()
+	setFingers(g, evdev.BTN_TOOL_TRIPLETAP)
+	touch(g, [][2]int32{{400, 400}, {500, 400}, {600, 400}})
+	touch(g, [][2]int32{{600, 400}, {700, 400}, {800, 400}})
+
+	got := rec.fired()
+	if len(got) != 2 || got[1] != "swipe3_right" {
+		t.Fatalf("fired %v, want second gesture swipe3_right", got)
+	}
+}
+
+func TestGesturePinch(t *testing.T) {
+	tests := []struct {
+		name  string
+		start [][2]int32
+		end   [][2]int32
+		want  string
+	}{
+		{"in", [][2]int32{{300, 500}, {700, 500}}, [][2]int32{{450, 500}, {550, 500}}, "pinch_in"},
+		{"out", [][2]int32{{450, 500}, {550, 500}}, [][2]int32{{300, 500}, {700, 500}}, "pinch_out"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := &gestureRecorder{}
+			g := NewGestureState(testTouchpadInfo(), testGestureSettings(), rec.fire)
+			setFingers(g, evdev.BTN_TOOL_DOUBLETAP)
+
+			touch(g, tt.start)
+			touch(g, tt.end)
+
+			got := rec.fired()
+			if len(got) != 1 || got[0] != tt.want {
+				t.Fatalf("fired %v, want [%s]", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestGestureTwoFingerScrollIsNotASwipe(t *testing.T) {
+	rec := &gestureRecorder{}
+	g := NewGestureState(testTouchpadInfo(), testGestureSettings(), rec.fire)
+	setFingers(g, evdev.BTN_TOOL_DOUBLETAP)
+
+	touch(g, [][2]int32{{450, 300}, {550, 300}})
+	touch(g, [][2]int32{{450, 700}, {550, 700}})
+
+	if got := rec.fired(); len(got) != 0 {
+		t.Fatalf("two-finger scroll fired %v", got)
+	}
+}
+
+func TestGestureHold(t *testing.T) {
+	settings := testGestureSettings()
+	settings.GestureHoldTime = 20
+	rec := &gestureRecorder{}
+	g := NewGestureState(testTouchpadInfo(), settings, rec.fire)
+	setFingers(g, evdev.BTN_TOOL_TRIPLETAP)
+
+	touch(g, [][2]int32{{400, 400}, {500, 400}, {600, 400}})
+	time.Sleep(100 * time.Millisecond)
+
+	got := rec.fired()
+	if len(got) != 1 || got[0] != "hold3" {
+		t.Fatalf("fired %v, want [hold3]", got)
+	}
+}
+
+func TestGestureHoldCancelledByLift(t *testing.T) {
+	settings := testGestureSettings()
+	settings.GestureHoldTime = 30
+	rec := &gestureRecorder{}
+	g := NewGestureState(testTouchpadInfo(), settings, rec.fire)
+	setFingers(g, evdev.BTN_TOOL_TRIPLETAP)
+
+	touch(g, [][2]int32{{400, 400}, {500, 400}, {600, 400}})
+	setFingers(g, 0)
+	g.Sync()
+	time.Sleep(100 * time.Millisecond)
+
+	if got := rec.fired(); len(got) != 0 {
+		t.Fatalf("fired %v after fingers lifted", got)
+	}
+}
+
+func TestGestureSuppressesMotionOnlyWhenEnabled(t *testing.T) {
+	for _, suppress := range []bool{false, true} {
+		settings := testGestureSettings()
+		settings.SuppressGestures = suppress
+		g := NewGestureState(testTouchpadInfo(), settings, func(string) {})
+
+		setFingers(g, evdev.BTN_TOOL_DOUBLETAP)
+		if g.HandleAbs(evdev.ABS_MT_POSITION_X, 100) {
+			t.Errorf("suppress=%v: two-finger motion was suppressed", suppress)
+		}
+
+		setFingers(g, evdev.BTN_TOOL_TRIPLETAP)
+		if got := g.HandleAbs(evdev.ABS_MT_POSITION_X, 100); got != suppress {
+			t.Errorf("suppress=%v: three-finger motion suppressed=%v", suppress, got)
+		}
+		if g.HandleAbs(evdev.ABS_MT_TRACKING_ID, -1) {
+			t.Errorf("suppress=%v: contact lift must always reach the compositor", suppress)
+		}
+	}
+}
@@ -0,0 +1,60 @@
+package handlers
+
+import (
+	"slices"
+	"strings"
+
+	"github.com/deprecatedluar/akeyshually/internal/config"
+	"github.com/deprecatedluar/akeyshually/internal/events"
+	"github.com/deprecatedluar/akeyshually/internal/keys"
+	"github.com/deprecatedluar/akeyshually/internal/matcher"
+	evdev "github.com/holoplot/go-evdev"
+)
+
+// Describe returns the events.Input event `akeyshually inspect` shows for a
+// key or axis event read from device: its name, the combo the matcher builds
+// for it and, for key presses, the configured shortcuts it reaches. It must
+// run before the event is handled, since a modifier press changes the combo.
+// Returns false for event types inspect does not show.
+func Describe(device string, event evdev.InputEvent, m *matcher.Matcher, cfg *config.Config) (events.Event, bool) {
+	code := uint16(event.Code)
+	e := events.Event{Type: events.Input, Device: device}
+
+	switch event.Type {
+	case evdev.EV_KEY:
+		e.Input = &events.InputInfo{Kind: "key", Code: code, Value: event.Value}
+		e.Key = m.KeyName(code) // custom modifier name if declared in [modifiers]
+		if e.Key == "" {
+			return e, true // no name in keys.KeyCodeMap, so nothing can be bound to it
+		}
+		e.Input.Named = true
+		e.Combo = m.GetCurrentCombo(code)
+		if event.Value != keyPressValue {
+			return e, true
+		}
+		for _, s := range m.GetShortcuts(e.Combo) {
+			e.Input.Matches = append(e.Input.Matches, s.KeyCombo+" ("+s.Behavior.String()+")")
+		}
+		if _, remapped := cfg.RemapTable[e.Combo]; remapped {
+			e.Input.Matches = append(e.Input.Matches, e.Combo+" (remap)")
+		}
+
+	case evdev.EV_ABS:
+		_, named := keys.AbsCodeNames[code]
+		e.Input = &events.InputInfo{Kind: "axis", Code: code, Value: event.Value, Named: named}
+		e.Key = strings.ToLower(keys.GetAbsName(code))
+		e.Combo = e.Key
+		// Axis shortcuts are bound per direction ("abs_x+", "abs_x-")
+		for _, direction := range []string{"+", "-"} {
+			for _, s := range cfg.ParsedShortcuts[e.Combo+direction] {
+				e.Input.Matches = append(e.Input.Matches, e.Combo+direction+" ("+s.Behavior.String()+")")
+			}
+		}
+
+	default:
+		return events.Event{}, false
+	}
+
+	slices.Sort(e.Input.Matches)
+	return e, true
+}
@@ -0,0 +1,58 @@
+package handlers
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/deprecatedluar/akeyshually/internal/config"
+	"github.com/deprecatedluar/akeyshually/internal/events"
+	"github.com/deprecatedluar/akeyshually/internal/matcher"
+	evdev "github.com/holoplot/go-evdev"
+)
+
+func TestDescribe(t *testing.T) {
+	cfg := &config.Config{
+		ParsedShortcuts: map[string][]*config.ParsedShortcut{
+			"ctrl+t": {{KeyCombo: "ctrl+t", Behavior: config.BehaviorNormal, Commands: []string{"kitty"}}},
+			"abs_x+": {{KeyCombo: "abs_x+", Behavior: config.BehaviorNormal, Commands: []string{">right"}}},
+		},
+		RemapTable: map[string]string{"capslock": "esc"},
+	}
+	m := matcher.New(cfg.ParsedShortcuts)
+	m.UpdateModifierState(evdev.KEY_LEFTCTRL, true)
+
+	key := func(code evdev.EvCode, value int32) evdev.InputEvent {
+		return evdev.InputEvent{Type: evdev.EV_KEY, Code: code, Value: value}
+	}
+
+	e, ok := Describe("kbd", key(evdev.KEY_T, 1), m, cfg)
+	if !ok || e.Type != events.Input || e.Device != "kbd" || e.Key != "t" || e.Combo != "ctrl+t" {
+		t.Fatalf("press t = %+v, %v", e, ok)
+	}
+	if !e.Input.Named || !slices.Equal(e.Input.Matches, []string{"ctrl+t (normal)"}) {
+		t.Fatalf("press t input = %+v, want the ctrl+t shortcut", e.Input)
+	}
+
+	if e, _ := Describe("kbd", key(evdev.KEY_T, 0), m, cfg); len(e.Input.Matches) != 0 {
+		t.Fatalf("release matched %v, want nothing", e.Input.Matches)
+	}
+
+	m.UpdateModifierState(evdev.KEY_LEFTCTRL, false)
+	if e, _ := Describe("kbd", key(evdev.KEY_CAPSLOCK, 1), m, cfg); !slices.Equal(e.Input.Matches, []string{"capslock (remap)"}) {
+		t.Fatalf("capslock matched %v, want its remap", e.Input.Matches)
+	}
+
+	e, _ = Describe("kbd", key(evdev.KEY_PROG1, 1), m, cfg)
+	if e.Input.Named || e.Key != "" || e.Combo != "" {
+		t.Fatalf("unnamed key = %+v, want it marked as unnamed", e)
+	}
+
+	e, _ = Describe("pad", evdev.InputEvent{Type: evdev.EV_ABS, Code: evdev.ABS_X, Value: 300}, m, cfg)
+	if e.Key != "abs_x" || !e.Input.Named || !slices.Equal(e.Input.Matches, []string{"abs_x+ (normal)"}) {
+		t.Fatalf("abs_x = %+v %+v", e, e.Input)
+	}
+
+	if _, ok := Describe("kbd", evdev.InputEvent{Type: evdev.EV_SYN}, m, cfg); ok {
+		t.Fatal("sync event described")
+	}
+}
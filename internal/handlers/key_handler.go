@@ -7,17 +7,29 @@ import (
 
 	"github.com/deprecatedluar/akeyshually/internal/common"
 	"github.com/deprecatedluar/akeyshually/internal/config"
+	"github.com/deprecatedluar/akeyshually/internal/events"
 	"github.com/deprecatedluar/akeyshually/internal/executor"
 	"github.com/deprecatedluar/akeyshually/internal/keys"
 	"github.com/deprecatedluar/akeyshually/internal/ladder"
 	"github.com/deprecatedluar/akeyshually/internal/matcher"
+	"github.com/deprecatedluar/akeyshually/internal/stats"
 	"github.com/deprecatedluar/akeyshually/internal/timers"
 	evdev "github.com/holoplot/go-evdev"
 )
 
 func HandlePress(code uint16, value int32, m *matcher.Matcher, cfg *config.Config, loopState *executor.LoopState, outputs executor.Outputs, virtual *evdev.InputDevice, stateMap *timers.StateMap, emittedTracker *timers.EmittedModifierTracker, translator *Translator) bool {
-	if translator != nil && translator.TryPress(code, m.GetCurrentCombo(code), cfg, m, virtual, outputs, emittedTracker) {
-		return true
+	if translator != nil {
+		if translator.TryDualRole(code, value, cfg, m, virtual, outputs, emittedTracker) {
+			return true
+		}
+		combo := m.GetCurrentCombo(code)
+		// Remapped keys are never part of a chord, so they end a pending one first
+		if _, remapped := cfg.RemapTable[combo]; remapped {
+			breakChord(cfg, virtual, stateMap)
+		}
+		if translator.TryPress(code, combo, cfg, m, virtual, outputs, emittedTracker) {
+			return true
+		}
 	}
 
 	common.LogKey(keys.GetKeyName(code), code)
@@ -25,22 +37,28 @@ func HandlePress(code uint16, value int32, m *matcher.Matcher, cfg *config.Confi
 
 	var combo string
 	var shortcuts []*config.ParsedShortcut
+	withhold := false // custom modifier waiting for a combo key
 
-	if matcher.IsModifierKey(code) {
+	if m.IsModifier(code) {
 		modifiers := m.GetCurrentModifiers()
-		if !modifiers.Super && !modifiers.Ctrl && !modifiers.Alt && !modifiers.Shift {
+		if len(modifiers) == 0 {
 			m.MarkTapCandidate(code)
 		}
 		m.UpdateModifierState(code, true)
-		common.LogDebug(">>> MODIFIER PRESS: %s, checking for active modifier ladders", keys.GetKeyName(code))
+		name := m.KeyName(code) // "super", "ctrl", "alt", "shift", or a custom modifier
+		common.LogDebug(">>> MODIFIER PRESS: %s, checking for active modifier ladders", name)
+
+		// Chords are modifier-free: a modifier ends any pending chord
+		breakChord(cfg, virtual, stateMap)
 
 		// Check if other modifiers have active ladders - escape hatch to combo or fallback to cancellation
-		checkModifierEscape := func(modName string, isHeld bool) bool {
-			if !isHeld {
-				return false
-			}
+		held := m.GetComboForName("") // all held modifiers, including this one
+		checkModifierEscape := func(modName string) bool {
 			if state := stateMap.Get(modName); state != nil {
-				comboKey := modName + "+" + keys.GetKeyName(code)
+				comboKey := modName + "+" + name
+				if _, custom := cfg.Modifiers[modName]; custom {
+					comboKey = held
+				}
 				if len(cfg.ParsedShortcuts[comboKey]) > 0 {
 					// Escape hatch: valid combo exists, migrate ladder to combo
 					common.LogDebug("Escape hatch: %s ladder migrating to %s", modName, comboKey)
@@ -50,13 +68,16 @@ func HandlePress(code uint16, value int32, m *matcher.Matcher, cfg *config.Confi
 					}
 					// Ladder goroutine owns migration entirely - return immediately
 					return true
+				} else if _, custom := cfg.Modifiers[modName]; custom && cfg.EscapeMap[comboKey] {
+					// A longer combo may still match: keep withholding the custom modifier
+					common.LogDebug("Keeping %s withheld (%s may grow into a combo)", modName, comboKey)
 				} else {
 					// Fallback: no combo defined, cancel and emit modifier
 					common.LogDebug("Cancelling %s ladder (combo detected), emitting %s keydown", modName, modName)
 					state.Cancel()
 					stateMap.Delete(modName)
 					if virtual != nil {
-						ladder.EmitModifierKey(virtual, keys.ResolveKeyCode, modName, true)
+						ladder.EmitModifierKey(virtual, cfg.ResolveKey, modName, true)
 						emittedTracker.MarkDown(modName)
 					}
 				}
@@ -64,39 +85,37 @@ func HandlePress(code uint16, value int32, m *matcher.Matcher, cfg *config.Confi
 			return false
 		}
 
-		if checkModifierEscape("super", modifiers.Super) {
-			return true
-		}
-		if checkModifierEscape("ctrl", modifiers.Ctrl) {
-			return true
-		}
-		if checkModifierEscape("alt", modifiers.Alt) {
-			return true
-		}
-		if checkModifierEscape("shift", modifiers.Shift) {
-			return true
+		for _, modName := range modifiers.Names() {
+			if checkModifierEscape(modName) {
+				return true
+			}
 		}
 
 		// Check for lone modifier shortcuts (super.doubletap, super.pressrelease, etc.)
-		combo = keys.GetKeyName(code) // "super", "ctrl", "alt", or "shift"
+		combo = name
 		shortcuts = m.GetShortcuts(combo)
-		if len(shortcuts) == 0 {
+		withhold = m.IsCustomModifier(code) && cfg.EscapeMap[combo]
+		if len(shortcuts) == 0 && !withhold {
 			// No shortcuts: forward transparently, system now sees it down.
 			emittedTracker.MarkDown(combo)
 			return false
 		}
-		// Fall through to ladder logic below
+		// Fall through to ladder logic below; a custom modifier with combos is
+		// withheld by its ladder until a combo key arrives or it is released alone
 	} else {
 		combo = m.GetCurrentCombo(code)
 		m.UpdateModifierState(code, true)
 
+		// Grow a pending chord with this key, or end it before handling the key
+		if combo == keys.GetKeyName(code) && extendChord(code, value, cfg, loopState, outputs, virtual, m.GetCurrentModifiers(), stateMap, emittedTracker) {
+			return true
+		}
+		breakChord(cfg, virtual, stateMap)
+
 		// Check if modifiers have active ladders - escape hatch to combo or fallback to cancellation
 		modifiers := m.GetCurrentModifiers()
-		common.LogDebug(">>> ESCAPE CHECK: super=%v ctrl=%v alt=%v shift=%v", modifiers.Super, modifiers.Ctrl, modifiers.Alt, modifiers.Shift)
-		checkModifierEscape := func(modName string, isHeld bool) bool {
-			if !isHeld {
-				return false
-			}
+		common.LogDebug(">>> ESCAPE CHECK: held=%v", modifiers.Names())
+		checkModifierEscape := func(modName string) bool {
 			if state := stateMap.Get(modName); state != nil {
 				comboKey := combo
 				if len(cfg.ParsedShortcuts[comboKey]) > 0 {
@@ -114,7 +133,7 @@ func HandlePress(code uint16, value int32, m *matcher.Matcher, cfg *config.Confi
 					state.Cancel()
 					stateMap.Delete(modName)
 					if virtual != nil {
-						ladder.EmitModifierKey(virtual, keys.ResolveKeyCode, modName, true)
+						ladder.EmitModifierKey(virtual, cfg.ResolveKey, modName, true)
 						emittedTracker.MarkDown(modName)
 					}
 				}
@@ -122,28 +141,20 @@ func HandlePress(code uint16, value int32, m *matcher.Matcher, cfg *config.Confi
 			return false
 		}
 
-		if checkModifierEscape("super", modifiers.Super) {
-			return true
-		}
-		if checkModifierEscape("ctrl", modifiers.Ctrl) {
-			return true
-		}
-		if checkModifierEscape("alt", modifiers.Alt) {
-			return true
-		}
-		if checkModifierEscape("shift", modifiers.Shift) {
-			return true
+		for _, modName := range modifiers.Names() {
+			if checkModifierEscape(modName) {
+				return true
+			}
 		}
 
 		shortcuts = m.GetShortcuts(combo)
-		if len(shortcuts) == 0 {
+		if len(shortcuts) == 0 && !cfg.ChordMap[combo] {
 			common.LogDebug("No shortcuts for %s, forwarding", combo)
 			restoreConsumedModifiers(virtual, modifiers, emittedTracker)
 			return false
 		}
 
-		common.LogDebug("Combo %s detected with modifiers: super=%v ctrl=%v alt=%v shift=%v",
-			combo, modifiers.Super, modifiers.Ctrl, modifiers.Alt, modifiers.Shift)
+		common.LogDebug("Combo %s detected with modifiers: %v", combo, modifiers.Names())
 	}
 
 	// Forward second press to a goroutine waiting in the doubletap window
@@ -158,18 +169,26 @@ func HandlePress(code uint16, value int32, m *matcher.Matcher, cfg *config.Confi
 
 	// Switch always fires immediately, independent of any chain
 	for _, s := range shortcuts {
-		if s.Behavior == config.BehaviorSwitch {
-			common.LogMatch(combo+".switch", m.GetComboCodes(code))
-			executeSwitchShortcut(combo, s, m, cfg)
-			suppress = true
+		if s.Behavior != config.BehaviorSwitch {
+			continue
+		}
+		if !loopState.AllowFire(s) {
+			// Refused by cooldown/ratelimit: swallow the press, or let it through
+			common.LogDebug("%s.switch refused by cooldown/ratelimit (forward=%v)", combo, s.ThrottleForward)
+			suppress = suppress || !s.ThrottleForward
+			continue
 		}
+		common.LogMatch(combo+".switch", m.GetComboCodes(code))
+		executeSwitchShortcut(combo, s, m, cfg, loopState)
+		suppress = true
 	}
 
 	// Build candidates for timer ladder
 	candidates := timers.BuildCandidates(shortcuts)
 
-	// No candidates means only switch/eager behaviors (already handled above)
-	if len(candidates) == 0 {
+	// No candidates means only switch/eager behaviors (already handled above),
+	// unless the key may still start a chord or is a custom modifier to withhold
+	if len(candidates) == 0 && !cfg.ChordMap[combo] && !withhold {
 		return suppress
 	}
 
@@ -179,23 +198,36 @@ func HandlePress(code uint16, value int32, m *matcher.Matcher, cfg *config.Confi
 	state := timers.NewComboState(cancel)
 	common.LogDebug(">>> ADDING %s to stateMap, launching goroutine", combo)
 	stateMap.Set(combo, state)
+	if cfg.ChordMap[combo] {
+		// Withhold the key while the rest of a chord may follow
+		state.Chord = timers.NewChordBuffer(state, code)
+		stateMap.SetChord(combo)
+	}
 	go ladder.Run(ctx, state, combo, code, value, candidates, cfg, loopState, outputs, virtual, modifiers, stateMap, emittedTracker, cfg.ParsedShortcuts)
 	return true
 }
 
 func HandleRelease(code uint16, value int32, m *matcher.Matcher, cfg *config.Config, loopState *executor.LoopState, outputs executor.Outputs, virtual *evdev.InputDevice, stateMap *timers.StateMap, emittedTracker *timers.EmittedModifierTracker, translator *Translator) bool {
-	if translator != nil && translator.TryRelease(code, m, virtual, emittedTracker) {
-		return true
+	if translator != nil {
+		if translator.TryDualRole(code, value, cfg, m, virtual, outputs, emittedTracker) {
+			return true
+		}
+		if translator.TryRelease(code, m, virtual, emittedTracker) {
+			return true
+		}
 	}
 
-	if matcher.IsModifierKey(code) {
-		combo := keys.GetKeyName(code)
+	if m.IsModifier(code) {
+		combo := m.KeyName(code)
 		common.LogDebug("Modifier %s released", combo)
 
 		if command, matched := m.CheckTap(code); matched {
 			resolvedCmd := cfg.ResolveCommand(command)
 			common.LogMatch(combo+".tap", fmt.Sprintf("%d", code))
 			common.LogTrigger(resolvedCmd)
+			behavior := config.BehaviorNormal.String() // a lone-modifier .onrelease shortcut
+			events.Fired(combo, behavior, config.DisplayCommand(resolvedCmd))
+			stats.Fired(combo, behavior, 0)
 			ctx := executor.ExecContext{
 				KeyCode:   code,
 				Value:     value,
@@ -221,7 +253,7 @@ func HandleRelease(code uint16, value int32, m *matcher.Matcher, cfg *config.Con
 		if emittedTracker.IsDown(combo) {
 			common.LogDebug("Emitting %s release (we emitted the press)", combo)
 			if virtual != nil {
-				ladder.EmitModifierKey(virtual, keys.ResolveKeyCode, combo, false)
+				ladder.EmitModifierKey(virtual, cfg.ResolveKey, combo, false)
 			}
 			emittedTracker.MarkUp(combo)
 			return true // Suppress original release since we emitted it
@@ -230,13 +262,23 @@ func HandleRelease(code uint16, value int32, m *matcher.Matcher, cfg *config.Con
 		// Either forwarded transparently (system already tracking it) or
 		// already consumed by a matched combo (a redundant keyup is a no-op).
 		emittedTracker.MarkUp(combo)
+		if m.IsCustomModifier(code) {
+			// The system never saw this press: it was withheld, and its ladder
+			// emits a tap if no combo matched
+			return true
+		}
 		common.LogDebug("Forwarding %s release to system", combo)
 		return false
 	}
 
+	// Keys of a chord release through the ladder that owns the chord
+	if releaseChord(code, cfg, virtual, stateMap) {
+		return true
+	}
+
 	combo := m.GetCurrentCombo(code)
-	common.LogDebug(">>> RELEASE: code=%d, built combo=%s, modifiers=super:%v ctrl:%v alt:%v shift:%v",
-		code, combo, m.GetCurrentModifiers().Super, m.GetCurrentModifiers().Ctrl, m.GetCurrentModifiers().Alt, m.GetCurrentModifiers().Shift)
+	common.LogDebug(">>> RELEASE: code=%d, built combo=%s, modifiers=%v",
+		code, combo, m.GetCurrentModifiers().Names())
 
 	// Signal release to active goroutine if one exists
 	if state := stateMap.Get(combo); state != nil {
@@ -252,35 +294,93 @@ func HandleRelease(code uint16, value int32, m *matcher.Matcher, cfg *config.Con
 	return false
 }
 
+// HandleRepeat handles kernel autorepeat (value 2) of a held key. Translated
+// keys and sustained remaps repeat their target, .autorepeat shortcuts fire
+// again, and repeats of any other key the daemon took are suppressed, so the
+// system never sees a repeat of a key it does not see held.
+func HandleRepeat(code uint16, value int32, m *matcher.Matcher, cfg *config.Config, loopState *executor.LoopState, outputs executor.Outputs, virtual *evdev.InputDevice, stateMap *timers.StateMap, emittedTracker *timers.EmittedModifierTracker, translator *Translator) bool {
+	if translator != nil {
+		if translator.TryDualRole(code, value, cfg, m, virtual, outputs, emittedTracker) {
+			return true
+		}
+		if translator.TryRepeat(code, virtual) {
+			return true
+		}
+	}
+
+	if m.IsModifier(code) {
+		// Forwarded modifiers repeat; withheld or consumed ones stay silent
+		return !emittedTracker.IsDown(m.KeyName(code))
+	}
+
+	// Keys of a forming chord are withheld until it resolves
+	if chord := stateMap.Chord(); chord != "" {
+		if state := stateMap.Get(chord); state != nil && state.Chord != nil && state.Chord.Withholds(code) {
+			return true
+		}
+	}
+
+	combo := m.GetCurrentCombo(code)
+	if held, err := loopState.RepeatHeldKey(combo); held {
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Failed to repeat held remap for %s: %v\n", combo, err)
+		}
+		return true
+	}
+
+	shortcuts := m.GetShortcuts(combo)
+	for _, s := range shortcuts {
+		if !s.AutoRepeat || stateMap.Get(combo) != nil {
+			continue
+		}
+		if !loopState.AllowFire(s) {
+			return true
+		}
+		resolvedCmd := cfg.ResolveCommand(s.Commands[0])
+		common.LogMatch(combo+".autorepeat", fmt.Sprintf("%d", code))
+		common.LogTrigger(resolvedCmd)
+		events.Fired(combo, s.Behavior.String(), config.DisplayCommand(resolvedCmd))
+		stats.Fired(combo, s.Behavior.String(), 0)
+		executor.Run(resolvedCmd, executor.ExecContext{
+			KeyCode:   code,
+			Value:     value,
+			Virtual:   virtual,
+			Outputs:   outputs,
+			Modifiers: m.GetCurrentModifiers(),
+			Config:    cfg,
+			LoopState: loopState,
+			Combo:     combo,
+			Policy:    s.Policy,
+			Timeout:   s.Timeout,
+		})
+		return true
+	}
+	return len(shortcuts) > 0
+}
+
 // restoreConsumedModifiers re-asserts any physically held modifier that was
 // previously consumed by a matched combo (its keyup sent to the system) but
 // is still being forwarded transparently now that no combo matched. Without
 // this, e.g. holding ctrl through a "ctrl+up" combo and then pressing an
 // unrelated key like "c" would arrive as a bare "c" instead of "ctrl+c".
+// Custom modifiers are ordinary keys to the system and are not restored.
 func restoreConsumedModifiers(virtual *evdev.InputDevice, modifiers matcher.ModifierState, emittedTracker *timers.EmittedModifierTracker) {
-	restore := func(name string, held bool) {
-		if !held || emittedTracker.IsDown(name) {
-			return
+	for _, name := range []string{"super", "ctrl", "alt", "shift"} {
+		if !modifiers.Has(name) || emittedTracker.IsDown(name) {
+			continue
 		}
 		if virtual != nil {
 			ladder.EmitModifierKey(virtual, keys.ResolveKeyCode, name, true)
 		}
 		emittedTracker.MarkDown(name)
 	}
-	restore("super", modifiers.Super)
-	restore("ctrl", modifiers.Ctrl)
-	restore("alt", modifiers.Alt)
-	restore("shift", modifiers.Shift)
 }
 
-func executeSwitchShortcut(combo string, shortcut *config.ParsedShortcut, m *matcher.Matcher, cfg *config.Config) {
-	groupKey := combo
-	if shortcut.AliasGroup != "" {
-		groupKey = shortcut.AliasGroup
-	}
-	key := fmt.Sprintf("%s.switch.%d", groupKey, shortcut.Timing)
-	command := m.GetNextSwitchCommand(key, shortcut.Commands)
+func executeSwitchShortcut(combo string, shortcut *config.ParsedShortcut, m *matcher.Matcher, cfg *config.Config, loopState *executor.LoopState) {
+	command := m.GetNextSwitchCommand(matcher.SwitchKey(combo, shortcut), shortcut)
 	resolvedCmd := cfg.ResolveCommand(command)
 	common.LogTrigger(resolvedCmd)
-	executor.Execute(resolvedCmd, cfg)
+	events.Fired(combo, shortcut.Behavior.String(), config.DisplayCommand(resolvedCmd))
+	stats.Fired(combo, shortcut.Behavior.String(), 0)
+	loopState.Launch(combo, shortcut.Policy, resolvedCmd, shortcut.Timeout, cfg)
 }
@@ -1,12 +1,17 @@
 package handlers
 
 import (
+	"os"
+	"path/filepath"
+	"strings"
 	"testing"
 	"time"
 
 	"github.com/deprecatedluar/akeyshually/internal/config"
+	"github.com/deprecatedluar/akeyshually/internal/events"
 	"github.com/deprecatedluar/akeyshually/internal/executor"
 	"github.com/deprecatedluar/akeyshually/internal/matcher"
+	"github.com/deprecatedluar/akeyshually/internal/stats"
 	"github.com/deprecatedluar/akeyshually/internal/timers"
 	evdev "github.com/holoplot/go-evdev"
 )
@@ -180,3 +185,262 @@ func TestNonComboKeyRestoresConsumedModifier(t *testing.T) {
 		t.Fatal("ctrl should have been restored before forwarding the unmatched key")
 	}
 }
+
+// customModifierTestConfig declares hyper = "capslock" and binds "hyper+h" to
+// a command that creates marker.
+func customModifierTestConfig(marker string) *config.Config {
+	shortcut := &config.ParsedShortcut{
+		KeyCombo: "hyper+h",
+		Behavior: config.BehaviorNormal,
+		Commands: []string{"touch " + marker},
+	}
+	return &config.Config{
+		Settings:        config.Settings{DefaultInterval: 150},
+		Modifiers:       map[string]string{"hyper": "capslock"},
+		ParsedShortcuts: map[string][]*config.ParsedShortcut{"hyper+h": {shortcut}},
+		EscapeMap:       map[string]bool{"hyper": true},
+	}
+}
+
+func TestCustomModifierComboEscapesLadder(t *testing.T) {
+	marker := filepath.Join(t.TempDir(), "fired")
+	cfg := customModifierTestConfig(marker)
+	m := matcher.New(cfg.ParsedShortcuts)
+	m.SetCustomModifiers(cfg.Modifiers)
+	stateMap := timers.NewStateMap()
+	emittedTracker := timers.NewEmittedModifierTracker()
+	loopState := executor.NewLoopState()
+
+	if !HandlePress(uint16(evdev.KEY_CAPSLOCK), 1, m, cfg, loopState, executor.Outputs{}, nil, stateMap, emittedTracker, nil) {
+		t.Fatal("custom modifier with combos should be withheld")
+	}
+	if stateMap.Get("hyper") == nil {
+		t.Fatal("custom modifier should wait in a ladder")
+	}
+	if !HandlePress(uint16(evdev.KEY_H), 1, m, cfg, loopState, executor.Outputs{}, nil, stateMap, emittedTracker, nil) {
+		t.Fatal("combo key should be suppressed")
+	}
+	waitForFile(t, marker)
+
+	HandleRelease(uint16(evdev.KEY_H), 0, m, cfg, loopState, executor.Outputs{}, nil, stateMap, emittedTracker, nil)
+	if !HandleRelease(uint16(evdev.KEY_CAPSLOCK), 0, m, cfg, loopState, executor.Outputs{}, nil, stateMap, emittedTracker, nil) {
+		t.Error("release of a withheld custom modifier should be suppressed")
+	}
+	if m.GetCurrentModifiers().Has("hyper") {
+		t.Error("hyper should no longer be held")
+	}
+}
+
+func TestCustomModifierReleasedAlone(t *testing.T) {
+	cfg := customModifierTestConfig(filepath.Join(t.TempDir(), "fired"))
+	m := matcher.New(cfg.ParsedShortcuts)
+	m.SetCustomModifiers(cfg.Modifiers)
+	stateMap := timers.NewStateMap()
+	emittedTracker := timers.NewEmittedModifierTracker()
+	loopState := executor.NewLoopState()
+
+	HandlePress(uint16(evdev.KEY_CAPSLOCK), 1, m, cfg, loopState, executor.Outputs{}, nil, stateMap, emittedTracker, nil)
+	if !HandleRelease(uint16(evdev.KEY_CAPSLOCK), 0, m, cfg, loopState, executor.Outputs{}, nil, stateMap, emittedTracker, nil) {
+		t.Fatal("physical release should be suppressed; the ladder emits the tap")
+	}
+	waitForLadderDone(t, stateMap, "hyper")
+}
+
+// A custom modifier with no combos is just a key and passes through.
+func TestUnusedCustomModifierIsForwarded(t *testing.T) {
+	cfg := &config.Config{
+		Modifiers:       map[string]string{"hyper": "capslock"},
+		ParsedShortcuts: map[string][]*config.ParsedShortcut{},
+		EscapeMap:       map[string]bool{},
+	}
+	m := matcher.New(cfg.ParsedShortcuts)
+	m.SetCustomModifiers(cfg.Modifiers)
+	emittedTracker := timers.NewEmittedModifierTracker()
+
+	if HandlePress(uint16(evdev.KEY_CAPSLOCK), 1, m, cfg, executor.NewLoopState(), executor.Outputs{}, nil,
+		timers.NewStateMap(), emittedTracker, nil) {
+		t.Fatal("custom modifier without combos should be forwarded")
+	}
+	if !emittedTracker.IsDown("hyper") {
+		t.Fatal("forwarded custom modifier should be tracked as down")
+	}
+}
+
+func TestAutoRepeatFiresOnKernelRepeat(t *testing.T) {
+	marker := filepath.Join(t.TempDir(), "fired")
+	shortcut := &config.ParsedShortcut{
+		KeyCombo:   "f5",
+		Behavior:   config.BehaviorNormal,
+		Commands:   []string{"touch " + marker},
+		AutoRepeat: true,
+	}
+	cfg := &config.Config{
+		Settings:        config.Settings{DefaultInterval: 150},
+		ParsedShortcuts: map[string][]*config.ParsedShortcut{"f5": {shortcut}},
+	}
+	m := matcher.New(cfg.ParsedShortcuts)
+	stateMap := timers.NewStateMap()
+	emittedTracker := timers.NewEmittedModifierTracker()
+	loopState := executor.NewLoopState()
+	codeF5 := uint16(evdev.KEY_F5)
+
+	stats.Reset()
+
+	HandlePress(codeF5, 1, m, cfg, loopState, executor.Outputs{}, nil, stateMap, emittedTracker, nil)
+	waitForFile(t, marker)
+	waitForLadderDone(t, stateMap, "f5")
+	if err := os.Remove(marker); err != nil {
+		t.Fatal(err)
+	}
+
+	fired, unsubscribe := events.Subscribe()
+	defer unsubscribe()
+	if !HandleRepeat(codeF5, 2, m, cfg, loopState, executor.Outputs{}, nil, stateMap, emittedTracker, nil) {
+		t.Fatal("repeat of an .autorepeat key should be suppressed")
+	}
+	waitForFile(t, marker)
+
+	// Repeat fires show up in watch and stats like the first one
+	if e := nextFired(t, fired); e.Combo != "f5" || e.Behavior != "normal" {
+		t.Errorf("fired event = %+v, want f5 (normal)", e)
+	}
+	if s := stats.Snapshot().Shortcuts; len(s) != 1 || s[0].Combo != "f5" || s[0].Fires != 2 {
+		t.Errorf("stats = %+v, want f5 fired twice", s)
+	}
+}
+
+// A lone-modifier tap is reported under one behavior name to watch and stats.
+func TestModifierTapReportsBehavior(t *testing.T) {
+	shortcut := &config.ParsedShortcut{KeyCombo: "super", Behavior: config.BehaviorNormal, Timing: config.TimingRelease, Commands: []string{"true"}}
+	cfg := &config.Config{ParsedShortcuts: map[string][]*config.ParsedShortcut{"super": {shortcut}}}
+	m := matcher.New(cfg.ParsedShortcuts)
+	m.SetTapState(matcher.NewTapState())
+	stateMap := timers.NewStateMap()
+	emittedTracker := timers.NewEmittedModifierTracker()
+	loopState := executor.NewLoopState()
+	stats.Reset()
+
+	fired, unsubscribe := events.Subscribe()
+	defer unsubscribe()
+	code := uint16(evdev.KEY_LEFTMETA)
+	HandlePress(code, 1, m, cfg, loopState, executor.Outputs{}, nil, stateMap, emittedTracker, nil)
+	HandleRelease(code, 0, m, cfg, loopState, executor.Outputs{}, nil, stateMap, emittedTracker, nil)
+
+	e := nextFired(t, fired)
+	s := stats.Snapshot().Shortcuts
+	if len(s) != 1 || s[0].Combo != e.Combo || s[0].Behavior != e.Behavior {
+		t.Errorf("fired event %s (%s), stats %+v: want the same shortcut", e.Combo, e.Behavior, s)
+	}
+}
+
+// nextFired waits up to a second for the next ShortcutFired event.
+func nextFired(t *testing.T, ch <-chan events.Event) events.Event {
+	t.Helper()
+	timeout := time.After(time.Second)
+	for {
+		select {
+		case e := <-ch:
+			if e.Type == events.ShortcutFired {
+				return e
+			}
+		case <-timeout:
+			t.Fatal("no shortcut fired event")
+		}
+	}
+}
+
+func TestRepeatOfBoundKeyIsSuppressed(t *testing.T) {
+	cfg := &config.Config{
+		ParsedShortcuts: map[string][]*config.ParsedShortcut{
+			"f6": {{KeyCombo: "f6", Behavior: config.BehaviorNormal, Commands: []string{"true"}}},
+		},
+	}
+	m := matcher.New(cfg.ParsedShortcuts)
+	emittedTracker := timers.NewEmittedModifierTracker()
+	repeat := func(code uint16) bool {
+		return HandleRepeat(code, 2, m, cfg, executor.NewLoopState(), executor.Outputs{}, nil, timers.NewStateMap(), emittedTracker, nil)
+	}
+
+	if !repeat(uint16(evdev.KEY_F6)) {
+		t.Error("repeat of a bound key should be suppressed")
+	}
+	if repeat(uint16(evdev.KEY_F7)) {
+		t.Error("repeat of an unbound key should be forwarded")
+	}
+
+	// A modifier repeats only while the system sees it held
+	m.UpdateModifierState(uint16(evdev.KEY_LEFTCTRL), true)
+	if !repeat(uint16(evdev.KEY_LEFTCTRL)) {
+		t.Error("repeat of a withheld modifier should be suppressed")
+	}
+	emittedTracker.MarkDown("ctrl")
+	if repeat(uint16(evdev.KEY_LEFTCTRL)) {
+		t.Error("repeat of a forwarded modifier should be forwarded")
+	}
+}
+
+func TestTripleTapOutlastsDoubleTap(t *testing.T) {
+	dir := t.TempDir()
+	doubleMarker := filepath.Join(dir, "double")
+	tripleMarker := filepath.Join(dir, "triple")
+	cfg := &config.Config{
+		Settings: config.Settings{DefaultInterval: 150},
+		ParsedShortcuts: map[string][]*config.ParsedShortcut{"f8": {
+			{KeyCombo: "f8", Behavior: config.BehaviorDoubleTap, Commands: []string{"touch " + doubleMarker}},
+			{KeyCombo: "f8", Behavior: config.BehaviorMultiTap, TapCount: 3, Commands: []string{"touch " + tripleMarker}},
+		}},
+	}
+	m := matcher.New(cfg.ParsedShortcuts)
+	stateMap := timers.NewStateMap()
+	emittedTracker := timers.NewEmittedModifierTracker()
+	loopState := executor.NewLoopState()
+	codeF8 := uint16(evdev.KEY_F8)
+
+	for range 3 {
+		HandlePress(codeF8, 1, m, cfg, loopState, executor.Outputs{}, nil, stateMap, emittedTracker, nil)
+		time.Sleep(10 * time.Millisecond)
+		HandleRelease(codeF8, 0, m, cfg, loopState, executor.Outputs{}, nil, stateMap, emittedTracker, nil)
+		time.Sleep(10 * time.Millisecond)
+	}
+	waitForFile(t, tripleMarker)
+	waitForLadderDone(t, stateMap, "f8")
+	if _, err := os.Stat(doubleMarker); err == nil {
+		t.Error("doubletap fired although a third tap followed")
+	}
+}
+
+func TestCooldownRefusesRepeatedFire(t *testing.T) {
+	log := filepath.Join(t.TempDir(), "fired")
+	cfg := &config.Config{
+		Settings: config.Settings{DefaultInterval: 150},
+		ParsedShortcuts: map[string][]*config.ParsedShortcut{"f4": {{
+			KeyCombo:   "f4",
+			Behavior:   config.BehaviorNormal,
+			Commands:   []string{"echo x >> " + log},
+			RateLimit:  1,
+			RateWindow: 60000,
+		}}},
+	}
+	m := matcher.New(cfg.ParsedShortcuts)
+	stateMap := timers.NewStateMap()
+	emittedTracker := timers.NewEmittedModifierTracker()
+	loopState := executor.NewLoopState()
+	codeF4 := uint16(evdev.KEY_F4)
+
+	for range 2 {
+		if !HandlePress(codeF4, 1, m, cfg, loopState, executor.Outputs{}, nil, stateMap, emittedTracker, nil) {
+			t.Fatal("press of a bound key should be suppressed")
+		}
+		waitForLadderDone(t, stateMap, "f4")
+		HandleRelease(codeF4, 0, m, cfg, loopState, executor.Outputs{}, nil, stateMap, emittedTracker, nil)
+	}
+	waitForFile(t, log)
+	time.Sleep(50 * time.Millisecond)
+	data, err := os.ReadFile(log)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := strings.Count(string(data), "x"); got != 1 {
+		t.Errorf("command ran %d times within the cooldown, want 1", got)
+	}
+}
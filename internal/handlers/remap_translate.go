@@ -2,6 +2,7 @@ package handlers
 
 import (
 	"strings"
+	"sync"
 
 	"github.com/deprecatedluar/akeyshually/internal/common"
 	"github.com/deprecatedluar/akeyshually/internal/config"
@@ -37,9 +38,18 @@ type activeTranslation struct {
 // combo resolves through Config.RemapTable is translated into its target
 // key at press/release time, before the matcher or ladder ever see it. One
 // Translator belongs to a single keyboard, mirroring Matcher's lifecycle.
+//
+// It also resolves .tapmod dual-role keys (see TryDualRole). Its mutex
+// serializes the keyboard's event handling with the dual-role hold timer.
 type Translator struct {
+	sync.Mutex
+
 	active            map[uint16]activeTranslation
 	virtualKeyCapable map[uint16]bool
+
+	virtual  *evdev.InputDevice
+	dispatch func(evdev.InputEvent) bool
+	dual     *pendingDualRole
 }
 
 // NewTranslator creates a Translator for the given keyboard's virtual clone.
@@ -53,7 +63,7 @@ func NewTranslator(virtual *evdev.InputDevice) *Translator {
 			capable[uint16(code)] = true
 		}
 	}
-	return &Translator{active: make(map[uint16]activeTranslation), virtualKeyCapable: capable}
+	return &Translator{active: make(map[uint16]activeTranslation), virtualKeyCapable: capable, virtual: virtual}
 }
 
 // TryPress looks up combo in cfg.RemapTable. On a hit it consumes any held
@@ -78,6 +88,12 @@ func (t *Translator) TryPress(code uint16, combo string, cfg *config.Config, m *
 	if !ok {
 		return false
 	}
+	return t.pressTarget(code, combo, target, m, virtual, outputs, emittedTracker)
+}
+
+// pressTarget emits the keydown for target on behalf of the physical key code
+// and records the translation so release can undo it. See TryPress.
+func (t *Translator) pressTarget(code uint16, combo, target string, m *matcher.Matcher, virtual *evdev.InputDevice, outputs executor.Outputs, emittedTracker *timers.EmittedModifierTracker) bool {
 	targetCode, ok := keys.ResolveKeyCode(target)
 	if !ok {
 		return false
@@ -90,7 +106,7 @@ func (t *Translator) TryPress(code uint16, combo string, cfg *config.Config, m *
 	var err error
 	switch sink {
 	case sinkVirtual:
-		err = emitKeyToVirtual(virtual, targetCode, true)
+		err = emitKeyToVirtual(virtual, targetCode, keyPressValue)
 	default:
 		err = output.WriteFrame(evdev.InputEvent{Type: evdev.EV_KEY, Code: evdev.EvCode(targetCode), Value: 1})
 	}
@@ -123,7 +139,7 @@ func (t *Translator) TryRelease(code uint16, m *matcher.Matcher, virtual *evdev.
 	var err error
 	switch translation.sink {
 	case sinkVirtual:
-		err = emitKeyToVirtual(virtual, translation.code, false)
+		err = emitKeyToVirtual(virtual, translation.code, keyReleaseValue)
 	default:
 		err = translation.output.WriteFrame(evdev.InputEvent{Type: evdev.EV_KEY, Code: evdev.EvCode(translation.code), Value: 0})
 	}
@@ -140,6 +156,31 @@ func (t *Translator) TryRelease(code uint16, m *matcher.Matcher, virtual *evdev.
 	return true
 }
 
+// TryRepeat mirrors a kernel autorepeat of a translated key onto its target,
+// on the same sink its press used. Pointer buttons have no autorepeat, so
+// their repeats are only swallowed. Returns false if code has no active
+// translation.
+func (t *Translator) TryRepeat(code uint16, virtual *evdev.InputDevice) bool {
+	translation, ok := t.active[code]
+	if !ok {
+		return false
+	}
+
+	var err error
+	switch translation.sink {
+	case sinkPointer:
+		return true
+	case sinkVirtual:
+		err = emitKeyToVirtual(virtual, translation.code, keyRepeatValue)
+	default:
+		err = translation.output.WriteFrame(evdev.InputEvent{Type: evdev.EV_KEY, Code: evdev.EvCode(translation.code), Value: keyRepeatValue})
+	}
+	if err != nil {
+		common.LogDebug("remap translate: repeat code=%d failed: %v", translation.code, err)
+	}
+	return true
+}
+
 // resolveSink decides where a translated target key should be written:
 // pointer buttons always go to outputs.Pointer; keyboard codes go to the
 // virtual clone when it declares the capability, falling back to
@@ -156,11 +197,7 @@ func (t *Translator) resolveSink(targetCode uint16, virtual *evdev.InputDevice,
 
 // emitKeyToVirtual writes a single key event followed by SYN_REPORT to the
 // device's own virtual clone, mirroring ladder.EmitModifierKey's framing.
-func emitKeyToVirtual(virtual *evdev.InputDevice, code uint16, down bool) error {
-	value := int32(0)
-	if down {
-		value = 1
-	}
+func emitKeyToVirtual(virtual *evdev.InputDevice, code uint16, value int32) error {
 	if err := virtual.WriteOne(&evdev.InputEvent{Type: evdev.EV_KEY, Code: evdev.EvCode(code), Value: value}); err != nil {
 		return err
 	}
@@ -170,7 +170,7 @@ func TestTranslatorPressUpdatesMatcherModifierState(t *testing.T) {
 	if !translator.TryPress(capslockCode, "capslock", cfg, m, nil, outputs, emittedTracker) {
 		t.Fatal("expected capslock press to translate via RemapTable hit")
 	}
-	if !m.GetCurrentModifiers().Super {
+	if !m.GetCurrentModifiers().Has("super") {
 		t.Fatal("translating capslock to super should set Matcher's Super state")
 	}
 	if !emittedTracker.IsDown("super") {
@@ -185,10 +185,41 @@ func TestTranslatorPressUpdatesMatcherModifierState(t *testing.T) {
 	if !translator.TryRelease(capslockCode, m, nil, emittedTracker) {
 		t.Fatal("expected capslock release to find the recorded translation")
 	}
-	if m.GetCurrentModifiers().Super {
+	if m.GetCurrentModifiers().Has("super") {
 		t.Fatal("releasing the translated capslock should clear Matcher's Super state")
 	}
 	if emittedTracker.IsDown("super") {
 		t.Fatal("releasing the translated capslock should mark super as emitted-up")
 	}
 }
+
+func TestTranslatorRepeatMirrorsTarget(t *testing.T) {
+	cfg := &config.Config{RemapTable: map[string]string{"f3": "a", "f2": "lclick"}}
+	outputs, keyboardWriter, pointerWriter := testOutputs()
+	emittedTracker := timers.NewEmittedModifierTracker()
+	m := matcher.New(map[string][]*config.ParsedShortcut{})
+	translator := NewTranslator(nil)
+
+	codeF3 := uint16(evdev.KEY_F3)
+	if translator.TryRepeat(codeF3, nil) {
+		t.Fatal("repeat without an active translation should not be taken")
+	}
+	translator.TryPress(codeF3, "f3", cfg, m, nil, outputs, emittedTracker)
+	if !translator.TryRepeat(codeF3, nil) {
+		t.Fatal("expected f3 repeat to follow its translation")
+	}
+	events := keyEvents(keyboardWriter.snapshot())
+	if len(events) != 2 || events[1].Code != evdev.KEY_A || events[1].Value != 2 {
+		t.Fatalf("expected a KEY_A repeat after the down, got %+v", events)
+	}
+
+	// Pointer buttons do not autorepeat: the repeat is swallowed
+	codeF2 := uint16(evdev.KEY_F2)
+	translator.TryPress(codeF2, "f2", cfg, m, nil, outputs, emittedTracker)
+	if !translator.TryRepeat(codeF2, nil) {
+		t.Fatal("expected f2 repeat to be taken")
+	}
+	if events := keyEvents(pointerWriter.snapshot()); len(events) != 1 {
+		t.Fatalf("expected only the BTN_LEFT down on pointer output, got %+v", events)
+	}
+}
@@ -0,0 +1,215 @@
+// Package client talks to the running daemon over its IPC socket using the
+// JSON protocol defined in package ipc.
+package client
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"time"
+
+	"github.com/deprecatedluar/akeyshually/internal/events"
+	"github.com/deprecatedluar/akeyshually/internal/ipc"
+	"github.com/deprecatedluar/akeyshually/internal/stats"
+)
+
+const replyTimeout = 10 * time.Second
+
+// ErrNotRunning is returned when nothing listens on the socket.
+var ErrNotRunning = errors.New("daemon not running")
+
+// Client sends requests to the daemon listening on SockPath, one connection
+// per request.
+type Client struct {
+	SockPath string
+}
+
+// New returns a client for the daemon socket at sockPath.
+func New(sockPath string) *Client {
+	return &Client{SockPath: sockPath}
+}
+
+// Do sends req and decodes the reply data into data (if non-nil). A reply
+// with ok=false is returned as an error carrying the daemon's message.
+func (c *Client) Do(req ipc.Request, data any) error {
+	conn, _, resp, err := c.request(req)
+	if err != nil {
+		return err
+	}
+	conn.Close()
+	if data != nil && len(resp.Data) > 0 {
+		return json.Unmarshal(resp.Data, data)
+	}
+	return nil
+}
+
+// Subscribe passes the daemon's events to handle, starting with an
+// events.State snapshot, until handle returns an error or the connection
+// ends (the daemon stopped or reloaded), which is returned as ErrNotRunning.
+func (c *Client) Subscribe(handle func(events.Event) error) error {
+	return c.stream(ipc.Request{Type: ipc.RequestSubscribe}, handle)
+}
+
+// Inspect passes every key and axis event the daemon reads to handle, as
+// events.Input events, until handle returns an error or the connection ends.
+func (c *Client) Inspect(handle func(events.Event) error) error {
+	return c.stream(ipc.Request{Type: ipc.RequestInspect}, handle)
+}
+
+// stream sends req and passes each event line after the reply to handle.
+func (c *Client) stream(req ipc.Request, handle func(events.Event) error) error {
+	conn, reader, _, err := c.request(req)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	conn.SetReadDeadline(time.Time{})
+
+	for {
+		line, err := reader.ReadBytes('\n')
+		if err != nil {
+			return fmt.Errorf("%w: connection closed", ErrNotRunning)
+		}
+		var e events.Event
+		if err := json.Unmarshal(line, &e); err != nil {
+			return fmt.Errorf("invalid event from daemon: %w", err)
+		}
+		if err := handle(e); err != nil {
+			return err
+		}
+	}
+}
+
+// request sends req and reads the Response, returning the open connection
+// and its reader for anything the daemon sends after it.
+func (c *Client) request(req ipc.Request) (net.Conn, *bufio.Reader, ipc.Response, error) {
+	req.Version = ipc.ProtocolVersion
+	var resp ipc.Response
+
+	conn, err := net.Dial("unix", c.SockPath)
+	if err != nil {
+		return nil, nil, resp, fmt.Errorf("%w: %v", ErrNotRunning, err)
+	}
+	fail := func(err error) (net.Conn, *bufio.Reader, ipc.Response, error) {
+		conn.Close()
+		return nil, nil, resp, err
+	}
+
+	line, err := json.Marshal(req)
+	if err != nil {
+		return fail(err)
+	}
+	if _, err := fmt.Fprintf(conn, "%s\n", line); err != nil {
+		return fail(fmt.Errorf("send request: %w", err))
+	}
+
+	// A trigger replies once its key has been released
+	conn.SetReadDeadline(time.Now().Add(replyTimeout + time.Duration(req.DurationMs)*time.Millisecond))
+	reader := bufio.NewReader(conn)
+	reply, err := reader.ReadBytes('\n')
+	if err != nil {
+		return fail(fmt.Errorf("no reply from daemon: %w", err))
+	}
+	if err := json.Unmarshal(reply, &resp); err != nil {
+		return fail(fmt.Errorf("invalid reply from daemon: %w", err))
+	}
+	if !resp.OK {
+		return fail(errors.New(resp.Error))
+	}
+	return conn, reader, resp, nil
+}
+
+// Emit injects a remap token sequence.
+func (c *Client) Emit(tokens []string) error {
+	return c.Do(ipc.Request{Type: ipc.RequestEmit, Tokens: tokens}, nil)
+}
+
+// ReleaseAll releases every key held with >>.
+func (c *Client) ReleaseAll() error {
+	return c.Do(ipc.Request{Type: ipc.RequestReleaseAll}, nil)
+}
+
+// Trigger fires the shortcut configured for combo as if its key were held
+// for hold. behavior picks the shortcut when the combo has several.
+func (c *Client) Trigger(combo, behavior string, hold time.Duration) error {
+	return c.Do(ipc.Request{
+		Type:       ipc.RequestTrigger,
+		Combo:      combo,
+		Behavior:   behavior,
+		DurationMs: hold.Milliseconds(),
+	}, nil)
+}
+
+// Reload makes the daemon restart with the current config files.
+func (c *Client) Reload() error {
+	return c.Do(ipc.Request{Type: ipc.RequestReload}, nil)
+}
+
+// EnableOverlay enables an overlay and reloads the daemon.
+func (c *Client) EnableOverlay(name string) error {
+	return c.Do(ipc.Request{Type: ipc.RequestEnableOverlay, Overlay: name}, nil)
+}
+
+// DisableOverlay disables an overlay and reloads the daemon.
+func (c *Client) DisableOverlay(name string) error {
+	return c.Do(ipc.Request{Type: ipc.RequestDisableOverlay, Overlay: name}, nil)
+}
+
+// Status describes the running daemon.
+func (c *Client) Status() (ipc.Status, error) {
+	var status ipc.Status
+	err := c.Do(ipc.Request{Type: ipc.RequestStatus}, &status)
+	return status, err
+}
+
+// Shortcuts lists the shortcuts the daemon has loaded.
+func (c *Client) Shortcuts() ([]ipc.ShortcutInfo, error) {
+	var shortcuts []ipc.ShortcutInfo
+	err := c.Do(ipc.Request{Type: ipc.RequestListShortcuts}, &shortcuts)
+	return shortcuts, err
+}
+
+// Devices lists the devices the daemon listens to.
+func (c *Client) Devices() ([]ipc.DeviceInfo, error) {
+	var devices []ipc.DeviceInfo
+	err := c.Do(ipc.Request{Type: ipc.RequestListDevices}, &devices)
+	return devices, err
+}
+
+// SwitchIndex returns the index the next press of a .switch combo fires.
+func (c *Client) SwitchIndex(combo string) (ipc.SwitchInfo, error) {
+	var info ipc.SwitchInfo
+	err := c.Do(ipc.Request{Type: ipc.RequestSwitch, Action: "get", Combo: combo}, &info)
+	return info, err
+}
+
+// SetSwitchIndex moves a .switch cycle so the next press fires index idx.
+func (c *Client) SetSwitchIndex(combo string, idx int) error {
+	return c.Do(ipc.Request{Type: ipc.RequestSwitch, Action: "set", Combo: combo, Index: idx}, nil)
+}
+
+// ResetSwitch moves a .switch cycle back to its first command.
+func (c *Client) ResetSwitch(combo string) error {
+	return c.Do(ipc.Request{Type: ipc.RequestSwitch, Action: "reset", Combo: combo}, nil)
+}
+
+// Last returns the n most recent command executions, most recent first.
+func (c *Client) Last(n int) ([]ipc.ExecutionInfo, error) {
+	var executions []ipc.ExecutionInfo
+	err := c.Do(ipc.Request{Type: ipc.RequestLast, Count: n}, &executions)
+	return executions, err
+}
+
+// Stats returns the daemon's usage counters.
+func (c *Client) Stats() (stats.Stats, error) {
+	var st stats.Stats
+	err := c.Do(ipc.Request{Type: ipc.RequestStats}, &st)
+	return st, err
+}
+
+// ResetStats zeroes the daemon's usage counters.
+func (c *Client) ResetStats() error {
+	return c.Do(ipc.Request{Type: ipc.RequestStats, Action: "reset"}, nil)
+}
@@ -0,0 +1,128 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	evdev "github.com/holoplot/go-evdev"
+
+	"github.com/deprecatedluar/akeyshually/internal/config"
+	"github.com/deprecatedluar/akeyshually/internal/events"
+	"github.com/deprecatedluar/akeyshually/internal/executor"
+	"github.com/deprecatedluar/akeyshually/internal/ipc"
+	"github.com/deprecatedluar/akeyshually/internal/matcher"
+)
+
+type fakeWriter struct{}
+
+func (fakeWriter) WriteOne(*evdev.InputEvent) error { return nil }
+
+type fakeDaemon struct {
+	*matcher.Matcher
+}
+
+func (fakeDaemon) Trigger(combo, _ string, _ time.Duration) error {
+	return errors.New("nothing bound to " + combo)
+}
+func (fakeDaemon) Status() ipc.Status                   { return ipc.Status{PID: 7, Shortcuts: 1} }
+func (fakeDaemon) Shortcuts() []ipc.ShortcutInfo        { return nil }
+func (fakeDaemon) Devices() []ipc.DeviceInfo            { return nil }
+func (fakeDaemon) Reload() (func(), error)              { return func() {}, nil }
+func (fakeDaemon) EnableOverlay(string) (func(), error) { return func() {}, nil }
+func (fakeDaemon) DisableOverlay(string) (func(), error) {
+	return nil, errors.New("overlay not enabled")
+}
+
+func startDaemon(t *testing.T) *Client {
+	t.Helper()
+	sockPath := filepath.Join(t.TempDir(), "test.sock")
+	outputs := executor.Outputs{
+		Keyboard: executor.NewEventSink(fakeWriter{}),
+		Pointer:  executor.NewEventSink(fakeWriter{}),
+	}
+	daemon := fakeDaemon{matcher.New(map[string][]*config.ParsedShortcut{
+		"f1": {{KeyCombo: "f1", Behavior: config.BehaviorSwitch, Commands: []string{"a", "b"}}},
+	})}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	go ipc.Serve(ctx, sockPath, outputs, executor.NewLoopState(), daemon)
+
+	for range 100 {
+		if _, err := os.Stat(sockPath); err == nil {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	return New(sockPath)
+}
+
+func TestClientRequests(t *testing.T) {
+	c := startDaemon(t)
+
+	if err := c.Emit([]string{">a"}); err != nil {
+		t.Fatalf("Emit: %v", err)
+	}
+	if err := c.ReleaseAll(); err != nil {
+		t.Fatalf("ReleaseAll: %v", err)
+	}
+	status, err := c.Status()
+	if err != nil || status.PID != 7 {
+		t.Fatalf("Status = %+v, %v", status, err)
+	}
+	if err := c.SetSwitchIndex("f1", 1); err != nil {
+		t.Fatalf("SetSwitchIndex: %v", err)
+	}
+	if info, err := c.SwitchIndex("f1"); err != nil || info.Next != 1 || info.Count != 2 {
+		t.Fatalf("SwitchIndex = %+v, %v", info, err)
+	}
+	if _, err := c.Last(3); err != nil {
+		t.Fatalf("Last: %v", err)
+	}
+}
+
+func TestClientReturnsDaemonErrors(t *testing.T) {
+	c := startDaemon(t)
+
+	if err := c.Emit([]string{">nosuchkey"}); err == nil {
+		t.Error("Emit of an unknown key succeeded")
+	}
+	if err := c.Trigger("f9", "", 0); err == nil || err.Error() != "nothing bound to f9" {
+		t.Errorf("Trigger error = %v, want the daemon's message", err)
+	}
+	if err := c.DisableOverlay("gaming.toml"); err == nil {
+		t.Error("DisableOverlay succeeded")
+	}
+}
+
+func TestClientNotRunning(t *testing.T) {
+	c := New(filepath.Join(t.TempDir(), "missing.sock"))
+	if err := c.Reload(); !errors.Is(err, ErrNotRunning) {
+		t.Fatalf("Reload error = %v, want ErrNotRunning", err)
+	}
+}
+
+func TestClientSubscribe(t *testing.T) {
+	c := startDaemon(t)
+
+	errStop := errors.New("stop")
+	var got []events.Event
+	err := c.Subscribe(func(e events.Event) error {
+		got = append(got, e)
+		if e.Type == events.State {
+			events.Publish(events.Event{Type: events.OverlayEnabled, Overlay: "gaming.toml"})
+			return nil
+		}
+		return errStop
+	})
+	if !errors.Is(err, errStop) {
+		t.Fatalf("Subscribe error = %v, want the handler's error", err)
+	}
+	if len(got) != 2 || got[0].Type != events.State || got[1].Overlay != "gaming.toml" {
+		t.Fatalf("events = %+v, want the state then the overlay change", got)
+	}
+}
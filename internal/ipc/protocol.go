@@ -0,0 +1,92 @@
+package ipc
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// ProtocolVersion is the version of the JSON protocol. A request with a
+// different version is refused; one without a version is taken as this one.
+const ProtocolVersion = 1
+
+// Request types of the JSON protocol.
+const (
+	RequestEmit           = "emit"            // Tokens: remap tokens to inject
+	RequestTrigger        = "trigger"         // Combo, Behavior, DurationMs: fire a configured shortcut
+	RequestReload         = "reload"          // reload the config (the daemon restarts itself)
+	RequestStatus         = "status"          // reply Data: Status
+	RequestListShortcuts  = "list-shortcuts"  // reply Data: []ShortcutInfo
+	RequestListDevices    = "list-devices"    // reply Data: []DeviceInfo
+	RequestEnableOverlay  = "enable-overlay"  // Overlay: file name in the config dir
+	RequestDisableOverlay = "disable-overlay" // Overlay: file name in the config dir
+	RequestReleaseAll     = "release-all"     // release every key held with >>
+	RequestSwitch         = "switch"          // Action get|set|reset, Combo, Index; reply Data: SwitchInfo
+	RequestLast           = "last"            // Count (default 10); reply Data: []ExecutionInfo
+	RequestSubscribe      = "subscribe"       // reply, then a stream of events.Event lines
+	RequestStats          = "stats"           // Action "" or reset; reply Data: stats.Stats
+	RequestInspect        = "inspect"         // reply, then a stream of events.Input lines
+)
+
+// Request is one JSON request, sent as a single line.
+type Request struct {
+	Version    int      `json:"v"`
+	Type       string   `json:"type"`
+	Tokens     []string `json:"tokens,omitempty"`
+	Combo      string   `json:"combo,omitempty"`
+	Behavior   string   `json:"behavior,omitempty"`    // trigger: which of the combo's shortcuts ("hold", "doubletap", ...)
+	DurationMs int64    `json:"duration_ms,omitempty"` // trigger: how long the key stays held
+	Overlay    string   `json:"overlay,omitempty"`
+	Action     string   `json:"action,omitempty"`
+	Index      int      `json:"index,omitempty"`
+	Count      int      `json:"count,omitempty"`
+}
+
+// Response is the single-line reply to a Request.
+type Response struct {
+	Version int             `json:"v"`
+	OK      bool            `json:"ok"`
+	Error   string          `json:"error,omitempty"`
+	Data    json.RawMessage `json:"data,omitempty"`
+}
+
+// Status describes the running daemon.
+type Status struct {
+	PID       int       `json:"pid"`
+	Started   time.Time `json:"started"`
+	Config    string    `json:"config,omitempty"` // custom config (-c), empty for config.toml with overlays
+	Overlays  []string  `json:"overlays"`
+	Devices   int       `json:"devices"`
+	Shortcuts int       `json:"shortcuts"`
+}
+
+// ShortcutInfo is one configured shortcut.
+type ShortcutInfo struct {
+	Combo    string   `json:"combo"`
+	Behavior string   `json:"behavior"`
+	Commands []string `json:"commands"`
+}
+
+// DeviceInfo is one input device the daemon listens to.
+type DeviceInfo struct {
+	Name string `json:"name"`
+	Path string `json:"path"`
+	Kind string `json:"kind"` // "keyboard", "declared" or "mouse"
+}
+
+// SwitchInfo is the position of a .switch cycle.
+type SwitchInfo struct {
+	Next  int `json:"next"`
+	Count int `json:"count"`
+}
+
+// ExecutionInfo is a finished command from the daemon's history.
+type ExecutionInfo struct {
+	Combo      string    `json:"combo"`
+	Command    string    `json:"command"`
+	Started    time.Time `json:"started"`
+	DurationMs int64     `json:"duration_ms"`
+	ExitCode   int       `json:"exit_code"`
+	Status     string    `json:"status"`
+	TimedOut   bool      `json:"timed_out,omitempty"`
+	Output     string    `json:"output,omitempty"`
+}
@@ -1,29 +1,77 @@
-// Package ipc exposes the running daemon's remap engine over a local Unix
-// socket so external processes (the CLI, scripts, aliases) can inject key
-// and mouse events without a one-shot uinput device.
+// Package ipc exposes the running daemon over a local Unix socket so
+// external processes (the CLI, scripts, aliases) can inject key and mouse
+// events without a one-shot uinput device, fire shortcuts and manage overlays.
 package ipc
 
 import (
 	"bufio"
 	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"net"
 	"os"
+	"strconv"
 	"strings"
 	"sync"
+	"time"
 
+	"github.com/deprecatedluar/akeyshually/internal/config"
+	"github.com/deprecatedluar/akeyshually/internal/events"
 	"github.com/deprecatedluar/akeyshually/internal/executor"
 	"github.com/deprecatedluar/akeyshually/internal/matcher"
+	"github.com/deprecatedluar/akeyshually/internal/stats"
 )
 
 const socketPerm = 0600
 
+// emitMu serializes whole token sequences from concurrent requests against
+// each other. Must not be loopState.Mu - runRemap's ">>" branch already
+// holds that lock while emitting, so reusing it here would self-deadlock.
+var emitMu sync.Mutex
+
+// Switches is the daemon's .switch cycle state (implemented by *matcher.Matcher).
+type Switches interface {
+	SwitchIndex(combo string) (next, count int, err error)
+	SetSwitchIndex(combo string, idx int) error
+	ResetSwitch(combo string) error
+}
+
+// Daemon is what requests reach beyond the remap engine (implemented by the
+// daemon's main package).
+type Daemon interface {
+	Switches
+	// Trigger fires a shortcut as if its key were held for hold, and
+	// returns once it has been released.
+	Trigger(combo, behavior string, hold time.Duration) error
+	Status() Status
+	Shortcuts() []ShortcutInfo
+	Devices() []DeviceInfo
+	// Reload, EnableOverlay and DisableOverlay check that the resulting config
+	// loads and return the function that restarts the daemon with it, which
+	// is called once the reply has been sent.
+	Reload() (restart func(), err error)
+	EnableOverlay(name string) (restart func(), err error)
+	DisableOverlay(name string) (restart func(), err error)
+}
+
 // Serve accepts connections on sockPath until ctx is cancelled. Each
-// connection carries one request: a single newline-terminated line of
-// whitespace-separated remap tokens. Every token is run through
-// executor.Run against outputs/loopState; the reply is "ok" or
-// "err: <message>", then the connection closes.
-func Serve(ctx context.Context, sockPath string, outputs executor.Outputs, loopState *executor.LoopState) error {
+// connection carries one request line. A line starting with "{" is a JSON
+// Request, answered with a JSON Response line; a subscribe or inspect request
+// keeps the connection and streams events after it.
+//
+// Any other line is the legacy format: whitespace-separated remap tokens.
+// Every token is run through executor.Run against outputs/loopState; the
+// reply is "ok" or "err: <message>", then the connection closes.
+//
+// A line starting with "switch" is a switch request instead:
+// "switch get <combo>" replies "ok <next index>", "switch set <combo> <index>"
+// and "switch reset <combo>" reply "ok".
+//
+// "last [n]" replies "ok" followed by the n most recent command executions
+// (default 10), one header line each with their output indented below.
+func Serve(ctx context.Context, sockPath string, outputs executor.Outputs, loopState *executor.LoopState, daemon Daemon) error {
 	os.Remove(sockPath) // stale socket left by an unclean previous exit
 
 	listener, err := net.Listen("unix", sockPath)
@@ -40,12 +88,6 @@ func Serve(ctx context.Context, sockPath string, outputs executor.Outputs, loopS
 		listener.Close()
 	}()
 
-	// Serializes whole token sequences from concurrent CLI connections
-	// against each other. Must not be loopState.Mu - runRemap's ">>"
-	// branch already holds that lock while emitting, so reusing it here
-	// would self-deadlock.
-	var emitMu sync.Mutex
-
 	for {
 		conn, err := listener.Accept()
 		if err != nil {
@@ -55,11 +97,11 @@ func Serve(ctx context.Context, sockPath string, outputs executor.Outputs, loopS
 			}
 			continue
 		}
-		go handleConn(conn, outputs, loopState, &emitMu)
+		go handleConn(ctx, conn, outputs, loopState, daemon)
 	}
 }
 
-func handleConn(conn net.Conn, outputs executor.Outputs, loopState *executor.LoopState, emitMu *sync.Mutex) {
+func handleConn(ctx context.Context, conn net.Conn, outputs executor.Outputs, loopState *executor.LoopState, daemon Daemon) {
 	defer conn.Close()
 
 	line, err := bufio.NewReader(conn).ReadString('\n')
@@ -67,17 +109,63 @@ func handleConn(conn net.Conn, outputs executor.Outputs, loopState *executor.Loo
 		return
 	}
 
+	execCtx := executor.ExecContext{
+		Outputs:   outputs,
+		LoopState: loopState,
+		Modifiers: matcher.ModifierState{},
+		Virtual:   nil,
+	}
+
+	if strings.HasPrefix(strings.TrimSpace(line), "{") {
+		var req Request
+		if json.Unmarshal([]byte(line), &req) == nil && supportedVersion(req) &&
+			(req.Type == RequestSubscribe || req.Type == RequestInspect) {
+			subscribe(ctx, conn, loopState, daemon, req.Type == RequestInspect)
+			return
+		}
+		handleJSON(conn, line, execCtx, daemon)
+		return
+	}
+
 	tokens := strings.Fields(line)
 	if len(tokens) == 0 {
 		fmt.Fprintln(conn, "err: empty request")
 		return
 	}
 
-	execCtx := executor.ExecContext{
+	if tokens[0] == "switch" {
+		fmt.Fprintln(conn, handleSwitch(tokens[1:], daemon))
+		return
+	}
+	if tokens[0] == "last" {
+		handleLast(conn, tokens[1:])
+		return
+	}
+
+	if err := emit(tokens, execCtx); err != nil {
+		fmt.Fprintf(conn, "err: %v\n", err)
+		return
+	}
+	fmt.Fprintln(conn, "ok")
+}
+
+// Emit runs a token sequence against outputs/loopState as one unit, never
+// interleaved with another request's tokens.
+func Emit(tokens []string, outputs executor.Outputs, loopState *executor.LoopState) error {
+	return emit(tokens, executor.ExecContext{
 		Outputs:   outputs,
 		LoopState: loopState,
 		Modifiers: matcher.ModifierState{},
-		Virtual:   nil,
+	})
+}
+
+// emit runs a token sequence as one unit against concurrent requests. Every
+// token must be a remap token: anything else would run as a shell command.
+func emit(tokens []string, execCtx executor.ExecContext) error {
+	for _, tok := range tokens {
+		if err := config.ValidateRemapToken(tok); err != nil {
+			return err
+		}
 	}
 
 	emitMu.Lock()
@@ -85,9 +173,280 @@ func handleConn(conn net.Conn, outputs executor.Outputs, loopState *executor.Loo
 
 	for _, tok := range tokens {
 		if err := executor.Run(tok, execCtx); err != nil {
-			fmt.Fprintf(conn, "err: %v\n", err)
+			return err
+		}
+	}
+	return nil
+}
+
+// handleJSON answers one JSON request. A restart the request asked for
+// (reload, overlay changes) happens after the reply is written.
+func handleJSON(w io.Writer, line string, execCtx executor.ExecContext, daemon Daemon) {
+	resp := Response{Version: ProtocolVersion, OK: true}
+
+	var req Request
+	var data any
+	var restart func()
+	err := json.Unmarshal([]byte(line), &req)
+	switch {
+	case err != nil:
+		err = fmt.Errorf("invalid request: %w", err)
+	case !supportedVersion(req):
+		err = fmt.Errorf("unsupported protocol version %d (daemon speaks %d)", req.Version, ProtocolVersion)
+	default:
+		data, restart, err = dispatch(req, execCtx, daemon)
+	}
+
+	if err == nil && data != nil {
+		resp.Data, err = json.Marshal(data)
+	}
+	if err != nil {
+		resp.OK = false
+		resp.Error = err.Error()
+		restart = nil
+	}
+
+	reply, _ := json.Marshal(resp)
+	fmt.Fprintf(w, "%s\n", reply)
+	if restart != nil {
+		restart()
+	}
+}
+
+func supportedVersion(req Request) bool {
+	return req.Version == 0 || req.Version == ProtocolVersion
+}
+
+// subscribe answers a subscribe request: an ok Response, a State event, then
+// every published event, one JSON line each, until the client hangs up or
+// the daemon shuts down. An inspect request (input) gets the Input events
+// instead, with no State event.
+func subscribe(ctx context.Context, conn net.Conn, loopState *executor.LoopState, daemon Daemon, input bool) {
+	subscribeFn := events.Subscribe
+	if input {
+		subscribeFn = events.SubscribeInput
+	}
+	stream, unsubscribe := subscribeFn()
+	defer unsubscribe()
+
+	enc := json.NewEncoder(conn)
+	enc.SetEscapeHTML(false) // keep ">>" readable in key names and commands
+	if enc.Encode(Response{Version: ProtocolVersion, OK: true}) != nil {
+		return
+	}
+	if !input {
+		state := events.Event{Time: time.Now(), Type: events.State}
+		if daemon != nil {
+			state.Overlays = daemon.Status().Overlays
+		}
+		if loopState != nil {
+			state.Held = loopState.PersistentKeys()
+		}
+		if enc.Encode(state) != nil {
 			return
 		}
 	}
-	fmt.Fprintln(conn, "ok")
+
+	// The client sends nothing after its request, so a read only returns
+	// once it has gone away.
+	gone := make(chan struct{})
+	go func() {
+		io.Copy(io.Discard, conn)
+		close(gone)
+	}()
+
+	for {
+		select {
+		case <-ctx.Done():
+			// Pass on what was published before the shutdown, such as the
+			// overlay change a reload is for
+			for {
+				select {
+				case e := <-stream:
+					if enc.Encode(e) != nil {
+						return
+					}
+				default:
+					return
+				}
+			}
+		case <-gone:
+			return
+		case e := <-stream:
+			if enc.Encode(e) != nil {
+				return
+			}
+		}
+	}
+}
+
+// dispatch runs a request and returns its reply data, if any.
+func dispatch(req Request, execCtx executor.ExecContext, daemon Daemon) (data any, restart func(), err error) {
+	switch req.Type {
+	case RequestEmit:
+		if len(req.Tokens) == 0 {
+			return nil, nil, errors.New("emit needs at least one token")
+		}
+		return nil, nil, emit(req.Tokens, execCtx)
+	case RequestReleaseAll:
+		return nil, nil, emit([]string{"<<"}, execCtx)
+	case RequestLast:
+		return lastExecutions(req.Count), nil, nil
+	case RequestStats:
+		data, err := statsRequest(req)
+		return data, nil, err
+	}
+
+	if daemon == nil {
+		return nil, nil, errors.New("daemon state unavailable")
+	}
+	switch req.Type {
+	case RequestTrigger:
+		if req.DurationMs < 0 {
+			return nil, nil, errors.New("duration cannot be negative")
+		}
+		return nil, nil, daemon.Trigger(req.Combo, req.Behavior, time.Duration(req.DurationMs)*time.Millisecond)
+	case RequestStatus:
+		return daemon.Status(), nil, nil
+	case RequestListShortcuts:
+		return daemon.Shortcuts(), nil, nil
+	case RequestListDevices:
+		return daemon.Devices(), nil, nil
+	case RequestSwitch:
+		data, err := switchRequest(req, daemon)
+		return data, nil, err
+	case RequestReload:
+		restart, err := daemon.Reload()
+		return nil, restart, err
+	case RequestEnableOverlay:
+		restart, err := daemon.EnableOverlay(req.Overlay)
+		return nil, restart, err
+	case RequestDisableOverlay:
+		restart, err := daemon.DisableOverlay(req.Overlay)
+		return nil, restart, err
+	default:
+		return nil, nil, fmt.Errorf("unknown request type %q", req.Type)
+	}
+}
+
+func switchRequest(req Request, switches Switches) (any, error) {
+	switch req.Action {
+	case "get":
+		next, count, err := switches.SwitchIndex(req.Combo)
+		if err != nil {
+			return nil, err
+		}
+		return SwitchInfo{Next: next, Count: count}, nil
+	case "set":
+		return nil, switches.SetSwitchIndex(req.Combo, req.Index)
+	case "reset":
+		return nil, switches.ResetSwitch(req.Combo)
+	default:
+		return nil, fmt.Errorf("unknown switch action %q (want get, set or reset)", req.Action)
+	}
+}
+
+func statsRequest(req Request) (any, error) {
+	switch req.Action {
+	case "":
+		return stats.Snapshot(), nil
+	case "reset":
+		stats.Reset()
+		return nil, nil
+	default:
+		return nil, fmt.Errorf("unknown stats action %q (want reset)", req.Action)
+	}
+}
+
+// lastExecutions returns the n most recent command executions (default 10).
+func lastExecutions(n int) []ExecutionInfo {
+	if n <= 0 {
+		n = defaultLastCount
+	}
+	executions := executor.RecentExecutions(n)
+	infos := make([]ExecutionInfo, len(executions))
+	for i, e := range executions {
+		infos[i] = ExecutionInfo{
+			Combo:      e.Combo,
+			Command:    e.Command,
+			Started:    e.Started,
+			DurationMs: e.Duration.Milliseconds(),
+			ExitCode:   e.ExitCode,
+			Status:     e.Status,
+			TimedOut:   e.TimedOut,
+			Output:     e.Output(),
+		}
+	}
+	return infos
+}
+
+// handleSwitch runs a "switch get|set|reset <combo> [index]" request and
+// returns the reply line.
+func handleSwitch(args []string, switches Switches) string {
+	if switches == nil {
+		return "err: switch state unavailable"
+	}
+	if len(args) < 2 {
+		return "err: usage: switch get|set|reset <combo> [index]"
+	}
+
+	action, combo := args[0], args[1]
+	switch {
+	case action == "get" && len(args) == 2:
+		next, _, err := switches.SwitchIndex(combo)
+		if err != nil {
+			return "err: " + err.Error()
+		}
+		return fmt.Sprintf("ok %d", next)
+	case action == "set" && len(args) == 3:
+		idx, err := strconv.Atoi(args[2])
+		if err != nil {
+			return fmt.Sprintf("err: invalid index %q", args[2])
+		}
+		if err := switches.SetSwitchIndex(combo, idx); err != nil {
+			return "err: " + err.Error()
+		}
+		return "ok"
+	case action == "reset" && len(args) == 2:
+		if err := switches.ResetSwitch(combo); err != nil {
+			return "err: " + err.Error()
+		}
+		return "ok"
+	default:
+		return "err: usage: switch get|set|reset <combo> [index]"
+	}
+}
+
+const defaultLastCount = 10
+
+// handleLast writes the most recent command executions for "last [n]".
+func handleLast(w io.Writer, args []string) {
+	n := defaultLastCount
+	if len(args) > 1 {
+		fmt.Fprintln(w, "err: usage: last [n]")
+		return
+	}
+	if len(args) == 1 {
+		parsed, err := strconv.Atoi(args[0])
+		if err != nil || parsed < 1 {
+			fmt.Fprintf(w, "err: invalid count %q\n", args[0])
+			return
+		}
+		n = parsed
+	}
+
+	fmt.Fprintln(w, "ok")
+	for _, e := range executor.RecentExecutions(n) {
+		combo := e.Combo
+		if combo == "" {
+			combo = "-"
+		}
+		fmt.Fprintf(w, "%s  %s  %s  %v  %s\n", e.Started.Format("15:04:05"), combo, e.Status,
+			e.Duration.Round(time.Millisecond), e.Command)
+		if output := strings.TrimRight(e.Output(), "\n"); output != "" {
+			for _, line := range strings.Split(output, "\n") {
+				fmt.Fprintf(w, "    %s\n", line)
+			}
+		}
+	}
 }
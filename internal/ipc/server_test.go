@@ -3,6 +3,8 @@ package ipc
 import (
 	"bufio"
 	"context"
+	"encoding/json"
+	"fmt"
 	"net"
 	"os"
 	"path/filepath"
@@ -10,7 +12,11 @@ import (
 	"testing"
 	"time"
 
+	"github.com/deprecatedluar/akeyshually/internal/config"
+	"github.com/deprecatedluar/akeyshually/internal/events"
 	"github.com/deprecatedluar/akeyshually/internal/executor"
+	"github.com/deprecatedluar/akeyshually/internal/matcher"
+	"github.com/deprecatedluar/akeyshually/internal/stats"
 	evdev "github.com/holoplot/go-evdev"
 )
 
@@ -18,7 +24,52 @@ type fakeWriter struct{}
 
 func (fakeWriter) WriteOne(*evdev.InputEvent) error { return nil }
 
+// fakeDaemon answers daemon requests with fixed data; .switch state comes
+// from a real matcher.
+type fakeDaemon struct {
+	*matcher.Matcher
+	triggered chan string
+	restarted chan struct{}
+}
+
+func (d *fakeDaemon) Trigger(combo, behavior string, hold time.Duration) error {
+	if combo != "f1" {
+		return fmt.Errorf("no shortcut bound to %q", combo)
+	}
+	d.triggered <- combo
+	return nil
+}
+
+func (d *fakeDaemon) Status() Status {
+	return Status{PID: 42, Overlays: []string{"gaming.toml"}, Devices: 1, Shortcuts: 1}
+}
+
+func (d *fakeDaemon) Shortcuts() []ShortcutInfo {
+	return []ShortcutInfo{{Combo: "f1", Behavior: "switch", Commands: []string{"a", "b", "c"}}}
+}
+
+func (d *fakeDaemon) Devices() []DeviceInfo {
+	return []DeviceInfo{{Name: "kbd", Path: "/dev/input/event0", Kind: "keyboard"}}
+}
+
+func (d *fakeDaemon) Reload() (func(), error) {
+	return func() { close(d.restarted) }, nil
+}
+
+func (d *fakeDaemon) EnableOverlay(name string) (func(), error) {
+	return nil, fmt.Errorf("overlay not found: %s", name)
+}
+
+func (d *fakeDaemon) DisableOverlay(name string) (func(), error) {
+	return nil, fmt.Errorf("overlay not enabled: %s", name)
+}
+
 func startTestServer(t *testing.T) (sockPath string, cancel context.CancelFunc, done chan error) {
+	sockPath, cancel, done, _ = startTestDaemon(t)
+	return sockPath, cancel, done
+}
+
+func startTestDaemon(t *testing.T) (sockPath string, cancel context.CancelFunc, done chan error, daemon *fakeDaemon) {
 	t.Helper()
 	sockPath = filepath.Join(t.TempDir(), "test.sock")
 
@@ -27,10 +78,17 @@ func startTestServer(t *testing.T) (sockPath string, cancel context.CancelFunc,
 		Pointer:  executor.NewEventSink(fakeWriter{}),
 	}
 	loopState := executor.NewLoopState()
+	daemon = &fakeDaemon{
+		Matcher: matcher.New(map[string][]*config.ParsedShortcut{
+			"f1": {{KeyCombo: "f1", Behavior: config.BehaviorSwitch, Commands: []string{"a", "b", "c"}}},
+		}),
+		triggered: make(chan string, 1),
+		restarted: make(chan struct{}),
+	}
 
 	ctx, cancelFn := context.WithCancel(context.Background())
 	done = make(chan error, 1)
-	go func() { done <- Serve(ctx, sockPath, outputs, loopState) }()
+	go func() { done <- Serve(ctx, sockPath, outputs, loopState, daemon) }()
 
 	// Wait for the socket file to appear.
 	for range 100 {
@@ -39,7 +97,7 @@ func startTestServer(t *testing.T) (sockPath string, cancel context.CancelFunc,
 		}
 		time.Sleep(10 * time.Millisecond)
 	}
-	return sockPath, cancelFn, done
+	return sockPath, cancelFn, done, daemon
 }
 
 func sendRequest(t *testing.T, sockPath, request string) string {
@@ -85,6 +143,188 @@ func TestServeErrReply(t *testing.T) {
 	}
 }
 
+func TestServeRejectsShellCommands(t *testing.T) {
+	sockPath, cancel, _ := startTestServer(t)
+	defer cancel()
+
+	marker := filepath.Join(t.TempDir(), "ran")
+	shell := "touch " + marker
+	if reply := sendRequest(t, sockPath, ">a "+shell); !strings.HasPrefix(reply, "err:") {
+		t.Errorf("plain request: got reply %q, want err: prefix", reply)
+	}
+	request, _ := json.Marshal(Request{Version: ProtocolVersion, Type: RequestEmit, Tokens: []string{shell}})
+	if resp := sendJSON(t, sockPath, string(request)); resp.OK || !strings.Contains(resp.Error, "not a remap token") {
+		t.Errorf("JSON emit of a shell command: got %+v, want a not a remap token error", resp)
+	}
+
+	time.Sleep(100 * time.Millisecond)
+	if _, err := os.Stat(marker); err == nil {
+		t.Fatal("emit ran a shell command")
+	}
+}
+
+func TestServeSwitchRequests(t *testing.T) {
+	sockPath, cancel, _ := startTestServer(t)
+	defer cancel()
+
+	steps := []struct {
+		request string
+		want    string
+	}{
+		{"switch get f1", "ok 0"},
+		{"switch set f1.switch 2", "ok"},
+		{"switch get f1", "ok 2"},
+		{"switch reset f1", "ok"},
+		{"switch get f1", "ok 0"},
+	}
+	for _, step := range steps {
+		if reply := sendRequest(t, sockPath, step.request); reply != step.want {
+			t.Fatalf("%q: got reply %q, want %q", step.request, reply, step.want)
+		}
+	}
+
+	for _, request := range []string{"switch set f1 3", "switch get f2", "switch get", "switch set f1 x"} {
+		if reply := sendRequest(t, sockPath, request); !strings.HasPrefix(reply, "err:") {
+			t.Errorf("%q: got reply %q, want err: prefix", request, reply)
+		}
+	}
+}
+
+func TestServeLastRequest(t *testing.T) {
+	sockPath, cancel, _ := startTestServer(t)
+	defer cancel()
+
+	if reply := sendRequest(t, sockPath, "last 5"); reply != "ok" {
+		t.Errorf("got reply %q, want ok", reply)
+	}
+	if reply := sendRequest(t, sockPath, "last none"); !strings.HasPrefix(reply, "err:") {
+		t.Errorf("got reply %q, want err: prefix", reply)
+	}
+}
+
+// sendJSON sends one JSON request line and decodes the reply.
+func sendJSON(t *testing.T, sockPath, request string) Response {
+	t.Helper()
+	var resp Response
+	reply := sendRequest(t, sockPath, request)
+	if err := json.Unmarshal([]byte(reply), &resp); err != nil {
+		t.Fatalf("%s: reply %q is not JSON: %v", request, reply, err)
+	}
+	if resp.Version != ProtocolVersion {
+		t.Errorf("%s: reply version %d, want %d", request, resp.Version, ProtocolVersion)
+	}
+	return resp
+}
+
+func TestServeJSONEmit(t *testing.T) {
+	sockPath, cancel, _ := startTestServer(t)
+	defer cancel()
+
+	if resp := sendJSON(t, sockPath, `{"v":1,"type":"emit","tokens":[">>shift",">a","<shift"]}`); !resp.OK {
+		t.Fatalf("emit: %s", resp.Error)
+	}
+	if resp := sendJSON(t, sockPath, `{"v":1,"type":"release-all"}`); !resp.OK {
+		t.Fatalf("release-all: %s", resp.Error)
+	}
+	if resp := sendJSON(t, sockPath, `{"v":1,"type":"emit","tokens":[">nosuchkey"]}`); resp.OK || resp.Error == "" {
+		t.Fatalf("emit of an unknown key: got %+v, want an error", resp)
+	}
+	if resp := sendJSON(t, sockPath, `{"v":1,"type":"emit"}`); resp.OK {
+		t.Fatal("emit without tokens succeeded")
+	}
+}
+
+func TestServeJSONDaemonRequests(t *testing.T) {
+	sockPath, cancel, _, daemon := startTestDaemon(t)
+	defer cancel()
+
+	resp := sendJSON(t, sockPath, `{"v":1,"type":"status"}`)
+	var status Status
+	if err := json.Unmarshal(resp.Data, &status); err != nil || !resp.OK {
+		t.Fatalf("status: %+v (%v)", resp, err)
+	}
+	if status.PID != 42 || len(status.Overlays) != 1 {
+		t.Errorf("status = %+v", status)
+	}
+
+	resp = sendJSON(t, sockPath, `{"v":1,"type":"list-shortcuts"}`)
+	var shortcuts []ShortcutInfo
+	if err := json.Unmarshal(resp.Data, &shortcuts); err != nil || len(shortcuts) != 1 || shortcuts[0].Behavior != "switch" {
+		t.Errorf("list-shortcuts = %+v (%v)", shortcuts, err)
+	}
+
+	resp = sendJSON(t, sockPath, `{"v":1,"type":"list-devices"}`)
+	var devices []DeviceInfo
+	if err := json.Unmarshal(resp.Data, &devices); err != nil || len(devices) != 1 || devices[0].Kind != "keyboard" {
+		t.Errorf("list-devices = %+v (%v)", devices, err)
+	}
+
+	if resp := sendJSON(t, sockPath, `{"v":1,"type":"trigger","combo":"f1"}`); !resp.OK {
+		t.Fatalf("trigger: %s", resp.Error)
+	}
+	if combo := <-daemon.triggered; combo != "f1" {
+		t.Errorf("triggered %q, want f1", combo)
+	}
+	if resp := sendJSON(t, sockPath, `{"v":1,"type":"trigger","combo":"f9"}`); resp.OK {
+		t.Error("trigger of an unbound combo succeeded")
+	}
+
+	if resp := sendJSON(t, sockPath, `{"v":1,"type":"enable-overlay","overlay":"nope.toml"}`); resp.OK || !strings.Contains(resp.Error, "nope.toml") {
+		t.Errorf("enable-overlay = %+v, want the daemon's error", resp)
+	}
+}
+
+func TestServeJSONSwitch(t *testing.T) {
+	sockPath, cancel, _ := startTestServer(t)
+	defer cancel()
+
+	if resp := sendJSON(t, sockPath, `{"v":1,"type":"switch","action":"set","combo":"f1","index":2}`); !resp.OK {
+		t.Fatalf("switch set: %s", resp.Error)
+	}
+	resp := sendJSON(t, sockPath, `{"v":1,"type":"switch","action":"get","combo":"f1"}`)
+	var info SwitchInfo
+	if err := json.Unmarshal(resp.Data, &info); err != nil || info != (SwitchInfo{Next: 2, Count: 3}) {
+		t.Fatalf("switch get = %+v (%v), want next 2 of 3", info, err)
+	}
+	if resp := sendJSON(t, sockPath, `{"v":1,"type":"switch","action":"spin","combo":"f1"}`); resp.OK {
+		t.Error("unknown switch action succeeded")
+	}
+}
+
+func TestServeJSONReloadRepliesBeforeRestart(t *testing.T) {
+	sockPath, cancel, _, daemon := startTestDaemon(t)
+	defer cancel()
+
+	if resp := sendJSON(t, sockPath, `{"v":1,"type":"reload"}`); !resp.OK {
+		t.Fatalf("reload: %s", resp.Error)
+	}
+	select {
+	case <-daemon.restarted:
+	case <-time.After(time.Second):
+		t.Fatal("reload did not restart the daemon")
+	}
+}
+
+func TestServeJSONErrors(t *testing.T) {
+	sockPath, cancel, _ := startTestServer(t)
+	defer cancel()
+
+	for _, request := range []string{
+		`{"v":1,"type":"teleport"}`,
+		`{"v":2,"type":"status"}`,
+		`{"v":1,"type":`,
+	} {
+		if resp := sendJSON(t, sockPath, request); resp.OK || resp.Error == "" {
+			t.Errorf("%s: got %+v, want an error", request, resp)
+		}
+	}
+
+	// A request without a version is taken as the current one.
+	if resp := sendJSON(t, sockPath, `{"type":"last","count":1}`); !resp.OK {
+		t.Errorf("unversioned request: %s", resp.Error)
+	}
+}
+
 func TestServeSocketPermissions(t *testing.T) {
 	sockPath, cancel, _ := startTestServer(t)
 	defer cancel()
@@ -110,3 +350,110 @@ func TestServeSocketRemovedOnShutdown(t *testing.T) {
 		t.Fatalf("socket file still exists after shutdown: %v", err)
 	}
 }
+
+func TestServeSubscribe(t *testing.T) {
+	sockPath, cancel, _ := startTestServer(t)
+	defer cancel()
+
+	if resp := sendJSON(t, sockPath, `{"v":1,"type":"emit","tokens":[">>shift"]}`); !resp.OK {
+		t.Fatalf("emit: %s", resp.Error)
+	}
+
+	conn, err := net.Dial("unix", sockPath)
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	defer conn.Close()
+	conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+	if _, err := conn.Write([]byte(`{"v":1,"type":"subscribe"}` + "\n")); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	dec := json.NewDecoder(conn)
+
+	var resp Response
+	if err := dec.Decode(&resp); err != nil || !resp.OK {
+		t.Fatalf("subscribe reply = %+v, %v", resp, err)
+	}
+	var state events.Event
+	if err := dec.Decode(&state); err != nil {
+		t.Fatalf("read state: %v", err)
+	}
+	if state.Type != events.State || len(state.Overlays) != 1 || state.Overlays[0] != "gaming.toml" ||
+		len(state.Held) != 1 || state.Held[0] != "shift" {
+		t.Fatalf("first event = %+v, want the overlay and held-key state", state)
+	}
+
+	if resp := sendJSON(t, sockPath, `{"v":1,"type":"release-all"}`); !resp.OK {
+		t.Fatalf("release-all: %s", resp.Error)
+	}
+	events.Fired("f1", "normal", "notify-send hi")
+
+	var released, fired events.Event
+	if err := dec.Decode(&released); err != nil || released.Type != events.KeyReleased || released.Key != "shift" {
+		t.Fatalf("second event = %+v, %v, want shift released", released, err)
+	}
+	if err := dec.Decode(&fired); err != nil || fired.Type != events.ShortcutFired || fired.Combo != "f1" || fired.Command != "notify-send hi" {
+		t.Fatalf("third event = %+v, %v, want the fired shortcut", fired, err)
+	}
+}
+
+func TestServeStats(t *testing.T) {
+	sockPath, cancel, _ := startTestServer(t)
+	defer cancel()
+
+	stats.Reset()
+	stats.Fired("f1", "switch", 0)
+
+	resp := sendJSON(t, sockPath, `{"v":1,"type":"stats"}`)
+	var st stats.Stats
+	if !resp.OK || json.Unmarshal(resp.Data, &st) != nil || len(st.Shortcuts) != 1 || st.Shortcuts[0].Fires != 1 {
+		t.Fatalf("stats reply = %+v", resp)
+	}
+
+	if resp := sendJSON(t, sockPath, `{"v":1,"type":"stats","action":"reset"}`); !resp.OK {
+		t.Fatalf("reset: %s", resp.Error)
+	}
+	if st := stats.Snapshot(); len(st.Shortcuts) != 0 {
+		t.Fatalf("shortcuts after reset = %+v", st.Shortcuts)
+	}
+	if resp := sendJSON(t, sockPath, `{"v":1,"type":"stats","action":"bogus"}`); resp.OK {
+		t.Fatal("unknown stats action succeeded")
+	}
+}
+
+func TestServeInspect(t *testing.T) {
+	sockPath, cancel, _ := startTestServer(t)
+	defer cancel()
+
+	conn, err := net.Dial("unix", sockPath)
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	defer conn.Close()
+	conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+	if _, err := conn.Write([]byte(`{"v":1,"type":"inspect"}` + "\n")); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	dec := json.NewDecoder(conn)
+
+	var resp Response
+	if err := dec.Decode(&resp); err != nil || !resp.OK {
+		t.Fatalf("inspect reply = %+v, %v", resp, err)
+	}
+	for !events.Inspecting() {
+		time.Sleep(time.Millisecond)
+	}
+
+	// Daemon events stay on subscribe connections; no State event either
+	events.Fired("f1", "normal", "notify-send hi")
+	events.Publish(events.Event{Type: events.Input, Device: "kbd", Key: "f1", Combo: "f1",
+		Input: &events.InputInfo{Kind: "key", Code: 59, Value: 1, Named: true, Matches: []string{"f1 (switch)"}}})
+
+	var e events.Event
+	if err := dec.Decode(&e); err != nil {
+		t.Fatalf("read event: %v", err)
+	}
+	if e.Type != events.Input || e.Device != "kbd" || e.Input == nil || len(e.Input.Matches) != 1 {
+		t.Fatalf("first event = %+v, want the input event", e)
+	}
+}
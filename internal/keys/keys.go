@@ -124,6 +124,16 @@ var AxisCodeMap = map[string]uint16{
 	"rx": evdev.ABS_RX, "ry": evdev.ABS_RY, "rz": evdev.ABS_RZ,
 }
 
+// GestureNames lists the bindable touchpad gesture names. Gestures have no
+// evdev code of their own; they are synthesized by the gesture recognizer from
+// multitouch slot data and fired by name.
+var GestureNames = map[string]bool{
+	"swipe3_left": true, "swipe3_right": true, "swipe3_up": true, "swipe3_down": true,
+	"swipe4_left": true, "swipe4_right": true, "swipe4_up": true, "swipe4_down": true,
+	"pinch_in": true, "pinch_out": true,
+	"hold3": true, "hold4": true,
+}
+
 // CodeToNameMap is a reverse lookup map for O(1) code -> name lookups (exported for testing)
 var CodeToNameMap map[uint16]string
 
@@ -213,7 +223,49 @@ func ResolveAbsCode(name string) (uint16, bool) {
 	return code, ok
 }
 
+// IsGestureName reports whether name is a bindable touchpad gesture.
+func IsGestureName(name string) bool {
+	return GestureNames[strings.ToLower(name)]
+}
+
 // GetKeyName returns the canonical name for a key code
 func GetKeyName(code uint16) string {
 	return CodeToNameMap[code]
 }
+
+// Capability is an event type a device must support, or one event code of it.
+type Capability struct {
+	Type    uint16
+	Code    uint16
+	AnyCode bool // only the type is required
+}
+
+// capabilityCodes maps event code name prefixes to their event type.
+var capabilityCodes = []struct {
+	prefix string
+	typ    uint16
+	codes  map[string]evdev.EvCode
+}{
+	{"KEY_", evdev.EV_KEY, evdev.KEYFromString},
+	{"BTN_", evdev.EV_KEY, evdev.KEYFromString},
+	{"REL_", evdev.EV_REL, evdev.RELFromString},
+	{"ABS_", evdev.EV_ABS, evdev.ABSFromString},
+	{"SW_", evdev.EV_SW, evdev.SWFromString},
+	{"LED_", evdev.EV_LED, evdev.LEDFromString},
+}
+
+// ResolveCapability looks up a kernel event type name ("EV_ABS") or event
+// code name ("KEY_VOLUMEUP", "BTN_LEFT", "ABS_MT_SLOT", "REL_WHEEL", "SW_LID").
+func ResolveCapability(name string) (Capability, bool) {
+	name = strings.ToUpper(name)
+	if typ, ok := evdev.EVFromString[name]; ok {
+		return Capability{Type: uint16(typ), AnyCode: true}, true
+	}
+	for _, c := range capabilityCodes {
+		if strings.HasPrefix(name, c.prefix) {
+			code, ok := c.codes[name]
+			return Capability{Type: c.typ, Code: uint16(code)}, ok
+		}
+	}
+	return Capability{}, false
+}
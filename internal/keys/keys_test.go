@@ -164,3 +164,37 @@ func TestStickAxisAliasesAreUnambiguous(t *testing.T) {
 		}
 	}
 }
+
+func TestGestureNamesDoNotShadowKeys(t *testing.T) {
+	for name := range GestureNames {
+		if _, ok := ResolveKeyCode(name); ok {
+			t.Errorf("gesture %q collides with a key name", name)
+		}
+		if !IsGestureName(strings.ToUpper(name)) {
+			t.Errorf("IsGestureName(%q) should be case-insensitive", strings.ToUpper(name))
+		}
+	}
+	if IsGestureName("swipe5_left") {
+		t.Error("swipe5_left should not be a gesture name")
+	}
+}
+
+func TestResolveCapability(t *testing.T) {
+	for name, want := range map[string]Capability{
+		"EV_ABS":       {Type: evdev.EV_ABS, AnyCode: true},
+		"ev_key":       {Type: evdev.EV_KEY, AnyCode: true},
+		"KEY_VOLUMEUP": {Type: evdev.EV_KEY, Code: evdev.KEY_VOLUMEUP},
+		"btn_left":     {Type: evdev.EV_KEY, Code: evdev.BTN_LEFT},
+		"ABS_MT_SLOT":  {Type: evdev.EV_ABS, Code: evdev.ABS_MT_SLOT},
+		"REL_WHEEL":    {Type: evdev.EV_REL, Code: evdev.REL_WHEEL},
+	} {
+		if got, ok := ResolveCapability(name); !ok || got != want {
+			t.Errorf("ResolveCapability(%q) = %+v, %v, want %+v", name, got, ok, want)
+		}
+	}
+	for _, name := range []string{"KEY_NOPE", "EV_BOGUS", "volumeup", ""} {
+		if _, ok := ResolveCapability(name); ok {
+			t.Errorf("ResolveCapability(%q) resolved", name)
+		}
+	}
+}
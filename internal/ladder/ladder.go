@@ -9,9 +9,11 @@ import (
 
 	"github.com/deprecatedluar/akeyshually/internal/common"
 	"github.com/deprecatedluar/akeyshually/internal/config"
+	"github.com/deprecatedluar/akeyshually/internal/events"
 	"github.com/deprecatedluar/akeyshually/internal/executor"
 	"github.com/deprecatedluar/akeyshually/internal/keys"
 	"github.com/deprecatedluar/akeyshually/internal/matcher"
+	"github.com/deprecatedluar/akeyshually/internal/stats"
 	"github.com/deprecatedluar/akeyshually/internal/timers"
 	evdev "github.com/holoplot/go-evdev"
 )
@@ -58,16 +60,48 @@ func Run(
 		candidates = append(candidates, timers.NewEscapeCandidate())
 	}
 
+	// Inject chord pending candidate if this combo can still grow into a chord.
+	// The chord window runs on its own timer, separate from the phase ladder.
+	var chordCh <-chan time.Time
+	if cfg.ChordMap[combo] && state.Chord != nil {
+		common.LogDebug("Ladder %s: injecting ChordPending (window %vms)", combo, cfg.Settings.ChordWindow)
+		candidates = append(candidates, timers.NewChordCandidate())
+		chordTimer := time.NewTimer(ms(cfg.Settings.ChordWindow))
+		defer chordTimer.Stop()
+		chordCh = chordTimer.C
+	}
+
+	// Keys withheld by the chord, taken once ChordPending is gone. If another
+	// ladder or the event handler took them first, this ladder has nothing left to do.
+	var chordKeys []timers.ChordKey
+	chordClosed := state.Chord == nil
+	takeChord := func() bool {
+		if chordClosed || hasChordPending(candidates) {
+			return true
+		}
+		chordClosed = true
+		chordCh = nil
+		var ok bool
+		chordKeys, ok = state.Chord.Close(state)
+		if !ok {
+			common.LogDebug("Ladder %s: chord keys taken elsewhere, exiting", combo)
+		}
+		return ok
+	}
+	if !takeChord() {
+		return
+	}
+
 	// Handle transparent press (modifier .pressrelease with empty press command)
-	handleTransparentPress(combo, candidates, virtual, emittedTracker)
+	handleTransparentPress(combo, candidates, cfg, virtual, emittedTracker)
 
 	// Build timer ladder: sorted unique thresholds
 	ladder := buildTimerLadder(candidates, cfg.Settings.DefaultInterval)
 	common.LogDebug("Ladder %s: timer phases=%v", combo, ladder)
 
 	// Single candidate with no timers = already won, fire immediately
-	// BUT: Skip early exit if the candidate is EscapePending (needs to wait for actual key events)
-	if len(candidates) == 1 && len(ladder) == 0 && candidates[0].Shortcut.Behavior != config.BehaviorEscapePending {
+	// BUT: Skip early exit if the candidate is EscapePending or ChordPending (needs to wait for actual key events)
+	if len(candidates) == 1 && len(ladder) == 0 && !isPendingBehavior(candidates[0].Shortcut.Behavior) {
 		common.LogDebug(">>> LADDER %s: single candidate no timers, firing immediately", combo)
 		fireWinner(combo, keyCode, value, &candidates[0], cfg, loopState, outputs, virtual, modifiers, ctx, state, pressed, emittedTracker)
 		return
@@ -83,6 +117,35 @@ func Run(
 		common.LogDebug("Doubletap timer started: %vms", ladder[0].Milliseconds())
 	}
 
+	// endChord drops ChordPending when the chord window expires or another key
+	// breaks the chord, then resolves the rest as if no chord had been possible.
+	// Returns true if the ladder is done.
+	endChord := func(reason string) bool {
+		candidates = dropChordPending(candidates)
+		common.LogDebug("Ladder %s: chord %s, survivors=%s", combo, reason, formatCandidates(candidates))
+		if !takeChord() {
+			return true
+		}
+		if len(candidates) == 0 {
+			common.LogDebug(">>> LADDER %s: NO WINNER (chord %s)", combo, reason)
+			if timer != nil {
+				timer.Stop()
+			}
+			ReplayChord(virtual, chordKeys)
+			return true
+		}
+		// Last standing wins, unless it still waits on a timer phase
+		if len(candidates) == 1 && phase >= len(ladder) {
+			common.LogDebug(">>> LADDER %s: WINNER=%s (last standing after chord %s)", combo, behaviorName(candidates[0].Shortcut.Behavior), reason)
+			if timer != nil {
+				timer.Stop()
+			}
+			fireWinner(combo, keyCode, value, &candidates[0], cfg, loopState, outputs, virtual, modifiers, ctx, state, pressed, emittedTracker)
+			return true
+		}
+		return false
+	}
+
 	for {
 		select {
 		case <-ctx.Done():
@@ -108,7 +171,7 @@ func Run(
 				return
 			}
 			keyName := newCombo[lastPlusIdx+1:]
-			newKey, ok := keys.ResolveKeyCode(keyName)
+			newKey, ok := cfg.ResolveKey(keyName)
 			if !ok {
 				common.LogDebug(">>> ESCAPE: failed to resolve key %s from combo %s, aborting", keyName, newCombo)
 				return
@@ -139,17 +202,22 @@ func Run(
 			candidates = pruneCandidates(candidates, count, pressed, phase, hasHold)
 			common.LogDebug("Ladder %s: pruned %d→%d survivors=%s", combo, beforePrune, len(candidates), formatCandidates(candidates))
 
+			if !takeChord() {
+				return
+			}
+
 			if len(candidates) == 0 {
 				common.LogDebug(">>> LADDER %s: NO WINNER (all eliminated after press)", combo)
 				if timer != nil {
 					timer.Stop()
 				}
-				emitUnmatchedModifier(combo, virtual, emittedTracker, pressed)
+				emitUnmatchedModifier(combo, cfg, virtual, emittedTracker, pressed)
+				ReplayChord(virtual, chordKeys)
 				return
 			}
 
 			// Last standing wins
-			if len(candidates) == 1 {
+			if len(candidates) == 1 && !hasChordPending(candidates) {
 				common.LogDebug(">>> LADDER %s: WINNER=%s (last standing after press)", combo, behaviorName(candidates[0].Shortcut.Behavior))
 				if timer != nil {
 					timer.Stop()
@@ -158,6 +226,12 @@ func Run(
 				return
 			}
 
+			// A multitap still short of its presses gets a fresh tap window
+			if phase == 0 && timer != nil && awaitsMoreTaps(candidates, count) {
+				timer.Reset(ladder[0])
+				common.LogDebug("Ladder %s: tap window reopened after press %d", combo, count)
+			}
+
 		case <-state.ReleaseCh:
 			// Key released
 			pressed = false
@@ -168,17 +242,22 @@ func Run(
 			candidates = pruneCandidates(candidates, count, pressed, phase, hasHold)
 			common.LogDebug("Ladder %s: pruned %d→%d survivors=%s", combo, beforePrune, len(candidates), formatCandidates(candidates))
 
+			if !takeChord() {
+				return
+			}
+
 			if len(candidates) == 0 {
 				common.LogDebug(">>> LADDER %s: NO WINNER (all eliminated after release)", combo)
 				if timer != nil {
 					timer.Stop()
 				}
-				emitUnmatchedModifier(combo, virtual, emittedTracker, pressed)
+				emitUnmatchedModifier(combo, cfg, virtual, emittedTracker, pressed)
+				ReplayChord(virtual, chordKeys)
 				return
 			}
 
 			// Last standing wins
-			if len(candidates) == 1 {
+			if len(candidates) == 1 && !hasChordPending(candidates) {
 				common.LogDebug(">>> LADDER %s: WINNER=%s (last standing after release)", combo, behaviorName(candidates[0].Shortcut.Behavior))
 				if timer != nil {
 					timer.Stop()
@@ -187,6 +266,16 @@ func Run(
 				return
 			}
 
+		case <-chordCh:
+			if endChord("window expired") {
+				return
+			}
+
+		case <-state.ChordBreakCh:
+			if hasChordPending(candidates) && endChord("broken by another key") {
+				return
+			}
+
 		case <-timerCh:
 			// Timer expired, advance phase
 			phase++
@@ -197,8 +286,12 @@ func Run(
 			candidates = pruneCandidates(candidates, count, pressed, phase, hasHold)
 			common.LogDebug("Ladder %s: pruned %d→%d survivors=%s", combo, beforePrune, len(candidates), formatCandidates(candidates))
 
+			if !takeChord() {
+				return
+			}
+
 			// Last standing wins
-			if len(candidates) == 1 {
+			if len(candidates) == 1 && !hasChordPending(candidates) {
 				common.LogDebug(">>> LADDER %s: WINNER=%s (last standing after timer)", combo, behaviorName(candidates[0].Shortcut.Behavior))
 				fireWinner(combo, keyCode, value, &candidates[0], cfg, loopState, outputs, virtual, modifiers, ctx, state, pressed, emittedTracker)
 				return
@@ -207,7 +300,8 @@ func Run(
 			// No winner yet (either 0 or multiple survivors)
 			if len(candidates) == 0 {
 				common.LogDebug(">>> LADDER %s: NO WINNER (all eliminated at phase %d)", combo, phase)
-				emitUnmatchedModifier(combo, virtual, emittedTracker, pressed)
+				emitUnmatchedModifier(combo, cfg, virtual, emittedTracker, pressed)
+				ReplayChord(virtual, chordKeys)
 				return
 			}
 
@@ -226,9 +320,9 @@ func Run(
 
 // handleTransparentPress emits modifier keydown for .pressrelease shortcuts with empty press command.
 // This allows modifiers to pass through to the system on initial press when configured as transparent.
-func handleTransparentPress(combo string, candidates []timers.Candidate, virtual *evdev.InputDevice, emittedTracker *timers.EmittedModifierTracker) {
+func handleTransparentPress(combo string, candidates []timers.Candidate, cfg *config.Config, virtual *evdev.InputDevice, emittedTracker *timers.EmittedModifierTracker) {
 	// Only runs if combo is a lone modifier
-	if !isModifierCombo(combo) {
+	if !cfg.IsModifier(combo) {
 		return
 	}
 
@@ -237,7 +331,7 @@ func handleTransparentPress(combo string, candidates []timers.Candidate, virtual
 		if c.Shortcut.Behavior == config.BehaviorPressRelease && len(c.Shortcut.Commands) > 0 && c.Shortcut.Commands[0] == "" {
 			// Emit modifier keydown and mark as emitted
 			common.LogDebug("handleTransparentPress: emitting %s keydown (transparent .pressrelease)", combo)
-			EmitModifierKey(virtual, keys.ResolveKeyCode, combo, true)
+			EmitModifierKey(virtual, cfg.ResolveKey, combo, true)
 			emittedTracker.MarkDown(combo)
 			return
 		}
@@ -248,13 +342,18 @@ func handleTransparentPress(combo string, candidates []timers.Candidate, virtual
 // system once its ladder resolves with no winner (no combo matched). This
 // runs on every no-winner exit path — press, release, and timer — so a
 // modifier withheld pending a possible combo is never dropped, regardless
-// of which event finally eliminates the last candidate.
-func emitUnmatchedModifier(combo string, virtual *evdev.InputDevice, emittedTracker *timers.EmittedModifierTracker, pressed bool) {
-	if !isModifierCombo(combo) || virtual == nil {
+// of which event finally eliminates the last candidate. A custom modifier
+// released alone is an ordinary key the system never saw go down, so it is
+// emitted as a full tap.
+func emitUnmatchedModifier(combo string, cfg *config.Config, virtual *evdev.InputDevice, emittedTracker *timers.EmittedModifierTracker, pressed bool) {
+	if !cfg.IsModifier(combo) || virtual == nil {
 		return
 	}
 	common.LogDebug("Emitting unmatched modifier %s to system (pressed=%v)", combo, pressed)
-	EmitModifierKey(virtual, keys.ResolveKeyCode, combo, pressed)
+	if _, custom := cfg.Modifiers[combo]; custom && !pressed && !emittedTracker.IsDown(combo) {
+		EmitModifierKey(virtual, cfg.ResolveKey, combo, true)
+	}
+	EmitModifierKey(virtual, cfg.ResolveKey, combo, pressed)
 	if pressed {
 		emittedTracker.MarkDown(combo)
 	} else {
@@ -271,7 +370,7 @@ func buildTimerLadder(candidates []timers.Candidate, defaultInterval float64) []
 		interval := intervalOrDefault(c.Shortcut.Interval, defaultInterval)
 
 		switch c.Shortcut.Behavior {
-		case config.BehaviorDoubleTap, config.BehaviorTapPressRelease:
+		case config.BehaviorDoubleTap, config.BehaviorTapPressRelease, config.BehaviorMultiTap:
 			// Needs Phase 1 timer for doubletap/tap window
 			if existing, ok := thresholds[1]; !ok || ms(interval) > existing {
 				thresholds[1] = ms(interval)
@@ -315,7 +414,11 @@ func buildTimerLadder(candidates []timers.Candidate, defaultInterval float64) []
 func pruneCandidates(candidates []timers.Candidate, count int, pressed bool, phase int, hasHold bool) []timers.Candidate {
 	pruned := make([]timers.Candidate, 0, len(candidates))
 	for _, c := range candidates {
-		if !isEliminated(c.Shortcut.Behavior, count, pressed, phase, hasHold) {
+		eliminated := isEliminated(c.Shortcut.Behavior, count, pressed, phase, hasHold)
+		if c.Shortcut.Behavior == config.BehaviorMultiTap {
+			eliminated = isMultiTapEliminated(c.Shortcut.TapCount, count, phase)
+		}
+		if !eliminated {
 			pruned = append(pruned, c)
 		}
 	}
@@ -349,6 +452,10 @@ func isEliminated(b config.BehaviorMode, count int, pressed bool, phase int, has
 		if phase >= 1 && count < 2 {
 			return true
 		}
+		// Eliminated by a third press (multitap territory)
+		if count > 2 {
+			return true
+		}
 		// Eliminated by holding past hold threshold on second press (taphold wins)
 		if phase >= 2 {
 			return true
@@ -368,18 +475,75 @@ func isEliminated(b config.BehaviorMode, count int, pressed bool, phase int, has
 		if phase >= 2 && count < 2 {
 			return true
 		}
+		// Eliminated by a third press (multitap territory)
+		if count > 2 {
+			return true
+		}
 		return false
 
 	case config.BehaviorEscapePending:
 		// Eliminated only when key released (no escape hatch arrived)
 		return !pressed
 
+	case config.BehaviorChordPending:
+		// Eliminated when a chord key is released or pressed again before the chord completes
+		return !pressed || count > 1
+
 	default:
 		// Unknown behavior, don't eliminate
 		return false
 	}
 }
 
+// isMultiTapEliminated returns true if a multitap candidate needing taps presses
+// can no longer win: the tap window expired short of them, or the key was
+// pressed more often than that.
+func isMultiTapEliminated(taps, count, phase int) bool {
+	if count > taps {
+		return true
+	}
+	return phase >= 1 && count < taps
+}
+
+// awaitsMoreTaps returns true while a surviving multitap candidate still needs
+// more presses than count. Each press then reopens the tap window, so the
+// window bounds the gap between taps rather than the whole sequence.
+func awaitsMoreTaps(candidates []timers.Candidate, count int) bool {
+	for _, c := range candidates {
+		if c.Shortcut.Behavior == config.BehaviorMultiTap && c.Shortcut.TapCount > count {
+			return true
+		}
+	}
+	return false
+}
+
+// isPendingBehavior returns true for pseudo-candidates that hold the ladder open
+// but never fire themselves
+func isPendingBehavior(b config.BehaviorMode) bool {
+	return b == config.BehaviorEscapePending || b == config.BehaviorChordPending
+}
+
+// hasChordPending returns true while the ChordPending pseudo-candidate survives
+func hasChordPending(candidates []timers.Candidate) bool {
+	for _, c := range candidates {
+		if c.Shortcut.Behavior == config.BehaviorChordPending {
+			return true
+		}
+	}
+	return false
+}
+
+// dropChordPending removes the ChordPending pseudo-candidate once the chord can no longer form
+func dropChordPending(candidates []timers.Candidate) []timers.Candidate {
+	pruned := make([]timers.Candidate, 0, len(candidates))
+	for _, c := range candidates {
+		if c.Shortcut.Behavior != config.BehaviorChordPending {
+			pruned = append(pruned, c)
+		}
+	}
+	return pruned
+}
+
 // isHoldBehavior returns true if behavior is hold-family (needs hold threshold)
 func isHoldBehavior(b config.BehaviorMode) bool {
 	return b == config.BehaviorHold || b == config.BehaviorHoldRelease || b == config.BehaviorLongPress
@@ -403,10 +567,21 @@ func fireWinner(
 ) {
 	s := winner.Shortcut
 
+	// A rate-limited shortcut refuses to fire again within its window
+	if !loopState.AllowFire(s) {
+		common.LogDebug("Ladder %s: %s refused by cooldown/ratelimit (forward=%v)", combo, behaviorName(s.Behavior), s.ThrottleForward)
+		if s.ThrottleForward {
+			forwardRefusedKey(combo, keyCode, cfg, virtual, emittedTracker, pressed)
+		}
+		return
+	}
+
 	// Consume any modifier that is part of this combo and currently
 	// forwarded to the system, so it does not leak into the fired command
 	// (e.g. holding ctrl through "ctrl+up" must not zoom the injected scroll).
-	consumeComboModifiers(virtual, combo, emittedTracker)
+	consumeComboModifiers(virtual, cfg, combo, emittedTracker)
+	events.Fired(combo, behaviorName(s.Behavior), config.DisplayCommand(cfg.ResolveCommand(firedCommand(s))))
+	stats.Fired(combo, behaviorName(s.Behavior), time.Since(state.Pressed))
 
 	// Build execution context
 	execCtx := executor.ExecContext{
@@ -417,6 +592,9 @@ func fireWinner(
 		Modifiers: modifiers,
 		Config:    cfg,
 		LoopState: loopState,
+		Combo:     combo,
+		Policy:    s.Policy,
+		Timeout:   s.Timeout,
 	}
 
 	switch s.Behavior {
@@ -424,6 +602,8 @@ func fireWinner(
 		common.LogMatch(combo, combo)
 		if s.Repeat {
 			loopState.ToggleLoop(combo, s, execCtx)
+		} else if s.ToggleProcess {
+			loopState.ToggleProcess(combo, s, execCtx)
 		} else {
 			resolvedCmd := cfg.ResolveCommand(s.Commands[0])
 			common.LogTrigger(resolvedCmd)
@@ -528,11 +708,14 @@ func fireWinner(
 	case config.BehaviorDoubleTap:
 		fire(combo+".doubletap", s.Commands[0], cfg, execCtx)
 
+	case config.BehaviorMultiTap:
+		fire(fmt.Sprintf("%s.tap(%d)", combo, s.TapCount), s.Commands[0], cfg, execCtx)
+
 	case config.BehaviorTapHold:
 		common.LogMatch(combo+".taphold", combo)
 		resolvedCmd := cfg.ResolveCommand(s.Commands[0])
 		common.LogTrigger(resolvedCmd)
-		cmd := executor.ExecuteTracked(resolvedCmd, cfg)
+		cmd := executor.ExecuteHeld(combo, resolvedCmd, s.Timeout, cfg)
 		if cmd != nil {
 			loopState.Mu.Lock()
 			loopState.HeldProcesses[combo] = cmd
@@ -594,7 +777,42 @@ func fireWinner(
 	case config.BehaviorEscapePending:
 		// No-op: this should never fire (eliminated before it can win)
 		common.LogDebug("BUG: EscapePending won the ladder for %s (should be impossible)", combo)
+
+	case config.BehaviorChordPending:
+		// No-op: the ladder never lets ChordPending win
+		common.LogDebug("BUG: ChordPending won the ladder for %s (should be impossible)", combo)
+	}
+}
+
+// forwardRefusedKey hands the system the key a refused shortcut withheld, as
+// if it were unbound. A key still held is only pressed: its physical release
+// then reaches the system like any unbound key's.
+func forwardRefusedKey(combo string, keyCode uint16, cfg *config.Config, virtual *evdev.InputDevice, emittedTracker *timers.EmittedModifierTracker, pressed bool) {
+	if cfg.IsModifier(combo) {
+		emitUnmatchedModifier(combo, cfg, virtual, emittedTracker, pressed)
+		return
+	}
+	if virtual == nil {
+		return
+	}
+	writeKey(virtual, keyCode, 1)
+	if !pressed {
+		writeKey(virtual, keyCode, 0)
+	}
+}
+
+// firedCommand returns the command a win of s runs first, for the
+// ShortcutFired event.
+func firedCommand(s *config.ParsedShortcut) string {
+	if s.Behavior == config.BehaviorTapLongPress {
+		return s.Commands[1]
+	}
+	for _, cmd := range s.Commands {
+		if cmd != "" {
+			return cmd
+		}
 	}
+	return ""
 }
 
 // fire is a helper for simple one-shot command execution with logging.
@@ -617,18 +835,13 @@ func intervalOrDefault(interval, def float64) float64 {
 	return def
 }
 
-// isModifierCombo checks if a combo is a lone modifier key
-func isModifierCombo(combo string) bool {
-	return combo == "super" || combo == "ctrl" || combo == "alt" || combo == "shift"
-}
-
 // consumeComboModifiers releases, on the virtual keyboard, any modifier
 // prefix of combo that the system currently sees as held (i.e. it was
 // forwarded transparently rather than suppressed). This is what lets a
 // modifier stay transparent right up until a combo actually matches: once
 // it matches, the modifier is consumed so it does not leak into whatever
 // the matched combo does (e.g. injected scroll wheel events).
-func consumeComboModifiers(virtual *evdev.InputDevice, combo string, emittedTracker *timers.EmittedModifierTracker) {
+func consumeComboModifiers(virtual *evdev.InputDevice, cfg *config.Config, combo string, emittedTracker *timers.EmittedModifierTracker) {
 	parts := strings.Split(combo, "+")
 	if len(parts) < 2 {
 		return
@@ -639,7 +852,7 @@ func consumeComboModifiers(virtual *evdev.InputDevice, combo string, emittedTrac
 		}
 		common.LogDebug("Consuming modifier %s (matched combo %s)", name, combo)
 		if virtual != nil {
-			EmitModifierKey(virtual, keys.ResolveKeyCode, name, false)
+			EmitModifierKey(virtual, cfg.ResolveKey, name, false)
 		}
 		emittedTracker.MarkUp(name)
 	}
@@ -664,6 +877,31 @@ func EmitModifierKey(virtual *evdev.InputDevice, resolver func(string) (uint16,
 	common.LogDebug("emitModifierKey: write results: key_err=%v, syn_err=%v", err1, err2)
 }
 
+// ReplayChord forwards keys withheld by a chord that resolved without a match,
+// in press order, followed by the releases that happened while they were withheld.
+func ReplayChord(virtual *evdev.InputDevice, chordKeys []timers.ChordKey) {
+	if virtual == nil || len(chordKeys) == 0 {
+		return
+	}
+	common.LogDebug("Replaying %d withheld chord key(s)", len(chordKeys))
+	for _, k := range chordKeys {
+		writeKey(virtual, k.Code, 1)
+	}
+	for _, k := range chordKeys {
+		if k.Released {
+			writeKey(virtual, k.Code, 0)
+		}
+	}
+}
+
+func writeKey(virtual *evdev.InputDevice, code uint16, value int32) {
+	if err := virtual.WriteOne(&evdev.InputEvent{Type: evdev.EV_KEY, Code: evdev.EvCode(code), Value: value}); err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to replay %s: %v\n", keys.GetKeyName(code), err)
+		return
+	}
+	virtual.WriteOne(&evdev.InputEvent{Type: evdev.EV_SYN, Code: evdev.SYN_REPORT, Value: 0})
+}
+
 // formatCandidates returns a compact string representation of candidate behaviors for debug logging
 func formatCandidates(candidates []timers.Candidate) string {
 	if len(candidates) == 0 {
@@ -701,8 +939,14 @@ func behaviorName(b config.BehaviorMode) string {
 		return "tappressrelease"
 	case config.BehaviorTapHoldRelease:
 		return "tapholdrelease"
+	case config.BehaviorTapMod:
+		return "tapmod"
+	case config.BehaviorMultiTap:
+		return "multitap"
 	case config.BehaviorEscapePending:
 		return "escape_pending"
+	case config.BehaviorChordPending:
+		return "chord_pending"
 	default:
 		return "unknown"
 	}
@@ -134,6 +134,10 @@ func TestIsEliminated_DoubleTap(t *testing.T) {
 
 		// Eliminated by holding past phase 2 (taphold wins)
 		{"count=2 phase=2", 2, true, 2, true},
+
+		// Eliminated by a third press (multitap territory)
+		{"count=3 phase=0", 3, true, 0, true},
+		{"count=3 released", 3, false, 0, true},
 	}
 
 	for _, tt := range tests {
@@ -176,6 +180,9 @@ func TestIsEliminated_TapHold(t *testing.T) {
 		// Survives within tap window on first press
 		{"count=1 pressed phase=0", 1, true, 0, false},
 		{"count=1 pressed phase=1", 1, true, 1, false},
+
+		// Eliminated by a third press (multitap territory)
+		{"count=3 pressed phase=0", 3, true, 0, true},
 	}
 
 	for _, tt := range tests {
@@ -238,6 +245,30 @@ func TestIsEliminated_EscapePending(t *testing.T) {
 	}
 }
 
+// TestIsEliminated_ChordPending tests elimination rules for BehaviorChordPending
+func TestIsEliminated_ChordPending(t *testing.T) {
+	tests := []struct {
+		name    string
+		count   int
+		pressed bool
+		want    bool
+	}{
+		{"pressed", 1, true, false},
+		{"released", 1, false, true},
+		{"pressed again", 2, true, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := isEliminated(config.BehaviorChordPending, tt.count, tt.pressed, 0, false)
+			if got != tt.want {
+				t.Errorf("isEliminated(ChordPending, count=%d, pressed=%v) = %v, want %v",
+					tt.count, tt.pressed, got, tt.want)
+			}
+		})
+	}
+}
+
 // TestPruning_NormalSolo tests solo normal behavior
 func TestPruning_NormalSolo(t *testing.T) {
 	candidates := []timers.Candidate{
@@ -805,6 +836,46 @@ func TestBuildTimerLadder(t *testing.T) {
 			wantPhases:      1,
 			wantDurations:   []int{350},
 		},
+		{
+			name: "multitap only - one phase",
+			candidates: []timers.Candidate{
+				{Shortcut: &config.ParsedShortcut{
+					Behavior: config.BehaviorMultiTap,
+					TapCount: 3,
+					Interval: 250,
+				}},
+			},
+			defaultInterval: 200,
+			wantPhases:      1,
+			wantDurations:   []int{250},
+		},
+		{
+			name: "doubletap + multitap - longest tap window",
+			candidates: []timers.Candidate{
+				{Shortcut: &config.ParsedShortcut{Behavior: config.BehaviorDoubleTap}},
+				{Shortcut: &config.ParsedShortcut{
+					Behavior: config.BehaviorMultiTap,
+					TapCount: 4,
+					Interval: 300,
+				}},
+			},
+			defaultInterval: 200,
+			wantPhases:      1,
+			wantDurations:   []int{300},
+		},
+		{
+			name: "multitap + taphold - two phases",
+			candidates: []timers.Candidate{
+				{Shortcut: &config.ParsedShortcut{Behavior: config.BehaviorMultiTap, TapCount: 3}},
+				{Shortcut: &config.ParsedShortcut{
+					Behavior:     config.BehaviorTapHold,
+					HoldInterval: 500,
+				}},
+			},
+			defaultInterval: 200,
+			wantPhases:      2,
+			wantDurations:   []int{200, 500},
+		},
 	}
 
 	for _, tt := range tests {
@@ -826,3 +897,225 @@ func TestBuildTimerLadder(t *testing.T) {
 		})
 	}
 }
+
+// TestIsEliminated_MultiTap tests elimination rules for BehaviorMultiTap
+func TestIsEliminated_MultiTap(t *testing.T) {
+	tests := []struct {
+		name  string
+		taps  int
+		count int
+		phase int
+		want  bool
+	}{
+		// Survives while presses keep arriving within the window
+		{"taps=3 count=1 phase=0", 3, 1, 0, false},
+		{"taps=3 count=2 phase=0", 3, 2, 0, false},
+		{"taps=3 count=3 phase=0", 3, 3, 0, false},
+
+		// Eliminated by window expiry short of its presses
+		{"taps=3 count=1 phase=1", 3, 1, 1, true},
+		{"taps=3 count=2 phase=1", 3, 2, 1, true},
+		{"taps=4 count=3 phase=1", 4, 3, 1, true},
+
+		// Survives window expiry once all presses arrived
+		{"taps=3 count=3 phase=1", 3, 3, 1, false},
+
+		// Eliminated by one press too many
+		{"taps=3 count=4 phase=0", 3, 4, 0, true},
+		{"taps=4 count=5 phase=0", 4, 5, 0, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := isMultiTapEliminated(tt.taps, tt.count, tt.phase)
+			if got != tt.want {
+				t.Errorf("isMultiTapEliminated(taps=%d, count=%d, phase=%d) = %v, want %v",
+					tt.taps, tt.count, tt.phase, got, tt.want)
+			}
+		})
+	}
+}
+
+// TestPruning_DoubleTapPlusTripleTap tests that a triple-tap candidate keeps
+// doubletap from resolving on the second press
+func TestPruning_DoubleTapPlusTripleTap(t *testing.T) {
+	candidates := []timers.Candidate{
+		{Shortcut: &config.ParsedShortcut{Behavior: config.BehaviorNormal}},
+		{Shortcut: &config.ParsedShortcut{Behavior: config.BehaviorDoubleTap}},
+		{Shortcut: &config.ParsedShortcut{Behavior: config.BehaviorMultiTap, TapCount: 3}},
+	}
+
+	tests := []struct {
+		name       string
+		count      int
+		pressed    bool
+		phase      int
+		wantCount  int
+		wantWinner config.BehaviorMode
+	}{
+		{
+			name:      "first release - all survive",
+			count:     1,
+			pressed:   false,
+			phase:     0,
+			wantCount: 3,
+		},
+		{
+			name:       "window expires after one tap - normal wins",
+			count:      1,
+			pressed:    false,
+			phase:      1,
+			wantCount:  1,
+			wantWinner: config.BehaviorNormal,
+		},
+		{
+			name:      "second press - doubletap waits for triple-tap",
+			count:     2,
+			pressed:   true,
+			phase:     0,
+			wantCount: 2,
+		},
+		{
+			name:      "second release - still waiting",
+			count:     2,
+			pressed:   false,
+			phase:     0,
+			wantCount: 2,
+		},
+		{
+			name:       "window expires after two taps - doubletap wins",
+			count:      2,
+			pressed:    false,
+			phase:      1,
+			wantCount:  1,
+			wantWinner: config.BehaviorDoubleTap,
+		},
+		{
+			name:       "third press - triple-tap wins",
+			count:      3,
+			pressed:    true,
+			phase:      0,
+			wantCount:  1,
+			wantWinner: config.BehaviorMultiTap,
+		},
+		{
+			name:      "fourth press - nothing left",
+			count:     4,
+			pressed:   true,
+			phase:     0,
+			wantCount: 0,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pruned := pruneCandidates(candidates, tt.count, tt.pressed, tt.phase, false)
+			if len(pruned) != tt.wantCount {
+				t.Errorf("got %d candidates, want %d (candidates: %s)", len(pruned), tt.wantCount, formatCandidates(pruned))
+			}
+			if tt.wantCount == 1 && pruned[0].Shortcut.Behavior != tt.wantWinner {
+				t.Errorf("winner = %s, want %s",
+					behaviorName(pruned[0].Shortcut.Behavior),
+					behaviorName(tt.wantWinner))
+			}
+		})
+	}
+}
+
+// TestPruning_MultiTapLadder tests triple- and quadruple-tap alongside
+// doubletap, hold and taphold on the same key. These tests simulate
+// progressive pruning as events occur.
+func TestPruning_MultiTapLadder(t *testing.T) {
+	newCandidates := func() []timers.Candidate {
+		return []timers.Candidate{
+			{Shortcut: &config.ParsedShortcut{Behavior: config.BehaviorDoubleTap}},
+			{Shortcut: &config.ParsedShortcut{Behavior: config.BehaviorMultiTap, TapCount: 3}},
+			{Shortcut: &config.ParsedShortcut{Behavior: config.BehaviorMultiTap, TapCount: 4}},
+			{Shortcut: &config.ParsedShortcut{Behavior: config.BehaviorHold}},
+			{Shortcut: &config.ParsedShortcut{Behavior: config.BehaviorTapHold}},
+		}
+	}
+	expectWinner := func(t *testing.T, candidates []timers.Candidate, behavior config.BehaviorMode, taps int) {
+		t.Helper()
+		if len(candidates) != 1 {
+			t.Fatalf("got %d candidates, want 1 (candidates: %s)", len(candidates), formatCandidates(candidates))
+		}
+		if candidates[0].Shortcut.Behavior != behavior || candidates[0].Shortcut.TapCount != taps {
+			t.Errorf("winner = %s (taps=%d), want %s (taps=%d)",
+				behaviorName(candidates[0].Shortcut.Behavior), candidates[0].Shortcut.TapCount,
+				behaviorName(behavior), taps)
+		}
+	}
+
+	t.Run("held past both thresholds - hold wins", func(t *testing.T) {
+		// taphold still waits on a second press at phase 1
+		candidates := pruneCandidates(newCandidates(), 1, true, 1, true)
+		candidates = pruneCandidates(candidates, 1, true, 2, true)
+		expectWinner(t, candidates, config.BehaviorHold, 0)
+	})
+
+	t.Run("tap then hold - taphold wins", func(t *testing.T) {
+		candidates := pruneCandidates(newCandidates(), 1, false, 0, true)
+		candidates = pruneCandidates(candidates, 2, true, 0, true)
+		candidates = pruneCandidates(candidates, 2, true, 1, true)
+		candidates = pruneCandidates(candidates, 2, true, 2, true)
+		expectWinner(t, candidates, config.BehaviorTapHold, 0)
+	})
+
+	t.Run("two taps - doubletap wins at window expiry", func(t *testing.T) {
+		candidates := pruneCandidates(newCandidates(), 1, false, 0, true)
+		candidates = pruneCandidates(candidates, 2, true, 0, true)
+		candidates = pruneCandidates(candidates, 2, false, 0, true)
+		if len(candidates) != 3 {
+			t.Fatalf("got %d candidates, want doubletap and both multitaps (candidates: %s)",
+				len(candidates), formatCandidates(candidates))
+		}
+		candidates = pruneCandidates(candidates, 2, false, 1, true)
+		expectWinner(t, candidates, config.BehaviorDoubleTap, 0)
+	})
+
+	t.Run("three taps - triple-tap wins at window expiry", func(t *testing.T) {
+		candidates := pruneCandidates(newCandidates(), 1, false, 0, true)
+		candidates = pruneCandidates(candidates, 2, false, 0, true)
+		candidates = pruneCandidates(candidates, 3, true, 0, true)
+		if len(candidates) != 2 {
+			t.Fatalf("got %d candidates, want both multitaps (candidates: %s)", len(candidates), formatCandidates(candidates))
+		}
+		candidates = pruneCandidates(candidates, 3, false, 1, true)
+		expectWinner(t, candidates, config.BehaviorMultiTap, 3)
+	})
+
+	t.Run("four taps - quadruple-tap wins on press", func(t *testing.T) {
+		candidates := pruneCandidates(newCandidates(), 1, false, 0, true)
+		candidates = pruneCandidates(candidates, 2, false, 0, true)
+		candidates = pruneCandidates(candidates, 3, false, 0, true)
+		candidates = pruneCandidates(candidates, 4, true, 0, true)
+		expectWinner(t, candidates, config.BehaviorMultiTap, 4)
+	})
+
+	t.Run("five taps - no winner", func(t *testing.T) {
+		candidates := pruneCandidates(newCandidates(), 1, false, 0, true)
+		candidates = pruneCandidates(candidates, 4, false, 0, true)
+		candidates = pruneCandidates(candidates, 5, true, 0, true)
+		if len(candidates) != 0 {
+			t.Errorf("got %d candidates, want 0 (candidates: %s)", len(candidates), formatCandidates(candidates))
+		}
+	})
+}
+
+// TestAwaitsMoreTaps tests when a press reopens the tap window
+func TestAwaitsMoreTaps(t *testing.T) {
+	candidates := []timers.Candidate{
+		{Shortcut: &config.ParsedShortcut{Behavior: config.BehaviorDoubleTap}},
+		{Shortcut: &config.ParsedShortcut{Behavior: config.BehaviorMultiTap, TapCount: 3}},
+	}
+	if !awaitsMoreTaps(candidates, 2) {
+		t.Error("triple-tap should await a third press after two")
+	}
+	if awaitsMoreTaps(candidates, 3) {
+		t.Error("triple-tap should not await more presses after three")
+	}
+	if awaitsMoreTaps(candidates[:1], 1) {
+		t.Error("doubletap alone never reopens the tap window")
+	}
+}
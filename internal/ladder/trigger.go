@@ -0,0 +1,103 @@
+package ladder
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/deprecatedluar/akeyshually/internal/common"
+	"github.com/deprecatedluar/akeyshually/internal/config"
+	"github.com/deprecatedluar/akeyshually/internal/events"
+	"github.com/deprecatedluar/akeyshually/internal/executor"
+	"github.com/deprecatedluar/akeyshually/internal/matcher"
+	"github.com/deprecatedluar/akeyshually/internal/stats"
+	"github.com/deprecatedluar/akeyshually/internal/timers"
+)
+
+// Trigger fires a shortcut of combo as if its key had been pressed, held for
+// hold and released, without waiting out tap windows or hold thresholds.
+// behavior picks the shortcut when the combo has several ("hold",
+// "doubletap", ...); empty means its plain press shortcut, or its only one.
+//
+// The shortcut runs through fireWinner like a physical press (cooldowns,
+// process policies, held remaps), and a .switch shortcut advances the cycle
+// kept in m. Trigger returns once the binding is done with the release.
+func Trigger(
+	combo string,
+	behavior string,
+	hold time.Duration,
+	m *matcher.Matcher,
+	cfg *config.Config,
+	loopState *executor.LoopState,
+	outputs executor.Outputs,
+) error {
+	key := cfg.NormalizeCombo(combo)
+	s, err := triggerShortcut(key, cfg.ParsedShortcuts[key], behavior)
+	if err != nil {
+		return err
+	}
+
+	switch s.Behavior {
+	case config.BehaviorTapMod:
+		return fmt.Errorf("%s.tapmod is resolved as keys are typed and cannot be triggered", key)
+	case config.BehaviorSwitch:
+		if !loopState.AllowFire(s) {
+			return fmt.Errorf("%s.switch refused by its cooldown/ratelimit", key)
+		}
+		common.LogMatch(key+".switch", key)
+		command := cfg.ResolveCommand(m.GetNextSwitchCommand(matcher.SwitchKey(key, s), s))
+		common.LogTrigger(command)
+		events.Fired(key, behaviorName(s.Behavior), config.DisplayCommand(command))
+		stats.Fired(key, behaviorName(s.Behavior), 0)
+		loopState.Launch(key, s.Policy, command, s.Timeout, cfg)
+		return nil
+	}
+
+	parts := strings.Split(key, "+")
+	keyCode, _ := cfg.ResolveKey(parts[len(parts)-1])
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	state := timers.NewComboState(cancel)
+	pressed := hold > 0
+	if pressed {
+		release := time.AfterFunc(hold, state.SignalRelease)
+		defer release.Stop()
+	} else {
+		state.SignalRelease()
+	}
+
+	common.LogDebug("Trigger %s: %s, held %v", key, behaviorName(s.Behavior), hold)
+	fireWinner(key, keyCode, 1, &timers.Candidate{Shortcut: s}, cfg, loopState, outputs, nil,
+		matcher.ModifierState{}, ctx, state, pressed, timers.NewEmittedModifierTracker())
+	return nil
+}
+
+// triggerShortcut picks the shortcut of combo with the named behavior.
+func triggerShortcut(combo string, shortcuts []*config.ParsedShortcut, behavior string) (*config.ParsedShortcut, error) {
+	if len(shortcuts) == 0 {
+		return nil, fmt.Errorf("no shortcut bound to %q", combo)
+	}
+
+	behavior = strings.TrimPrefix(strings.ToLower(behavior), ".")
+	if behavior == "press" {
+		behavior = behaviorName(config.BehaviorNormal)
+	}
+
+	var names []string
+	for _, s := range shortcuts {
+		name := behaviorName(s.Behavior)
+		if name == behavior || behavior == "" && s.Behavior == config.BehaviorNormal {
+			return s, nil
+		}
+		names = append(names, name)
+	}
+	if behavior == "" && len(shortcuts) == 1 {
+		return shortcuts[0], nil
+	}
+	if behavior == "" {
+		return nil, fmt.Errorf("%s has several shortcuts (%s), pick one with --behavior", combo, strings.Join(names, ", "))
+	}
+	return nil, fmt.Errorf("%s has no .%s shortcut (it has %s)", combo, behavior, strings.Join(names, ", "))
+}
@@ -0,0 +1,130 @@
+package ladder
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/deprecatedluar/akeyshually/internal/config"
+	"github.com/deprecatedluar/akeyshually/internal/executor"
+	"github.com/deprecatedluar/akeyshually/internal/matcher"
+)
+
+func triggerTestConfig(shortcuts map[string][]*config.ParsedShortcut) *config.Config {
+	return &config.Config{
+		Settings:        config.Settings{Shell: "sh", DefaultInterval: 150},
+		ParsedShortcuts: shortcuts,
+	}
+}
+
+// waitForContent polls until path holds want, or fails after a second.
+func waitForContent(t *testing.T, path, want string) {
+	t.Helper()
+	var got []byte
+	for range 100 {
+		got, _ = os.ReadFile(path)
+		if string(got) == want {
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatalf("%s = %q, want %q", filepath.Base(path), got, want)
+}
+
+func TestTriggerShortcutSelection(t *testing.T) {
+	normal := &config.ParsedShortcut{KeyCombo: "f1", Behavior: config.BehaviorNormal}
+	hold := &config.ParsedShortcut{KeyCombo: "f1", Behavior: config.BehaviorHold}
+	doubletap := &config.ParsedShortcut{KeyCombo: "f2", Behavior: config.BehaviorDoubleTap}
+	longpress := &config.ParsedShortcut{KeyCombo: "f2", Behavior: config.BehaviorLongPress}
+
+	tests := []struct {
+		name      string
+		shortcuts []*config.ParsedShortcut
+		behavior  string
+		want      *config.ParsedShortcut
+	}{
+		{"default is the press shortcut", []*config.ParsedShortcut{hold, normal}, "", normal},
+		{"press names the press shortcut", []*config.ParsedShortcut{hold, normal}, "press", normal},
+		{"behavior by name", []*config.ParsedShortcut{normal, hold}, "hold", hold},
+		{"leading dot", []*config.ParsedShortcut{normal, hold}, ".hold", hold},
+		{"only shortcut", []*config.ParsedShortcut{doubletap}, "", doubletap},
+		{"ambiguous", []*config.ParsedShortcut{doubletap, longpress}, "", nil},
+		{"missing behavior", []*config.ParsedShortcut{normal}, "hold", nil},
+		{"unbound", nil, "", nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := triggerShortcut("f1", tt.shortcuts, tt.behavior)
+			if tt.want == nil {
+				if err == nil {
+					t.Fatalf("got %v, want an error", got.Behavior)
+				}
+				return
+			}
+			if err != nil || got != tt.want {
+				t.Fatalf("got %v (%v), want %v", got, err, tt.want.Behavior)
+			}
+		})
+	}
+}
+
+func TestTriggerRunsResolvedCommand(t *testing.T) {
+	out := filepath.Join(t.TempDir(), "out")
+	cfg := triggerTestConfig(map[string][]*config.ParsedShortcut{
+		"ctrl+shift+t": {{KeyCombo: "ctrl+shift+t", Behavior: config.BehaviorNormal, Commands: []string{"echo pressed >> " + out}}},
+	})
+
+	if err := Trigger("Shift+Ctrl+T", "", 0, matcher.New(cfg.ParsedShortcuts), cfg, executor.NewLoopState(), executor.Outputs{}); err != nil {
+		t.Fatalf("Trigger: %v", err)
+	}
+	waitForContent(t, out, "pressed\n")
+}
+
+func TestTriggerHoldsForDuration(t *testing.T) {
+	out := filepath.Join(t.TempDir(), "out")
+	cfg := triggerTestConfig(map[string][]*config.ParsedShortcut{
+		"f3": {{KeyCombo: "f3", Behavior: config.BehaviorHoldRelease, Commands: []string{"echo down >> " + out, "echo up >> " + out}}},
+	})
+
+	start := time.Now()
+	if err := Trigger("f3", "holdrelease", 150*time.Millisecond, matcher.New(cfg.ParsedShortcuts), cfg, executor.NewLoopState(), executor.Outputs{}); err != nil {
+		t.Fatalf("Trigger: %v", err)
+	}
+	if held := time.Since(start); held < 150*time.Millisecond {
+		t.Errorf("Trigger returned after %v, before the release", held)
+	}
+	waitForContent(t, out, "down\nup\n")
+}
+
+func TestTriggerAdvancesSwitch(t *testing.T) {
+	out := filepath.Join(t.TempDir(), "out")
+	cfg := triggerTestConfig(map[string][]*config.ParsedShortcut{
+		"f4": {{KeyCombo: "f4", Behavior: config.BehaviorSwitch, Commands: []string{"mark", "echo second >> " + out}}},
+	})
+	cfg.Commands = map[string]string{"mark": "echo variable >> " + out}
+	m := matcher.New(cfg.ParsedShortcuts)
+	loopState := executor.NewLoopState()
+
+	for range 2 {
+		if err := Trigger("f4", "", 0, m, cfg, loopState, executor.Outputs{}); err != nil {
+			t.Fatalf("Trigger: %v", err)
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+	waitForContent(t, out, "variable\nsecond\n")
+	if next, _, _ := m.SwitchIndex("f4"); next != 0 {
+		t.Errorf("next switch index = %d, want 0", next)
+	}
+}
+
+func TestTriggerRejectsTapMod(t *testing.T) {
+	cfg := triggerTestConfig(map[string][]*config.ParsedShortcut{
+		"capslock": {{KeyCombo: "capslock", Behavior: config.BehaviorTapMod, Commands: []string{">esc", ">>ctrl"}}},
+	})
+	err := Trigger("capslock", "", 0, matcher.New(cfg.ParsedShortcuts), cfg, executor.NewLoopState(), executor.Outputs{})
+	if err == nil || !strings.Contains(err.Error(), "tapmod") {
+		t.Fatalf("Trigger error = %v, want a .tapmod error", err)
+	}
+}
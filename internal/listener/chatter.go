@@ -0,0 +1,81 @@
+package listener
+
+import (
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	"github.com/deprecatedluar/akeyshually/internal/common"
+	evdev "github.com/holoplot/go-evdev"
+)
+
+// ChatterFilter drops the spurious release+press pairs worn switches send while
+// a key is held. Each release is held back for the window; if the same key is
+// pressed again before it ends (by event timestamp), both events are dropped.
+type ChatterFilter struct {
+	window   time.Duration
+	pending  *evdev.InputEvent // release held back until the window ends
+	filtered *atomic.Uint64    // the device's stats counter once listened to
+}
+
+// NewChatterFilter returns a filter for the given window in milliseconds,
+// or nil when the window is zero (filter disabled).
+func NewChatterFilter(windowMs float64) *ChatterFilter {
+	if windowMs <= 0 {
+		return nil
+	}
+	return &ChatterFilter{window: time.Duration(windowMs * float64(time.Millisecond)), filtered: new(atomic.Uint64)}
+}
+
+// Filtered returns the number of events dropped so far (two per chatter pair),
+// as counted in the device's stats once it is listened to.
+func (f *ChatterFilter) Filtered() uint64 {
+	return f.filtered.Load()
+}
+
+// Offer passes an event through the filter and returns the events to dispatch,
+// in order. A held release is flushed ahead of any other key event.
+func (f *ChatterFilter) Offer(event evdev.InputEvent) []evdev.InputEvent {
+	if event.Type != evdev.EV_KEY {
+		return []evdev.InputEvent{event}
+	}
+
+	var out []evdev.InputEvent
+	if f.pending != nil {
+		release := *f.pending
+		f.pending = nil
+		gap := timevalDuration(event.Time) - timevalDuration(release.Time)
+		if event.Code == release.Code && event.Value == 1 && gap <= f.window {
+			f.filtered.Add(2)
+			common.LogDebug("Chatter filter: dropped %s release+press (%v apart)", event.CodeName(), gap)
+			return nil
+		}
+		out = append(out, release)
+	}
+
+	if event.Value == 0 {
+		f.pending = &event
+		return out
+	}
+	return append(out, event)
+}
+
+// Pending reports whether a release is being held back.
+func (f *ChatterFilter) Pending() bool {
+	return f.pending != nil
+}
+
+// Flush releases the held-back release, followed by a SYN_REPORT since the
+// original frame's sync has already been dispatched.
+func (f *ChatterFilter) Flush() []evdev.InputEvent {
+	if f.pending == nil {
+		return nil
+	}
+	release := *f.pending
+	f.pending = nil
+	return []evdev.InputEvent{release, {Time: release.Time, Type: evdev.EV_SYN, Code: evdev.SYN_REPORT}}
+}
+
+func timevalDuration(tv syscall.Timeval) time.Duration {
+	return time.Duration(tv.Sec)*time.Second + time.Duration(tv.Usec)*time.Microsecond
+}
@@ -0,0 +1,298 @@
+package listener
+
+import (
+	"context"
+	"encoding/binary"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/deprecatedluar/akeyshually/internal/common"
+	"github.com/deprecatedluar/akeyshually/internal/config"
+	"github.com/deprecatedluar/akeyshually/internal/events"
+	evdev "github.com/holoplot/go-evdev"
+)
+
+const (
+	inputDir = "/dev/input"
+
+	// hotplugSettle is how long a new device node must stay quiet before it
+	// is opened: udev creates it root-only and fixes permissions right after.
+	hotplugSettle = 250 * time.Millisecond
+)
+
+// Added is a device that appeared after startup and passed detection.
+type Added struct {
+	Kind  string             // UseKeyboard (also for button devices and remappers), UseDeclared or UseMouse
+	Name  string             // device name
+	Pair  KeyboardPair       // grabbed keyboard or declared device
+	Mouse *evdev.InputDevice // mouse, opened read-only
+}
+
+// Hotplug watches /dev/input for devices that appear after startup and
+// applies the FindKeyboards, FindDeclaredDevices and FindMice rules to them.
+// A device a listener lost is handed back to that listener (see Await);
+// other new devices go to the onAdded callback of Run.
+type Hotplug struct {
+	declared []config.DeviceSelector
+	exclude  []config.DeviceSelector
+	inotify  *os.File
+	done     chan struct{} // closed when Run returns
+
+	mu      sync.Mutex
+	active  map[string]string   // device paths in use -> Added.Kind
+	waiting map[string][]waiter // device name -> listeners waiting for it
+}
+
+// waiter is a listener waiting for its lost device to come back.
+type waiter struct {
+	kind string // Added.Kind the device was used as
+	ch   chan KeyboardPair
+}
+
+// NewHotplug starts watching /dev/input. Events are queued until Run, so
+// devices that appear while the startup scan runs are not missed.
+func NewHotplug(declared, exclude []config.DeviceSelector) (*Hotplug, error) {
+	fd, err := syscall.InotifyInit1(syscall.IN_CLOEXEC | syscall.IN_NONBLOCK)
+	if err != nil {
+		return nil, fmt.Errorf("inotify: %w", err)
+	}
+	if _, err := syscall.InotifyAddWatch(fd, inputDir, syscall.IN_CREATE|syscall.IN_ATTRIB); err != nil {
+		syscall.Close(fd)
+		return nil, fmt.Errorf("watch %s: %w", inputDir, err)
+	}
+	return &Hotplug{
+		declared: declared,
+		exclude:  exclude,
+		inotify:  os.NewFile(uintptr(fd), "inotify"),
+		done:     make(chan struct{}),
+		active:   make(map[string]string),
+		waiting:  make(map[string][]waiter),
+	}, nil
+}
+
+// Track marks the device at path as in use as kind (an Added.Kind), so it is
+// not picked up again.
+func (h *Hotplug) Track(path, kind string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.active[path] = kind
+}
+
+// Release forgets the device at path once its listener is done with it.
+func (h *Hotplug) Release(path string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	delete(h.active, path)
+}
+
+// Await is the AwaitDevice for ListenWithReconnect: it waits, without a time
+// limit, for the next device called name to show up and be grabbed for the
+// same use as the one lost at lostPath.
+func (h *Hotplug) Await(name, lostPath string) (KeyboardPair, bool) {
+	ch := make(chan KeyboardPair, 1)
+	h.mu.Lock()
+	kind := h.active[lostPath]
+	delete(h.active, lostPath)
+	h.waiting[name] = append(h.waiting[name], waiter{kind: kind, ch: ch})
+	h.mu.Unlock()
+
+	select {
+	case pair := <-ch:
+		return pair, true
+	case <-h.done:
+		return KeyboardPair{}, false
+	}
+}
+
+// Run handles new devices until ctx is cancelled, passing those no listener
+// waits for to onAdded and the ones that could not be grabbed to onFailed.
+func (h *Hotplug) Run(ctx context.Context, onAdded func(Added), onFailed func(FailedGrab)) error {
+	defer close(h.done)
+	go func() {
+		<-ctx.Done()
+		h.inotify.Close()
+	}()
+
+	names := make(chan string)
+	readErr := make(chan error, 1)
+	go func() { readErr <- h.read(names) }()
+
+	// Each node is opened once it has been quiet for hotplugSettle
+	pending := make(map[string]*time.Timer)
+	ready := make(chan string)
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case err := <-readErr:
+			if ctx.Err() != nil {
+				return nil
+			}
+			return fmt.Errorf("read %s events: %w", inputDir, err)
+		case name := <-names:
+			path := filepath.Join(inputDir, name)
+			if timer, ok := pending[path]; ok {
+				timer.Reset(hotplugSettle)
+				continue
+			}
+			pending[path] = time.AfterFunc(hotplugSettle, func() {
+				select {
+				case ready <- path:
+				case <-ctx.Done():
+				}
+			})
+		case path := <-ready:
+			delete(pending, path)
+			h.add(path, onAdded, onFailed)
+		}
+	}
+}
+
+// read passes the names of event* nodes created or changed in /dev/input.
+func (h *Hotplug) read(names chan<- string) error {
+	buf := make([]byte, 64*(syscall.SizeofInotifyEvent+syscall.NAME_MAX+1))
+	for {
+		n, err := h.inotify.Read(buf)
+		if err != nil {
+			return err
+		}
+		for _, name := range eventNodeNames(buf[:n]) {
+			select {
+			case names <- name:
+			case <-h.done:
+				return nil
+			}
+		}
+	}
+}
+
+// eventNodeNames returns the event* names in a buffer of inotify events.
+func eventNodeNames(buf []byte) []string {
+	var names []string
+	for offset := 0; offset+syscall.SizeofInotifyEvent <= len(buf); {
+		// struct inotify_event: wd, mask, cookie, len, then the padded name
+		nameLen := int(binary.NativeEndian.Uint32(buf[offset+12:]))
+		nameStart := offset + syscall.SizeofInotifyEvent
+		if nameStart+nameLen > len(buf) {
+			break
+		}
+		name := strings.TrimRight(string(buf[nameStart:nameStart+nameLen]), "\x00")
+		offset = nameStart + nameLen
+
+		if strings.HasPrefix(name, "event") {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+// add classifies the device at path and hands it to whoever takes it.
+func (h *Hotplug) add(path string, onAdded func(Added), onFailed func(FailedGrab)) {
+	h.mu.Lock()
+	_, inUse := h.active[path]
+	h.mu.Unlock()
+	if inUse {
+		return
+	}
+
+	added, err := h.classify(path)
+	if err != nil {
+		onFailed(FailedGrab{Name: added.Name, Reason: err.Error()})
+		return
+	}
+	if added.Kind == "" {
+		return
+	}
+	h.hand(path, added, onAdded)
+}
+
+// hand gives the device at path back to the listener that lost a device of
+// the same name and kind, or passes it to onAdded as a new device.
+func (h *Hotplug) hand(path string, added Added, onAdded func(Added)) {
+	h.mu.Lock()
+	h.active[path] = added.Kind
+	waiting := h.waiting[added.Name]
+	if i := slices.IndexFunc(waiting, func(w waiter) bool { return w.kind == added.Kind }); i >= 0 {
+		ch := waiting[i].ch
+		if waiting = slices.Delete(waiting, i, i+1); len(waiting) == 0 {
+			delete(h.waiting, added.Name)
+		} else {
+			h.waiting[added.Name] = waiting
+		}
+		h.mu.Unlock()
+		ch <- added.Pair
+		return
+	}
+	h.mu.Unlock()
+
+	events.Publish(events.Event{Type: events.DeviceConnected, Device: added.Name, Path: path})
+	onAdded(added)
+}
+
+// classify opens the device at path and, if detection would use it, grabs it
+// (keyboards, declared devices) or keeps it open read-only (mice). An empty
+// Kind means the device is not used. A device that cannot be opened yet is
+// ignored: udev changing its permissions brings it back.
+func (h *Hotplug) classify(path string) (Added, error) {
+	dev, err := evdev.Open(path)
+	if err != nil {
+		common.LogDebug("Hotplug: cannot open %s yet: %v", path, err)
+		return Added{}, nil
+	}
+
+	name, _ := dev.Name()
+	added := Added{Name: name}
+	id := identify(dev, path)
+	rule := keyboardRule(dev)
+	switch {
+	case strings.Contains(strings.ToLower(name), common.AppName):
+		// One of our own virtual devices
+	case config.MatchDevice(h.exclude, id):
+		common.LogDebug("Excluded device: %s", name)
+	case rule == UseKeyboard && remapperPresent():
+		common.LogDebug("Hotplug: %s left to the remapper", name)
+	case rule != "":
+		common.LogDebug("Hotplug: found %s: %s", rule, name)
+		added.Kind = UseKeyboard
+		added.Pair, err = grabPair(dev, evdev.CloneDevice)
+	case config.MatchDevice(h.declared, id):
+		common.LogDebug("Hotplug: found declared device: %s", name)
+		added.Kind = UseDeclared
+		added.Pair, err = grabPair(dev, cloneWithoutOutputCapabilities)
+	case !isRemapperVirtual(dev) && isMouse(dev):
+		common.LogDebug("Hotplug: found mouse: %s", name)
+		added.Kind = UseMouse
+		added.Mouse = dev
+		return added, nil
+	}
+
+	if added.Kind == "" || err != nil {
+		dev.Close()
+		added.Kind = ""
+	}
+	return added, err
+}
+
+// remapperPresent reports whether a remapper's virtual keyboard exists, in
+// which case FindKeyboards leaves physical keyboards to the remapper.
+func remapperPresent() bool {
+	paths, err := evdev.ListDevicePaths()
+	if err != nil {
+		return false
+	}
+	for _, path := range paths {
+		name := strings.ToLower(path.Name)
+		for _, remapper := range knownRemappers {
+			if strings.Contains(name, remapper) {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -1,6 +1,7 @@
 package listener
 
 import (
+	"errors"
 	"fmt"
 	"os"
 	"path/filepath"
@@ -9,12 +10,14 @@ import (
 	"time"
 
 	"github.com/deprecatedluar/akeyshually/internal/common"
+	"github.com/deprecatedluar/akeyshually/internal/config"
+	"github.com/deprecatedluar/akeyshually/internal/events"
+	"github.com/deprecatedluar/akeyshually/internal/stats"
 	evdev "github.com/holoplot/go-evdev"
 )
 
 const (
-	reconnectMaxAttempts = 30
-	reconnectInterval    = 2 * time.Second
+	reconnectInterval = 2 * time.Second
 
 	virtualDeviceSuffix       = " (" + common.AppName + ")"
 	keyboardInjectorName      = common.AppName + "-injector"
@@ -69,7 +72,10 @@ func cloneWithoutOutputCapabilities(name string, dev *evdev.InputDevice) (*evdev
 	return evdev.CreateDevice(name, id, capabilities)
 }
 
-func FindKeyboards() (DeviceResult, error) {
+// FindKeyboards grabs and clones the keyboards and button devices, or the
+// remappers' virtual keyboards when there are any. Devices matching exclude
+// are left alone.
+func FindKeyboards(exclude []config.DeviceSelector) (DeviceResult, error) {
 	paths, err := evdev.ListDevicePaths()
 	if err != nil {
 		return DeviceResult{}, fmt.Errorf("failed to list input devices: %w", err)
@@ -78,7 +84,6 @@ func FindKeyboards() (DeviceResult, error) {
 	var remappers []*evdev.InputDevice
 	var keyboards []*evdev.InputDevice
 	var buttonDevices []*evdev.InputDevice
-	devicePaths := make(map[*evdev.InputDevice]string)
 
 	common.LogDebug("Scanning %d input devices...", len(paths))
 
@@ -96,35 +101,26 @@ func FindKeyboards() (DeviceResult, error) {
 			continue
 		}
 
-		// Check remappers first (they don't always have EV_REP)
-		if isRemapperVirtual(dev) {
-			hasKey := hasKeyCapability(dev)
-			hasAlpha := hasAlphabetKeys(dev)
-			common.LogDebug("Found remapper: %s (hasKey=%v, hasAlpha=%v)", name, hasKey, hasAlpha)
-			if hasKey && hasAlpha {
-				devicePaths[dev] = path.Path
-				remappers = append(remappers, dev)
-				continue
-			}
+		if config.MatchDevice(exclude, identify(dev, path.Path)) {
+			common.LogDebug("Excluded device: %s", name)
+			dev.Close()
+			continue
 		}
 
-		// Button devices (phone hardware buttons, media keys)
-		if isButtonDevice(dev) {
+		switch keyboardRule(dev) {
+		case UseRemapper:
+			common.LogDebug("Found remapper: %s", name)
+			remappers = append(remappers, dev)
+		case UseButtons:
+			// Phone hardware buttons, media keys
 			common.LogDebug("Found button device: %s", name)
-			devicePaths[dev] = path.Path
 			buttonDevices = append(buttonDevices, dev)
-			continue
-		}
-
-		// Physical keyboards need EV_REP
-		if isKeyboard(dev) {
+		case UseKeyboard:
 			common.LogDebug("Found physical keyboard: %s", name)
-			devicePaths[dev] = path.Path
 			keyboards = append(keyboards, dev)
-			continue
+		default:
+			dev.Close()
 		}
-
-		dev.Close()
 	}
 
 	// Prefer remapper virtual keyboards (keyd/kanata grab physical ones)
@@ -146,36 +142,14 @@ func FindKeyboards() (DeviceResult, error) {
 	var pairs []KeyboardPair
 	var failures []FailedGrab
 	for _, physical := range devicesToGrab {
-		name, _ := physical.Name()
-
-		// Grab for exclusive access
-		if err := physical.Grab(); err != nil {
-			devicePath := devicePaths[physical]
-			holders := findProcessesUsingDevice(devicePath)
-			var reason string
-			if holders != "" {
-				reason = "held by " + holders
-			} else {
-				reason = err.Error()
-			}
-			failures = append(failures, FailedGrab{Name: name, Reason: reason})
-			physical.Close()
-			continue
-		}
-
-		// Clone to create virtual keyboard
-		virtual, err := evdev.CloneDevice(name+virtualDeviceSuffix, physical)
+		pair, err := grabPair(physical, evdev.CloneDevice)
 		if err != nil {
+			name, _ := physical.Name()
 			failures = append(failures, FailedGrab{Name: name, Reason: err.Error()})
-			physical.Ungrab()
 			physical.Close()
 			continue
 		}
-
-		pairs = append(pairs, KeyboardPair{
-			Physical: physical,
-			Virtual:  virtual,
-		})
+		pairs = append(pairs, pair)
 	}
 
 	if len(pairs) == 0 {
@@ -185,13 +159,56 @@ func FindKeyboards() (DeviceResult, error) {
 	return DeviceResult{Pairs: pairs, Failures: failures}, nil
 }
 
-func isKeyboard(dev *evdev.InputDevice) bool {
+// deviceInfo is what the device classifiers read from a device.
+// *evdev.InputDevice provides it.
+type deviceInfo interface {
+	Name() (string, error)
+	CapableTypes() []evdev.EvType
+	CapableEvents(t evdev.EvType) []evdev.EvCode
+}
+
+// keyboardRule tells which FindKeyboards rule takes dev: UseRemapper,
+// UseButtons, UseKeyboard, or "" for none. Remappers are checked first as
+// they don't always have EV_REP; physical keyboards need it.
+func keyboardRule(dev deviceInfo) string {
+	switch {
+	case isRemapperKeyboard(dev):
+		return UseRemapper
+	case isButtonDevice(dev):
+		return UseButtons
+	case isKeyboard(dev):
+		return UseKeyboard
+	}
+	return ""
+}
+
+// grabPair grabs dev for exclusive access and creates the virtual device
+// events are forwarded to with clone. The caller closes dev on error.
+func grabPair(dev *evdev.InputDevice, clone func(name string, dev *evdev.InputDevice) (*evdev.InputDevice, error)) (KeyboardPair, error) {
+	name, _ := dev.Name()
+	if err := dev.Grab(); err != nil {
+		return KeyboardPair{}, errors.New(grabFailure(dev.Path(), err))
+	}
+	virtual, err := clone(name+virtualDeviceSuffix, dev)
+	if err != nil {
+		dev.Ungrab()
+		return KeyboardPair{}, err
+	}
+	return KeyboardPair{Physical: dev, Virtual: virtual}, nil
+}
+
+// isRemapperKeyboard detects the virtual keyboard of a remapper such as keyd.
+func isRemapperKeyboard(dev deviceInfo) bool {
+	return isRemapperVirtual(dev) && hasKeyCapability(dev) && hasAlphabetKeys(dev)
+}
+
+func isKeyboard(dev deviceInfo) bool {
 	return hasKeyCapability(dev) && hasRepCapability(dev) && hasAlphabetKeys(dev)
 }
 
 // isButtonDevice detects hardware buttons (volume, power) that send EV_KEY events
 // but lack EV_REP and full keyboard layout
-func isButtonDevice(dev *evdev.InputDevice) bool {
+func isButtonDevice(dev deviceInfo) bool {
 	if !hasKeyCapability(dev) {
 		return false
 	}
@@ -230,7 +247,7 @@ func isButtonDevice(dev *evdev.InputDevice) bool {
 	return false
 }
 
-func hasCapability(dev *evdev.InputDevice, typ evdev.EvType) bool {
+func hasCapability(dev deviceInfo, typ evdev.EvType) bool {
 	for _, t := range dev.CapableTypes() {
 		if t == typ {
 			return true
@@ -239,10 +256,10 @@ func hasCapability(dev *evdev.InputDevice, typ evdev.EvType) bool {
 	return false
 }
 
-func hasKeyCapability(dev *evdev.InputDevice) bool { return hasCapability(dev, evdev.EV_KEY) }
-func hasRepCapability(dev *evdev.InputDevice) bool { return hasCapability(dev, evdev.EV_REP) }
+func hasKeyCapability(dev deviceInfo) bool { return hasCapability(dev, evdev.EV_KEY) }
+func hasRepCapability(dev deviceInfo) bool { return hasCapability(dev, evdev.EV_REP) }
 
-func isRemapperVirtual(dev *evdev.InputDevice) bool {
+func isRemapperVirtual(dev deviceInfo) bool {
 	name, _ := dev.Name()
 	nameLower := strings.ToLower(name)
 
@@ -254,7 +271,7 @@ func isRemapperVirtual(dev *evdev.InputDevice) bool {
 	return false
 }
 
-func hasAlphabetKeys(dev *evdev.InputDevice) bool {
+func hasAlphabetKeys(dev deviceInfo) bool {
 	capableKeys := dev.CapableEvents(evdev.EV_KEY)
 	if len(capableKeys) == 0 {
 		return false
@@ -275,26 +292,89 @@ func hasAlphabetKeys(dev *evdev.InputDevice) bool {
 	return true
 }
 
-func Listen(pair KeyboardPair, handler EventHandler) error {
-	for {
-		event, err := pair.Physical.ReadOne()
-		if err != nil {
-			if err == syscall.ENODEV {
-				return fmt.Errorf("device disconnected")
+// Listen reads events from the physical device and dispatches them until the
+// device fails. A non-nil filter drops key chatter before the handler sees it.
+func Listen(pair KeyboardPair, handler EventHandler, filter *ChatterFilter) error {
+	if filter == nil {
+		for {
+			event, err := pair.Physical.ReadOne()
+			if err != nil {
+				return readError(err)
 			}
-			if err == syscall.EACCES {
-				fmt.Fprintf(os.Stderr, "Permission denied. Add user to input group:\n")
-				fmt.Fprintf(os.Stderr, "  sudo usermod -aG input $USER\n")
-				fmt.Fprintf(os.Stderr, "Then logout and login again.\n")
+
+			if err := dispatchEvent(event, handler, pair.Virtual.WriteOne); err != nil {
 				return err
 			}
-			return fmt.Errorf("read error: %w", err)
 		}
+	}
 
-		if err := dispatchEvent(event, handler, pair.Virtual.WriteOne); err != nil {
-			return err
+	type readResult struct {
+		event *evdev.InputEvent
+		err   error
+	}
+	reads := make(chan readResult)
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		for {
+			event, err := pair.Physical.ReadOne()
+			select {
+			case reads <- readResult{event, err}:
+			case <-done:
+				return
+			}
+			if err != nil {
+				return
+			}
+		}
+	}()
+
+	dispatchAll := func(events []evdev.InputEvent) error {
+		for i := range events {
+			if err := dispatchEvent(&events[i], handler, pair.Virtual.WriteOne); err != nil {
+				return err
+			}
 		}
+		return nil
 	}
+
+	flushTimer := time.NewTimer(filter.window)
+	flushTimer.Stop()
+	defer flushTimer.Stop()
+
+	for {
+		select {
+		case r := <-reads:
+			if r.err != nil {
+				// Let the handler see a release held back when the device went away.
+				dispatchAll(filter.Flush())
+				return readError(r.err)
+			}
+			if err := dispatchAll(filter.Offer(*r.event)); err != nil {
+				return err
+			}
+			if filter.Pending() {
+				flushTimer.Reset(filter.window)
+			}
+		case <-flushTimer.C:
+			if err := dispatchAll(filter.Flush()); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+func readError(err error) error {
+	if err == syscall.ENODEV {
+		return fmt.Errorf("device disconnected")
+	}
+	if err == syscall.EACCES {
+		fmt.Fprintf(os.Stderr, "Permission denied. Add user to input group:\n")
+		fmt.Fprintf(os.Stderr, "  sudo usermod -aG input $USER\n")
+		fmt.Fprintf(os.Stderr, "Then logout and login again.\n")
+		return err
+	}
+	return fmt.Errorf("read error: %w", err)
 }
 
 func dispatchEvent(event *evdev.InputEvent, handler EventHandler, forward eventForwarder) error {
@@ -390,8 +470,9 @@ func findProcessesUsingDevice(devicePath string) string {
 	return strings.Join(processes, ", ")
 }
 
-// FindDeclaredDevices finds and grabs devices whose names contain any of the given substrings.
-func FindDeclaredDevices(matches []string) (DeviceResult, error) {
+// FindDeclaredDevices finds and grabs devices matching any of the selectors
+// and none of exclude.
+func FindDeclaredDevices(selectors, exclude []config.DeviceSelector) (DeviceResult, error) {
 	paths, err := evdev.ListDevicePaths()
 	if err != nil {
 		return DeviceResult{}, fmt.Errorf("failed to list input devices: %w", err)
@@ -415,54 +496,75 @@ func FindDeclaredDevices(matches []string) (DeviceResult, error) {
 			continue
 		}
 
-		// Check if device name matches any declared pattern (case-insensitive)
-		matched := false
-		for _, match := range matches {
-			if strings.Contains(nameLower, strings.ToLower(match)) {
-				matched = true
-				break
-			}
-		}
-
-		if !matched {
+		id := identify(dev, path.Path)
+		if !config.MatchDevice(selectors, id) {
 			dev.Close()
 			continue
 		}
-
-		common.LogDebug("Found declared device: %s", name)
-
-		if err := dev.Grab(); err != nil {
-			holders := findProcessesUsingDevice(path.Path)
-			var reason string
-			if holders != "" {
-				reason = "held by " + holders
-			} else {
-				reason = err.Error()
-			}
-			failures = append(failures, FailedGrab{Name: name, Reason: reason})
+		if config.MatchDevice(exclude, id) {
+			common.LogDebug("Excluded declared device: %s", name)
 			dev.Close()
 			continue
 		}
 
-		virtual, err := cloneWithoutOutputCapabilities(name+virtualDeviceSuffix, dev)
+		common.LogDebug("Found declared device: %s", name)
+
+		pair, err := grabPair(dev, cloneWithoutOutputCapabilities)
 		if err != nil {
 			failures = append(failures, FailedGrab{Name: name, Reason: err.Error()})
-			dev.Ungrab()
 			dev.Close()
 			continue
 		}
-
-		pairs = append(pairs, KeyboardPair{Physical: dev, Virtual: virtual})
+		pairs = append(pairs, pair)
 	}
 
 	return DeviceResult{Pairs: pairs, Failures: failures}, nil
 }
 
+// identify reads what device selectors match on from the open device at path.
+func identify(dev *evdev.InputDevice, path string) config.DeviceIdentity {
+	id := config.DeviceIdentity{Path: path, Capabilities: make(map[uint16][]uint16)}
+	id.Name, _ = dev.Name()
+	id.Phys, _ = dev.PhysicalLocation()
+	id.Uniq, _ = dev.UniqueID()
+	if inputID, err := dev.InputID(); err == nil {
+		id.Vendor, id.Product = inputID.Vendor, inputID.Product
+	}
+	for _, t := range dev.CapableTypes() {
+		var codes []uint16
+		for _, code := range dev.CapableEvents(t) {
+			codes = append(codes, uint16(code))
+		}
+		id.Capabilities[uint16(t)] = codes
+	}
+	return id
+}
+
+// grabFailure explains a failed grab of the device at path, naming the
+// processes that have it open when there are any.
+func grabFailure(path string, err error) string {
+	if holders := findProcessesUsingDevice(path); holders != "" {
+		return "held by " + holders
+	}
+	return err.Error()
+}
+
+// AwaitDevice blocks until the device called name, lost from lostPath, is
+// back and grabbed again. It returns false when the daemon stops waiting.
+type AwaitDevice func(name, lostPath string) (KeyboardPair, bool)
+
 // ListenWithReconnect wraps Listen with automatic reconnection on device disconnect.
-// On ENODEV it calls findFn every 2 seconds (up to 30 attempts) to find the device by name.
-func ListenWithReconnect(pair KeyboardPair, handler EventHandler, findFn func() (DeviceResult, error), deviceName string) error {
+// On ENODEV it waits for await to hand the device back, however long that
+// takes, and listens to it again.
+// The filter (may be nil) is kept across reconnects; what it drops is counted
+// in the device's stats.
+func ListenWithReconnect(pair KeyboardPair, handler EventHandler, filter *ChatterFilter, await AwaitDevice, deviceName string) error {
+	handler = countEvents(handler, deviceName)
+	if filter != nil {
+		filter.filtered = stats.ChatterEvents(deviceName)
+	}
 	for {
-		err := Listen(pair, handler)
+		err := Listen(pair, handler, filter)
 		if err == nil {
 			return nil
 		}
@@ -473,40 +575,95 @@ func ListenWithReconnect(pair KeyboardPair, handler EventHandler, findFn func()
 		}
 
 		Cleanup(pair)
-		common.LogDebug("Device %q disconnected, attempting reconnect...", deviceName)
+		lostPath := pair.Physical.Path()
+		events.Publish(events.Event{Type: events.DeviceDisconnected, Device: deviceName, Path: lostPath})
+		common.LogDebug("Device %q disconnected, waiting for it to reconnect...", deviceName)
+		if filter != nil {
+			common.LogDebug("Chatter filter on %q: %d event(s) filtered so far", deviceName, filter.Filtered())
+		}
+
+		newPair, ok := await(deviceName, lostPath)
+		if !ok {
+			return nil
+		}
+
+		pair = newPair
+		fmt.Printf("  - Reconnected: %s\n", deviceName)
+		events.Publish(events.Event{Type: events.DeviceConnected, Device: deviceName, Path: pair.Physical.Path()})
+	}
+}
+
+// PollDevice is the AwaitDevice used without hotplug events: every 2 seconds
+// it lists the device nodes, which only opens them for their name, and calls
+// findFn to grab the device once a node called name shows up that was not
+// there when it was lost. Other keyboards are left alone until then.
+func PollDevice(findFn func() (DeviceResult, error)) AwaitDevice {
+	return func(name, _ string) (KeyboardPair, bool) {
+		// Nodes of that name still present belong to other devices, such as
+		// a second keyboard of the same model
+		known := make(map[string]bool)
+		if paths, err := evdev.ListDevicePaths(); err == nil {
+			for _, path := range newDevicePaths(paths, name, nil) {
+				known[path] = true
+			}
+		}
 
-		var newPair *KeyboardPair
-		for attempt := 1; attempt <= reconnectMaxAttempts; attempt++ {
+		for attempt := 1; ; attempt++ {
 			time.Sleep(reconnectInterval)
 
+			paths, err := evdev.ListDevicePaths()
+			if err != nil {
+				common.LogDebug("Reconnect attempt %d: %v", attempt, err)
+				continue
+			}
+			if len(newDevicePaths(paths, name, known)) == 0 {
+				common.LogDebug("Reconnect attempt %d: %q not found", attempt, name)
+				continue
+			}
+
 			result, err := findFn()
 			if err != nil {
-				common.LogDebug("Reconnect attempt %d/%d: %v", attempt, reconnectMaxAttempts, err)
+				common.LogDebug("Reconnect attempt %d: %v", attempt, err)
 				continue
 			}
 
+			var found *KeyboardPair
 			for _, p := range result.Pairs {
-				name, _ := p.Physical.Name()
-				if name == deviceName && newPair == nil {
-					found := p
-					newPair = &found
+				pName, _ := p.Physical.Name()
+				if pName == name && !known[p.Physical.Path()] && found == nil {
+					found = &p
 				} else {
 					Cleanup(p)
 				}
 			}
 
-			if newPair != nil {
-				break
+			if found != nil {
+				return *found, true
 			}
-			common.LogDebug("Reconnect attempt %d/%d: %q not found", attempt, reconnectMaxAttempts, deviceName)
+			common.LogDebug("Reconnect attempt %d: %q could not be grabbed", attempt, name)
 		}
+	}
+}
 
-		if newPair == nil {
-			return fmt.Errorf("device %q did not reconnect within 1 minute", deviceName)
+// newDevicePaths returns the paths of the nodes called name that are not in known.
+func newDevicePaths(paths []evdev.InputPath, name string, known map[string]bool) []string {
+	var found []string
+	for _, p := range paths {
+		if p.Name == name && !known[p.Path] {
+			found = append(found, p.Path)
 		}
+	}
+	return found
+}
 
-		pair = *newPair
-		fmt.Printf("  - Reconnected: %s\n", deviceName)
+// countEvents counts the key and axis events handler gets from deviceName.
+func countEvents(handler EventHandler, deviceName string) EventHandler {
+	counter := stats.DeviceEvents(deviceName)
+	return func(event evdev.InputEvent) bool {
+		if event.Type != evdev.EV_SYN {
+			counter.Add(1)
+		}
+		return handler(event)
 	}
 }
 
@@ -554,7 +711,7 @@ func FindMice() ([]*evdev.InputDevice, error) {
 	return mice, nil
 }
 
-func isMouse(dev *evdev.InputDevice) bool {
+func isMouse(dev deviceInfo) bool {
 	if !hasKeyCapability(dev) {
 		return false
 	}
@@ -609,6 +766,7 @@ func ListenMouse(dev *evdev.InputDevice, handler MouseButtonHandler) error {
 }
 
 // CreateKeyboardInjector creates a keyboard-only uinput device for key remaps.
+// It declares EV_REP so the kernel autorepeats keys held on it (">>" holds).
 func CreateKeyboardInjector() (*evdev.InputDevice, error) {
 	codes := make([]evdev.EvCode, injectorKeyCount)
 	for i := range codes {
@@ -617,6 +775,7 @@ func CreateKeyboardInjector() (*evdev.InputDevice, error) {
 	return evdev.CreateDevice(keyboardInjectorName, injectorID(keyboardInjectorProductID),
 		map[evdev.EvType][]evdev.EvCode{
 			evdev.EV_KEY: codes,
+			evdev.EV_REP: nil,
 		})
 }
 
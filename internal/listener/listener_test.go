@@ -1,8 +1,11 @@
 package listener
 
 import (
+	"encoding/binary"
 	"errors"
+	"syscall"
 	"testing"
+	"time"
 
 	evdev "github.com/holoplot/go-evdev"
 )
@@ -90,3 +93,206 @@ func TestDispatchEventPropagatesForwardError(t *testing.T) {
 		t.Fatalf("dispatchEvent() error = %v, want wrapped %v", err, wantErr)
 	}
 }
+
+func keyEventAt(code uint16, value int32, usec int64) evdev.InputEvent {
+	return evdev.InputEvent{
+		Time:  syscall.Timeval{Sec: 100, Usec: usec},
+		Type:  evdev.EV_KEY,
+		Code:  evdev.EvCode(code),
+		Value: value,
+	}
+}
+
+func TestChatterFilterDropsReleasePressPair(t *testing.T) {
+	f := NewChatterFilter(10)
+
+	if out := f.Offer(keyEventAt(evdev.KEY_A, 1, 0)); len(out) != 1 {
+		t.Fatalf("press: got %d events, want 1", len(out))
+	}
+	if out := f.Offer(keyEventAt(evdev.KEY_A, 0, 40000)); len(out) != 0 {
+		t.Fatalf("release should be held back, got %+v", out)
+	}
+	if out := f.Offer(keyEventAt(evdev.KEY_A, 1, 43000)); len(out) != 0 {
+		t.Fatalf("chatter press should be dropped, got %+v", out)
+	}
+	if f.Pending() {
+		t.Error("dropped release still pending")
+	}
+	if got := f.Filtered(); got != 2 {
+		t.Errorf("Filtered() = %d, want 2", got)
+	}
+}
+
+func TestChatterFilterKeepsSlowRetap(t *testing.T) {
+	f := NewChatterFilter(10)
+
+	f.Offer(keyEventAt(evdev.KEY_A, 0, 0))
+	out := f.Offer(keyEventAt(evdev.KEY_A, 1, 25000))
+	if len(out) != 2 || out[0].Value != 0 || out[1].Value != 1 {
+		t.Fatalf("got %+v, want release then press", out)
+	}
+	if got := f.Filtered(); got != 0 {
+		t.Errorf("Filtered() = %d, want 0", got)
+	}
+}
+
+func TestChatterFilterFlushesOnOtherKey(t *testing.T) {
+	f := NewChatterFilter(10)
+
+	f.Offer(keyEventAt(evdev.KEY_A, 0, 0))
+	out := f.Offer(keyEventAt(evdev.KEY_B, 1, 1000))
+	if len(out) != 2 || out[0].Code != evdev.KEY_A || out[1].Code != evdev.KEY_B {
+		t.Fatalf("got %+v, want A release then B press", out)
+	}
+
+	syn := evdev.InputEvent{Type: evdev.EV_SYN, Code: evdev.SYN_REPORT}
+	if out := f.Offer(syn); len(out) != 1 || out[0].Type != evdev.EV_SYN {
+		t.Errorf("SYN should pass through, got %+v", out)
+	}
+}
+
+func TestChatterFilterFlushAppendsSyn(t *testing.T) {
+	f := NewChatterFilter(10)
+
+	f.Offer(keyEventAt(evdev.KEY_A, 0, 0))
+	out := f.Flush()
+	if len(out) != 2 || out[0].Code != evdev.KEY_A || out[1].Type != evdev.EV_SYN {
+		t.Fatalf("got %+v, want A release then SYN_REPORT", out)
+	}
+	if out := f.Flush(); out != nil {
+		t.Errorf("second Flush() = %+v, want nil", out)
+	}
+}
+
+func TestNewChatterFilterDisabled(t *testing.T) {
+	if f := NewChatterFilter(0); f != nil {
+		t.Errorf("NewChatterFilter(0) = %+v, want nil", f)
+	}
+}
+
+func TestEventNodeNames(t *testing.T) {
+	// inotify_event headers followed by names padded with NULs
+	inotifyEvent := func(name string, padded int) []byte {
+		b := make([]byte, syscall.SizeofInotifyEvent+padded)
+		binary.NativeEndian.PutUint32(b[12:], uint32(padded))
+		copy(b[syscall.SizeofInotifyEvent:], name)
+		return b
+	}
+	var buf []byte
+	buf = append(buf, inotifyEvent("event7", 16)...)
+	buf = append(buf, inotifyEvent("mouse2", 16)...)
+	buf = append(buf, inotifyEvent("by-id", 16)...)
+	buf = append(buf, inotifyEvent("event12", 16)...)
+
+	got := eventNodeNames(buf)
+	if len(got) != 2 || got[0] != "event7" || got[1] != "event12" {
+		t.Errorf("eventNodeNames = %q, want [event7 event12]", got)
+	}
+	if got := eventNodeNames(buf[:syscall.SizeofInotifyEvent+4]); got != nil {
+		t.Errorf("truncated buffer: got %q, want nothing", got)
+	}
+}
+
+func newTestHotplug() *Hotplug {
+	return &Hotplug{
+		done:    make(chan struct{}),
+		active:  make(map[string]string),
+		waiting: make(map[string][]waiter),
+	}
+}
+
+// awaitInBackground calls h.Await and returns the channel its result arrives
+// on (closed without one if Await gives up), once the wait is registered.
+func awaitInBackground(t *testing.T, h *Hotplug, name, lostPath string) <-chan KeyboardPair {
+	t.Helper()
+	got := make(chan KeyboardPair, 1)
+	go func() {
+		if pair, ok := h.Await(name, lostPath); ok {
+			got <- pair
+		}
+		close(got)
+	}()
+	for range 100 {
+		h.mu.Lock()
+		waiting := len(h.waiting[name])
+		h.mu.Unlock()
+		if waiting > 0 {
+			return got
+		}
+		time.Sleep(time.Millisecond)
+	}
+	t.Fatalf("Await(%q) did not start waiting", name)
+	return nil
+}
+
+func TestHotplugHandsBackByNameAndKind(t *testing.T) {
+	h := newTestHotplug()
+	h.Track("/dev/input/event5", UseKeyboard)
+	got := awaitInBackground(t, h, "Pad", "/dev/input/event5")
+
+	var added []Added
+	onAdded := func(a Added) { added = append(added, a) }
+
+	// Same name, but the declared-device half of a composite device
+	declared := KeyboardPair{Physical: &evdev.InputDevice{}}
+	h.hand("/dev/input/event8", Added{Kind: UseDeclared, Name: "Pad", Pair: declared}, onAdded)
+	// Another device of the same kind
+	h.hand("/dev/input/event9", Added{Kind: UseKeyboard, Name: "Other", Pair: KeyboardPair{Physical: &evdev.InputDevice{}}}, onAdded)
+	if len(added) != 2 {
+		t.Fatalf("onAdded got %d devices, want the 2 nobody waits for", len(added))
+	}
+	select {
+	case <-got:
+		t.Fatal("listener got a device of another name or kind back")
+	default:
+	}
+
+	keyboard := KeyboardPair{Physical: &evdev.InputDevice{}}
+	h.hand("/dev/input/event10", Added{Kind: UseKeyboard, Name: "Pad", Pair: keyboard}, onAdded)
+	select {
+	case pair := <-got:
+		if pair.Physical != keyboard.Physical {
+			t.Error("listener got the wrong device back")
+		}
+	case <-time.After(time.Second):
+		t.Fatal("keyboard was not handed back to its listener")
+	}
+	if len(added) != 2 || len(h.waiting) != 0 {
+		t.Errorf("handed back device also went to onAdded (%d) or left a waiter (%v)", len(added), h.waiting)
+	}
+}
+
+func TestHotplugAddSkipsDevicesInUse(t *testing.T) {
+	h := newTestHotplug()
+	h.Track("/dev/input/event5", UseKeyboard)
+	h.add("/dev/input/event5", func(Added) {
+		t.Error("device in use was added again")
+	}, func(FailedGrab) {
+		t.Error("device in use was grabbed again")
+	})
+
+	got := awaitInBackground(t, h, "Pad", "/dev/input/event5")
+	if _, inUse := h.active["/dev/input/event5"]; inUse {
+		t.Error("Await did not release the lost device's path")
+	}
+	close(h.done)
+	if _, ok := <-got; ok {
+		t.Error("Await returned a device after hotplug stopped")
+	}
+}
+
+func TestNewDevicePaths(t *testing.T) {
+	paths := []evdev.InputPath{
+		{Name: "Keychron K2", Path: "/dev/input/event3"},
+		{Name: "Logitech Mouse", Path: "/dev/input/event4"},
+		{Name: "Keychron K2", Path: "/dev/input/event9"},
+	}
+	known := map[string]bool{"/dev/input/event3": true} // the second K2, still listened to
+
+	if got := newDevicePaths(paths, "Keychron K2", known); len(got) != 1 || got[0] != "/dev/input/event9" {
+		t.Errorf("newDevicePaths = %q, want [/dev/input/event9]", got)
+	}
+	if got := newDevicePaths(paths[:2], "Keychron K2", known); got != nil {
+		t.Errorf("newDevicePaths without the lost keyboard = %q, want nothing", got)
+	}
+}
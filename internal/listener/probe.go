@@ -0,0 +1,246 @@
+package listener
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/deprecatedluar/akeyshually/internal/common"
+	"github.com/deprecatedluar/akeyshually/internal/config"
+	evdev "github.com/holoplot/go-evdev"
+)
+
+// What device detection does with a device (DeviceReport.Use).
+const (
+	UseKeyboard = "keyboard" // grabbed by FindKeyboards
+	UseButtons  = "button"   // grabbed by FindKeyboards as a button device
+	UseRemapper = "remapper" // grabbed by FindKeyboards instead of the keyboards it remaps
+	UseDeclared = "declared" // grabbed by FindDeclaredDevices
+	UseMouse    = "mouse"    // read by FindMice for tap cancellation, never grabbed
+	UseExcluded = "excluded" // matches exclude_devices, never grabbed
+	UseOwn      = "own"      // one of akeyshually's virtual devices
+)
+
+// Check is the verdict of one of the device classifiers.
+type Check struct {
+	Classifier string `json:"classifier"` // "keyboard", "button", "remapper" or "mouse"
+	Accepted   bool   `json:"accepted"`
+	Reason     string `json:"reason,omitempty"` // why it was rejected
+}
+
+// DeviceReport describes an input device and how device detection treats it.
+type DeviceReport struct {
+	Path         string   `json:"path"`
+	Name         string   `json:"name"`
+	Vendor       uint16   `json:"vendor"`
+	Product      uint16   `json:"product"`
+	Phys         string   `json:"phys,omitempty"`
+	Uniq         string   `json:"uniq,omitempty"`
+	Links        []string `json:"links,omitempty"` // /dev/input/by-id and by-path symlinks to it
+	Capabilities []string `json:"capabilities"`    // event types, with their code count: "EV_KEY(104)"
+	Checks       []Check  `json:"checks,omitempty"`
+	Use          string   `json:"use,omitempty"`        // Use* constant, empty if ignored
+	Note         string   `json:"note,omitempty"`       // why a keyboard is left alone
+	Daemon       string   `json:"daemon,omitempty"`     // kind the running daemon lists it under
+	GrabError    string   `json:"grab_error,omitempty"` // why a test grab failed
+	Error        string   `json:"error,omitempty"`      // the device could not be opened
+}
+
+// ProbeDevices reports on every /dev/input/event* device: its identity and
+// capabilities, the classifier verdicts and what detection would use it for
+// with the declared and excluded device selectors. daemon maps the paths the running
+// daemon listens on to their kind (nil when it is not running). Devices
+// detection would grab but the daemon does not hold are test-grabbed, and
+// released at once, to find out what stands in the way.
+func ProbeDevices(declared, exclude []config.DeviceSelector, daemon map[string]string) ([]DeviceReport, error) {
+	paths, err := evdev.ListDevicePaths()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list input devices: %w", err)
+	}
+
+	links := deviceLinks()
+	var reports []DeviceReport
+	hasRemapper := false
+	for _, path := range paths {
+		report := DeviceReport{Path: path.Path, Name: path.Name, Links: links[path.Path], Daemon: daemon[path.Path]}
+		dev, err := evdev.Open(path.Path)
+		if err != nil {
+			report.Error = err.Error()
+			reports = append(reports, report)
+			continue
+		}
+		describeDevice(dev, &report, declared, exclude)
+		dev.Close()
+		hasRemapper = hasRemapper || report.Use == UseRemapper
+		reports = append(reports, report)
+	}
+
+	for i := range reports {
+		r := &reports[i]
+		if hasRemapper && r.Use == UseKeyboard {
+			// FindKeyboards prefers the remapper's virtual keyboard
+			r.Use = ""
+			r.Note = "a remapper's virtual keyboard is used instead"
+		}
+		if r.Daemon == "" && grabbed(r.Use) {
+			r.GrabError = testGrab(r.Path)
+		}
+	}
+	return reports, nil
+}
+
+// describeDevice fills in what can be read from the open device.
+func describeDevice(dev *evdev.InputDevice, report *DeviceReport, declared, exclude []config.DeviceSelector) {
+	id := identify(dev, report.Path)
+	if id.Name != "" {
+		report.Name = id.Name
+	}
+	id.Name = report.Name
+	report.Vendor, report.Product = id.Vendor, id.Product
+	report.Phys, report.Uniq = id.Phys, id.Uniq
+	report.Capabilities = capabilitySummary(dev)
+	report.Checks, report.Use = deviceVerdicts(dev, id, declared, exclude)
+}
+
+// deviceVerdicts runs every classifier on dev and tells what detection uses
+// it for: the FindKeyboards rule, then FindDeclaredDevices and FindMice.
+func deviceVerdicts(dev deviceInfo, id config.DeviceIdentity, declared, exclude []config.DeviceSelector) ([]Check, string) {
+	if strings.Contains(strings.ToLower(id.Name), common.AppName) {
+		return nil, UseOwn
+	}
+
+	remapper := isRemapperVirtual(dev)
+	mouse := !remapper && isMouse(dev)
+	checks := []Check{
+		check("keyboard", isKeyboard(dev), keyboardRejection(dev)),
+		check("button", isButtonDevice(dev), buttonRejection(dev)),
+		check("remapper", isRemapperKeyboard(dev), remapperRejection(remapper)),
+		check("mouse", mouse, mouseRejection(dev, remapper)),
+	}
+
+	rule := keyboardRule(dev)
+	switch {
+	case config.MatchDevice(exclude, id):
+		return checks, UseExcluded
+	case rule != "":
+		return checks, rule
+	case config.MatchDevice(declared, id):
+		return checks, UseDeclared
+	case mouse:
+		return checks, UseMouse
+	}
+	return checks, ""
+}
+
+func check(classifier string, accepted bool, reason string) Check {
+	if accepted {
+		return Check{Classifier: classifier, Accepted: true}
+	}
+	return Check{Classifier: classifier, Reason: reason}
+}
+
+// grabbed reports whether detection grabs devices used as use.
+func grabbed(use string) bool {
+	switch use {
+	case UseKeyboard, UseButtons, UseRemapper, UseDeclared:
+		return true
+	}
+	return false
+}
+
+// testGrab grabs and releases the device at path, returning why the grab
+// failed or "" if it worked.
+func testGrab(path string) string {
+	dev, err := evdev.Open(path)
+	if err != nil {
+		return err.Error()
+	}
+	defer dev.Close()
+	if err := dev.Grab(); err != nil {
+		return grabFailure(path, err)
+	}
+	dev.Ungrab()
+	return ""
+}
+
+// deviceLinks maps device nodes to the /dev/input/by-id and by-path
+// symlinks pointing at them.
+func deviceLinks() map[string][]string {
+	links := make(map[string][]string)
+	for _, dir := range []string{"/dev/input/by-id", "/dev/input/by-path"} {
+		entries, err := os.ReadDir(dir)
+		if err != nil {
+			continue
+		}
+		for _, entry := range entries {
+			link := filepath.Join(dir, entry.Name())
+			if node, err := filepath.EvalSymlinks(link); err == nil {
+				links[node] = append(links[node], link)
+			}
+		}
+	}
+	return links
+}
+
+// capabilitySummary lists the device's event types, e.g.
+// ["EV_KEY(104)", "EV_MSC(1)", "EV_LED(3)", "EV_REP"].
+func capabilitySummary(dev *evdev.InputDevice) []string {
+	types := dev.CapableTypes()
+	slices.Sort(types)
+	var summary []string
+	for _, t := range types {
+		if t == evdev.EV_SYN {
+			continue
+		}
+		if n := len(dev.CapableEvents(t)); n > 0 {
+			summary = append(summary, fmt.Sprintf("%s(%d)", evdev.TypeName(t), n))
+		} else {
+			summary = append(summary, evdev.TypeName(t))
+		}
+	}
+	return summary
+}
+
+func keyboardRejection(dev deviceInfo) string {
+	switch {
+	case !hasKeyCapability(dev):
+		return "no EV_KEY"
+	case !hasRepCapability(dev):
+		return "no EV_REP (key repeat)"
+	default:
+		return "lacks some of the A-Z keys"
+	}
+}
+
+func buttonRejection(dev deviceInfo) string {
+	switch {
+	case !hasKeyCapability(dev):
+		return "no EV_KEY"
+	case hasRepCapability(dev):
+		return "has EV_REP (key repeat)"
+	default:
+		return "no volume, power, mute or brightness keys"
+	}
+}
+
+func remapperRejection(remapper bool) string {
+	if !remapper {
+		return "name matches none of " + strings.Join(knownRemappers, ", ")
+	}
+	return "lacks the A-Z keys"
+}
+
+func mouseRejection(dev deviceInfo, remapper bool) string {
+	switch {
+	case remapper:
+		return "remapper virtual device"
+	case !hasKeyCapability(dev):
+		return "no EV_KEY"
+	case hasRepCapability(dev):
+		return "has EV_REP (key repeat)"
+	default:
+		return "no BTN_LEFT"
+	}
+}
@@ -0,0 +1,145 @@
+package listener
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/deprecatedluar/akeyshually/internal/config"
+	evdev "github.com/holoplot/go-evdev"
+)
+
+// fakeDevice is a deviceInfo with fixed capabilities.
+type fakeDevice struct {
+	name string
+	caps map[evdev.EvType][]evdev.EvCode
+}
+
+func (d fakeDevice) Name() (string, error) { return d.name, nil }
+
+func (d fakeDevice) CapableTypes() []evdev.EvType {
+	var types []evdev.EvType
+	for t := range d.caps {
+		types = append(types, t)
+	}
+	return types
+}
+
+func (d fakeDevice) CapableEvents(t evdev.EvType) []evdev.EvCode { return d.caps[t] }
+
+func (d fakeDevice) identity() config.DeviceIdentity {
+	id := config.DeviceIdentity{Path: "/dev/input/event3", Name: d.name, Capabilities: make(map[uint16][]uint16)}
+	for t, codes := range d.caps {
+		for _, code := range codes {
+			id.Capabilities[uint16(t)] = append(id.Capabilities[uint16(t)], uint16(code))
+		}
+	}
+	return id
+}
+
+func alphabetKeys(extra ...evdev.EvCode) []evdev.EvCode {
+	var keys []evdev.EvCode
+	for key := evdev.EvCode(evdev.KEY_A); key <= evdev.EvCode(evdev.KEY_Z); key++ {
+		keys = append(keys, key)
+	}
+	return append(keys, extra...)
+}
+
+func TestDeviceVerdicts(t *testing.T) {
+	keyboard := map[evdev.EvType][]evdev.EvCode{evdev.EV_KEY: alphabetKeys(), evdev.EV_REP: nil}
+	buttons := map[evdev.EvType][]evdev.EvCode{evdev.EV_KEY: {evdev.KEY_POWER, evdev.KEY_VOLUMEUP}}
+	mouse := map[evdev.EvType][]evdev.EvCode{evdev.EV_KEY: {evdev.BTN_LEFT, evdev.BTN_RIGHT}, evdev.EV_REL: {evdev.REL_X, evdev.REL_Y}}
+	pad := map[evdev.EvType][]evdev.EvCode{evdev.EV_KEY: {evdev.BTN_SOUTH}, evdev.EV_ABS: {evdev.ABS_X}}
+
+	const notRemapper = "name matches none of keyd, kanata, kmonad, xremap"
+	declared := []config.DeviceSelector{{Name: "Pad"}, {Name: "Keychron"}}
+	exclude := []config.DeviceSelector{{Name: "Yubico"}}
+
+	tests := []struct {
+		dev     fakeDevice
+		use     string
+		reasons map[string]string // classifier -> rejection reason; missing ones accept
+	}{
+		{fakeDevice{"Keychron K2", keyboard}, UseKeyboard, map[string]string{
+			"button":   "has EV_REP (key repeat)",
+			"remapper": notRemapper,
+			"mouse":    "has EV_REP (key repeat)",
+		}},
+		{fakeDevice{"Power Button", buttons}, UseButtons, map[string]string{
+			"keyboard": "no EV_REP (key repeat)",
+			"remapper": notRemapper,
+			"mouse":    "no BTN_LEFT",
+		}},
+		{fakeDevice{"keyd virtual keyboard", map[evdev.EvType][]evdev.EvCode{evdev.EV_KEY: alphabetKeys()}}, UseRemapper, map[string]string{
+			"keyboard": "no EV_REP (key repeat)",
+			"button":   "no volume, power, mute or brightness keys",
+			"mouse":    "remapper virtual device",
+		}},
+		{fakeDevice{"keyd virtual pointer", mouse}, "", map[string]string{
+			"keyboard": "no EV_REP (key repeat)",
+			"button":   "no volume, power, mute or brightness keys",
+			"remapper": "lacks the A-Z keys",
+			"mouse":    "remapper virtual device",
+		}},
+		{fakeDevice{"Logitech Mouse", mouse}, UseMouse, map[string]string{
+			"keyboard": "no EV_REP (key repeat)",
+			"button":   "no volume, power, mute or brightness keys",
+			"remapper": notRemapper,
+		}},
+		{fakeDevice{"Game Pad", pad}, UseDeclared, map[string]string{
+			"keyboard": "no EV_REP (key repeat)",
+			"button":   "no volume, power, mute or brightness keys",
+			"remapper": notRemapper,
+			"mouse":    "no BTN_LEFT",
+		}},
+		{fakeDevice{"Yubico YubiKey", keyboard}, UseExcluded, map[string]string{
+			"button":   "has EV_REP (key repeat)",
+			"remapper": notRemapper,
+			"mouse":    "has EV_REP (key repeat)",
+		}},
+		{fakeDevice{"Lid Switch", map[evdev.EvType][]evdev.EvCode{evdev.EV_SW: {evdev.SW_LID}}}, "", map[string]string{
+			"keyboard": "no EV_KEY",
+			"button":   "no EV_KEY",
+			"remapper": notRemapper,
+			"mouse":    "no EV_KEY",
+		}},
+		{fakeDevice{"akeyshually virtual keyboard", keyboard}, UseOwn, nil},
+	}
+	for _, tt := range tests {
+		checks, use := deviceVerdicts(tt.dev, tt.dev.identity(), declared, exclude)
+		if use != tt.use {
+			t.Errorf("%s: use = %q, want %q", tt.dev.name, use, tt.use)
+		}
+		if use == UseOwn {
+			if checks != nil {
+				t.Errorf("%s: own device was classified: %+v", tt.dev.name, checks)
+			}
+			continue
+		}
+		var classifiers []string
+		for _, c := range checks {
+			classifiers = append(classifiers, c.Classifier)
+			want, rejected := tt.reasons[c.Classifier]
+			if c.Accepted == rejected || c.Reason != want {
+				t.Errorf("%s: %s check = %+v, want reason %q", tt.dev.name, c.Classifier, c, want)
+			}
+		}
+		if want := []string{"keyboard", "button", "remapper", "mouse"}; !slices.Equal(classifiers, want) {
+			t.Errorf("%s: checks %v, want %v", tt.dev.name, classifiers, want)
+		}
+	}
+}
+
+// The verdicts must pick the same FindKeyboards rule the daemon grabs with.
+func TestDeviceVerdictsFollowKeyboardRule(t *testing.T) {
+	devices := []fakeDevice{
+		{"keyd virtual keyboard", map[evdev.EvType][]evdev.EvCode{evdev.EV_KEY: alphabetKeys(evdev.KEY_VOLUMEUP)}},
+		{"Keyboard with media keys", map[evdev.EvType][]evdev.EvCode{evdev.EV_KEY: alphabetKeys(evdev.KEY_MUTE), evdev.EV_REP: nil}},
+		{"Remote", map[evdev.EvType][]evdev.EvCode{evdev.EV_KEY: {evdev.KEY_MUTE, evdev.KEY_A}}},
+	}
+	for _, dev := range devices {
+		_, use := deviceVerdicts(dev, dev.identity(), nil, nil)
+		if rule := keyboardRule(dev); use != rule {
+			t.Errorf("%s: use = %q, keyboardRule = %q", dev.name, use, rule)
+		}
+	}
+}
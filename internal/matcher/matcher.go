@@ -2,6 +2,8 @@ package matcher
 
 import (
 	"fmt"
+	"maps"
+	"slices"
 	"strings"
 	"sync"
 
@@ -11,11 +13,35 @@ import (
 	evdev "github.com/holoplot/go-evdev"
 )
 
-type ModifierState struct {
-	Super bool
-	Ctrl  bool
-	Alt   bool
-	Shift bool
+// ModifierState is the set of held modifiers by name: "super", "ctrl", "alt",
+// "shift", or a custom modifier declared in [modifiers] (e.g. "hyper").
+type ModifierState map[string]bool
+
+// standardModifiers lists the built-in modifiers in canonical combo order
+var standardModifiers = []string{"super", "ctrl", "alt", "shift"}
+
+// Has returns true if the named modifier is held
+func (s ModifierState) Has(name string) bool {
+	return s[name]
+}
+
+// Names returns the held modifiers in canonical combo order:
+// super → ctrl → alt → shift → custom modifiers sorted by name.
+func (s ModifierState) Names() []string {
+	names := make([]string, 0, len(s))
+	for _, name := range standardModifiers {
+		if s[name] {
+			names = append(names, name)
+		}
+	}
+	var custom []string
+	for name := range s {
+		if !slices.Contains(standardModifiers, name) {
+			custom = append(custom, name)
+		}
+	}
+	slices.Sort(custom)
+	return append(names, custom...)
 }
 
 // ShortcutKey uniquely identifies a shortcut by combo + behavior + timing
@@ -58,15 +84,19 @@ func (ts *TapState) Check(code uint16) bool {
 }
 
 type Matcher struct {
-	state     ModifierState
-	shortcuts map[ShortcutKey]*config.ParsedShortcut
+	// Held modifiers, shared by the listeners of every device; stateMutex
+	// also guards comboBuilder
+	state      ModifierState
+	stateMutex sync.Mutex
+	shortcuts  map[ShortcutKey]*config.ParsedShortcut
 
 	// Passthrough shortcuts (indexed by base key only, no modifiers)
 	passthroughShortcuts map[ShortcutKey]*config.ParsedShortcut
 
 	// Switch state (cycle through commands)
-	switchState map[string]int // "super+k.switch.press" -> next index
-	switchMutex sync.Mutex
+	switchState     map[string]config.SwitchPosition // "super+k.switch.0" -> next index
+	switchMutex     sync.Mutex
+	persistSwitches bool // write switchState to the state file on every change
 
 	// Tap shortcuts (lone modifiers with .onrelease)
 	tapShortcuts map[uint16]string
@@ -74,6 +104,11 @@ type Matcher struct {
 	// Shared tap state (for mouse cancellation)
 	tapState *TapState
 
+	// Custom modifiers declared in [modifiers], by key code and by name
+	customNames map[uint16]string
+	customCodes map[string]uint16
+	modifiers   map[string]string // as declared, for normalizing combos
+
 	// Reusable string builder (avoids allocations in hot path)
 	comboBuilder strings.Builder
 }
@@ -122,9 +157,10 @@ func New(parsedShortcuts map[string][]*config.ParsedShortcut) *Matcher {
 	}
 
 	return &Matcher{
+		state:                make(ModifierState),
 		shortcuts:            shortcuts,
 		passthroughShortcuts: passthroughShortcuts,
-		switchState:          make(map[string]int),
+		switchState:          make(map[string]config.SwitchPosition),
 		tapShortcuts:         tapShortcuts,
 		tapState:             nil, // Set via SetTapState() if needed
 	}
@@ -135,6 +171,41 @@ func (m *Matcher) SetTapState(ts *TapState) {
 	m.tapState = ts
 }
 
+// SetCustomModifiers registers the custom modifiers declared in [modifiers]
+// (name -> key name), so their keys are tracked like built-in modifiers.
+func (m *Matcher) SetCustomModifiers(modifiers map[string]string) {
+	m.modifiers = modifiers
+	m.customNames = make(map[uint16]string, len(modifiers))
+	m.customCodes = make(map[string]uint16, len(modifiers))
+	for name, key := range modifiers {
+		if code, ok := keys.ResolveKeyCode(key); ok {
+			m.customNames[code] = name
+			m.customCodes[name] = code
+		}
+	}
+}
+
+// IsModifier returns true if the key code is a built-in or custom modifier key
+func (m *Matcher) IsModifier(code uint16) bool {
+	_, custom := m.customNames[code]
+	return custom || IsModifierKey(code)
+}
+
+// IsCustomModifier returns true if the key code is declared in [modifiers]
+func (m *Matcher) IsCustomModifier(code uint16) bool {
+	_, ok := m.customNames[code]
+	return ok
+}
+
+// KeyName returns the name a key is matched by: its custom modifier name if
+// declared in [modifiers], its key name otherwise.
+func (m *Matcher) KeyName(code uint16) string {
+	if name, ok := m.customNames[code]; ok {
+		return name
+	}
+	return keys.GetKeyName(code)
+}
+
 // GetShortcuts returns all shortcuts for a combo (including passthrough matches).
 func (m *Matcher) GetShortcuts(combo string) []*config.ParsedShortcut {
 	var result []*config.ParsedShortcut
@@ -164,92 +235,81 @@ func extractBaseKey(combo string) string {
 
 // GetCurrentCombo builds the current key combo string
 func (m *Matcher) GetCurrentCombo(code uint16) string {
+	return m.GetComboForName(m.KeyName(code))
+}
+
+// GetComboForName prefixes a key or gesture name with the held modifiers,
+// e.g. "swipe3_left" -> "super+swipe3_left" while super is down.
+func (m *Matcher) GetComboForName(name string) string {
+	m.stateMutex.Lock()
+	defer m.stateMutex.Unlock()
+
 	// Fast path: no modifiers (most common case)
-	if !m.state.Super && !m.state.Ctrl && !m.state.Alt && !m.state.Shift {
-		return keys.GetKeyName(code)
+	if len(m.state) == 0 {
+		return name
 	}
 
 	m.comboBuilder.Reset()
-	needPlus := false
-
-	if m.state.Super {
-		m.comboBuilder.WriteString("super")
-		needPlus = true
-	}
-	if m.state.Ctrl {
-		if needPlus {
-			m.comboBuilder.WriteByte('+')
-		}
-		m.comboBuilder.WriteString("ctrl")
-		needPlus = true
-	}
-	if m.state.Alt {
-		if needPlus {
-			m.comboBuilder.WriteByte('+')
-		}
-		m.comboBuilder.WriteString("alt")
-		needPlus = true
-	}
-	if m.state.Shift {
-		if needPlus {
+	for i, mod := range m.state.Names() {
+		if i > 0 {
 			m.comboBuilder.WriteByte('+')
 		}
-		m.comboBuilder.WriteString("shift")
-		needPlus = true
+		m.comboBuilder.WriteString(mod)
 	}
 
-	if name := keys.GetKeyName(code); name != "" {
-		if needPlus {
-			m.comboBuilder.WriteByte('+')
-		}
+	if name != "" {
+		m.comboBuilder.WriteByte('+')
 		m.comboBuilder.WriteString(name)
 	}
 
 	return m.comboBuilder.String()
 }
 
+// modifierCodes maps the built-in modifiers to the key code shown in logs
+var modifierCodes = map[string]uint16{
+	"super": evdev.KEY_LEFTMETA,
+	"ctrl":  evdev.KEY_LEFTCTRL,
+	"alt":   evdev.KEY_LEFTALT,
+	"shift": evdev.KEY_LEFTSHIFT,
+}
+
 // GetComboCodes returns the keycodes for the current combo as a string like "125+28"
 func (m *Matcher) GetComboCodes(code uint16) string {
+	m.stateMutex.Lock()
+	defer m.stateMutex.Unlock()
+
 	// Fast path: no modifiers
-	if !m.state.Super && !m.state.Ctrl && !m.state.Alt && !m.state.Shift {
+	if len(m.state) == 0 {
 		return fmt.Sprintf("%d", code)
 	}
 
 	var codes []string
-	if m.state.Super {
-		codes = append(codes, fmt.Sprintf("%d", evdev.KEY_LEFTMETA))
-	}
-	if m.state.Ctrl {
-		codes = append(codes, fmt.Sprintf("%d", evdev.KEY_LEFTCTRL))
-	}
-	if m.state.Alt {
-		codes = append(codes, fmt.Sprintf("%d", evdev.KEY_LEFTALT))
-	}
-	if m.state.Shift {
-		codes = append(codes, fmt.Sprintf("%d", evdev.KEY_LEFTSHIFT))
+	for _, name := range m.state.Names() {
+		modCode, ok := modifierCodes[name]
+		if !ok {
+			modCode = m.customCodes[name]
+		}
+		codes = append(codes, fmt.Sprintf("%d", modCode))
 	}
 	codes = append(codes, fmt.Sprintf("%d", code))
 
-	result := ""
-	for i, c := range codes {
-		if i > 0 {
-			result += "+"
-		}
-		result += c
-	}
-	return result
+	return strings.Join(codes, "+")
 }
 
 func (m *Matcher) updateModifierState(code uint16, pressed bool) {
-	switch code {
-	case evdev.KEY_LEFTMETA, evdev.KEY_RIGHTMETA:
-		m.state.Super = pressed
-	case evdev.KEY_LEFTCTRL, evdev.KEY_RIGHTCTRL:
-		m.state.Ctrl = pressed
-	case evdev.KEY_LEFTALT, evdev.KEY_RIGHTALT:
-		m.state.Alt = pressed
-	case evdev.KEY_LEFTSHIFT, evdev.KEY_RIGHTSHIFT:
-		m.state.Shift = pressed
+	name, ok := m.customNames[code]
+	if !ok {
+		if !IsModifierKey(code) {
+			return
+		}
+		name = keys.GetKeyName(code) // "super", "ctrl", "alt", or "shift"
+	}
+	m.stateMutex.Lock()
+	defer m.stateMutex.Unlock()
+	if pressed {
+		m.state[name] = true
+	} else {
+		delete(m.state, name)
 	}
 }
 
@@ -260,7 +320,9 @@ func (m *Matcher) UpdateModifierState(code uint16, pressed bool) {
 
 // GetCurrentModifiers returns a copy of current modifier state
 func (m *Matcher) GetCurrentModifiers() ModifierState {
-	return m.state
+	m.stateMutex.Lock()
+	defer m.stateMutex.Unlock()
+	return maps.Clone(m.state)
 }
 
 // MarkTapCandidate sets the tap candidate if this modifier has a tap action
@@ -290,17 +352,6 @@ func (m *Matcher) CheckTap(code uint16) (string, bool) {
 	return "", false
 }
 
-// GetNextSwitchCommand returns the next command in the switch cycle
-func (m *Matcher) GetNextSwitchCommand(key string, commands []string) string {
-	m.switchMutex.Lock()
-	defer m.switchMutex.Unlock()
-
-	idx := m.switchState[key]
-	command := commands[idx]
-	m.switchState[key] = (idx + 1) % len(commands)
-	return command
-}
-
 // IsModifierKey returns true if the key code is a modifier key
 func IsModifierKey(code uint16) bool {
 	switch code {
@@ -1,9 +1,15 @@
 package matcher
 
 import (
+	"os"
+	"path/filepath"
+	"slices"
+	"sync"
 	"testing"
+	"time"
 
 	"github.com/deprecatedluar/akeyshually/internal/config"
+	evdev "github.com/holoplot/go-evdev"
 )
 
 func TestNewExcludesAxisShortcutsFromKeyboardMatching(t *testing.T) {
@@ -23,3 +29,137 @@ func TestNewExcludesAxisShortcutsFromKeyboardMatching(t *testing.T) {
 		t.Fatalf("axis shortcut entered keyboard matcher: %v", got)
 	}
 }
+
+func TestCustomModifierCombo(t *testing.T) {
+	m := New(map[string][]*config.ParsedShortcut{})
+	m.SetCustomModifiers(map[string]string{"hyper": "capslock"})
+
+	if !m.IsModifier(evdev.KEY_CAPSLOCK) || !m.IsCustomModifier(evdev.KEY_CAPSLOCK) {
+		t.Fatal("capslock should be a custom modifier")
+	}
+	if m.IsCustomModifier(evdev.KEY_LEFTMETA) {
+		t.Fatal("super is not a custom modifier")
+	}
+
+	m.UpdateModifierState(evdev.KEY_CAPSLOCK, true)
+	m.UpdateModifierState(evdev.KEY_LEFTMETA, true)
+	if got := m.GetCurrentCombo(evdev.KEY_H); got != "super+hyper+h" {
+		t.Errorf("GetCurrentCombo(h) = %q, want super+hyper+h", got)
+	}
+	if !m.GetCurrentModifiers().Has("hyper") {
+		t.Error("hyper should be held")
+	}
+
+	m.UpdateModifierState(evdev.KEY_CAPSLOCK, false)
+	if got := m.GetCurrentCombo(evdev.KEY_H); got != "super+h" {
+		t.Errorf("GetCurrentCombo(h) = %q, want super+h", got)
+	}
+}
+
+func TestModifierStateNames(t *testing.T) {
+	state := ModifierState{"shift": true, "hyper": true, "super": true, "alpha": true}
+	want := []string{"super", "shift", "alpha", "hyper"}
+	if got := state.Names(); !slices.Equal(got, want) {
+		t.Errorf("Names() = %v, want %v", got, want)
+	}
+}
+
+func TestSwitchCycleAndInactivityReset(t *testing.T) {
+	switchShortcut := &config.ParsedShortcut{
+		KeyCombo:    "f1",
+		Behavior:    config.BehaviorSwitch,
+		Commands:    []string{"a", "b", "c"},
+		SwitchReset: 3000,
+	}
+	m := New(map[string][]*config.ParsedShortcut{"f1": {switchShortcut}})
+	key := SwitchKey("f1", switchShortcut)
+
+	for _, want := range []string{"a", "b"} {
+		if got := m.GetNextSwitchCommand(key, switchShortcut); got != want {
+			t.Fatalf("GetNextSwitchCommand() = %q, want %q", got, want)
+		}
+	}
+	if next, count, err := m.SwitchIndex("f1"); err != nil || next != 2 || count != 3 {
+		t.Fatalf("SwitchIndex() = %d, %d, %v; want 2, 3, nil", next, count, err)
+	}
+
+	// Idle past the reset interval
+	pos := m.switchState[key]
+	pos.LastFired = pos.LastFired.Add(-4 * time.Second)
+	m.switchState[key] = pos
+
+	if got := m.GetNextSwitchCommand(key, switchShortcut); got != "a" {
+		t.Fatalf("after inactivity GetNextSwitchCommand() = %q, want %q", got, "a")
+	}
+}
+
+func TestSwitchStatePersists(t *testing.T) {
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	if err := os.MkdirAll(filepath.Join(os.Getenv("XDG_CONFIG_HOME"), "akeyshually"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	shortcuts := map[string][]*config.ParsedShortcut{
+		"f1": {
+			{KeyCombo: "f1", Behavior: config.BehaviorSwitch, Commands: []string{"a", "b", "c"}, AliasGroup: "f1/f2.switch"},
+		},
+	}
+
+	m := New(shortcuts)
+	if err := m.LoadSwitchState(); err != nil {
+		t.Fatalf("LoadSwitchState() error = %v", err)
+	}
+	if err := m.SetSwitchIndex("f1", 2); err != nil {
+		t.Fatalf("SetSwitchIndex() error = %v", err)
+	}
+
+	restarted := New(shortcuts)
+	if err := restarted.LoadSwitchState(); err != nil {
+		t.Fatalf("LoadSwitchState() error = %v", err)
+	}
+	if next, _, _ := restarted.SwitchIndex("f1.switch"); next != 2 {
+		t.Errorf("after restart SwitchIndex() = %d, want 2", next)
+	}
+}
+
+func TestModifierStateConcurrentDevices(t *testing.T) {
+	m := New(map[string][]*config.ParsedShortcut{})
+	m.SetCustomModifiers(map[string]string{"hyper": "capslock"})
+
+	// One goroutine per device listener, all sharing the matcher
+	var wg sync.WaitGroup
+	for _, mod := range []uint16{evdev.KEY_LEFTMETA, evdev.KEY_LEFTCTRL, evdev.KEY_CAPSLOCK} {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < 1000; i++ {
+				m.UpdateModifierState(mod, true)
+				m.GetCurrentCombo(evdev.KEY_A)
+				m.GetComboCodes(evdev.KEY_A)
+				m.GetCurrentModifiers()
+				m.UpdateModifierState(mod, false)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if got := m.GetCurrentCombo(evdev.KEY_A); got != "a" {
+		t.Errorf("GetCurrentCombo() = %q after all releases, want %q", got, "a")
+	}
+}
+
+func TestSwitchComboNormalized(t *testing.T) {
+	shortcuts := map[string][]*config.ParsedShortcut{
+		"super+shift+hyper+k": {
+			{KeyCombo: "super+shift+hyper+k", Behavior: config.BehaviorSwitch, Commands: []string{"a", "b"}},
+		},
+	}
+	m := New(shortcuts)
+	m.SetCustomModifiers(map[string]string{"hyper": "capslock"})
+
+	if err := m.SetSwitchIndex(" Shift+CapsLock+Super+K.switch", 1); err != nil {
+		t.Fatalf("SetSwitchIndex() error = %v", err)
+	}
+	if next, count, err := m.SwitchIndex("hyper+shift+super+k"); err != nil || next != 1 || count != 2 {
+		t.Errorf("SwitchIndex() = %d, %d, %v; want 1, 2, nil", next, count, err)
+	}
+}
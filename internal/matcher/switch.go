@@ -0,0 +1,142 @@
+package matcher
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/deprecatedluar/akeyshually/internal/config"
+	"github.com/deprecatedluar/akeyshually/internal/events"
+)
+
+// SwitchKey returns the state key a .switch shortcut cycles under. Aliases
+// share their group's key so every key in the group advances one cycle.
+func SwitchKey(combo string, shortcut *config.ParsedShortcut) string {
+	groupKey := combo
+	if shortcut.AliasGroup != "" {
+		groupKey = shortcut.AliasGroup
+	}
+	return fmt.Sprintf("%s.switch.%d", groupKey, shortcut.Timing)
+}
+
+// LoadSwitchState restores switch positions from the state file and keeps
+// the file updated from then on.
+func (m *Matcher) LoadSwitchState() error {
+	positions, err := config.ReadSwitchState()
+	if err != nil {
+		return err
+	}
+
+	m.switchMutex.Lock()
+	defer m.switchMutex.Unlock()
+	m.switchState = positions
+	m.persistSwitches = true
+	return nil
+}
+
+// GetNextSwitchCommand returns the next command in the switch cycle
+func (m *Matcher) GetNextSwitchCommand(key string, shortcut *config.ParsedShortcut) string {
+	m.switchMutex.Lock()
+	defer m.switchMutex.Unlock()
+
+	now := time.Now()
+	idx := m.switchIndex(key, shortcut, now)
+	next := (idx + 1) % len(shortcut.Commands)
+	m.switchState[key] = config.SwitchPosition{
+		Next:      next,
+		LastFired: now,
+	}
+	m.saveSwitchState()
+	publishModeChange(shortcut, next)
+	return shortcut.Commands[idx]
+}
+
+// SwitchIndex returns the index the next press of combo's switch fires,
+// and the number of commands in the cycle.
+func (m *Matcher) SwitchIndex(combo string) (int, int, error) {
+	key, shortcut, err := m.findSwitch(combo)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	m.switchMutex.Lock()
+	defer m.switchMutex.Unlock()
+	return m.switchIndex(key, shortcut, time.Now()), len(shortcut.Commands), nil
+}
+
+// SetSwitchIndex makes the next press of combo's switch fire command idx.
+func (m *Matcher) SetSwitchIndex(combo string, idx int) error {
+	key, shortcut, err := m.findSwitch(combo)
+	if err != nil {
+		return err
+	}
+	if idx < 0 || idx >= len(shortcut.Commands) {
+		return fmt.Errorf("index %d out of range (0-%d)", idx, len(shortcut.Commands)-1)
+	}
+
+	m.switchMutex.Lock()
+	defer m.switchMutex.Unlock()
+	m.switchState[key] = config.SwitchPosition{Next: idx}
+	m.saveSwitchState()
+	publishModeChange(shortcut, idx)
+	return nil
+}
+
+// ResetSwitch restarts combo's switch cycle at its first command.
+func (m *Matcher) ResetSwitch(combo string) error {
+	return m.SetSwitchIndex(combo, 0)
+}
+
+// switchIndex returns the next index for key, restarting the cycle when the
+// shortcut's reset interval has passed since it last fired. Caller holds switchMutex.
+func (m *Matcher) switchIndex(key string, shortcut *config.ParsedShortcut, now time.Time) int {
+	pos := m.switchState[key]
+	if shortcut.SwitchReset > 0 && !pos.LastFired.IsZero() &&
+		now.Sub(pos.LastFired) > time.Duration(shortcut.SwitchReset*float64(time.Millisecond)) {
+		return 0
+	}
+	// The config may have shrunk the cycle since the position was saved
+	return pos.Next % len(shortcut.Commands)
+}
+
+// findSwitch resolves a combo as typed by a user ("f1", "Shift+Super+K.switch")
+// to its switch state key and shortcut, normalized like a triggered combo.
+func (m *Matcher) findSwitch(combo string) (string, *config.ParsedShortcut, error) {
+	combo = strings.ToLower(strings.TrimSpace(combo))
+	if i := strings.Index(combo, ".switch"); i >= 0 {
+		combo = combo[:i]
+	}
+	combo = (&config.Config{Modifiers: m.modifiers}).NormalizeCombo(combo)
+
+	for _, s := range m.GetShortcuts(combo) {
+		if s.Behavior == config.BehaviorSwitch {
+			return SwitchKey(combo, s), s, nil
+		}
+	}
+	return "", nil, fmt.Errorf("no .switch shortcut bound to %q", combo)
+}
+
+// publishModeChange reports that shortcut's cycle now fires command next.
+func publishModeChange(shortcut *config.ParsedShortcut, next int) {
+	combo := shortcut.KeyCombo
+	if shortcut.AliasGroup != "" {
+		combo = shortcut.AliasGroup
+	}
+	events.Publish(events.Event{
+		Type:    events.ModeChanged,
+		Combo:   combo,
+		Index:   &next,
+		Command: config.DisplayCommand(shortcut.Commands[next]),
+	})
+}
+
+// saveSwitchState writes switchState to the state file. Caller holds switchMutex.
+func (m *Matcher) saveSwitchState() {
+	if !m.persistSwitches {
+		return
+	}
+	if err := config.WriteSwitchState(m.switchState); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: failed to save switch state: %v\n", err)
+	}
+}
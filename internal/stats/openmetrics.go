@@ -0,0 +1,132 @@
+package stats
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	openMetricsType = "application/openmetrics-text; version=1.0.0; charset=utf-8"
+	metricsPath     = "/metrics"
+	unixPrefix      = "unix:"
+	metricsSockPerm = 0600
+)
+
+// WriteOpenMetrics writes st in the OpenMetrics text format.
+func WriteOpenMetrics(w io.Writer, st Stats) error {
+	bw := bufio.NewWriter(w)
+
+	family(bw, "akeyshually_shortcut_fires", "counter", "Shortcut fires.")
+	for _, s := range st.Shortcuts {
+		fmt.Fprintf(bw, "akeyshually_shortcut_fires_total{%s} %d\n", shortcutLabels(s), s.Fires)
+	}
+
+	family(bw, "akeyshually_fire_latency_seconds", "histogram", "Time from key press to shortcut fire.")
+	for _, s := range st.Shortcuts {
+		labels := shortcutLabels(s)
+		var cumulative uint64
+		for i, bound := range LatencyBuckets {
+			cumulative += s.Buckets[i]
+			fmt.Fprintf(bw, "akeyshually_fire_latency_seconds_bucket{%s,le=\"%s\"} %d\n", labels, seconds(bound), cumulative)
+		}
+		fmt.Fprintf(bw, "akeyshually_fire_latency_seconds_bucket{%s,le=\"+Inf\"} %d\n", labels, s.Fires)
+		fmt.Fprintf(bw, "akeyshually_fire_latency_seconds_count{%s} %d\n", labels, s.Fires)
+		fmt.Fprintf(bw, "akeyshually_fire_latency_seconds_sum{%s} %s\n", labels, strconv.FormatFloat(s.LatencySumMs/1000, 'g', -1, 64))
+	}
+
+	counts(bw, "akeyshually_commands_failed", "combo", "Commands that exited non-zero or timed out.", st.CommandsFailed)
+	counts(bw, "akeyshually_device_events", "device", "Key and axis events read from a device.", st.DeviceEvents)
+	counts(bw, "akeyshually_chatter_events", "device", "Events the chatter filter dropped on a device.", st.ChatterEvents)
+	counts(bw, "akeyshually_loop_iterations", "combo", "Runs of .repeat loops.", st.LoopIterations)
+
+	fmt.Fprintln(bw, "# EOF")
+	return bw.Flush()
+}
+
+func family(w io.Writer, name, kind, help string) {
+	fmt.Fprintf(w, "# TYPE %s %s\n# HELP %s %s\n", name, kind, name, help)
+}
+
+func counts(w io.Writer, name, label, help string, counts []Count) {
+	family(w, name, "counter", help)
+	for _, c := range counts {
+		fmt.Fprintf(w, "%s_total{%s=\"%s\"} %d\n", name, label, escapeLabel(c.Name), c.Count)
+	}
+}
+
+func shortcutLabels(s Shortcut) string {
+	return fmt.Sprintf("combo=\"%s\",behavior=\"%s\"", escapeLabel(s.Combo), escapeLabel(s.Behavior))
+}
+
+func seconds(d time.Duration) string {
+	return strconv.FormatFloat(d.Seconds(), 'g', -1, 64)
+}
+
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+func escapeLabel(s string) string {
+	return labelEscaper.Replace(s)
+}
+
+// ServeMetrics serves the counters at /metrics on addr until ctx is
+// cancelled. addr is "unix:<path>" for a socket only the user can reach, or
+// a loopback host:port.
+func ServeMetrics(ctx context.Context, addr string) error {
+	listener, err := listenMetrics(addr)
+	if err != nil {
+		return err
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc(metricsPath, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", openMetricsType)
+		WriteOpenMetrics(w, Snapshot())
+	})
+	server := &http.Server{Handler: mux, ReadHeaderTimeout: 5 * time.Second}
+
+	go func() {
+		<-ctx.Done()
+		server.Close()
+	}()
+	if err := server.Serve(listener); !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
+}
+
+func listenMetrics(addr string) (net.Listener, error) {
+	if path, ok := strings.CutPrefix(addr, unixPrefix); ok {
+		os.Remove(path) // stale socket left by an unclean previous exit
+		listener, err := net.Listen("unix", path)
+		if err != nil {
+			return nil, fmt.Errorf("metrics: listen on %s: %w", path, err)
+		}
+		if err := os.Chmod(path, metricsSockPerm); err != nil {
+			listener.Close()
+			return nil, fmt.Errorf("metrics: chmod %s: %w", path, err)
+		}
+		return listener, nil
+	}
+
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return nil, fmt.Errorf("metrics: %w", err)
+	}
+	if ip := net.ParseIP(host); host != "localhost" && (ip == nil || !ip.IsLoopback()) {
+		return nil, fmt.Errorf("metrics: %s is not a loopback address", host)
+	}
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, fmt.Errorf("metrics: %w", err)
+	}
+	return listener, nil
+}
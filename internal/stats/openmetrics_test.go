@@ -0,0 +1,84 @@
+package stats
+
+import (
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestWriteOpenMetrics(t *testing.T) {
+	st := Stats{
+		Shortcuts: []Shortcut{{
+			Combo: "super+t", Behavior: "normal", Fires: 2,
+			Buckets:      []uint64{1, 0, 1, 0, 0, 0, 0, 0, 0, 0},
+			LatencySumMs: 25,
+		}},
+		DeviceEvents:  []Count{{`My "Board"`, 9}},
+		ChatterEvents: []Count{{`My "Board"`, 2}},
+	}
+	var b strings.Builder
+	if err := WriteOpenMetrics(&b, st); err != nil {
+		t.Fatal(err)
+	}
+	out := b.String()
+
+	for _, want := range []string{
+		"# TYPE akeyshually_shortcut_fires counter\n",
+		`akeyshually_shortcut_fires_total{combo="super+t",behavior="normal"} 2` + "\n",
+		`akeyshually_fire_latency_seconds_bucket{combo="super+t",behavior="normal",le="0.005"} 1` + "\n",
+		`akeyshually_fire_latency_seconds_bucket{combo="super+t",behavior="normal",le="0.025"} 2` + "\n",
+		`akeyshually_fire_latency_seconds_bucket{combo="super+t",behavior="normal",le="+Inf"} 2` + "\n",
+		`akeyshually_fire_latency_seconds_sum{combo="super+t",behavior="normal"} 0.025` + "\n",
+		`akeyshually_device_events_total{device="My \"Board\""} 9` + "\n",
+		`akeyshually_chatter_events_total{device="My \"Board\""} 2` + "\n",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("output lacks %q:\n%s", want, out)
+		}
+	}
+	if !strings.HasSuffix(out, "# EOF\n") {
+		t.Error("output does not end with # EOF")
+	}
+}
+
+func TestServeMetricsUnixSocket(t *testing.T) {
+	sock := filepath.Join(t.TempDir(), "metrics.sock")
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go ServeMetrics(ctx, unixPrefix+sock)
+
+	httpClient := &http.Client{Transport: &http.Transport{
+		DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+			return (&net.Dialer{}).DialContext(ctx, "unix", sock)
+		},
+	}}
+	var resp *http.Response
+	var err error
+	for range 100 {
+		if resp, err = httpClient.Get("http://localhost/metrics"); err == nil {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if err != nil {
+		t.Fatalf("GET /metrics: %v", err)
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(resp.Body)
+	if resp.Header.Get("Content-Type") != openMetricsType || !strings.HasSuffix(string(body), "# EOF\n") {
+		t.Fatalf("got %s %q", resp.Header.Get("Content-Type"), body)
+	}
+}
+
+func TestServeMetricsRefusesRemoteAddress(t *testing.T) {
+	for _, addr := range []string{"0.0.0.0:9464", "192.168.1.5:9464", "example.com:9464", "9464"} {
+		if err := ServeMetrics(context.Background(), addr); err == nil {
+			t.Errorf("ServeMetrics(%q) succeeded", addr)
+		}
+	}
+}
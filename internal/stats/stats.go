@@ -0,0 +1,289 @@
+// Package stats keeps the daemon's usage counters: how often each shortcut
+// fires and how long its ladder took to decide, failed commands, events read
+// and chatter events dropped per device and repeat loop iterations. They are kept across reloads in the
+// state directory and read with `akeyshually stats` or over OpenMetrics.
+package stats
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	stateFile     = "stats.json" // under the state directory
+	stateDirPerm  = 0755
+	stateFilePerm = 0644
+)
+
+// LatencyBuckets are the upper bounds of the press-to-fire latency histogram.
+var LatencyBuckets = []time.Duration{
+	5 * time.Millisecond,
+	10 * time.Millisecond,
+	25 * time.Millisecond,
+	50 * time.Millisecond,
+	100 * time.Millisecond,
+	250 * time.Millisecond,
+	500 * time.Millisecond,
+	time.Second,
+	2500 * time.Millisecond,
+}
+
+// Stats is a snapshot of every counter, sorted by name.
+type Stats struct {
+	Since          time.Time  `json:"since"` // when counting started (last reset)
+	Shortcuts      []Shortcut `json:"shortcuts"`
+	CommandsFailed []Count    `json:"commands_failed"` // by shortcut
+	DeviceEvents   []Count    `json:"device_events"`   // key and axis events, by device name
+	ChatterEvents  []Count    `json:"chatter_events"`  // events the chatter filter dropped, by device name
+	LoopIterations []Count    `json:"loop_iterations"` // .repeat loop runs, by shortcut
+}
+
+// Shortcut counts the fires of one shortcut and the time from its key press
+// to the fire, which includes waiting out tap windows and hold thresholds.
+type Shortcut struct {
+	Combo    string `json:"combo"`
+	Behavior string `json:"behavior"`
+	Fires    uint64 `json:"fires"`
+	// Buckets[i] counts fires within LatencyBuckets[i] (not cumulative);
+	// the extra last bucket counts slower ones.
+	Buckets      []uint64  `json:"latency_buckets"`
+	LatencySumMs float64   `json:"latency_sum_ms"`
+	LatencyMaxMs float64   `json:"latency_max_ms"`
+	LastFired    time.Time `json:"last_fired"`
+}
+
+// LatencyAvgMs is the mean press-to-fire latency.
+func (s Shortcut) LatencyAvgMs() float64 {
+	if s.Fires == 0 {
+		return 0
+	}
+	return s.LatencySumMs / float64(s.Fires)
+}
+
+// Count is a counter with the name it is kept under.
+type Count struct {
+	Name  string `json:"name"`
+	Count uint64 `json:"count"`
+}
+
+type shortcutKey struct {
+	combo, behavior string
+}
+
+var counters = newRegistry()
+
+type registry struct {
+	mu        sync.Mutex
+	since     time.Time
+	shortcuts map[shortcutKey]*Shortcut
+	failed    map[string]uint64
+	devices   map[string]*atomic.Uint64 // handed to readers, which count without the lock
+	chatter   map[string]*atomic.Uint64 // handed to chatter filters, likewise
+	loops     map[string]uint64
+}
+
+func newRegistry() *registry {
+	return &registry{
+		since:     time.Now(),
+		shortcuts: make(map[shortcutKey]*Shortcut),
+		failed:    make(map[string]uint64),
+		devices:   make(map[string]*atomic.Uint64),
+		chatter:   make(map[string]*atomic.Uint64),
+		loops:     make(map[string]uint64),
+	}
+}
+
+// Fired counts a fire of the combo's shortcut with the given behavior,
+// latency after its key went down.
+func Fired(combo, behavior string, latency time.Duration) {
+	counters.mu.Lock()
+	defer counters.mu.Unlock()
+
+	key := shortcutKey{combo, behavior}
+	s := counters.shortcuts[key]
+	if s == nil {
+		s = &Shortcut{Combo: combo, Behavior: behavior, Buckets: make([]uint64, len(LatencyBuckets)+1)}
+		counters.shortcuts[key] = s
+	}
+	bucket := sort.Search(len(LatencyBuckets), func(i int) bool { return latency <= LatencyBuckets[i] })
+	ms := float64(latency) / float64(time.Millisecond)
+
+	s.Fires++
+	s.Buckets[bucket]++
+	s.LatencySumMs += ms
+	s.LatencyMaxMs = max(s.LatencyMaxMs, ms)
+	s.LastFired = time.Now()
+}
+
+// CommandFailed counts a command of combo's shortcut that exited non-zero or
+// timed out.
+func CommandFailed(combo string) {
+	counters.mu.Lock()
+	counters.failed[combo]++
+	counters.mu.Unlock()
+}
+
+// LoopIteration counts one run of combo's .repeat loop.
+func LoopIteration(combo string) {
+	counters.mu.Lock()
+	counters.loops[combo]++
+	counters.mu.Unlock()
+}
+
+// DeviceEvents returns the counter of events read from the named device,
+// for the listener to add to on every event.
+func DeviceEvents(device string) *atomic.Uint64 {
+	counters.mu.Lock()
+	defer counters.mu.Unlock()
+	return deviceCounter(counters.devices, device)
+}
+
+// ChatterEvents returns the counter of events the chatter filter dropped on
+// the named device (two per release+press pair).
+func ChatterEvents(device string) *atomic.Uint64 {
+	counters.mu.Lock()
+	defer counters.mu.Unlock()
+	return deviceCounter(counters.chatter, device)
+}
+
+// deviceCounter returns the device's counter in m, adding it if needed.
+// Caller holds mu.
+func deviceCounter(m map[string]*atomic.Uint64, device string) *atomic.Uint64 {
+	c := m[device]
+	if c == nil {
+		c = new(atomic.Uint64)
+		m[device] = c
+	}
+	return c
+}
+
+// Snapshot returns the current counters.
+func Snapshot() Stats {
+	counters.mu.Lock()
+	defer counters.mu.Unlock()
+
+	st := Stats{
+		Since:          counters.since,
+		Shortcuts:      []Shortcut{},
+		CommandsFailed: sortedCounts(counters.failed),
+		LoopIterations: sortedCounts(counters.loops),
+	}
+	for _, s := range counters.shortcuts {
+		c := *s
+		c.Buckets = append([]uint64(nil), s.Buckets...)
+		st.Shortcuts = append(st.Shortcuts, c)
+	}
+	sort.Slice(st.Shortcuts, func(i, j int) bool {
+		a, b := st.Shortcuts[i], st.Shortcuts[j]
+		if a.Combo != b.Combo {
+			return a.Combo < b.Combo
+		}
+		return a.Behavior < b.Behavior
+	})
+
+	st.DeviceEvents = loadCounts(counters.devices)
+	st.ChatterEvents = loadCounts(counters.chatter)
+	return st
+}
+
+func loadCounts(m map[string]*atomic.Uint64) []Count {
+	values := make(map[string]uint64, len(m))
+	for name, c := range m {
+		values[name] = c.Load()
+	}
+	return sortedCounts(values)
+}
+
+func sortedCounts(m map[string]uint64) []Count {
+	counts := make([]Count, 0, len(m))
+	for name, n := range m {
+		counts = append(counts, Count{Name: name, Count: n})
+	}
+	sort.Slice(counts, func(i, j int) bool { return counts[i].Name < counts[j].Name })
+	return counts
+}
+
+// Reset zeroes every counter and restarts Since.
+func Reset() {
+	counters.mu.Lock()
+	defer counters.mu.Unlock()
+
+	counters.since = time.Now()
+	clear(counters.shortcuts)
+	clear(counters.failed)
+	clear(counters.loops)
+	for _, c := range counters.devices {
+		c.Store(0) // listeners keep their counters
+	}
+	for _, c := range counters.chatter {
+		c.Store(0)
+	}
+}
+
+// StatePath returns the file the counters are kept in across restarts.
+func StatePath(stateDir string) string {
+	return filepath.Join(stateDir, stateFile)
+}
+
+// Load restores the counters saved at path, before counting starts. A
+// missing file is not an error.
+func Load(path string) error {
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	var saved Stats
+	if err := json.Unmarshal(data, &saved); err != nil {
+		return err
+	}
+
+	counters.mu.Lock()
+	defer counters.mu.Unlock()
+
+	if !saved.Since.IsZero() {
+		counters.since = saved.Since
+	}
+	for _, s := range saved.Shortcuts {
+		if len(s.Buckets) != len(LatencyBuckets)+1 {
+			s.Buckets = make([]uint64, len(LatencyBuckets)+1) // saved with other buckets
+		}
+		counters.shortcuts[shortcutKey{s.Combo, s.Behavior}] = &s
+	}
+	for _, c := range saved.CommandsFailed {
+		counters.failed[c.Name] = c.Count
+	}
+	for _, c := range saved.LoopIterations {
+		counters.loops[c.Name] = c.Count
+	}
+	for _, c := range saved.DeviceEvents {
+		deviceCounter(counters.devices, c.Name).Store(c.Count)
+	}
+	for _, c := range saved.ChatterEvents {
+		deviceCounter(counters.chatter, c.Name).Store(c.Count)
+	}
+	return nil
+}
+
+// Save writes the current counters to path.
+func Save(path string) error {
+	data, err := json.MarshalIndent(Snapshot(), "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), stateDirPerm); err != nil {
+		return err
+	}
+	tmpPath := path + ".tmp"
+	if err := os.WriteFile(tmpPath, data, stateFilePerm); err != nil {
+		return err
+	}
+	return os.Rename(tmpPath, path)
+}
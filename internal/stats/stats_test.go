@@ -0,0 +1,88 @@
+package stats
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestFiredLatencyBuckets(t *testing.T) {
+	Reset()
+	Fired("f1", "normal", 3*time.Millisecond)
+	Fired("f1", "normal", 40*time.Millisecond)
+	Fired("f1", "normal", 5*time.Second)
+	Fired("f1", "doubletap", 250*time.Millisecond)
+
+	st := Snapshot()
+	if len(st.Shortcuts) != 2 {
+		t.Fatalf("got %d shortcuts, want 2", len(st.Shortcuts))
+	}
+	tap, normal := st.Shortcuts[0], st.Shortcuts[1]
+	if tap.Behavior != "doubletap" || tap.Fires != 1 || tap.Buckets[5] != 1 {
+		t.Errorf("doubletap = %+v, want one fire in the 250ms bucket", tap)
+	}
+	want := []uint64{1, 0, 0, 1, 0, 0, 0, 0, 0, 1}
+	if normal.Fires != 3 || !reflect.DeepEqual(normal.Buckets, want) {
+		t.Errorf("normal fires %d buckets %v, want 3 and %v", normal.Fires, normal.Buckets, want)
+	}
+	if normal.LatencyMaxMs != 5000 || normal.LatencyAvgMs() != 1681 {
+		t.Errorf("normal latency max %v avg %v, want 5000 and 1681", normal.LatencyMaxMs, normal.LatencyAvgMs())
+	}
+}
+
+func TestCountersAndReset(t *testing.T) {
+	Reset()
+	CommandFailed("super+r")
+	CommandFailed("super+r")
+	LoopIteration("f9")
+	keyboard := DeviceEvents("kbd")
+	keyboard.Add(5)
+	chatter := ChatterEvents("kbd")
+	chatter.Add(2)
+
+	st := Snapshot()
+	if !reflect.DeepEqual(st.CommandsFailed, []Count{{"super+r", 2}}) ||
+		!reflect.DeepEqual(st.LoopIterations, []Count{{"f9", 1}}) ||
+		!reflect.DeepEqual(st.DeviceEvents, []Count{{"kbd", 5}}) ||
+		!reflect.DeepEqual(st.ChatterEvents, []Count{{"kbd", 2}}) {
+		t.Fatalf("snapshot = %+v", st)
+	}
+
+	Reset()
+	keyboard.Add(1) // the listener's counter keeps working
+	st = Snapshot()
+	if len(st.Shortcuts)+len(st.CommandsFailed)+len(st.LoopIterations) != 0 ||
+		!reflect.DeepEqual(st.DeviceEvents, []Count{{"kbd", 1}}) ||
+		!reflect.DeepEqual(st.ChatterEvents, []Count{{"kbd", 0}}) {
+		t.Fatalf("snapshot after reset = %+v", st)
+	}
+}
+
+func TestSaveLoad(t *testing.T) {
+	Reset()
+	Fired("ctrl+t", "normal", 20*time.Millisecond)
+	CommandFailed("ctrl+t")
+	DeviceEvents("kbd").Add(7)
+	ChatterEvents("kbd").Add(4)
+	saved := Snapshot()
+
+	path := StatePath(t.TempDir())
+	if err := Save(path); err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+	Reset()
+	if err := Load(path); err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+
+	loaded := Snapshot()
+	if !loaded.Since.Equal(saved.Since) || !reflect.DeepEqual(loaded.Shortcuts[0].Buckets, saved.Shortcuts[0].Buckets) ||
+		!reflect.DeepEqual(loaded.CommandsFailed, saved.CommandsFailed) || !reflect.DeepEqual(loaded.DeviceEvents, saved.DeviceEvents) ||
+		!reflect.DeepEqual(loaded.ChatterEvents, saved.ChatterEvents) {
+		t.Fatalf("loaded %+v, saved %+v", loaded, saved)
+	}
+	if err := Load(filepath.Join(t.TempDir(), "missing.json")); err != nil {
+		t.Fatalf("Load of a missing file: %v", err)
+	}
+}
@@ -2,7 +2,9 @@ package timers
 
 import (
 	"context"
+	"slices"
 	"sync"
+	"time"
 
 	"github.com/deprecatedluar/akeyshually/internal/config"
 )
@@ -22,6 +24,10 @@ func BuildCandidates(shortcuts []*config.ParsedShortcut) []Candidate {
 		if s.Behavior == config.BehaviorSwitch {
 			continue
 		}
+		// Exclude tapmod — resolved by the input-stage Translator
+		if s.Behavior == config.BehaviorTapMod {
+			continue
+		}
 		out = append(out, Candidate{Shortcut: s})
 	}
 	return out
@@ -37,22 +43,37 @@ func NewEscapeCandidate() Candidate {
 	}
 }
 
+// NewChordCandidate creates a pseudo-candidate that holds the ladder open while
+// the pressed keys may still grow into a chord (e.g. "j" pending "j+k").
+func NewChordCandidate() Candidate {
+	return Candidate{
+		Shortcut: &config.ParsedShortcut{
+			Behavior: config.BehaviorChordPending,
+		},
+	}
+}
+
 // ComboState drives the chain goroutine for a single key press.
 // The event handler signals it via channels; the goroutine owns resolution.
 type ComboState struct {
 	sync.Mutex
-	cancel    context.CancelFunc
-	ReleaseCh chan struct{} // key released
-	PressCh   chan struct{} // second press arrived (doubletap window)
-	EscapeCh  chan string   // foreign key pressed (escape hatch to combo)
+	cancel       context.CancelFunc
+	ReleaseCh    chan struct{} // key released
+	PressCh      chan struct{} // second press arrived (doubletap window)
+	EscapeCh     chan string   // foreign key pressed (escape hatch to combo)
+	ChordBreakCh chan struct{} // key pressed that cannot extend the pending chord
+	Chord        *ChordBuffer  // keys withheld for a chord, nil if combo is not part of one
+	Pressed      time.Time     // when the key went down
 }
 
 func NewComboState(cancel context.CancelFunc) *ComboState {
 	return &ComboState{
-		cancel:    cancel,
-		ReleaseCh: make(chan struct{}, 1),
-		PressCh:   make(chan struct{}, 1),
-		EscapeCh:  make(chan string, 1),
+		cancel:       cancel,
+		ReleaseCh:    make(chan struct{}, 1),
+		PressCh:      make(chan struct{}, 1),
+		EscapeCh:     make(chan string, 1),
+		ChordBreakCh: make(chan struct{}, 1),
+		Pressed:      time.Now(),
 	}
 }
 
@@ -76,10 +97,106 @@ func (s *ComboState) SignalPress() {
 	}
 }
 
+func (s *ComboState) SignalChordBreak() {
+	select {
+	case s.ChordBreakCh <- struct{}{}:
+	default:
+	}
+}
+
+// ChordKey is a key withheld from the system while a chord forms.
+type ChordKey struct {
+	Code     uint16
+	Released bool
+}
+
+// ChordBuffer holds the keys of a chord that is still forming. It belongs to
+// one ladder at a time: Handoff passes it to the ladder of a larger chord, and
+// Close takes the keys exactly once, ending the chord.
+type ChordBuffer struct {
+	mu    sync.Mutex
+	owner *ComboState // nil once the chord is closed
+	keys  []ChordKey
+}
+
+func NewChordBuffer(owner *ComboState, code uint16) *ChordBuffer {
+	return &ChordBuffer{
+		owner: owner,
+		keys:  []ChordKey{{Code: code}},
+	}
+}
+
+// Pending reports whether the chord is still open to more keys.
+func (b *ChordBuffer) Pending() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.owner != nil
+}
+
+// Handoff adds code to the chord and moves ownership from one ladder to the
+// next. Returns false if from no longer owns the chord.
+func (b *ChordBuffer) Handoff(from, to *ComboState, code uint16) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if from == nil || b.owner != from {
+		return false
+	}
+	b.keys = append(b.keys, ChordKey{Code: code})
+	b.owner = to
+	return true
+}
+
+// Release marks code as released. member reports whether code is part of the
+// chord; withheld reports whether the chord was still open, in which case the
+// release belongs to the chord too.
+func (b *ChordBuffer) Release(code uint16) (member, withheld bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for i := range b.keys {
+		if b.keys[i].Code != code {
+			continue
+		}
+		if b.owner == nil {
+			return true, false
+		}
+		b.keys[i].Released = true
+		return true, true
+	}
+	return false, false
+}
+
+// Withholds reports whether code is a key of the chord that is still forming.
+func (b *ChordBuffer) Withholds(code uint16) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.owner == nil {
+		return false
+	}
+	for _, key := range b.keys {
+		if key.Code == code {
+			return true
+		}
+	}
+	return false
+}
+
+// Close ends the chord and returns its keys in press order. Returns false if
+// owner no longer owns the chord (handed off, or already closed).
+func (b *ChordBuffer) Close(owner *ComboState) ([]ChordKey, bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if owner == nil || b.owner != owner {
+		return nil, false
+	}
+	b.owner = nil
+	return slices.Clone(b.keys), true
+}
+
 // StateMap holds one active ComboState per combo.
 type StateMap struct {
 	mu     sync.Mutex
 	states map[string]*ComboState
+	chord  string // combo of the latest chord ladder, cleared when it is deleted
 }
 
 func NewStateMap() *StateMap {
@@ -101,9 +218,26 @@ func (sm *StateMap) Set(combo string, s *ComboState) {
 func (sm *StateMap) Delete(combo string) {
 	sm.mu.Lock()
 	delete(sm.states, combo)
+	if sm.chord == combo {
+		sm.chord = ""
+	}
+	sm.mu.Unlock()
+}
+
+// SetChord records combo as the ladder that owns the keys of a chord.
+func (sm *StateMap) SetChord(combo string) {
+	sm.mu.Lock()
+	sm.chord = combo
 	sm.mu.Unlock()
 }
 
+// Chord returns the combo recorded by SetChord, or "" if its ladder is gone.
+func (sm *StateMap) Chord() string {
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+	return sm.chord
+}
+
 // CancelCombosWithModifier cancels all active combos that include the given modifier
 func (sm *StateMap) CancelCombosWithModifier(modifierName string) []string {
 	sm.mu.Lock()
@@ -90,3 +90,50 @@ func TestStateMapSetGetDelete(t *testing.T) {
 		t.Error("Get after Delete should return nil")
 	}
 }
+
+func TestChordBufferHandoffAndClose(t *testing.T) {
+	_, cancel := context.WithCancel(context.Background())
+	first := NewComboState(cancel)
+	second := NewComboState(cancel)
+	b := NewChordBuffer(first, 36)
+
+	if !b.Handoff(first, second, 37) {
+		t.Fatal("Handoff from owner failed")
+	}
+	if _, ok := b.Close(first); ok {
+		t.Error("Close by previous owner should fail after handoff")
+	}
+	if member, withheld := b.Release(36); !member || !withheld {
+		t.Errorf("Release(36) = %v, %v; want true, true while pending", member, withheld)
+	}
+
+	keys, ok := b.Close(second)
+	if !ok {
+		t.Fatal("Close by owner failed")
+	}
+	if len(keys) != 2 || keys[0].Code != 36 || !keys[0].Released || keys[1].Code != 37 || keys[1].Released {
+		t.Errorf("Close returned %+v, want [36 released, 37 held]", keys)
+	}
+	if _, ok := b.Close(second); ok {
+		t.Error("second Close should fail")
+	}
+	if member, withheld := b.Release(37); !member || withheld {
+		t.Errorf("Release(37) after close = %v, %v; want true, false", member, withheld)
+	}
+}
+
+func TestStateMapDeleteClearsChord(t *testing.T) {
+	sm := NewStateMap()
+	_, cancel := context.WithCancel(context.Background())
+	sm.Set("j", NewComboState(cancel))
+	sm.SetChord("j")
+
+	sm.Delete("k")
+	if got := sm.Chord(); got != "j" {
+		t.Errorf("Chord() = %q after unrelated Delete, want j", got)
+	}
+	sm.Delete("j")
+	if got := sm.Chord(); got != "" {
+		t.Errorf("Chord() = %q after Delete, want empty", got)
+	}
+}